@@ -0,0 +1,184 @@
+package liveflux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownAction is returned by DispatchAction when no method is routed to
+// the requested action. The handlers answer it with 400 "unknown action".
+var ErrUnknownAction = errors.New("liveflux: unknown action")
+
+// ActionAllowList lets a component route actions to exported methods that do
+// not follow the Action<Name> naming convention. Only the listed method
+// names are callable; the action name is the kebab-cased method name
+// (e.g. "AddItem" handles "add-item").
+type ActionAllowList interface {
+	AllowedActions() []string
+}
+
+// ActionMethod maps an action name to the component method handling it.
+type ActionMethod struct {
+	Action string
+	Method string
+}
+
+var (
+	contextType   = reflect.TypeOf((*context.Context)(nil)).Elem()
+	urlValuesType = reflect.TypeOf(url.Values{})
+	errorType     = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// actionRoutes caches the routes of a component type.
+var actionRoutes sync.Map // map[reflect.Type]map[string]reflect.Method
+
+// ParseActionMethods scans a component for action methods, following the
+// same approach as ParseOnAttributes.
+// Convention: ActionAddItem(ctx context.Context, data url.Values) error
+// handles "add-item"; the data parameter may be omitted or replaced by a
+// struct decoded with DecodeForm. Methods listed by ActionAllowList are
+// routed by their own name.
+func ParseActionMethods(component ComponentInterface) []ActionMethod {
+	var allowed map[string]bool
+	if al, ok := component.(ActionAllowList); ok {
+		allowed = map[string]bool{}
+		for _, name := range al.AllowedActions() {
+			allowed[name] = true
+		}
+	}
+
+	methods := []ActionMethod{}
+	t := reflect.TypeOf(component)
+	for i := 0; i < t.NumMethod(); i++ {
+		method := t.Method(i)
+		if name, ok := strings.CutPrefix(method.Name, "Action"); ok && name != "" && name[0] >= 'A' && name[0] <= 'Z' {
+			methods = append(methods, ActionMethod{Action: toKebabCase(name), Method: method.Name})
+			continue
+		}
+		if allowed[method.Name] {
+			methods = append(methods, ActionMethod{Action: toKebabCase(method.Name), Method: method.Name})
+		}
+	}
+	return methods
+}
+
+// DispatchAction calls the component method routed to action (see
+// ParseActionMethods). Call it from Handle to replace a string switch:
+//
+//	func (c *TodoList) Handle(ctx context.Context, action string, data url.Values) error {
+//		return liveflux.DispatchAction(ctx, c, action, data)
+//	}
+//
+// Action names are matched in kebab-case, so "add-item", "add_item" and
+// "addItem" all reach ActionAddItem. Returns an error wrapping
+// ErrUnknownAction when nothing is routed to action.
+//
+// A method may take a struct (or struct pointer) instead of url.Values:
+//
+//	type SaveUserInput struct {
+//		Email string `flux:"email"`
+//		Age   int    `flux:"age"`
+//	}
+//
+//	func (c *UserForm) ActionSaveUser(ctx context.Context, in SaveUserInput) error
+//
+// The input is decoded with DecodeForm; values that fail to convert are
+// returned as ValidationErrors without calling the method. Top-level
+// *UploadedFile and []*UploadedFile fields receive the posted files.
+func DispatchAction(ctx context.Context, component ComponentInterface, action string, data url.Values) error {
+	if component == nil {
+		return errors.New("liveflux: DispatchAction requires non-nil component")
+	}
+	method, ok := actionRoutesOf(component)[normalizeAction(action)]
+	if !ok {
+		return fmt.Errorf("%w %q for component '%s'", ErrUnknownAction, action, component.GetKind())
+	}
+
+	args, err := actionArgs(method, ctx, data)
+	if err != nil {
+		return err
+	}
+	results := reflect.ValueOf(component).Method(method.Index).Call(args)
+	if len(results) == 1 && !results[0].IsNil() {
+		return results[0].Interface().(error)
+	}
+	return nil
+}
+
+// actionRoutesOf returns the cached routes for the component's type.
+func actionRoutesOf(component ComponentInterface) map[string]reflect.Method {
+	t := reflect.TypeOf(component)
+	// Allow-lists may depend on instance state, so only convention routes are cached
+	_, dynamic := component.(ActionAllowList)
+	if !dynamic {
+		if cached, ok := actionRoutes.Load(t); ok {
+			return cached.(map[string]reflect.Method)
+		}
+	}
+
+	routes := map[string]reflect.Method{}
+	for _, am := range ParseActionMethods(component) {
+		method, _ := t.MethodByName(am.Method)
+		routes[am.Action] = method
+	}
+	if !dynamic {
+		actionRoutes.Store(t, routes)
+	}
+	return routes
+}
+
+// actionArgs builds the arguments for a routed method. Supported signatures:
+// func(ctx), func(ctx, url.Values) and func(ctx, T) where T is a struct or
+// struct pointer decoded from data; the error result is optional.
+func actionArgs(method reflect.Method, ctx context.Context, data url.Values) ([]reflect.Value, error) {
+	mt := method.Type // includes the receiver as the first input
+	if mt.NumOut() > 1 || (mt.NumOut() == 1 && mt.Out(0) != errorType) {
+		return nil, fmt.Errorf("liveflux: action method %s must return nothing or error", method.Name)
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if data == nil {
+		data = url.Values{}
+	}
+
+	switch {
+	case mt.NumIn() == 2 && mt.In(1) == contextType:
+		return []reflect.Value{reflect.ValueOf(ctx)}, nil
+	case mt.NumIn() == 3 && mt.In(1) == contextType && mt.In(2) == urlValuesType:
+		return []reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(data)}, nil
+	case mt.NumIn() == 3 && mt.In(1) == contextType && isInputType(mt.In(2)):
+		in := mt.In(2)
+		dst := reflect.New(in)
+		if in.Kind() == reflect.Ptr {
+			dst = reflect.New(in.Elem())
+		}
+		if err := DecodeForm(data, dst.Interface()); err != nil {
+			return nil, err
+		}
+		bindUploads(ctx, dst)
+		if in.Kind() != reflect.Ptr {
+			dst = dst.Elem()
+		}
+		return []reflect.Value{reflect.ValueOf(ctx), dst}, nil
+	}
+	return nil, fmt.Errorf("liveflux: action method %s has an unsupported signature %s", method.Name, mt)
+}
+
+// isInputType reports whether t can be decoded by DecodeForm.
+func isInputType(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct
+}
+
+// normalizeAction maps "add_item", "addItem" and "AddItem" to "add-item".
+func normalizeAction(action string) string {
+	return toKebabCase(strings.ReplaceAll(strings.TrimSpace(action), "_", "-"))
+}
@@ -0,0 +1,192 @@
+package liveflux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dracory/hb"
+)
+
+// routedComp handles actions through DispatchAction.
+type routedComp struct {
+	Base
+	Items []string
+	Log   []string
+}
+
+func (c *routedComp) GetKind() string                                { return "test.routed-comp" }
+func (c *routedComp) Mount(context.Context, map[string]string) error { return nil }
+func (c *routedComp) Handle(ctx context.Context, action string, data url.Values) error {
+	return DispatchAction(ctx, c, action, data)
+}
+func (c *routedComp) Render(context.Context) hb.TagInterface {
+	return c.Root(hb.Text("items=" + strings.Join(c.Items, ",")))
+}
+
+func (c *routedComp) ActionAddItem(_ context.Context, data url.Values) error {
+	c.Items = append(c.Items, data.Get("item"))
+	return nil
+}
+
+func (c *routedComp) ActionClear(context.Context) {
+	c.Items = nil
+}
+
+func (c *routedComp) ActionFail(context.Context) error {
+	return errors.New("boom")
+}
+
+func (c *routedComp) ActionBadSignature(int) error { return nil }
+
+type addManyInput struct {
+	Items []string `flux:"items"`
+	Times int      `flux:"times"`
+}
+
+func (c *routedComp) ActionAddMany(_ context.Context, in addManyInput) error {
+	for i := 0; i < in.Times; i++ {
+		c.Items = append(c.Items, in.Items...)
+	}
+	return nil
+}
+
+func (c *routedComp) ActionRename(_ context.Context, in *struct {
+	Name string `flux:"name"`
+}) {
+	c.Log = append(c.Log, "rename:"+in.Name)
+}
+
+// Reset is exported but neither prefixed nor allow-listed.
+func (c *routedComp) Reset(context.Context) error {
+	c.Log = append(c.Log, "reset")
+	return nil
+}
+
+// allowListComp exposes an unprefixed method through ActionAllowList.
+type allowListComp struct {
+	routedComp
+}
+
+func (c *allowListComp) AllowedActions() []string { return []string{"Archive"} }
+func (c *allowListComp) Handle(ctx context.Context, action string, data url.Values) error {
+	return DispatchAction(ctx, c, action, data)
+}
+func (c *allowListComp) Archive(context.Context) error {
+	c.Log = append(c.Log, "archive")
+	return nil
+}
+
+func TestParseActionMethods(t *testing.T) {
+	got := map[string]string{}
+	for _, am := range ParseActionMethods(&routedComp{}) {
+		got[am.Action] = am.Method
+	}
+	if got["add-item"] != "ActionAddItem" || got["clear"] != "ActionClear" {
+		t.Fatalf("expected convention methods to be routed, got %v", got)
+	}
+	if _, ok := got["reset"]; ok {
+		t.Fatalf("expected unprefixed method not to be routed")
+	}
+}
+
+func TestDispatchAction_RoutesByConvention(t *testing.T) {
+	c := &routedComp{}
+	ctx := context.Background()
+
+	for _, action := range []string{"add-item", "add_item", "addItem"} {
+		if err := DispatchAction(ctx, c, action, url.Values{"item": {action}}); err != nil {
+			t.Fatalf("DispatchAction(%q): %v", action, err)
+		}
+	}
+	if strings.Join(c.Items, ",") != "add-item,add_item,addItem" {
+		t.Fatalf("unexpected items: %v", c.Items)
+	}
+
+	if err := DispatchAction(ctx, c, "clear", nil); err != nil || c.Items != nil {
+		t.Fatalf("expected clear without data to work, err=%v items=%v", err, c.Items)
+	}
+	if err := DispatchAction(ctx, c, "fail", nil); err == nil || err.Error() != "boom" {
+		t.Fatalf("expected method error to propagate, got %v", err)
+	}
+	if err := DispatchAction(ctx, c, "bad-signature", nil); err == nil || errors.Is(err, ErrUnknownAction) {
+		t.Fatalf("expected signature error, got %v", err)
+	}
+}
+
+func TestDispatchAction_TypedInput(t *testing.T) {
+	c := &routedComp{}
+	ctx := context.Background()
+
+	if err := DispatchAction(ctx, c, "add-many", url.Values{"items[]": {"a", "b"}, "times": {"2"}}); err != nil {
+		t.Fatalf("DispatchAction(add-many): %v", err)
+	}
+	if strings.Join(c.Items, ",") != "a,b,a,b" {
+		t.Fatalf("unexpected items: %v", c.Items)
+	}
+
+	if err := DispatchAction(ctx, c, "rename", url.Values{"name": {"x"}}); err != nil {
+		t.Fatalf("DispatchAction(rename): %v", err)
+	}
+	if strings.Join(c.Log, ",") != "rename:x" {
+		t.Fatalf("expected pointer input to be decoded, got %v", c.Log)
+	}
+
+	var verrs ValidationErrors
+	err := DispatchAction(ctx, c, "add-many", url.Values{"items": {"c"}, "times": {"lots"}})
+	if !errors.As(err, &verrs) || verrs[0].Field != "times" {
+		t.Fatalf("expected a validation error for times, got %v", err)
+	}
+	if len(c.Items) != 4 {
+		t.Fatalf("expected the method not to run on invalid input, got %v", c.Items)
+	}
+}
+
+func TestDispatchAction_RejectsUnroutedMethods(t *testing.T) {
+	c := &routedComp{}
+	for _, action := range []string{"reset", "render", "set-id", "missing", ""} {
+		if err := DispatchAction(context.Background(), c, action, nil); !errors.Is(err, ErrUnknownAction) {
+			t.Fatalf("DispatchAction(%q): expected ErrUnknownAction, got %v", action, err)
+		}
+	}
+	if len(c.Log) != 0 {
+		t.Fatalf("expected no method to run, got %v", c.Log)
+	}
+}
+
+func TestDispatchAction_AllowList(t *testing.T) {
+	c := &allowListComp{}
+	if err := DispatchAction(context.Background(), c, "archive", nil); err != nil {
+		t.Fatalf("DispatchAction(archive): %v", err)
+	}
+	if err := DispatchAction(context.Background(), c, "reset", nil); !errors.Is(err, ErrUnknownAction) {
+		t.Fatalf("expected non-listed method to be refused, got %v", err)
+	}
+	if err := DispatchAction(context.Background(), c, "add-item", url.Values{"item": {"x"}}); err != nil {
+		t.Fatalf("expected convention methods to stay routed, got %v", err)
+	}
+	if strings.Join(c.Log, ",") != "archive" {
+		t.Fatalf("unexpected log: %v", c.Log)
+	}
+}
+
+func TestHandler_UnknownActionReturns400(t *testing.T) {
+	h := NewHandler(NewMemoryStore())
+	kind := registerTestKind(t, &routedComp{})
+
+	rec := postForm(h, url.Values{FormComponentKind: {kind}})
+	id := extractAttr(t, rec.Body.String(), DataFluxComponentID)
+
+	rec = postForm(h, url.Values{FormComponentKind: {kind}, FormComponentID: {id}, FormAction: {"add-item"}, "item": {"a"}})
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "items=a") {
+		t.Fatalf("expected routed action to succeed, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = postForm(h, url.Values{FormComponentKind: {kind}, FormComponentID: {id}, FormAction: {"reset"}})
+	if rec.Code != http.StatusBadRequest || rec.Body.String() != "unknown action" {
+		t.Fatalf("expected 400 unknown action, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -2,6 +2,7 @@ package liveflux
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/dracory/hb"
 	"github.com/samber/lo"
@@ -29,6 +30,28 @@ type Base struct {
 	// dirtyTargets tracks which DOM targets need to be updated.
 	// Components can use MarkTargetDirty to signal that specific selectors changed.
 	dirtyTargets map[string]bool
+
+	// stateToken is the signed client-side snapshot emitted by Root when the
+	// handler uses a ClientStateStore.
+	stateToken string
+
+	// errorBag holds validation messages rendered by ErrorFor. The handler
+	// clears it before each action and fills it from ValidationErrors.
+	errorBag *ErrorBag
+
+	// children maps Child keys to the nested components rendered under them.
+	// It is part of the Dehydrate snapshot.
+	children map[string]childRef
+
+	// scope is set while the component renders, see Child.
+	scope *childScope
+
+	// poll is the data-flux-poll directive rendered by Root, e.g. "5s" or
+	// "5s refresh". It is part of the Dehydrate snapshot.
+	poll string
+	// pollChanged is set by SetPollInterval until the handler sends
+	// PollHeader.
+	pollChanged bool
 }
 
 // GetKind returns the component's kind.
@@ -53,6 +76,26 @@ func (b *Base) SetID(id string) {
 	b.id = id
 }
 
+// setStateToken stores the client-side snapshot emitted by Root.
+func (b *Base) setStateToken(token string) {
+	b.stateToken = token
+}
+
+func (b *Base) childScope() *childScope { return b.scope }
+
+func (b *Base) setChildScope(scope *childScope) { b.scope = scope }
+
+func (b *Base) childRefs() map[string]childRef { return b.children }
+
+func (b *Base) setChildRef(key string, ref childRef) {
+	if b.children == nil {
+		b.children = map[string]childRef{}
+	}
+	b.children[key] = ref
+}
+
+func (b *Base) removeChildRef(key string) { delete(b.children, key) }
+
 // Redirect requests a client-side redirect with an optional delay in seconds.
 // If delaySeconds is not provided, 0 is used (immediate).
 func (b *Base) Redirect(url string, delaySeconds ...int) {
@@ -97,12 +140,47 @@ func (b *Base) Root(content hb.TagInterface) hb.TagInterface {
 		// data-flux-component-id is the component instance ID (INSTANCE identifier).
 		Attr(DataFluxComponentID, b.GetID())
 
+	if b.stateToken != "" {
+		// data-flux-state carries the signed snapshot posted back on actions.
+		root = root.Attr(DataFluxState, b.stateToken)
+	}
+
+	if b.poll != "" {
+		// data-flux-poll makes the client refresh the component periodically.
+		root = root.Attr(DataFluxPoll, b.poll)
+	}
+
 	if content != nil {
 		root = root.Child(content)
 	}
 	return root
 }
 
+// SetPollInterval makes the client re-render the component every interval
+// while the page is visible, or call action instead if given. An interval
+// <= 0 stops polling, e.g. once a background job finished:
+//
+//	func (c *Export) Handle(ctx context.Context, action string, _ url.Values) error {
+//		if action == "refresh" && c.job().Done() {
+//			c.SetPollInterval(0)
+//		}
+//		return nil
+//	}
+func (b *Base) SetPollInterval(interval time.Duration, action ...string) {
+	b.poll = formatPoll(interval, lo.FirstOr(action, ""))
+	b.pollChanged = true
+}
+
+func (b *Base) pollDirective() string { return b.poll }
+
+func (b *Base) setPollDirective(directive string) { b.poll = directive }
+
+func (b *Base) takePollChange() (string, bool) {
+	changed := b.pollChanged
+	b.pollChanged = false
+	return b.poll, changed
+}
+
 // GetEventDispatcher returns the component's event dispatcher, creating it if needed.
 func (b *Base) GetEventDispatcher() *EventDispatcher {
 	if b.eventDispatcher == nil {
@@ -130,6 +208,13 @@ func (b *Base) DispatchToKindAndID(componentKind string, componentID string, eve
 	b.GetEventDispatcher().DispatchToKindAndID(componentKind, componentID, eventName, data...)
 }
 
+// BroadcastTo queues an event to be published on a PubSub channel after the
+// current action, reaching every WebSocket-connected component subscribed to it.
+// Usage: component.BroadcastTo("orders", "order-created", map[string]any{"id": 1})
+func (b *Base) BroadcastTo(channel string, eventName string, data ...map[string]any) {
+	b.GetEventDispatcher().BroadcastTo(channel, eventName, data...)
+}
+
 // DispatchSelf queues an event to be sent only to the current component.
 // Usage: component.DispatchSelf("post-created", map[string]any{"id": 1, "title": "My Post"})
 func (b *Base) DispatchSelf(eventName string, data ...map[string]any) {
@@ -172,3 +257,49 @@ func (b *Base) GetDirtyTargets() map[string]bool {
 	}
 	return result
 }
+
+// Errors returns the component's error bag, creating it if needed.
+func (b *Base) Errors() *ErrorBag {
+	if b.errorBag == nil {
+		b.errorBag = &ErrorBag{}
+	}
+	return b.errorBag
+}
+
+// AddError records a validation message for field.
+// Usage: c.AddError("email", "is already taken")
+func (b *Base) AddError(field, message string) {
+	b.Errors().Add(field, message)
+}
+
+// HasError reports whether field has a validation message.
+func (b *Base) HasError(field string) bool {
+	return b.errorBag.Has(field)
+}
+
+// ClearErrors removes all validation messages.
+func (b *Base) ClearErrors() {
+	b.errorBag.Clear()
+}
+
+// ErrorFor renders the first message for field, or nil (which hb skips)
+// when the field is valid.
+// Usage: hb.Div().Child(input).Child(c.ErrorFor("email"))
+func (b *Base) ErrorFor(field string) hb.TagInterface {
+	if !b.HasError(field) {
+		return nil
+	}
+	return hb.Div().
+		Class(ErrorMessageClass).
+		Attr(DataFluxError, field).
+		Text(b.errorBag.First(field))
+}
+
+// InvalidClass returns InvalidFieldClass when field has an error, or "".
+// Usage: hb.Input().Name("email").Class("form-control " + c.InvalidClass("email"))
+func (b *Base) InvalidClass(field string) string {
+	if !b.HasError(field) {
+		return ""
+	}
+	return InvalidFieldClass
+}
@@ -0,0 +1,281 @@
+package liveflux
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/dracory/hb"
+)
+
+// PropsUpdater is implemented by child components that react to props
+// changed by their parent. PropsUpdated is called instead of Mount when the
+// parent renders an existing child with different props.
+type PropsUpdater interface {
+	PropsUpdated(ctx context.Context, props map[string]string) error
+}
+
+// childLockTimeout bounds the wait for a child's lock while the parent's is
+// held, so a child action routing an event to its parent, which takes the
+// locks in the opposite order, cannot deadlock with a parent render.
+const childLockTimeout = 2 * time.Second
+
+// childRef is the parent's record of a child rendered under a key.
+type childRef struct {
+	ID    string
+	Kind  string
+	Props map[string]string `json:",omitempty"`
+}
+
+// childScope tracks the children rendered during one parent render.
+type childScope struct {
+	ctx   context.Context
+	store Store
+	seen  map[string]bool
+	dirty bool // the parent's child registry changed
+}
+
+// Child renders a nested component owned by parent under key. The first
+// call for a key mounts child with props and persists it; later parent
+// renders reuse the stored instance, so its state survives parent
+// re-renders, and child itself is only used for its kind. Changed props are
+// passed to PropsUpdated (see PropsUpdater). Children whose key is not
+// rendered any more are deleted from the store.
+//
+// Call Child from the parent's Render. The parent must embed Base. Keys must
+// be unique among a parent's children, e.g. a row ID in a list.
+//
+//	func (c *Board) Render(ctx context.Context) hb.TagInterface {
+//		list := hb.Div()
+//		for _, card := range c.Cards {
+//			list.Child(liveflux.Child(c, &Card{}, card.ID, map[string]string{"title": card.Title}))
+//		}
+//		return c.Root(list)
+//	}
+//
+// With a ClientStateStore nothing is kept server-side, so children are
+// mounted afresh on every parent render.
+func Child(parent ComponentInterface, child ComponentInterface, key string, props map[string]string) hb.TagInterface {
+	if parent == nil || child == nil {
+		return hb.Text("component missing")
+	}
+	owner, ok := parent.(childOwner)
+	if !ok {
+		return childError(fmt.Errorf("liveflux: Child requires a parent embedding liveflux.Base"))
+	}
+	scope := owner.childScope()
+	if scope == nil {
+		// Rendered outside the handler/SSR (e.g. calling Render in a test)
+		scope = &childScope{ctx: context.Background(), store: StoreDefault, seen: map[string]bool{}}
+	}
+	if scope.seen[key] {
+		return childError(fmt.Errorf("liveflux: duplicate child key %q in '%s'", key, parent.GetKind()))
+	}
+	scope.seen[key] = true
+
+	c, err := scope.resolve(owner, child, key, props)
+	if err != nil {
+		return childError(err)
+	}
+	return render(scope.ctx, c)
+}
+
+// resolve returns the stored child for key, mounting or updating it first.
+func (s *childScope) resolve(owner childOwner, proto ComponentInterface, key string, props map[string]string) (ComponentInterface, error) {
+	ctx := s.ctx
+	kind := proto.GetKind()
+	ref, exists := owner.childRefs()[key]
+
+	if exists && ref.Kind == kind {
+		// The instance handling the current request, e.g. a child whose
+		// event re-renders its parent, is already loaded and locked
+		if c := heldComponent(ctx, ref.ID); c != nil && c.GetKind() == kind {
+			return c, s.update(owner, c, key, ref, props)
+		}
+
+		lockCtx, cancel := context.WithTimeout(ctx, childLockTimeout)
+		defer cancel()
+		unlock, err := lockStore(lockCtx, s.store, ref.ID)
+		if err != nil {
+			return nil, fmt.Errorf("liveflux: lock child %q: %w", key, err)
+		}
+		defer unlock()
+
+		if c, found := storeGetContext(ctx, s.store, ref.ID); found && c != nil && c.GetKind() == kind {
+			if err := afterHydrate(ctx, c); err != nil {
+				return nil, err
+			}
+			return c, s.update(owner, c, key, ref, props)
+		}
+	}
+
+	// New key, a different kind under the same key, or an evicted child
+	if exists {
+		deleteChild(ctx, s.store, ref.ID)
+	}
+	proto.SetKind(kind)
+	proto.SetID(NewID())
+	if err := proto.Mount(ctx, props); err != nil {
+		return nil, fmt.Errorf("mount error: %w", err)
+	}
+	if err := s.save(proto); err != nil {
+		return nil, err
+	}
+	owner.setChildRef(key, childRef{ID: proto.GetID(), Kind: kind, Props: maps.Clone(props)})
+	s.dirty = true
+	return proto, nil
+}
+
+// update passes changed props to an existing child and records them.
+func (s *childScope) update(owner childOwner, c ComponentInterface, key string, ref childRef, props map[string]string) error {
+	if maps.Equal(ref.Props, props) {
+		return nil
+	}
+	if pu, ok := c.(PropsUpdater); ok {
+		if err := pu.PropsUpdated(s.ctx, props); err != nil {
+			return err
+		}
+		if err := s.save(c); err != nil {
+			return err
+		}
+	}
+	ref.Props = maps.Clone(props)
+	owner.setChildRef(key, ref)
+	s.dirty = true
+	return nil
+}
+
+// save persists a child; with a ClientStateStore the snapshot is embedded
+// in the child's root instead.
+func (s *childScope) save(c ComponentInterface) error {
+	if err := beforeDehydrate(s.ctx, c); err != nil {
+		return err
+	}
+	if cs, ok := s.store.(*ClientStateStore); ok {
+		_, err := cs.attach(c)
+		return err
+	}
+	storeSetContext(s.ctx, s.store, c)
+	return nil
+}
+
+// childOwner is implemented by Base.
+type childOwner interface {
+	ComponentInterface
+	childScope() *childScope
+	setChildScope(scope *childScope)
+	childRefs() map[string]childRef
+	setChildRef(key string, ref childRef)
+	removeChildRef(key string)
+}
+
+// renderWithChildren runs fn with a child scope on c. After a full render
+// (full=true) children whose keys were not rendered are deleted and c is
+// re-saved if its children changed. Partial renders (RenderTargets) only
+// add children.
+func renderWithChildren(ctx context.Context, c ComponentInterface, full bool, fn func()) {
+	owner, ok := c.(childOwner)
+	if !ok {
+		fn()
+		return
+	}
+	store := storeFromContext(ctx)
+	scope := &childScope{ctx: ctx, store: store, seen: map[string]bool{}}
+	prev := owner.childScope()
+	owner.setChildScope(scope)
+	defer owner.setChildScope(prev)
+
+	fn()
+
+	if full {
+		for key, ref := range owner.childRefs() {
+			if !scope.seen[key] {
+				deleteChild(ctx, store, ref.ID)
+				owner.removeChildRef(key)
+				scope.dirty = true
+			}
+		}
+	}
+	if scope.dirty {
+		if _, clientState := store.(*ClientStateStore); !clientState {
+			storeSetContext(ctx, store, c)
+		}
+	}
+}
+
+// deleteChild removes a child and, depth first, its own children.
+func deleteChild(ctx context.Context, store Store, id string) {
+	if c, ok := storeGetContext(ctx, store, id); ok && c != nil {
+		if owner, ok := c.(childOwner); ok {
+			for _, ref := range owner.childRefs() {
+				deleteChild(ctx, store, ref.ID)
+			}
+		}
+	}
+	store.Delete(id)
+}
+
+func childError(err error) hb.TagInterface {
+	return hb.Div().Class("alert alert-danger").Text(err.Error())
+}
+
+type heldContextKey struct{}
+
+// contextWithHeld records that c is loaded and locked by the current
+// request, so nested renders use it instead of loading it again.
+func contextWithHeld(ctx context.Context, c ComponentInterface) context.Context {
+	held, _ := ctx.Value(heldContextKey{}).(map[string]ComponentInterface)
+	next := make(map[string]ComponentInterface, len(held)+1)
+	maps.Copy(next, held)
+	next[c.GetID()] = c
+	return context.WithValue(ctx, heldContextKey{}, next)
+}
+
+// heldComponent returns the instance id if the current request holds it.
+func heldComponent(ctx context.Context, id string) ComponentInterface {
+	held, _ := ctx.Value(heldContextKey{}).(map[string]ComponentInterface)
+	return held[id]
+}
+
+type storeContextKey struct{}
+
+// contextWithStore attaches the handler's store for nested renders.
+func contextWithStore(ctx context.Context, store Store) context.Context {
+	return context.WithValue(ctx, storeContextKey{}, store)
+}
+
+// storeFromContext returns the store attached by the handler, or
+// StoreDefault (as used by SSR).
+func storeFromContext(ctx context.Context) Store {
+	if store, ok := ctx.Value(storeContextKey{}).(Store); ok && store != nil {
+		return store
+	}
+	return StoreDefault
+}
+
+// storeGetContext reads a component, passing ctx to a ContextStore.
+func storeGetContext(ctx context.Context, store Store, id string) (ComponentInterface, bool) {
+	if cs, ok := store.(ContextStore); ok {
+		return cs.GetContext(ctx, id)
+	}
+	return store.Get(id)
+}
+
+// storeSetContext persists a component, passing ctx to a ContextStore.
+func storeSetContext(ctx context.Context, store Store, c ComponentInterface) {
+	if cs, ok := store.(ContextStore); ok {
+		cs.SetContext(ctx, c)
+		return
+	}
+	store.Set(c)
+}
+
+// lockStore takes the component lock when store implements LockingStore.
+func lockStore(ctx context.Context, store Store, id string) (func(), error) {
+	ls, ok := store.(LockingStore)
+	if !ok {
+		return func() {}, nil
+	}
+	return ls.Lock(ctx, id)
+}
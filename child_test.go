@@ -0,0 +1,305 @@
+package liveflux
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dracory/hb"
+)
+
+// boardComp renders one cardComp per card.
+type boardComp struct {
+	Base
+	Cards  []string
+	Titles map[string]string
+}
+
+func (c *boardComp) GetKind() string { return "test.board-comp" }
+func (c *boardComp) Mount(context.Context, map[string]string) error {
+	c.Cards = []string{"a", "b"}
+	c.Titles = map[string]string{"a": "Alpha", "b": "Beta"}
+	return nil
+}
+func (c *boardComp) Handle(_ context.Context, action string, data url.Values) error {
+	switch action {
+	case "rename":
+		c.Titles[data.Get("card")] = data.Get("title")
+	case "remove":
+		for i, card := range c.Cards {
+			if card == data.Get("card") {
+				c.Cards = append(c.Cards[:i], c.Cards[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil
+}
+func (c *boardComp) Render(context.Context) hb.TagInterface {
+	list := hb.Div()
+	for _, card := range c.Cards {
+		list.Child(Child(c, &cardComp{}, card, map[string]string{"title": c.Titles[card]}))
+	}
+	return c.Root(list)
+}
+
+// cardComp counts clicks and records prop updates.
+type cardComp struct {
+	Base
+	Title   string
+	Count   int
+	Updates int
+}
+
+func (c *cardComp) GetKind() string { return "test.card-comp" }
+func (c *cardComp) Mount(_ context.Context, props map[string]string) error {
+	c.Title = props["title"]
+	return nil
+}
+func (c *cardComp) Handle(_ context.Context, action string, _ url.Values) error {
+	if action == "inc" {
+		c.Count++
+	}
+	return nil
+}
+func (c *cardComp) PropsUpdated(_ context.Context, props map[string]string) error {
+	c.Title = props["title"]
+	c.Updates++
+	return nil
+}
+func (c *cardComp) Render(context.Context) hb.TagInterface {
+	return c.Root(hb.Text(fmt.Sprintf("%s count=%d updates=%d", c.Title, c.Count, c.Updates)))
+}
+
+var cardIDPattern = regexp.MustCompile(DataFluxComponentID + `="([^"]+)" ` + DataFluxComponentKind + `="test\.card-comp"`)
+
+func cardIDs(html string) []string {
+	var ids []string
+	for _, m := range cardIDPattern.FindAllStringSubmatch(html, -1) {
+		ids = append(ids, m[1])
+	}
+	return ids
+}
+
+func TestHandler_ChildComponents(t *testing.T) {
+	store := NewMemoryStore()
+	h := NewHandler(store)
+	kind := registerTestKind(t, &boardComp{})
+	cardKind := registerTestKind(t, &cardComp{})
+
+	rec := postForm(h, url.Values{FormComponentKind: {kind}})
+	body := rec.Body.String()
+	id := extractAttr(t, body, DataFluxComponentID)
+	ids := cardIDs(body)
+	if len(ids) != 2 || !strings.Contains(body, "Alpha count=0") || !strings.Contains(body, "Beta count=0") {
+		t.Fatalf("expected two mounted cards, got %q", body)
+	}
+
+	// Children handle their own actions
+	rec = postForm(h, url.Values{FormComponentKind: {cardKind}, FormComponentID: {ids[0]}, FormAction: {"inc"}})
+	if !strings.Contains(rec.Body.String(), "Alpha count=1") {
+		t.Fatalf("expected child action to apply, got %q", rec.Body.String())
+	}
+
+	// A parent re-render keeps the child instance and passes changed props
+	post := func(action string, fields url.Values) string {
+		fields.Set(FormComponentKind, kind)
+		fields.Set(FormComponentID, id)
+		fields.Set(FormAction, action)
+		return postForm(h, fields).Body.String()
+	}
+	body = post("rename", url.Values{"card": {"a"}, "title": {"Alpha 2"}})
+	if got := cardIDs(body); len(got) != 2 || got[0] != ids[0] || got[1] != ids[1] {
+		t.Fatalf("expected child IDs to be stable, got %v want %v", got, ids)
+	}
+	if !strings.Contains(body, "Alpha 2 count=1 updates=1") || !strings.Contains(body, "Beta count=0 updates=0") {
+		t.Fatalf("expected preserved state and updated props, got %q", body)
+	}
+
+	// Removed children are deleted from the store
+	body = post("remove", url.Values{"card": {"b"}})
+	if got := cardIDs(body); len(got) != 1 || got[0] != ids[0] {
+		t.Fatalf("expected only the first card, got %v", got)
+	}
+	if _, ok := store.Get(ids[1]); ok {
+		t.Fatalf("expected removed child %s to be deleted", ids[1])
+	}
+	if _, ok := store.Get(ids[0]); !ok {
+		t.Fatalf("expected remaining child %s to stay", ids[0])
+	}
+}
+
+// shelfComp nests a boardComp, so removing it removes the cards too.
+type shelfComp struct {
+	Base
+	Show bool
+}
+
+func (c *shelfComp) GetKind() string                                  { return "test.shelf-comp" }
+func (c *shelfComp) Mount(context.Context, map[string]string) error   { c.Show = true; return nil }
+func (c *shelfComp) Handle(context.Context, string, url.Values) error { c.Show = false; return nil }
+func (c *shelfComp) Render(context.Context) hb.TagInterface {
+	if !c.Show {
+		return c.Root(nil)
+	}
+	return c.Root(Child(c, &boardComp{}, "board", nil))
+}
+
+func TestChild_RemovesDescendants(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := contextWithStore(context.Background(), store)
+	shelf := &shelfComp{}
+	shelf.SetKind(shelf.GetKind())
+	shelf.SetID(NewID())
+	_ = shelf.Mount(ctx, nil)
+
+	html := render(ctx, shelf).ToHTML()
+	ids := cardIDs(html)
+	// The shelf is re-saved with its child registry alongside board and cards
+	if len(ids) != 2 || store.Len() != 4 {
+		t.Fatalf("expected shelf, board and two cards in the store, got %d entries: %s", store.Len(), html)
+	}
+
+	shelf.Show = false
+	render(ctx, shelf)
+	if store.Len() != 1 {
+		t.Fatalf("expected board and cards to be deleted, %d entries left", store.Len())
+	}
+}
+
+func TestChild_DuplicateKeyAndSnapshot(t *testing.T) {
+	registerTestKind(t, &boardComp{})
+	store := NewMemoryStore()
+	ctx := contextWithStore(context.Background(), store)
+
+	board := &boardComp{}
+	board.SetKind(board.GetKind())
+	board.SetID(NewID())
+	_ = board.Mount(ctx, nil)
+	board.Cards = []string{"a", "a"}
+	if html := render(ctx, board).ToHTML(); !strings.Contains(html, `duplicate child key \"a\"`) && !strings.Contains(html, "duplicate child key &#34;a&#34;") {
+		t.Fatalf("expected duplicate key error, got %q", html)
+	}
+
+	data, err := Dehydrate(board, nil)
+	if err != nil {
+		t.Fatalf("Dehydrate: %v", err)
+	}
+	restored, err := Hydrate(data, nil)
+	if err != nil {
+		t.Fatalf("Hydrate: %v", err)
+	}
+	if got, want := restored.(*boardComp).childRefs()["a"], board.childRefs()["a"]; got.ID == "" || got.ID != want.ID || got.Props["title"] != "Alpha" {
+		t.Fatalf("expected child registry to survive a snapshot, got %+v", got)
+	}
+}
+
+// lockOrderBoard and lockOrderCard take each other's locks in opposite
+// orders: a board action renders the card, and a card action routes an
+// event to the board. Their actions wait on lockOrderBarrier so both hold
+// their own lock before reaching for the other's.
+var lockOrderBarrier sync.WaitGroup
+
+type lockOrderBoard struct {
+	Base
+	Changes int
+}
+
+func (c *lockOrderBoard) GetKind() string                                { return "test.lock-order-board" }
+func (c *lockOrderBoard) Mount(context.Context, map[string]string) error { return nil }
+func (c *lockOrderBoard) Handle(context.Context, string, url.Values) error {
+	lockOrderBarrier.Done()
+	lockOrderBarrier.Wait()
+	return nil
+}
+func (c *lockOrderBoard) OnCardChanged(context.Context, Event) error {
+	c.Changes++
+	return nil
+}
+func (c *lockOrderBoard) Render(context.Context) hb.TagInterface {
+	return c.Root(hb.Div().
+		Child(hb.Text(fmt.Sprintf("changes=%d", c.Changes))).
+		Child(Child(c, &lockOrderCard{}, "card", nil)))
+}
+
+type lockOrderCard struct{ Base }
+
+func (c *lockOrderCard) GetKind() string                                { return "test.lock-order-card" }
+func (c *lockOrderCard) Mount(context.Context, map[string]string) error { return nil }
+func (c *lockOrderCard) Handle(context.Context, string, url.Values) error {
+	lockOrderBarrier.Done()
+	lockOrderBarrier.Wait()
+	c.Dispatch("card-changed")
+	return nil
+}
+func (c *lockOrderCard) Render(context.Context) hb.TagInterface {
+	return c.Root(hb.Text("card"))
+}
+
+func TestChild_ParentRenderAndChildEventDoNotDeadlock(t *testing.T) {
+	store := NewMemoryStore()
+	h := NewHandler(store)
+	boardKind := registerTestKind(t, &lockOrderBoard{})
+	cardKind := registerTestKind(t, &lockOrderCard{})
+
+	body := postForm(h, url.Values{FormComponentKind: {boardKind}}).Body.String()
+	boardID := extractAttr(t, body, DataFluxComponentID)
+	cardID := cardIDsOf(t, body, cardKind)
+
+	lockOrderBarrier.Add(2)
+	bodies := make(chan string, 2)
+	go func() {
+		bodies <- postForm(h, url.Values{
+			FormComponentKind: {boardKind},
+			FormComponentID:   {boardID},
+			FormAction:        {"refresh"},
+		}).Body.String()
+	}()
+	go func() {
+		bodies <- postForm(h, url.Values{
+			FormComponentKind:  {cardKind},
+			FormComponentID:    {cardID},
+			FormAction:         {"change"},
+			FormLiveComponents: {boardKind + ":" + boardID},
+		}).Body.String()
+	}()
+
+	timeout := time.After(childLockTimeout + eventLockTimeout + 3*time.Second)
+	for range 2 {
+		select {
+		case <-bodies:
+		case <-timeout:
+			t.Fatal("expected the parent render and the child event to give up instead of deadlocking")
+		}
+	}
+
+	// Both locks are free again
+	body = postForm(h, url.Values{FormComponentKind: {boardKind}, FormComponentID: {boardID}}).Body.String()
+	if !strings.Contains(body, "card") || strings.Contains(body, "alert") {
+		t.Fatalf("expected the board to render its card, got %q", body)
+	}
+
+	// A child held elsewhere is rendered as an error after the timeout
+	unlock, err := store.Lock(context.Background(), cardID)
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	defer unlock()
+	done := make(chan string, 1)
+	go func() {
+		done <- postForm(h, url.Values{FormComponentKind: {boardKind}, FormComponentID: {boardID}}).Body.String()
+	}()
+	select {
+	case body = <-done:
+	case <-time.After(childLockTimeout + 3*time.Second):
+		t.Fatal("expected the parent render to stop waiting for the child's lock")
+	}
+	if !strings.Contains(body, "alert") || !strings.Contains(body, "lock child") {
+		t.Fatalf("expected a child error, got %q", body)
+	}
+}
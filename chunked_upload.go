@@ -0,0 +1,770 @@
+package liveflux
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Query parameters of the chunked upload protocol, see ChunkedUploads.
+const (
+	// FormUploadOp selects the operation: "init" or "chunk".
+	FormUploadOp       = "liveflux_upload"
+	FormUploadID       = "liveflux_upload_id"
+	FormUploadOffset   = "liveflux_upload_offset"
+	FormUploadChecksum = "liveflux_upload_checksum"
+	FormUploadField    = "liveflux_upload_field"
+	FormUploadFilename = "liveflux_upload_filename"
+	FormUploadType     = "liveflux_upload_type"
+	FormUploadSize     = "liveflux_upload_size"
+	// FormChunkedUpload references a finished upload in the action request
+	// that finalizes it, e.g. liveflux_chunked_upload[avatar]=<id>.
+	FormChunkedUpload = "liveflux_chunked_upload"
+)
+
+// DefaultChunkSize is the largest chunk accepted when no
+// WithChunkedUploadChunkSize option is given.
+const DefaultChunkSize int64 = 8 << 20
+
+// DefaultMaxChunkedUploadSize caps assembled files when no
+// WithChunkedUploadMaxFileSize option is given.
+const DefaultMaxChunkedUploadSize int64 = 1 << 30
+
+// Limits on uploads in progress when no WithChunkedUploadMaxPerComponent,
+// WithChunkedUploadMaxPerSession or WithChunkedUploadMaxTotalSize option is
+// given. The total counts the announced size of every unfinished upload.
+const (
+	DefaultMaxChunkedUploadsPerComponent       = 4
+	DefaultMaxChunkedUploadsPerSession         = 16
+	DefaultMaxChunkedUploadsTotalSize    int64 = 8 << 30
+)
+
+// DefaultChunkedUploadPurgeInterval is how often stale uploads are purged
+// when no WithChunkedUploadPurgeInterval option is given.
+const DefaultChunkedUploadPurgeInterval = time.Hour
+
+// Chunked upload errors. The handler answers them with 404 "upload not
+// found", 409 (with the current offset), 400 "checksum mismatch", 400
+// "upload incomplete" and 429 "too many uploads".
+var (
+	ErrUploadNotFound   = errors.New("liveflux: upload not found")
+	ErrUploadOffset     = errors.New("liveflux: chunk offset does not match upload")
+	ErrUploadChecksum   = errors.New("liveflux: upload checksum mismatch")
+	ErrUploadIncomplete = errors.New("liveflux: upload incomplete")
+	ErrUploadLimit      = errors.New("liveflux: too many uploads in progress")
+
+	// errInvalidUpload marks malformed protocol requests (400 "invalid upload").
+	errInvalidUpload = errors.New("liveflux: invalid upload")
+)
+
+// ChunkedUploadOption configures ChunkedUploads.
+type ChunkedUploadOption func(*chunkedUploadOptions)
+
+type chunkedUploadOptions struct {
+	chunkSize       int64
+	maxFileSize     int64
+	maxTotalSize    int64
+	maxPerComponent int
+	maxPerSession   int
+	session         SessionExtractor
+	ttl             time.Duration
+	purgeInterval   time.Duration
+}
+
+// WithChunkedUploadChunkSize sets the largest accepted chunk. Clients must
+// send smaller or equal chunks (ClientOptions.UploadChunkSize).
+func WithChunkedUploadChunkSize(n int64) ChunkedUploadOption {
+	return func(opts *chunkedUploadOptions) {
+		opts.chunkSize = n
+	}
+}
+
+// WithChunkedUploadMaxFileSize rejects uploads announcing more than n bytes
+// with ErrUploadTooLarge.
+func WithChunkedUploadMaxFileSize(n int64) ChunkedUploadOption {
+	return func(opts *chunkedUploadOptions) {
+		opts.maxFileSize = n
+	}
+}
+
+// WithChunkedUploadMaxPerComponent limits the unfinished uploads of one
+// component instance; init beyond it fails with ErrUploadLimit. n <= 0
+// removes the limit.
+func WithChunkedUploadMaxPerComponent(n int) ChunkedUploadOption {
+	return func(opts *chunkedUploadOptions) {
+		opts.maxPerComponent = n
+	}
+}
+
+// WithChunkedUploadMaxPerSession limits the unfinished uploads of one
+// session; init beyond it fails with ErrUploadLimit. n <= 0 removes the
+// limit. Sessions are told apart with WithChunkedUploadSession.
+func WithChunkedUploadMaxPerSession(n int) ChunkedUploadOption {
+	return func(opts *chunkedUploadOptions) {
+		opts.maxPerSession = n
+	}
+}
+
+// WithChunkedUploadMaxTotalSize limits the announced size of all unfinished
+// uploads together; init beyond it fails with ErrUploadLimit. n <= 0
+// removes the limit.
+func WithChunkedUploadMaxTotalSize(n int64) ChunkedUploadOption {
+	return func(opts *chunkedUploadOptions) {
+		opts.maxTotalSize = n
+	}
+}
+
+// WithChunkedUploadSession sets how uploads are attributed to sessions for
+// WithChunkedUploadMaxPerSession. Defaults to the extractor of the handler's
+// SessionStore; without either, the per-session limit does not apply.
+func WithChunkedUploadSession(extractor SessionExtractor) ChunkedUploadOption {
+	return func(opts *chunkedUploadOptions) {
+		opts.session = extractor
+	}
+}
+
+// WithChunkedUploadTTL sets how long an upload may go without a chunk
+// before the background purge removes it. Defaults to 24 hours.
+func WithChunkedUploadTTL(ttl time.Duration) ChunkedUploadOption {
+	return func(opts *chunkedUploadOptions) {
+		opts.ttl = ttl
+	}
+}
+
+// WithChunkedUploadPurgeInterval sets how often a background goroutine
+// calls Cleanup with the TTL. Defaults to DefaultChunkedUploadPurgeInterval;
+// zero disables the goroutine. Stop it with Close.
+func WithChunkedUploadPurgeInterval(interval time.Duration) ChunkedUploadOption {
+	return func(opts *chunkedUploadOptions) {
+		opts.purgeInterval = interval
+	}
+}
+
+// ChunkedUploads receives large files in pieces over the Liveflux endpoint,
+// so no single request has to carry the whole file:
+//
+//  1. init: POST ?liveflux_upload=init with the field, file name, content
+//     type, size and optionally the SHA-256 of the whole file (checksum).
+//     The answer is {"id","offset","size","chunk_size"}. Sending the id of an
+//     earlier upload for the same file resumes it from its offset.
+//  2. chunk: POST ?liveflux_upload=chunk&liveflux_upload_id=..&
+//     liveflux_upload_offset=.. with the raw bytes as body and optionally
+//     their SHA-256 as checksum. The answer is the new offset; a stale offset
+//     is answered with 409 and the current one.
+//  3. finalize: the action request posts liveflux_chunked_upload[field]=id.
+//     The assembled file is verified, moved to the handler's TempStorage and
+//     passed to the action as an *UploadedFile.
+//
+// Init is only accepted for a component the request could act on, and the
+// upload is bound to that instance. Partial files are kept in a directory
+// with a JSON sidecar, so uploads survive restarts.
+type ChunkedUploads struct {
+	dir   string
+	opts  chunkedUploadOptions
+	now   func() time.Time
+	locks *MutexLocker
+
+	mu     sync.Mutex
+	active map[string]activeUpload // unfinished uploads by ID
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// chunkedUpload is the sidecar describing an upload in progress.
+type chunkedUpload struct {
+	ID          string `json:"id"`
+	Kind        string `json:"kind"`
+	ComponentID string `json:"component_id"`
+	Field       string `json:"field"`
+	Filename    string `json:"filename"`
+	ContentType string `json:"content_type"`
+	Size        int64  `json:"size"`
+	Checksum    string `json:"checksum,omitempty"`
+	// Session is a hash of the session that started the upload
+	Session string `json:"session,omitempty"`
+}
+
+// activeUpload is an unfinished upload counted against the limits.
+type activeUpload struct {
+	meta    chunkedUpload
+	touched time.Time
+}
+
+// chunkedUploadStatus is the JSON answer to init and chunk requests.
+type chunkedUploadStatus struct {
+	ID        string `json:"id"`
+	Offset    int64  `json:"offset"`
+	Size      int64  `json:"size,omitempty"`
+	ChunkSize int64  `json:"chunk_size"`
+}
+
+// NewChunkedUploads creates ChunkedUploads keeping partial files in dir,
+// creating the directory if needed.
+func NewChunkedUploads(dir string, optFns ...ChunkedUploadOption) (*ChunkedUploads, error) {
+	if dir == "" {
+		return nil, errors.New("liveflux: chunked uploads require a directory")
+	}
+	options := chunkedUploadOptions{
+		chunkSize:       DefaultChunkSize,
+		maxFileSize:     DefaultMaxChunkedUploadSize,
+		maxTotalSize:    DefaultMaxChunkedUploadsTotalSize,
+		maxPerComponent: DefaultMaxChunkedUploadsPerComponent,
+		maxPerSession:   DefaultMaxChunkedUploadsPerSession,
+		ttl:             24 * time.Hour,
+		purgeInterval:   DefaultChunkedUploadPurgeInterval,
+	}
+	for _, fn := range optFns {
+		if fn != nil {
+			fn(&options)
+		}
+	}
+	if options.chunkSize <= 0 {
+		return nil, errors.New("liveflux: chunk size must be positive")
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("liveflux: chunked uploads: %w", err)
+	}
+
+	u := &ChunkedUploads{
+		dir:    dir,
+		opts:   options,
+		now:    time.Now,
+		locks:  NewMutexLocker(),
+		active: map[string]activeUpload{},
+		stop:   make(chan struct{}),
+	}
+	if err := u.loadActive(); err != nil {
+		return nil, fmt.Errorf("liveflux: chunked uploads: %w", err)
+	}
+	if options.purgeInterval > 0 && options.ttl > 0 {
+		go u.janitor(options.purgeInterval)
+	}
+	return u, nil
+}
+
+// ChunkSize returns the largest chunk the server accepts.
+func (u *ChunkedUploads) ChunkSize() int64 {
+	return u.opts.chunkSize
+}
+
+// Cleanup removes uploads that received no chunk for more than maxAge and
+// reports how many were removed.
+func (u *ChunkedUploads) Cleanup(ctx context.Context, maxAge time.Duration) (int, error) {
+	entries, err := os.ReadDir(u.dir)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := u.now().Add(-maxAge)
+	removed := 0
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ok || !validKey(id) {
+			continue
+		}
+		info, err := os.Stat(u.partPath(id))
+		if err == nil && info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := u.remove(id); err == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// loadActive counts the uploads an earlier process left in dir.
+func (u *ChunkedUploads) loadActive() error {
+	entries, err := os.ReadDir(u.dir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		id, ok := strings.CutSuffix(entry.Name(), ".json")
+		if entry.IsDir() || !ok || !validKey(id) {
+			continue
+		}
+		meta, err := u.load(id)
+		if err != nil {
+			continue
+		}
+		info, err := os.Stat(u.partPath(id))
+		if err != nil {
+			continue
+		}
+		u.active[id] = activeUpload{meta: meta, touched: info.ModTime()}
+	}
+	return nil
+}
+
+// Close stops the purge goroutine. It is safe to call more than once.
+func (u *ChunkedUploads) Close() error {
+	u.closeOnce.Do(func() { close(u.stop) })
+	return nil
+}
+
+// begin starts an upload, or resumes resumeID if it describes the same
+// file for the same component, and returns it with its current offset.
+func (u *ChunkedUploads) begin(ctx context.Context, meta chunkedUpload, resumeID string) (chunkedUpload, int64, error) {
+	if meta.Kind == "" || meta.ComponentID == "" || meta.Field == "" || meta.Size < 0 {
+		return meta, 0, fmt.Errorf("%w: a component, a field and a size are required", errInvalidUpload)
+	}
+	if meta.Checksum != "" && !validChecksum(meta.Checksum) {
+		return meta, 0, fmt.Errorf("%w: checksum must be a hex SHA-256", errInvalidUpload)
+	}
+	if u.opts.maxFileSize > 0 && meta.Size > u.opts.maxFileSize {
+		return meta, 0, ErrUploadTooLarge
+	}
+
+	if validKey(resumeID) {
+		unlock, err := u.locks.Lock(ctx, resumeID)
+		if err != nil {
+			return meta, 0, err
+		}
+		defer unlock()
+		if prev, err := u.load(resumeID); err == nil {
+			meta.ID = prev.ID
+			if prev == meta {
+				offset, err := u.offset(prev.ID)
+				return prev, offset, err
+			}
+		}
+	}
+
+	id, err := randomKey()
+	if err != nil {
+		return meta, 0, err
+	}
+	meta.ID = id
+	stale, err := u.reserve(meta)
+	for _, staleID := range stale {
+		_ = u.remove(staleID)
+	}
+	if err != nil {
+		return meta, 0, err
+	}
+	// The sidecar goes first: Cleanup finds uploads by it and removes those
+	// whose part file is missing
+	data, err := json.Marshal(meta)
+	if err == nil {
+		err = os.WriteFile(u.metaPath(id), data, 0o600)
+	}
+	if err == nil {
+		var part *os.File
+		if part, err = os.OpenFile(u.partPath(id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600); err == nil {
+			err = part.Close()
+		}
+	}
+	if err != nil {
+		_ = u.remove(id)
+		return meta, 0, err
+	}
+	u.touch(id)
+	return meta, 0, nil
+}
+
+// reserve counts meta against the limits and admits it as an upload in
+// progress, or fails with ErrUploadLimit. Uploads past the TTL no longer
+// count; their IDs are returned for removal.
+func (u *ChunkedUploads) reserve(meta chunkedUpload) ([]string, error) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+
+	now := u.now()
+	var stale []string
+	perComponent, perSession, total := 0, 0, meta.Size
+	for id, a := range u.active {
+		if u.opts.ttl > 0 && now.Sub(a.touched) > u.opts.ttl {
+			delete(u.active, id)
+			stale = append(stale, id)
+			continue
+		}
+		if a.meta.Kind == meta.Kind && a.meta.ComponentID == meta.ComponentID {
+			perComponent++
+		}
+		if meta.Session != "" && a.meta.Session == meta.Session {
+			perSession++
+		}
+		total += a.meta.Size
+	}
+
+	if (u.opts.maxPerComponent > 0 && perComponent >= u.opts.maxPerComponent) ||
+		(u.opts.maxPerSession > 0 && perSession >= u.opts.maxPerSession) ||
+		(u.opts.maxTotalSize > 0 && total > u.opts.maxTotalSize) {
+		return stale, ErrUploadLimit
+	}
+	u.active[meta.ID] = activeUpload{meta: meta, touched: now}
+	return stale, nil
+}
+
+// appendChunk writes body at offset and returns the new offset. The upload
+// must have been started for owner's component and session. On
+// ErrUploadOffset the returned offset is the current one.
+func (u *ChunkedUploads) appendChunk(ctx context.Context, id string, owner chunkedUpload, offset int64, checksum string, body io.Reader) (int64, error) {
+	if !validKey(id) {
+		return 0, ErrUploadNotFound
+	}
+	unlock, err := u.locks.Lock(ctx, id)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	meta, err := u.load(id)
+	if err != nil {
+		return 0, err
+	}
+	if meta.Kind != owner.Kind || meta.ComponentID != owner.ComponentID || meta.Session != owner.Session {
+		return 0, ErrUploadNotFound
+	}
+	current, err := u.offset(id)
+	if err != nil {
+		return 0, err
+	}
+	if offset != current {
+		return current, ErrUploadOffset
+	}
+
+	chunk, err := io.ReadAll(io.LimitReader(body, u.opts.chunkSize+1))
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return current, ErrUploadTooLarge
+		}
+		return current, err
+	}
+	if int64(len(chunk)) > u.opts.chunkSize {
+		return current, ErrUploadTooLarge
+	}
+	if current+int64(len(chunk)) > meta.Size {
+		return current, fmt.Errorf("%w: chunk exceeds the announced size of %d bytes", errInvalidUpload, meta.Size)
+	}
+	if checksum != "" && !checksumMatches(checksum, chunk) {
+		return current, ErrUploadChecksum
+	}
+
+	f, err := os.OpenFile(u.partPath(id), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return current, err
+	}
+	_, err = f.Write(chunk)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		// Drop a partial write so the offset stays on a chunk boundary
+		_ = os.Truncate(u.partPath(id), current)
+		return current, err
+	}
+	u.touch(id)
+	return current + int64(len(chunk)), nil
+}
+
+// finalize verifies a complete upload owned by the given component field,
+// copies it into storage and removes it from u.
+func (u *ChunkedUploads) finalize(ctx context.Context, id, kind, componentID, field string, storage TempStorage) (*UploadedFile, error) {
+	if !validKey(id) {
+		return nil, ErrUploadNotFound
+	}
+	unlock, err := u.locks.Lock(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	meta, err := u.load(id)
+	if err != nil {
+		return nil, err
+	}
+	if meta.Kind != kind || meta.ComponentID != componentID || meta.Field != field {
+		return nil, ErrUploadNotFound
+	}
+	offset, err := u.offset(id)
+	if err != nil {
+		return nil, err
+	}
+	if offset != meta.Size {
+		return nil, ErrUploadIncomplete
+	}
+
+	part, err := os.Open(u.partPath(id))
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = part.Close() }()
+
+	hash := sha256.New()
+	key, size, err := storage.Store(ctx, io.TeeReader(part, hash))
+	if err != nil {
+		return nil, err
+	}
+	if meta.Checksum != "" && !strings.EqualFold(meta.Checksum, hex.EncodeToString(hash.Sum(nil))) {
+		_ = storage.Remove(key)
+		return nil, ErrUploadChecksum
+	}
+	if err := u.remove(id); err != nil {
+		fmt.Printf("liveflux: chunked upload cleanup error: %v\n", err)
+	}
+
+	return &UploadedFile{
+		Field:       field,
+		Filename:    cleanFilename(meta.Filename),
+		ContentType: meta.ContentType,
+		Size:        size,
+		Key:         key,
+		storage:     storage,
+	}, nil
+}
+
+func (u *ChunkedUploads) load(id string) (chunkedUpload, error) {
+	var meta chunkedUpload
+	data, err := os.ReadFile(u.metaPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return meta, ErrUploadNotFound
+	}
+	if err != nil {
+		return meta, err
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return meta, fmt.Errorf("liveflux: corrupt upload %s: %w", id, err)
+	}
+	return meta, nil
+}
+
+// offset is the size of the partial file, so it stays correct after a
+// crash between writing a chunk and answering the client.
+func (u *ChunkedUploads) offset(id string) (int64, error) {
+	info, err := os.Stat(u.partPath(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, ErrUploadNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+// touch stamps the upload with the storage clock so Cleanup follows it.
+func (u *ChunkedUploads) touch(id string) {
+	now := u.now()
+	_ = os.Chtimes(u.partPath(id), now, now)
+	u.mu.Lock()
+	if a, ok := u.active[id]; ok {
+		a.touched = now
+		u.active[id] = a
+	}
+	u.mu.Unlock()
+}
+
+func (u *ChunkedUploads) remove(id string) error {
+	u.mu.Lock()
+	delete(u.active, id)
+	u.mu.Unlock()
+	var errs []error
+	for _, path := range []string{u.partPath(id), u.metaPath(id)} {
+		if err := os.Remove(path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (u *ChunkedUploads) partPath(id string) string {
+	return filepath.Join(u.dir, id+".part")
+}
+
+func (u *ChunkedUploads) metaPath(id string) string {
+	return filepath.Join(u.dir, id+".json")
+}
+
+func (u *ChunkedUploads) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := u.Cleanup(context.Background(), u.opts.ttl); err != nil {
+				fmt.Printf("liveflux: chunked upload cleanup error: %v\n", err)
+			}
+		case <-u.stop:
+			return
+		}
+	}
+}
+
+func validChecksum(sum string) bool {
+	if len(sum) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(sum)
+	return err == nil
+}
+
+func checksumMatches(sum string, data []byte) bool {
+	want, err := hex.DecodeString(sum)
+	if err != nil {
+		return false
+	}
+	got := sha256.Sum256(data)
+	return bytes.Equal(want, got[:])
+}
+
+// chunkedUploadRefs collects liveflux_chunked_upload[field]=id values.
+func chunkedUploadRefs(data url.Values) map[string][]string {
+	refs := map[string][]string{}
+	for key, ids := range data {
+		rest, ok := strings.CutPrefix(key, FormChunkedUpload+"[")
+		if !ok {
+			continue
+		}
+		field, ok := strings.CutSuffix(rest, "]")
+		if !ok || field == "" {
+			continue
+		}
+		refs[field] = append(refs[field], ids...)
+	}
+	return refs
+}
+
+// serveChunkedUpload answers init and chunk requests. Their parameters are
+// read from the query string; chunk bodies are raw bytes.
+func (h *Handler) serveChunkedUpload(w http.ResponseWriter, r *http.Request, op string) {
+	u := h.ChunkedUploads
+	if u == nil {
+		h.writeError(w, http.StatusNotFound, "chunked uploads not enabled")
+		return
+	}
+	q := r.URL.Query()
+
+	switch op {
+	case "init":
+		size, err := strconv.ParseInt(q.Get(FormUploadSize), 10, 64)
+		if err != nil {
+			h.writeError(w, http.StatusBadRequest, "invalid upload")
+			return
+		}
+		kind, id := q.Get(FormComponentKind), q.Get(FormComponentID)
+		if !h.validateKindAndID(w, kind, id) {
+			return
+		}
+		// Only a component this request could act on may receive uploads
+		ctx := contextWithStore(contextWithRequest(r.Context(), r), h.Store)
+		c, ok := h.loadComponent(ctx, w, r, kind, id)
+		if !ok || !h.verifyKind(ctx, w, kind, c) {
+			return
+		}
+		meta, offset, err := u.begin(ctx, chunkedUpload{
+			Kind:        kind,
+			ComponentID: id,
+			Field:       q.Get(FormUploadField),
+			Filename:    q.Get(FormUploadFilename),
+			ContentType: q.Get(FormUploadType),
+			Size:        size,
+			Checksum:    strings.ToLower(q.Get(FormUploadChecksum)),
+			Session:     h.uploadSession(r),
+		}, q.Get(FormUploadID))
+		if err != nil {
+			h.writeUploadError(w, err)
+			return
+		}
+		h.writeUploadStatus(w, http.StatusOK, meta.ID, offset, meta.Size)
+
+	case "chunk":
+		id := q.Get(FormUploadID)
+		offset, err := strconv.ParseInt(q.Get(FormUploadOffset), 10, 64)
+		if err != nil || offset < 0 {
+			h.writeError(w, http.StatusBadRequest, "invalid upload")
+			return
+		}
+		// Chunks are accepted only from the component and session that
+		// started the upload
+		owner := chunkedUpload{
+			Kind:        q.Get(FormComponentKind),
+			ComponentID: q.Get(FormComponentID),
+			Session:     h.uploadSession(r),
+		}
+		body := http.MaxBytesReader(w, r.Body, u.opts.chunkSize)
+		next, err := u.appendChunk(r.Context(), id, owner, offset, q.Get(FormUploadChecksum), body)
+		if errors.Is(err, ErrUploadOffset) {
+			h.writeUploadStatus(w, http.StatusConflict, id, next, 0)
+			return
+		}
+		if err != nil {
+			h.writeUploadError(w, err)
+			return
+		}
+		h.writeUploadStatus(w, http.StatusOK, id, next, 0)
+
+	default:
+		h.writeError(w, http.StatusBadRequest, "unknown upload operation")
+	}
+}
+
+// uploadSession identifies the session of r for the per-session upload
+// limit, hashed so session keys are not written to disk.
+func (h *Handler) uploadSession(r *http.Request) string {
+	extractor := h.ChunkedUploads.opts.session
+	if s, ok := h.Store.(*SessionStore); ok && extractor == nil {
+		extractor = s.extractor
+	}
+	if extractor == nil {
+		return ""
+	}
+	key := extractor(r)
+	if key == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func (h *Handler) writeUploadStatus(w http.ResponseWriter, status int, id string, offset, size int64) {
+	body, _ := json.Marshal(chunkedUploadStatus{
+		ID:        id,
+		Offset:    offset,
+		Size:      size,
+		ChunkSize: h.ChunkedUploads.ChunkSize(),
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// writeUploadError maps upload errors, from single requests and the chunked
+// protocol alike, to responses.
+func (h *Handler) writeUploadError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, ErrUploadTooLarge):
+		h.writeError(w, http.StatusRequestEntityTooLarge, "upload too large")
+	case errors.Is(err, ErrUploadNotFound):
+		h.writeError(w, http.StatusNotFound, "upload not found")
+	case errors.Is(err, ErrUploadChecksum):
+		h.writeError(w, http.StatusBadRequest, "checksum mismatch")
+	case errors.Is(err, ErrUploadIncomplete):
+		h.writeError(w, http.StatusBadRequest, "upload incomplete")
+	case errors.Is(err, ErrUploadLimit):
+		h.writeError(w, http.StatusTooManyRequests, "too many uploads")
+	case errors.Is(err, errInvalidUpload):
+		h.writeError(w, http.StatusBadRequest, "invalid upload")
+	default:
+		fmt.Printf("liveflux: upload error: %v\n", err)
+		h.writeError(w, http.StatusInternalServerError, "upload error")
+	}
+}
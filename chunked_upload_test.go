@@ -0,0 +1,430 @@
+package liveflux
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func sha256Hex(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+func postChunked(h http.Handler, query url.Values, body string) (*httptest.ResponseRecorder, chunkedUploadStatus) {
+	req := httptest.NewRequest(http.MethodPost, "/liveflux?"+query.Encode(), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/octet-stream")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	var status chunkedUploadStatus
+	_ = json.Unmarshal(rec.Body.Bytes(), &status)
+	return rec, status
+}
+
+// newChunkedHandler mounts an uploadComp and returns a handler with chunked
+// uploads enabled, the component's kind and ID, and a func starting an
+// upload of size bytes for field.
+func newChunkedHandler(t *testing.T, opts ...ChunkedUploadOption) (*Handler, string, string, func(field string, size int, checksum string) chunkedUploadStatus) {
+	t.Helper()
+	h, kind, _ := newUploadHandler(t)
+	chunked, err := NewChunkedUploads(t.TempDir(), opts...)
+	if err != nil {
+		t.Fatalf("NewChunkedUploads: %v", err)
+	}
+	t.Cleanup(func() { _ = chunked.Close() })
+	h.ChunkedUploads = chunked
+
+	rec := postForm(h, url.Values{FormComponentKind: {kind}})
+	id := extractAttr(t, rec.Body.String(), DataFluxComponentID)
+
+	begin := func(field string, size int, checksum string) chunkedUploadStatus {
+		t.Helper()
+		rec, status := postChunked(h, url.Values{
+			FormUploadOp:       {"init"},
+			FormComponentKind:  {kind},
+			FormComponentID:    {id},
+			FormUploadField:    {field},
+			FormUploadFilename: {"data.csv"},
+			FormUploadType:     {"text/csv"},
+			FormUploadSize:     {strconv.Itoa(size)},
+			FormUploadChecksum: {checksum},
+		}, "")
+		if rec.Code != http.StatusOK || status.ID == "" {
+			t.Fatalf("init: %d %q", rec.Code, rec.Body.String())
+		}
+		return status
+	}
+	return h, kind, id, begin
+}
+
+func sendChunk(h http.Handler, kind, id, uploadID string, offset int, chunk, checksum string) (*httptest.ResponseRecorder, chunkedUploadStatus) {
+	return postChunked(h, url.Values{
+		FormUploadOp:       {"chunk"},
+		FormComponentKind:  {kind},
+		FormComponentID:    {id},
+		FormUploadID:       {uploadID},
+		FormUploadOffset:   {strconv.Itoa(offset)},
+		FormUploadChecksum: {checksum},
+	}, chunk)
+}
+
+func TestHandler_ChunkedUpload(t *testing.T) {
+	h, kind, id, begin := newChunkedHandler(t, WithChunkedUploadChunkSize(4))
+	content := "hello world"
+	status := begin("avatar", len(content), sha256Hex(content))
+	if status.Offset != 0 || status.ChunkSize != 4 {
+		t.Fatalf("unexpected init status %+v", status)
+	}
+
+	for offset := 0; offset < len(content); offset += 4 {
+		chunk := content[offset:min(offset+4, len(content))]
+		rec, next := sendChunk(h, kind, id, status.ID, offset, chunk, sha256Hex(chunk))
+		if rec.Code != http.StatusOK || next.Offset != int64(offset+len(chunk)) {
+			t.Fatalf("chunk at %d: %d %q", offset, rec.Code, rec.Body.String())
+		}
+	}
+
+	rec := postForm(h, url.Values{
+		FormComponentKind:              {kind},
+		FormComponentID:                {id},
+		FormAction:                     {"attach"},
+		"title":                        {"CSV"},
+		FormChunkedUpload + "[avatar]": {status.ID},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("finalize: %d %q", rec.Code, rec.Body.String())
+	}
+	if want := "summary=CSV:data.csv:text/csv:11:hello world"; !strings.Contains(rec.Body.String(), want) {
+		t.Fatalf("expected %q in %q", want, rec.Body.String())
+	}
+
+	// Finalized uploads cannot be used twice
+	rec = postForm(h, url.Values{
+		FormComponentKind:              {kind},
+		FormComponentID:                {id},
+		FormAction:                     {"attach"},
+		FormChunkedUpload + "[avatar]": {status.ID},
+	})
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a finalized upload, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandler_ChunkedUploadResume(t *testing.T) {
+	h, kind, id, begin := newChunkedHandler(t, WithChunkedUploadChunkSize(4))
+	status := begin("avatar", 8, "")
+	if rec, _ := sendChunk(h, kind, id, status.ID, 0, "abcd", ""); rec.Code != http.StatusOK {
+		t.Fatalf("chunk: %d %q", rec.Code, rec.Body.String())
+	}
+
+	// A retried chunk at a stale offset answers the current one
+	rec, next := sendChunk(h, kind, id, status.ID, 0, "abcd", "")
+	if rec.Code != http.StatusConflict || next.Offset != 4 {
+		t.Fatalf("expected 409 with offset 4, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// Init with the same id resumes
+	rec, resumed := postChunked(h, url.Values{
+		FormUploadOp:       {"init"},
+		FormUploadID:       {status.ID},
+		FormComponentKind:  {kind},
+		FormComponentID:    {id},
+		FormUploadField:    {"avatar"},
+		FormUploadFilename: {"data.csv"},
+		FormUploadType:     {"text/csv"},
+		FormUploadSize:     {"8"},
+	}, "")
+	if rec.Code != http.StatusOK || resumed.ID != status.ID || resumed.Offset != 4 {
+		t.Fatalf("expected resume at offset 4, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// Finalizing early is refused
+	rec = postForm(h, url.Values{
+		FormComponentKind:              {kind},
+		FormComponentID:                {id},
+		FormAction:                     {"attach"},
+		FormChunkedUpload + "[avatar]": {status.ID},
+	})
+	if rec.Code != http.StatusBadRequest || rec.Body.String() != "upload incomplete" {
+		t.Fatalf("expected 400 upload incomplete, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandler_ChunkedUploadRejections(t *testing.T) {
+	h, kind, id, begin := newChunkedHandler(t, WithChunkedUploadChunkSize(4), WithChunkedUploadMaxFileSize(16))
+	status := begin("avatar", 8, sha256Hex("12345678"))
+
+	rec, _ := sendChunk(h, kind, id, status.ID, 0, "abcd", sha256Hex("other"))
+	if rec.Code != http.StatusBadRequest || rec.Body.String() != "checksum mismatch" {
+		t.Fatalf("expected 400 checksum mismatch, got %d %q", rec.Code, rec.Body.String())
+	}
+	rec, _ = sendChunk(h, kind, id, status.ID, 0, "abcde", "")
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for an oversized chunk, got %d %q", rec.Code, rec.Body.String())
+	}
+	rec, _ = sendChunk(h, kind, id, strings.Repeat("0", 32), 0, "abcd", "")
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown upload, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec, _ = postChunked(h, url.Values{
+		FormUploadOp:      {"init"},
+		FormComponentKind: {kind},
+		FormComponentID:   {id},
+		FormUploadField:   {"avatar"},
+		FormUploadSize:    {"17"},
+	}, "")
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413 for an oversized file, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// The whole-file checksum is verified on finalize
+	sendChunk(h, kind, id, status.ID, 0, "abcd", "")
+	sendChunk(h, kind, id, status.ID, 4, "efgh", "")
+	finalize := func(fields url.Values) *httptest.ResponseRecorder {
+		fields.Set(FormComponentKind, kind)
+		fields.Set(FormAction, "attach")
+		fields.Set(FormChunkedUpload+"[avatar]", status.ID)
+		return postForm(h, fields)
+	}
+
+	// Uploads are bound to their component
+	other := extractAttr(t, postForm(h, url.Values{FormComponentKind: {kind}}).Body.String(), DataFluxComponentID)
+	if rec, _ := sendChunk(h, kind, other, status.ID, 8, "x", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a chunk from another component, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := finalize(url.Values{FormComponentID: {other}}); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another component, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := finalize(url.Values{FormComponentID: {id}}); rec.Code != http.StatusBadRequest || rec.Body.String() != "checksum mismatch" {
+		t.Fatalf("expected 400 checksum mismatch, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	h.ChunkedUploads = nil
+	if rec, _ := sendChunk(h, kind, id, status.ID, 8, "x", ""); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 when chunked uploads are disabled, got %d", rec.Code)
+	}
+}
+
+func TestHandler_ChunkedUploadInitChecksComponent(t *testing.T) {
+	h, kind, id, _ := newChunkedHandler(t)
+	initFor := func(kind, id string) *httptest.ResponseRecorder {
+		rec, _ := postChunked(h, url.Values{
+			FormUploadOp:      {"init"},
+			FormComponentKind: {kind},
+			FormComponentID:   {id},
+			FormUploadField:   {"avatar"},
+			FormUploadSize:    {"4"},
+		}, "")
+		return rec
+	}
+
+	if rec := initFor(kind, "missing"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown component, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := initFor("other.kind", id); rec.Code != http.StatusBadRequest || !strings.Contains(rec.Body.String(), "kind_mismatch") {
+		t.Fatalf("expected 400 kind_mismatch, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := initFor(kind, ""); rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400 without a component id, got %d %q", rec.Code, rec.Body.String())
+	}
+	if entries, _ := os.ReadDir(h.ChunkedUploads.dir); len(entries) != 0 {
+		t.Fatalf("expected no upload to be created, found %d files", len(entries))
+	}
+}
+
+func TestHandler_ChunkedUploadInitChecksSession(t *testing.T) {
+	h, kind, _ := newUploadHandler(t)
+	h.Store = NewSessionStore(NewMemoryStore(), SessionFromCookie("sid"))
+	chunked, err := NewChunkedUploads(t.TempDir(), WithChunkedUploadMaxPerSession(1))
+	if err != nil {
+		t.Fatalf("NewChunkedUploads: %v", err)
+	}
+	t.Cleanup(func() { _ = chunked.Close() })
+	h.ChunkedUploads = chunked
+
+	post := func(session string, query url.Values, form url.Values) *httptest.ResponseRecorder {
+		target := "/"
+		if query != nil {
+			target += "?" + query.Encode()
+		}
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: "sid", Value: session})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+	mount := func(session string) string {
+		return extractAttr(t, post(session, nil, url.Values{FormComponentKind: {kind}}).Body.String(), DataFluxComponentID)
+	}
+	initFor := func(session, id string) *httptest.ResponseRecorder {
+		return post(session, url.Values{
+			FormUploadOp:      {"init"},
+			FormComponentKind: {kind},
+			FormComponentID:   {id},
+			FormUploadField:   {"avatar"},
+			FormUploadSize:    {"4"},
+		}, nil)
+	}
+
+	alice := mount("alice")
+	if rec := initFor("mallory", alice); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a foreign component, got %d %q", rec.Code, rec.Body.String())
+	}
+	rec := initFor("alice", alice)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the owner's init to succeed, got %d %q", rec.Code, rec.Body.String())
+	}
+	var status chunkedUploadStatus
+	_ = json.Unmarshal(rec.Body.Bytes(), &status)
+
+	// Chunks are only accepted from the session that started the upload
+	chunk := func(session string) *httptest.ResponseRecorder {
+		return post(session, url.Values{
+			FormUploadOp:      {"chunk"},
+			FormUploadID:      {status.ID},
+			FormUploadOffset:  {"0"},
+			FormComponentKind: {kind},
+			FormComponentID:   {alice},
+		}, nil)
+	}
+	if rec := chunk("mallory"); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for a chunk from another session, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := chunk("alice"); rec.Code != http.StatusOK {
+		t.Fatalf("expected the owner's chunk to succeed, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// The session limit spans the session's components
+	if rec := initFor("alice", mount("alice")); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 past the session limit, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := initFor("bob", mount("bob")); rec.Code != http.StatusOK {
+		t.Fatalf("expected another session to be unaffected, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestHandler_ChunkedUploadLimits(t *testing.T) {
+	h, kind, id, begin := newChunkedHandler(t, WithChunkedUploadMaxPerComponent(2), WithChunkedUploadMaxTotalSize(20))
+	initFor := func(id string, size int) *httptest.ResponseRecorder {
+		rec, _ := postChunked(h, url.Values{
+			FormUploadOp:      {"init"},
+			FormComponentKind: {kind},
+			FormComponentID:   {id},
+			FormUploadField:   {"avatar"},
+			FormUploadSize:    {strconv.Itoa(size)},
+		}, "")
+		return rec
+	}
+
+	first := begin("avatar", 4, "")
+	begin("avatar", 4, "")
+	if rec := initFor(id, 4); rec.Code != http.StatusTooManyRequests || rec.Body.String() != "too many uploads" {
+		t.Fatalf("expected 429 past the component limit, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	other := extractAttr(t, postForm(h, url.Values{FormComponentKind: {kind}}).Body.String(), DataFluxComponentID)
+	if rec := initFor(other, 13); rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected 429 past the total size, got %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := initFor(other, 12); rec.Code != http.StatusOK {
+		t.Fatalf("expected init within the total size, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	// Finished uploads free their slot
+	sendChunk(h, kind, id, first.ID, 0, "abcd", "")
+	rec := postForm(h, url.Values{
+		FormComponentKind:              {kind},
+		FormComponentID:                {id},
+		FormAction:                     {"attach"},
+		FormChunkedUpload + "[avatar]": {first.ID},
+	})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("finalize: %d %q", rec.Code, rec.Body.String())
+	}
+	if rec := initFor(id, 4); rec.Code != http.StatusOK {
+		t.Fatalf("expected init after finalize, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestChunkedUploads_StaleUploadsAreDropped(t *testing.T) {
+	dir := t.TempDir()
+	u, err := NewChunkedUploads(dir, WithChunkedUploadMaxPerComponent(1), WithChunkedUploadTTL(time.Hour))
+	if err != nil {
+		t.Fatalf("NewChunkedUploads: %v", err)
+	}
+	defer u.Close()
+	now := time.Now()
+	u.now = func() time.Time { return now }
+
+	meta := chunkedUpload{Kind: "k", ComponentID: "c", Field: "f", Size: 4}
+	stale, _, err := u.begin(context.Background(), meta, "")
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	if _, _, err := u.begin(context.Background(), meta, ""); err != ErrUploadLimit {
+		t.Fatalf("expected ErrUploadLimit, got %v", err)
+	}
+
+	// Uploads left by an earlier process count too
+	restarted, err := NewChunkedUploads(dir, WithChunkedUploadMaxPerComponent(1), WithChunkedUploadTTL(time.Hour))
+	if err != nil {
+		t.Fatalf("NewChunkedUploads: %v", err)
+	}
+	defer restarted.Close()
+	restarted.now = func() time.Time { return now }
+	if _, _, err := restarted.begin(context.Background(), meta, ""); err != ErrUploadLimit {
+		t.Fatalf("expected ErrUploadLimit after a restart, got %v", err)
+	}
+
+	// Past the TTL the upload is removed instead of counted
+	now = now.Add(2 * time.Hour)
+	if _, _, err := u.begin(context.Background(), meta, ""); err != nil {
+		t.Fatalf("expected the stale upload to give way, got %v", err)
+	}
+	if _, err := u.load(stale.ID); err != ErrUploadNotFound {
+		t.Fatalf("expected the stale upload to be gone, got %v", err)
+	}
+}
+
+func TestChunkedUploads_Cleanup(t *testing.T) {
+	u, err := NewChunkedUploads(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewChunkedUploads: %v", err)
+	}
+	defer u.Close()
+	now := time.Now()
+	u.now = func() time.Time { return now }
+
+	meta := chunkedUpload{Kind: "k", ComponentID: "c", Field: "f", Size: 4}
+	stale, _, err := u.begin(context.Background(), meta, "")
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+	now = now.Add(2 * time.Hour)
+	fresh, _, err := u.begin(context.Background(), meta, "")
+	if err != nil {
+		t.Fatalf("begin: %v", err)
+	}
+
+	removed, err := u.Cleanup(context.Background(), time.Hour)
+	if err != nil || removed != 1 {
+		t.Fatalf("expected one stale upload to be purged, got %d %v", removed, err)
+	}
+	if _, err := u.load(stale.ID); err != ErrUploadNotFound {
+		t.Fatalf("expected stale upload to be gone, got %v", err)
+	}
+	if _, err := u.load(fresh.ID); err != nil {
+		t.Fatalf("expected fresh upload to remain, got %v", err)
+	}
+}
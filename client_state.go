@@ -0,0 +1,235 @@
+package liveflux
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Errors returned by ClientStateStore.Decode.
+var (
+	ErrStateMissing  = errors.New("liveflux: component state missing")
+	ErrStateInvalid  = errors.New("liveflux: component state invalid")
+	ErrStateTampered = errors.New("liveflux: component state signature mismatch")
+	ErrStateExpired  = errors.New("liveflux: component state expired")
+)
+
+// ClientStateStore keeps component state on the client instead of the server.
+// The handler embeds a signed (and optionally encrypted) snapshot into the
+// component root rendered by Base.Root; the client posts it back with every
+// action and the handler verifies and rehydrates it. This allows stateless
+// deployments behind load balancers without sticky sessions.
+//
+// ClientStateStore satisfies Store so it can be passed to NewHandler, but its
+// Get/Set/Delete methods are no-ops: there is nothing to look up server-side.
+type ClientStateStore struct {
+	signKey []byte
+	aead    cipher.AEAD
+	codec   StateCodec
+	maxAge  time.Duration
+	now     func() time.Time
+}
+
+// DefaultClientStateMaxAge is how long a ClientStateStore accepts a snapshot
+// unless WithClientStateMaxAge says otherwise. Bounding it limits how far
+// back a replayed token can roll a component.
+const DefaultClientStateMaxAge = 24 * time.Hour
+
+// ClientStateOption configures a ClientStateStore.
+type ClientStateOption func(*clientStateOptions)
+
+type clientStateOptions struct {
+	encryptionKey []byte
+	codec         StateCodec
+	maxAge        time.Duration
+}
+
+// WithClientStateEncryption encrypts snapshots with AES-GCM using key
+// (16, 24 or 32 bytes). Without it snapshots are signed but readable.
+func WithClientStateEncryption(key []byte) ClientStateOption {
+	return func(opts *clientStateOptions) {
+		opts.encryptionKey = key
+	}
+}
+
+// WithClientStateCodec sets the codec used to serialize snapshots.
+// Defaults to DefaultStateCodec.
+func WithClientStateCodec(codec StateCodec) ClientStateOption {
+	return func(opts *clientStateOptions) {
+		opts.codec = codec
+	}
+}
+
+// WithClientStateMaxAge rejects snapshots older than maxAge. Defaults to
+// DefaultClientStateMaxAge; zero disables expiry, letting any old snapshot
+// be replayed.
+func WithClientStateMaxAge(maxAge time.Duration) ClientStateOption {
+	return func(opts *clientStateOptions) {
+		opts.maxAge = maxAge
+	}
+}
+
+// NewClientStateStore creates a ClientStateStore signing snapshots with secret.
+func NewClientStateStore(secret []byte, optFns ...ClientStateOption) (*ClientStateStore, error) {
+	if len(secret) == 0 {
+		return nil, fmt.Errorf("liveflux: NewClientStateStore requires a non-empty secret")
+	}
+
+	options := clientStateOptions{maxAge: DefaultClientStateMaxAge}
+	for _, fn := range optFns {
+		if fn != nil {
+			fn(&options)
+		}
+	}
+
+	s := &ClientStateStore{
+		signKey: append([]byte(nil), secret...),
+		codec:   options.codec,
+		maxAge:  options.maxAge,
+		now:     time.Now,
+	}
+	if s.codec == nil {
+		s.codec = DefaultStateCodec
+	}
+
+	if len(options.encryptionKey) > 0 {
+		block, err := aes.NewCipher(options.encryptionKey)
+		if err != nil {
+			return nil, fmt.Errorf("liveflux: client state encryption key: %w", err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("liveflux: client state encryption key: %w", err)
+		}
+		s.aead = aead
+	}
+
+	return s, nil
+}
+
+// Get always reports a miss; state travels with the request instead.
+func (s *ClientStateStore) Get(id string) (ComponentInterface, bool) { return nil, false }
+
+// Set is a no-op; state is emitted into the rendered component root.
+func (s *ClientStateStore) Set(c ComponentInterface) {}
+
+// Delete is a no-op.
+func (s *ClientStateStore) Delete(id string) {}
+
+// Encode serializes the component into a signed, URL-safe token.
+//
+// Token layout: base64url(issuedAt || body) "." base64url(HMAC-SHA256),
+// where body is the snapshot, AES-GCM sealed (nonce || ciphertext) when
+// encryption is enabled.
+func (s *ClientStateStore) Encode(c ComponentInterface) (string, error) {
+	body, err := Dehydrate(c, s.codec)
+	if err != nil {
+		return "", err
+	}
+
+	if s.aead != nil {
+		nonce := make([]byte, s.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", fmt.Errorf("liveflux: client state nonce: %w", err)
+		}
+		body = s.aead.Seal(nonce, nonce, body, nil)
+	}
+
+	payload := make([]byte, 8, 8+len(body))
+	binary.BigEndian.PutUint64(payload, uint64(s.now().Unix()))
+	payload = append(payload, body...)
+
+	enc := base64.RawURLEncoding
+	return enc.EncodeToString(payload) + "." + enc.EncodeToString(s.sign(payload)), nil
+}
+
+// Decode verifies a token produced by Encode and rebuilds the component.
+// Returns ErrStateMissing, ErrStateInvalid, ErrStateTampered or ErrStateExpired
+// when the token cannot be trusted.
+func (s *ClientStateStore) Decode(token string) (ComponentInterface, error) {
+	if token == "" {
+		return nil, ErrStateMissing
+	}
+
+	payloadPart, sigPart, ok := strings.Cut(token, ".")
+	if !ok {
+		return nil, ErrStateInvalid
+	}
+	enc := base64.RawURLEncoding
+	payload, err := enc.DecodeString(payloadPart)
+	if err != nil || len(payload) < 8 {
+		return nil, ErrStateInvalid
+	}
+	sig, err := enc.DecodeString(sigPart)
+	if err != nil {
+		return nil, ErrStateInvalid
+	}
+	if !hmac.Equal(sig, s.sign(payload)) {
+		return nil, ErrStateTampered
+	}
+
+	issuedAt := time.Unix(int64(binary.BigEndian.Uint64(payload[:8])), 0)
+	if s.maxAge > 0 && s.now().Sub(issuedAt) > s.maxAge {
+		return nil, ErrStateExpired
+	}
+
+	body := payload[8:]
+	if s.aead != nil {
+		n := s.aead.NonceSize()
+		if len(body) < n {
+			return nil, ErrStateInvalid
+		}
+		body, err = s.aead.Open(nil, body[:n], body[n:], nil)
+		if err != nil {
+			return nil, ErrStateTampered
+		}
+	}
+
+	c, err := Hydrate(body, s.codec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrStateInvalid, err)
+	}
+	return c, nil
+}
+
+// attach encodes the component and stores the token on it so Base.Root can
+// emit it. Components that do not embed Base are left untouched.
+func (s *ClientStateStore) attach(c ComponentInterface) (string, error) {
+	token, err := s.Encode(c)
+	if err != nil {
+		return "", err
+	}
+	if st, ok := c.(interface{ setStateToken(string) }); ok {
+		st.setStateToken(token)
+	}
+	return token, nil
+}
+
+func (s *ClientStateStore) sign(payload []byte) []byte {
+	mac := hmac.New(sha256.New, s.signKey)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// clientStateError maps Decode errors to an HTTP status code and message.
+func clientStateError(err error) (int, string) {
+	switch {
+	case errors.Is(err, ErrStateMissing):
+		return http.StatusBadRequest, "missing component state"
+	case errors.Is(err, ErrStateTampered):
+		return http.StatusForbidden, "invalid component state signature"
+	case errors.Is(err, ErrStateExpired):
+		return http.StatusGone, "component state expired"
+	default:
+		return http.StatusBadRequest, "invalid component state"
+	}
+}
@@ -0,0 +1,261 @@
+package liveflux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/hb"
+)
+
+type clientStateComp struct {
+	Base
+	Count int
+}
+
+func (c *clientStateComp) GetKind() string                                { return "test.client-state-comp" }
+func (c *clientStateComp) Mount(context.Context, map[string]string) error { return nil }
+func (c *clientStateComp) Handle(_ context.Context, action string, _ url.Values) error {
+	if action == "inc" {
+		c.Count++
+	}
+	return nil
+}
+func (c *clientStateComp) Render(context.Context) hb.TagInterface {
+	return c.Root(hb.Div().Text(fmt.Sprintf("count=%d", c.Count)))
+}
+
+func newTestClientStateStore(t *testing.T, opts ...ClientStateOption) *ClientStateStore {
+	t.Helper()
+	registerTestKind(t, &clientStateComp{})
+	s, err := NewClientStateStore([]byte("test-secret"), opts...)
+	if err != nil {
+		t.Fatalf("NewClientStateStore: %v", err)
+	}
+	return s
+}
+
+func newClientStateComp(count int) *clientStateComp {
+	c := &clientStateComp{Count: count}
+	c.SetKind(c.GetKind())
+	c.SetID("cs-id")
+	return c
+}
+
+func TestNewClientStateStore_RequiresSecret(t *testing.T) {
+	if _, err := NewClientStateStore(nil); err == nil {
+		t.Fatalf("expected error for empty secret")
+	}
+	if _, err := NewClientStateStore([]byte("s"), WithClientStateEncryption([]byte("short"))); err == nil {
+		t.Fatalf("expected error for invalid encryption key")
+	}
+}
+
+func TestClientStateStore_EncodeDecode(t *testing.T) {
+	cases := map[string][]ClientStateOption{
+		"signed":    nil,
+		"encrypted": {WithClientStateEncryption([]byte("0123456789abcdef"))},
+		"gob":       {WithClientStateCodec(GobCodec{})},
+	}
+	for name, opts := range cases {
+		t.Run(name, func(t *testing.T) {
+			s := newTestClientStateStore(t, opts...)
+			token, err := s.Encode(newClientStateComp(5))
+			if err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+			got, err := s.Decode(token)
+			if err != nil {
+				t.Fatalf("Decode: %v", err)
+			}
+			c, ok := got.(*clientStateComp)
+			if !ok || c.Count != 5 || c.GetID() != "cs-id" {
+				t.Fatalf("unexpected decoded component: %#v", got)
+			}
+		})
+	}
+}
+
+func TestClientStateStore_EncryptedHidesState(t *testing.T) {
+	s := newTestClientStateStore(t, WithClientStateEncryption([]byte("0123456789abcdef")))
+	token, err := s.Encode(newClientStateComp(5))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	if strings.Contains(token, "Y291bnQ") || strings.Contains(token, "client-state") {
+		t.Fatalf("encrypted token should not expose plaintext: %s", token)
+	}
+}
+
+func TestClientStateStore_DecodeErrors(t *testing.T) {
+	s := newTestClientStateStore(t)
+	token, err := s.Encode(newClientStateComp(1))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	other, _ := NewClientStateStore([]byte("other-secret"))
+
+	tampered := tamperToken(token)
+
+	if _, err := s.Decode(""); !errors.Is(err, ErrStateMissing) {
+		t.Fatalf("expected ErrStateMissing, got %v", err)
+	}
+	if _, err := s.Decode("garbage"); !errors.Is(err, ErrStateInvalid) {
+		t.Fatalf("expected ErrStateInvalid, got %v", err)
+	}
+	if _, err := s.Decode(tampered); !errors.Is(err, ErrStateTampered) {
+		t.Fatalf("expected ErrStateTampered for modified payload, got %v", err)
+	}
+	if _, err := other.Decode(token); !errors.Is(err, ErrStateTampered) {
+		t.Fatalf("expected ErrStateTampered for foreign secret, got %v", err)
+	}
+}
+
+func TestClientStateStore_Expired(t *testing.T) {
+	s := newTestClientStateStore(t, WithClientStateMaxAge(time.Minute))
+	issued := time.Now()
+	s.now = func() time.Time { return issued }
+	token, err := s.Encode(newClientStateComp(1))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	s.now = func() time.Time { return issued.Add(2 * time.Minute) }
+	if _, err := s.Decode(token); !errors.Is(err, ErrStateExpired) {
+		t.Fatalf("expected ErrStateExpired, got %v", err)
+	}
+}
+
+func TestClientStateStore_DefaultMaxAge(t *testing.T) {
+	s := newTestClientStateStore(t)
+	issued := time.Now()
+	s.now = func() time.Time { return issued }
+	token, err := s.Encode(newClientStateComp(1))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+
+	s.now = func() time.Time { return issued.Add(DefaultClientStateMaxAge - time.Minute) }
+	if _, err := s.Decode(token); err != nil {
+		t.Fatalf("expected a token within the default max age to decode, got %v", err)
+	}
+	s.now = func() time.Time { return issued.Add(DefaultClientStateMaxAge + time.Minute) }
+	if _, err := s.Decode(token); !errors.Is(err, ErrStateExpired) {
+		t.Fatalf("expected ErrStateExpired after the default max age, got %v", err)
+	}
+
+	unbounded := newTestClientStateStore(t, WithClientStateMaxAge(0))
+	unbounded.now = s.now
+	if _, err := unbounded.Decode(token); err != nil {
+		t.Fatalf("expected no expiry with a zero max age, got %v", err)
+	}
+}
+
+// tamperToken changes the first payload character while keeping valid base64.
+func tamperToken(token string) string {
+	payload, sig, _ := strings.Cut(token, ".")
+	repl := "B"
+	if payload[0] == 'B' {
+		repl = "C"
+	}
+	return repl + payload[1:] + "." + sig
+}
+
+func postForm(h http.Handler, form url.Values) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func extractAttr(t *testing.T, html, attr string) string {
+	t.Helper()
+	start := strings.Index(html, attr+"=\"")
+	if start < 0 {
+		t.Fatalf("no %s in HTML: %s", attr, html)
+	}
+	start += len(attr + "=\"")
+	end := strings.Index(html[start:], "\"")
+	return html[start : start+end]
+}
+
+func TestHandler_ClientState_MountAndAction(t *testing.T) {
+	s := newTestClientStateStore(t)
+	h := NewHandler(s)
+	kind := (&clientStateComp{}).GetKind()
+
+	mountRec := postForm(h, url.Values{FormComponentKind: {kind}})
+	if mountRec.Code != http.StatusOK {
+		t.Fatalf("mount: expected 200, got %d %q", mountRec.Code, mountRec.Body.String())
+	}
+	html := mountRec.Body.String()
+	id := extractAttr(t, html, DataFluxComponentID)
+	token := extractAttr(t, html, DataFluxState)
+	if mountRec.Header().Get(StateHeader) != token {
+		t.Fatalf("expected %s header to match rendered token", StateHeader)
+	}
+
+	actRec := postForm(h, url.Values{
+		FormComponentKind:  {kind},
+		FormComponentID:    {id},
+		FormAction:         {"inc"},
+		FormComponentState: {token},
+	})
+	if actRec.Code != http.StatusOK || !strings.Contains(actRec.Body.String(), "count=1") {
+		t.Fatalf("action: expected 200 count=1, got %d %q", actRec.Code, actRec.Body.String())
+	}
+
+	// The refreshed snapshot carries the new state
+	next := extractAttr(t, actRec.Body.String(), DataFluxState)
+	actRec2 := postForm(h, url.Values{
+		FormComponentKind:  {kind},
+		FormComponentID:    {id},
+		FormAction:         {"inc"},
+		FormComponentState: {next},
+	})
+	if !strings.Contains(actRec2.Body.String(), "count=2") {
+		t.Fatalf("expected count=2, got %q", actRec2.Body.String())
+	}
+}
+
+func TestHandler_ClientState_Rejections(t *testing.T) {
+	s := newTestClientStateStore(t)
+	h := NewHandler(s)
+	kind := (&clientStateComp{}).GetKind()
+
+	token, err := s.Encode(newClientStateComp(1))
+	if err != nil {
+		t.Fatalf("Encode: %v", err)
+	}
+	cases := []struct {
+		name   string
+		id     string
+		state  string
+		status int
+	}{
+		{"missing", "cs-id", "", http.StatusBadRequest},
+		{"tampered", "cs-id", tamperToken(token), http.StatusForbidden},
+		{"id mismatch", "other-id", token, http.StatusForbidden},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := postForm(h, url.Values{
+				FormComponentKind:  {kind},
+				FormComponentID:    {tc.id},
+				FormAction:         {"inc"},
+				FormComponentState: {tc.state},
+			})
+			if rec.Code != tc.status {
+				t.Fatalf("expected %d, got %d %q", tc.status, rec.Code, rec.Body.String())
+			}
+		})
+	}
+}
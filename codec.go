@@ -0,0 +1,186 @@
+package liveflux
+
+import (
+	"bytes"
+	"encoding/gob"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// StateCodec converts component snapshots to and from bytes.
+// Stores that persist outside the process (Redis, SQL, cookies, ...) use a
+// codec together with Dehydrate/Hydrate instead of holding live pointers.
+type StateCodec interface {
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// JSONCodec encodes snapshots with encoding/json.
+type JSONCodec struct{}
+
+// Marshal implements StateCodec.
+func (JSONCodec) Marshal(v any) ([]byte, error) { return json.Marshal(v) }
+
+// Unmarshal implements StateCodec.
+func (JSONCodec) Unmarshal(data []byte, v any) error { return json.Unmarshal(data, v) }
+
+// GobCodec encodes snapshots with encoding/gob. Concrete types stored in
+// interface-typed fields must be registered with gob.Register.
+type GobCodec struct{}
+
+// Marshal implements StateCodec.
+func (GobCodec) Marshal(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Unmarshal implements StateCodec.
+func (GobCodec) Unmarshal(data []byte, v any) error {
+	return gob.NewDecoder(bytes.NewReader(data)).Decode(v)
+}
+
+// DefaultStateCodec is used when a nil codec is passed to Dehydrate or Hydrate.
+var DefaultStateCodec StateCodec = JSONCodec{}
+
+// snapshot is the envelope written by Dehydrate.
+type snapshot struct {
+	Kind     string
+	ID       string
+	State    []byte
+	Children map[string]childRef `json:",omitempty"`
+	Poll     string              `json:",omitempty"`
+}
+
+// Dehydrate encodes the component's kind, ID and exported fields using codec.
+// Fields tagged `flux:"-"` and fields that cannot be serialized (funcs,
+// channels) are skipped. The embedded Base contributes only kind, ID, the
+// keys of its nested children (see Child) and its poll directive.
+func Dehydrate(c ComponentInterface, codec StateCodec) ([]byte, error) {
+	if c == nil {
+		return nil, fmt.Errorf("liveflux: Dehydrate requires non-nil component")
+	}
+	if codec == nil {
+		codec = DefaultStateCodec
+	}
+
+	snap := snapshot{Kind: c.GetKind(), ID: c.GetID()}
+	if snap.Kind == "" {
+		return nil, fmt.Errorf("liveflux: Dehydrate requires a component with a kind")
+	}
+	if owner, ok := c.(childOwner); ok {
+		snap.Children = owner.childRefs()
+	}
+	if p, ok := c.(poller); ok {
+		snap.Poll = p.pollDirective()
+	}
+
+	v := reflect.ValueOf(c)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("liveflux: component '%s' must be a pointer to a struct", snap.Kind)
+	}
+
+	layout := stateLayoutOf(v.Elem().Type())
+	if layout.typ != nil {
+		state := reflect.New(layout.typ).Elem()
+		for i, idx := range layout.fields {
+			state.Field(i).Set(v.Elem().Field(idx))
+		}
+		data, err := codec.Marshal(state.Addr().Interface())
+		if err != nil {
+			return nil, fmt.Errorf("liveflux: encode state for '%s': %w", snap.Kind, err)
+		}
+		snap.State = data
+	}
+
+	return codec.Marshal(&snap)
+}
+
+// Hydrate decodes data produced by Dehydrate and rebuilds the component
+// through the registry. The component kind must be registered.
+func Hydrate(data []byte, codec StateCodec) (ComponentInterface, error) {
+	if codec == nil {
+		codec = DefaultStateCodec
+	}
+
+	var snap snapshot
+	if err := codec.Unmarshal(data, &snap); err != nil {
+		return nil, fmt.Errorf("liveflux: decode snapshot: %w", err)
+	}
+
+	c, err := newByKind(snap.Kind)
+	if err != nil {
+		return nil, err
+	}
+	c.SetID(snap.ID)
+	if owner, ok := c.(childOwner); ok {
+		for key, ref := range snap.Children {
+			owner.setChildRef(key, ref)
+		}
+	}
+	if p, ok := c.(poller); ok {
+		p.setPollDirective(snap.Poll)
+	}
+
+	v := reflect.ValueOf(c).Elem()
+	if v.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("liveflux: component '%s' must be a pointer to a struct", snap.Kind)
+	}
+
+	layout := stateLayoutOf(v.Type())
+	if layout.typ != nil && len(snap.State) > 0 {
+		state := reflect.New(layout.typ)
+		if err := codec.Unmarshal(snap.State, state.Interface()); err != nil {
+			return nil, fmt.Errorf("liveflux: decode state for '%s': %w", snap.Kind, err)
+		}
+		for i, idx := range layout.fields {
+			v.Field(idx).Set(state.Elem().Field(i))
+		}
+	}
+
+	return c, nil
+}
+
+// stateLayout describes the serializable subset of a component struct.
+type stateLayout struct {
+	// typ is a generated struct holding only serializable fields; nil if none.
+	typ reflect.Type
+	// fields maps generated field positions to the component's field indexes.
+	fields []int
+}
+
+var stateLayouts sync.Map // map[reflect.Type]stateLayout
+
+var baseType = reflect.TypeOf(Base{})
+
+// stateLayoutOf returns the cached serializable layout for a component struct type.
+func stateLayoutOf(t reflect.Type) stateLayout {
+	if cached, ok := stateLayouts.Load(t); ok {
+		return cached.(stateLayout)
+	}
+
+	var layout stateLayout
+	var fields []reflect.StructField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() || f.Type == baseType || f.Tag.Get("flux") == "-" {
+			continue
+		}
+		switch f.Type.Kind() {
+		case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+			continue
+		}
+		fields = append(fields, reflect.StructField{Name: f.Name, Type: f.Type, Tag: f.Tag})
+		layout.fields = append(layout.fields, i)
+	}
+	if len(fields) > 0 {
+		layout.typ = reflect.StructOf(fields)
+	}
+
+	stateLayouts.Store(t, layout)
+	return layout
+}
@@ -0,0 +1,131 @@
+package liveflux
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dracory/hb"
+)
+
+type codecItem struct {
+	Name string
+	Qty  int
+}
+
+type codecComp struct {
+	Base
+	Title    string
+	Count    int
+	Tags     []string
+	Items    []codecItem
+	Meta     map[string]string
+	Secret   string `flux:"-"`
+	OnChange func()
+	internal int
+}
+
+func (c *codecComp) GetKind() string                                  { return "test.codec-comp" }
+func (c *codecComp) Mount(context.Context, map[string]string) error   { return nil }
+func (c *codecComp) Handle(context.Context, string, url.Values) error { return nil }
+func (c *codecComp) Render(context.Context) hb.TagInterface           { return hb.Div() }
+
+func newCodecComp(t *testing.T) *codecComp {
+	t.Helper()
+	registerTestKind(t, &codecComp{})
+	c := &codecComp{
+		Title:    "hello",
+		Count:    7,
+		Tags:     []string{"a", "b"},
+		Items:    []codecItem{{Name: "x", Qty: 2}},
+		Meta:     map[string]string{"k": "v"},
+		Secret:   "do-not-persist",
+		OnChange: func() {},
+		internal: 42,
+	}
+	c.SetKind(c.GetKind())
+	c.SetID("codec-id")
+	return c
+}
+
+func TestDehydrateHydrate_RoundTrip(t *testing.T) {
+	codecs := map[string]StateCodec{"json": JSONCodec{}, "gob": GobCodec{}}
+	for name, codec := range codecs {
+		t.Run(name, func(t *testing.T) {
+			c := newCodecComp(t)
+
+			data, err := Dehydrate(c, codec)
+			if err != nil {
+				t.Fatalf("Dehydrate: %v", err)
+			}
+
+			got, err := Hydrate(data, codec)
+			if err != nil {
+				t.Fatalf("Hydrate: %v", err)
+			}
+
+			out, ok := got.(*codecComp)
+			if !ok {
+				t.Fatalf("expected *codecComp, got %T", got)
+			}
+			if out.GetKind() != "test.codec-comp" || out.GetID() != "codec-id" {
+				t.Fatalf("kind/id not restored: %q %q", out.GetKind(), out.GetID())
+			}
+			if out.Title != "hello" || out.Count != 7 {
+				t.Fatalf("scalar fields not restored: %+v", out)
+			}
+			if len(out.Tags) != 2 || out.Tags[1] != "b" {
+				t.Fatalf("slice not restored: %v", out.Tags)
+			}
+			if len(out.Items) != 1 || out.Items[0].Qty != 2 {
+				t.Fatalf("struct slice not restored: %v", out.Items)
+			}
+			if out.Meta["k"] != "v" {
+				t.Fatalf("map not restored: %v", out.Meta)
+			}
+			if out.Secret != "" {
+				t.Fatalf("flux:\"-\" field should be skipped, got %q", out.Secret)
+			}
+			if out.OnChange != nil || out.internal != 0 {
+				t.Fatalf("func and unexported fields should be skipped")
+			}
+		})
+	}
+}
+
+func TestDehydrate_NilCodecUsesDefault(t *testing.T) {
+	c := newCodecComp(t)
+	data, err := Dehydrate(c, nil)
+	if err != nil {
+		t.Fatalf("Dehydrate: %v", err)
+	}
+	if !strings.Contains(string(data), `"Kind":"test.codec-comp"`) {
+		t.Fatalf("expected JSON snapshot, got %s", data)
+	}
+	if _, err := Hydrate(data, nil); err != nil {
+		t.Fatalf("Hydrate: %v", err)
+	}
+}
+
+func TestDehydrate_Errors(t *testing.T) {
+	if _, err := Dehydrate(nil, nil); err == nil {
+		t.Fatalf("expected error for nil component")
+	}
+	if _, err := Dehydrate(&handlerComp{}, nil); err == nil {
+		t.Fatalf("expected error for component without kind")
+	}
+}
+
+func TestHydrate_UnregisteredKind(t *testing.T) {
+	data := []byte(`{"Kind":"test.codec-missing","ID":"x"}`)
+	if _, err := Hydrate(data, JSONCodec{}); err == nil || !strings.Contains(err.Error(), "not registered") {
+		t.Fatalf("expected not registered error, got %v", err)
+	}
+}
+
+func TestHydrate_InvalidData(t *testing.T) {
+	if _, err := Hydrate([]byte("not-json"), JSONCodec{}); err == nil {
+		t.Fatalf("expected decode error")
+	}
+}
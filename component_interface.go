@@ -16,6 +16,9 @@ import (
 // - Handle(ctx, action, form) on user actions
 // - Render(ctx) returns the current HTML (hb.Tag)
 //
+// Optional hooks (AfterHydrater, BeforeDehydrater, BeforeActioner,
+// AfterActioner, BeforeRenderer, Unmounter) are described in lifecycle.go.
+//
 // Kind is assigned by the framework when the instance is created from the registry.
 // ID and SetID are used by the framework to track component instances and are
 // assigned on mount.
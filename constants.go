@@ -5,12 +5,16 @@ const (
 	DataFluxDispatchTo    = "data-flux-dispatch-to"
 	DataFluxComponentKind = "data-flux-component-kind"
 	DataFluxComponentID   = "data-flux-component-id"
+	DataFluxError         = "data-flux-error"
 	DataFluxExclude       = "data-flux-exclude"
 	DataFluxInclude       = "data-flux-include"
 	DataFluxIndicator     = "data-flux-indicator"
 	DataFluxSelect        = "data-flux-select"
+	DataFluxModel         = "data-flux-model"
 	DataFluxMount         = "data-flux-mount"
 	DataFluxParam         = "data-flux-param"
+	DataFluxPoll          = "data-flux-poll"
+	DataFluxState         = "data-flux-state"
 	DataFluxSubmit        = "data-flux-submit"
 	DataFluxWS            = "data-flux-ws"
 	DataFluxWSURL         = "data-flux-ws-url"
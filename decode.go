@@ -0,0 +1,349 @@
+package liveflux
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FieldError describes a submitted value that failed decoding or validation.
+// Field is the form path, e.g. "email" or "items.0.qty".
+type FieldError struct {
+	Field   string
+	Message string
+}
+
+// ValidationErrors is returned when one or more fields are invalid.
+type ValidationErrors []FieldError
+
+// Error implements error.
+func (e ValidationErrors) Error() string {
+	parts := make([]string, len(e))
+	for i, fe := range e {
+		parts[i] = fe.Field + ": " + fe.Message
+	}
+	return "liveflux: invalid input: " + strings.Join(parts, "; ")
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	textUnmarshalType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// DecodeForm decodes form values into the struct pointed to by dst.
+//
+// Field names come from the `flux:"name"` tag, or the Go field name when no
+// tag is set, and are matched case-insensitively; `flux:"-"` skips a field.
+// Nested structs use dotted or bracketed keys ("address.city",
+// "address[city]"), slices of scalars use repeated keys ("tags", "tags[]"),
+// and slices of structs use indexes ("items.0.name", "items[0][name]").
+// Supported leaf types are strings, bools, ints, uints, floats, time.Time
+// (RFC 3339 or 2006-01-02) and encoding.TextUnmarshaler. Empty values leave
+// fields at their zero value.
+//
+// Values that cannot be converted are reported as ValidationErrors.
+func DecodeForm(values url.Values, dst any) error {
+	rv := reflect.ValueOf(dst)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() || rv.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("liveflux: DecodeForm requires a non-nil pointer to a struct, got %T", dst)
+	}
+	d := formDecoder{values: normalizeFormKeys(values)}
+	d.decodeStruct("", "", rv.Elem())
+	if len(d.errs) > 0 {
+		return d.errs
+	}
+	return nil
+}
+
+// DecodeJSON decodes a JSON object (e.g. WebSocketMessage.Data) into dst
+// with the same rules as DecodeForm. The object is flattened into form
+// values first, so tags, nesting and error reporting behave identically.
+func DecodeJSON(data []byte, dst any) error {
+	values, err := jsonToValues(data)
+	if err != nil {
+		return err
+	}
+	return DecodeForm(values, dst)
+}
+
+type formDecoder struct {
+	values map[string][]string // lower-cased, dotted keys
+	errs   ValidationErrors
+}
+
+// decodeStruct fills v. path is the user-facing field path, key its
+// lower-cased lookup form.
+func (d *formDecoder) decodeStruct(path, key string, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		tag := field.Tag.Get("flux")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			d.decodeStruct(path, key, v.Field(i)) // embedded fields are promoted
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		d.decodeValue(joinPath(path, name), joinPath(key, strings.ToLower(name)), v.Field(i))
+	}
+}
+
+func (d *formDecoder) decodeValue(path, key string, v reflect.Value) {
+	t := v.Type()
+	if t == uploadedFileType || (t.Kind() == reflect.Slice && t.Elem() == uploadedFileType) {
+		return // files are bound from the request, see bindUploads
+	}
+
+	if isLeafType(t) {
+		if vals, ok := d.values[key]; ok && len(vals) > 0 {
+			d.setLeaf(path, v, vals[0])
+		}
+		return
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		if !d.present(key) {
+			return
+		}
+		if v.IsNil() {
+			v.Set(reflect.New(t.Elem()))
+		}
+		d.decodeValue(path, key, v.Elem())
+	case reflect.Struct:
+		d.decodeStruct(path, key, v)
+	case reflect.Slice:
+		d.decodeSlice(path, key, v)
+	}
+}
+
+func (d *formDecoder) decodeSlice(path, key string, v reflect.Value) {
+	elem := v.Type().Elem()
+	if isLeafType(elem) {
+		vals, ok := d.values[key]
+		if !ok {
+			return
+		}
+		out := reflect.MakeSlice(v.Type(), 0, len(vals))
+		for i, raw := range vals {
+			ev := reflect.New(elem).Elem()
+			if d.setLeaf(path+"."+strconv.Itoa(i), ev, raw) {
+				out = reflect.Append(out, ev)
+			}
+		}
+		v.Set(out)
+		return
+	}
+
+	indexes := d.indexes(key)
+	if len(indexes) == 0 {
+		return
+	}
+	out := reflect.MakeSlice(v.Type(), 0, len(indexes))
+	for _, idx := range indexes {
+		ev := reflect.New(elem).Elem()
+		n := strconv.Itoa(idx)
+		d.decodeValue(path+"."+n, key+"."+n, ev)
+		out = reflect.Append(out, ev)
+	}
+	v.Set(out)
+}
+
+// setLeaf converts raw into v, recording a field error on failure.
+func (d *formDecoder) setLeaf(path string, v reflect.Value, raw string) bool {
+	if err := setLeaf(v, strings.TrimSpace(raw)); err != nil {
+		d.errs = append(d.errs, FieldError{Field: path, Message: err.Error()})
+		return false
+	}
+	return true
+}
+
+// present reports whether any value exists at key or below it.
+func (d *formDecoder) present(key string) bool {
+	if _, ok := d.values[key]; ok {
+		return true
+	}
+	prefix := key + "."
+	for k := range d.values {
+		if strings.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// indexes returns the sorted numeric indexes submitted below key.
+func (d *formDecoder) indexes(key string) []int {
+	prefix := key + "."
+	seen := map[int]bool{}
+	for k := range d.values {
+		rest, ok := strings.CutPrefix(k, prefix)
+		if !ok {
+			continue
+		}
+		head, _, _ := strings.Cut(rest, ".")
+		if n, err := strconv.Atoi(head); err == nil && n >= 0 {
+			seen[n] = true
+		}
+	}
+	out := make([]int, 0, len(seen))
+	for n := range seen {
+		out = append(out, n)
+	}
+	sort.Ints(out)
+	return out
+}
+
+func isLeafType(t reflect.Type) bool {
+	if t == timeType || reflect.PointerTo(t).Implements(textUnmarshalType) {
+		return true
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+// setLeaf converts raw into v. Empty input leaves v untouched.
+func setLeaf(v reflect.Value, raw string) error {
+	if tu, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok && v.Type() != timeType {
+		if raw == "" {
+			return nil
+		}
+		if err := tu.UnmarshalText([]byte(raw)); err != nil {
+			return errors.New("is invalid")
+		}
+		return nil
+	}
+	if v.Kind() == reflect.String {
+		v.SetString(raw)
+		return nil
+	}
+	if raw == "" {
+		return nil
+	}
+
+	switch {
+	case v.Type() == timeType:
+		for _, layout := range []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02"} {
+			if tm, err := time.Parse(layout, raw); err == nil {
+				v.Set(reflect.ValueOf(tm))
+				return nil
+			}
+		}
+		return errors.New("must be a valid date")
+	case v.Kind() == reflect.Bool:
+		switch strings.ToLower(raw) {
+		case "1", "true", "on", "yes":
+			v.SetBool(true)
+		case "0", "false", "off", "no":
+			v.SetBool(false)
+		default:
+			return errors.New("must be true or false")
+		}
+	case v.CanInt():
+		n, err := strconv.ParseInt(raw, 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be a whole number")
+		}
+		v.SetInt(n)
+	case v.CanUint():
+		n, err := strconv.ParseUint(raw, 10, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be a positive whole number")
+		}
+		v.SetUint(n)
+	case v.CanFloat():
+		n, err := strconv.ParseFloat(raw, v.Type().Bits())
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		v.SetFloat(n)
+	}
+	return nil
+}
+
+// normalizeFormKeys lower-cases keys and rewrites bracket notation to dots:
+// "Items[0][Name]" -> "items.0.name", "tags[]" -> "tags".
+func normalizeFormKeys(values url.Values) map[string][]string {
+	out := make(map[string][]string, len(values))
+	for k, vals := range values {
+		nk := strings.ToLower(k)
+		nk = strings.ReplaceAll(nk, "][", ".")
+		nk = strings.ReplaceAll(nk, "[", ".")
+		nk = strings.ReplaceAll(nk, "]", "")
+		nk = strings.TrimSuffix(nk, ".")
+		out[nk] = append(out[nk], vals...)
+	}
+	return out
+}
+
+// jsonToValues flattens a JSON object into form values: nested objects
+// become dotted keys, arrays of scalars repeated keys and arrays of objects
+// indexed keys.
+func jsonToValues(data []byte) (url.Values, error) {
+	values := url.Values{}
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 || bytes.Equal(data, []byte("null")) {
+		return values, nil
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var obj map[string]any
+	if err := dec.Decode(&obj); err != nil {
+		return nil, fmt.Errorf("liveflux: payload must be a JSON object: %w", err)
+	}
+	flattenJSON("", obj, values)
+	return values, nil
+}
+
+func flattenJSON(prefix string, v any, out url.Values) {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, val := range x {
+			flattenJSON(joinPath(prefix, k), val, out)
+		}
+	case []any:
+		for i, val := range x {
+			switch val.(type) {
+			case map[string]any, []any:
+				flattenJSON(prefix+"."+strconv.Itoa(i), val, out)
+			default:
+				flattenJSON(prefix, val, out)
+			}
+		}
+	case string:
+		out.Add(prefix, x)
+	case json.Number:
+		out.Add(prefix, x.String())
+	case bool:
+		out.Add(prefix, strconv.FormatBool(x))
+	}
+}
+
+func joinPath(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "." + name
+}
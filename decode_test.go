@@ -0,0 +1,185 @@
+package liveflux
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+type decodeAddress struct {
+	City string `flux:"city"`
+	Zip  string `flux:"zip"`
+}
+
+type decodeItem struct {
+	Name string `flux:"name"`
+	Qty  int    `flux:"qty"`
+}
+
+type decodeInput struct {
+	Email    string        `flux:"email"`
+	Age      int           `flux:"age"`
+	Score    float64       `flux:"score"`
+	Active   bool          `flux:"active"`
+	Born     time.Time     `flux:"born"`
+	Nickname string        // matched by field name
+	Address  decodeAddress `flux:"address"`
+	Billing  *decodeAddress
+	Tags     []string     `flux:"tags"`
+	IDs      []int        `flux:"ids"`
+	Items    []decodeItem `flux:"items"`
+	Secret   string       `flux:"-"`
+}
+
+func TestDecodeForm(t *testing.T) {
+	values := url.Values{
+		"email":           {"a@example.com"},
+		"age":             {"42"},
+		"score":           {"9.5"},
+		"active":          {"on"},
+		"born":            {"1990-05-17"},
+		"nickname":        {"al"},
+		"address.city":    {"Sofia"},
+		"address[zip]":    {"1000"},
+		"tags[]":          {"x", "y"},
+		"ids":             {"1", "2"},
+		"items[1][name]":  {"second"},
+		"items.0.name":    {"first"},
+		"items[0][qty]":   {"3"},
+		"Secret":          {"nope"},
+		"unrelated_field": {"ignored"},
+	}
+
+	var in decodeInput
+	if err := DecodeForm(values, &in); err != nil {
+		t.Fatalf("DecodeForm: %v", err)
+	}
+
+	want := decodeInput{
+		Email:    "a@example.com",
+		Age:      42,
+		Score:    9.5,
+		Active:   true,
+		Born:     time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC),
+		Nickname: "al",
+		Address:  decodeAddress{City: "Sofia", Zip: "1000"},
+		Tags:     []string{"x", "y"},
+		IDs:      []int{1, 2},
+		Items:    []decodeItem{{Name: "first", Qty: 3}, {Name: "second"}},
+	}
+	if !reflect.DeepEqual(in, want) {
+		t.Fatalf("unexpected result:\n got %+v\nwant %+v", in, want)
+	}
+}
+
+func TestDecodeForm_AllocatesPointersOnlyWhenPresent(t *testing.T) {
+	var in decodeInput
+	if err := DecodeForm(url.Values{"billing.city": {"Varna"}}, &in); err != nil {
+		t.Fatalf("DecodeForm: %v", err)
+	}
+	if in.Billing == nil || in.Billing.City != "Varna" {
+		t.Fatalf("expected billing to be allocated, got %+v", in.Billing)
+	}
+
+	in = decodeInput{}
+	if err := DecodeForm(url.Values{"email": {"x"}}, &in); err != nil {
+		t.Fatalf("DecodeForm: %v", err)
+	}
+	if in.Billing != nil {
+		t.Fatalf("expected billing to stay nil")
+	}
+}
+
+func TestDecodeForm_ConversionErrorsAreValidationErrors(t *testing.T) {
+	values := url.Values{
+		"age":          {"old"},
+		"active":       {"maybe"},
+		"ids":          {"1", "two"},
+		"items.0.qty":  {"many"},
+		"born":         {"yesterday"},
+		"email":        {"still decoded"},
+		"address.city": {""},
+	}
+	var in decodeInput
+	err := DecodeForm(values, &in)
+
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("expected ValidationErrors, got %T %v", err, err)
+	}
+	got := map[string]string{}
+	for _, fe := range verrs {
+		got[fe.Field] = fe.Message
+	}
+	for _, field := range []string{"age", "active", "born", "ids.1", "items.0.qty"} {
+		if got[field] == "" {
+			t.Errorf("expected an error for %q, got %v", field, got)
+		}
+	}
+	if len(verrs) != 5 {
+		t.Fatalf("expected 5 field errors, got %v", verrs)
+	}
+	if in.Email != "still decoded" {
+		t.Fatalf("expected valid fields to be decoded, got %q", in.Email)
+	}
+}
+
+func TestDecodeForm_RejectsNonStructDestination(t *testing.T) {
+	var n int
+	for _, dst := range []any{nil, decodeInput{}, &n, (*decodeInput)(nil)} {
+		err := DecodeForm(url.Values{}, dst)
+		var verrs ValidationErrors
+		if err == nil || errors.As(err, &verrs) {
+			t.Fatalf("DecodeForm(%T): expected a usage error, got %v", dst, err)
+		}
+	}
+}
+
+func TestDecodeJSON(t *testing.T) {
+	data := []byte(`{
+		"email": "a@example.com",
+		"age": 42,
+		"active": true,
+		"address": {"city": "Sofia"},
+		"tags": ["x", "y"],
+		"ids": [1, 2],
+		"items": [{"name": "first", "qty": 3}, {"name": "second"}]
+	}`)
+	var in decodeInput
+	if err := DecodeJSON(data, &in); err != nil {
+		t.Fatalf("DecodeJSON: %v", err)
+	}
+	if in.Email != "a@example.com" || in.Age != 42 || !in.Active || in.Address.City != "Sofia" {
+		t.Fatalf("unexpected scalars: %+v", in)
+	}
+	if !reflect.DeepEqual(in.Tags, []string{"x", "y"}) || !reflect.DeepEqual(in.IDs, []int{1, 2}) {
+		t.Fatalf("unexpected slices: %v %v", in.Tags, in.IDs)
+	}
+	if !reflect.DeepEqual(in.Items, []decodeItem{{Name: "first", Qty: 3}, {Name: "second"}}) {
+		t.Fatalf("unexpected items: %+v", in.Items)
+	}
+
+	var verrs ValidationErrors
+	if err := DecodeJSON([]byte(`{"age": "old"}`), &in); !errors.As(err, &verrs) || verrs[0].Field != "age" {
+		t.Fatalf("expected a validation error for age, got %v", err)
+	}
+	if err := DecodeJSON([]byte(`[1, 2]`), &in); err == nil {
+		t.Fatalf("expected an error for a non-object payload")
+	}
+	if err := DecodeJSON(nil, &in); err != nil {
+		t.Fatalf("expected empty payload to decode, got %v", err)
+	}
+}
+
+func TestWebSocketMessage_Values(t *testing.T) {
+	msg := &WebSocketMessage{Data: []byte(`{"value": 7, "tags": ["a", "b"]}`)}
+	values, err := msg.Values()
+	if err != nil {
+		t.Fatalf("Values: %v", err)
+	}
+	if values.Get("value") != "7" || len(values["tags"]) != 2 {
+		t.Fatalf("unexpected values: %v", values)
+	}
+}
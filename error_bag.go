@@ -0,0 +1,93 @@
+package liveflux
+
+var (
+	// InvalidFieldClass is returned by Base.InvalidClass for fields with
+	// errors. Defaults to Bootstrap's class.
+	InvalidFieldClass = "is-invalid"
+
+	// ErrorMessageClass is the class of messages rendered by Base.ErrorFor.
+	ErrorMessageClass = "invalid-feedback d-block"
+)
+
+// ErrorBag holds validation messages per field, in insertion order. The
+// zero value is ready to use and all methods are safe on a nil bag.
+type ErrorBag struct {
+	fields   []string
+	messages map[string][]string
+}
+
+// NewErrorBag returns a bag filled from errs.
+func NewErrorBag(errs ValidationErrors) *ErrorBag {
+	bag := &ErrorBag{}
+	bag.Merge(errs)
+	return bag
+}
+
+// Add appends a message for field.
+func (e *ErrorBag) Add(field, message string) {
+	if e.messages == nil {
+		e.messages = map[string][]string{}
+	}
+	if _, ok := e.messages[field]; !ok {
+		e.fields = append(e.fields, field)
+	}
+	e.messages[field] = append(e.messages[field], message)
+}
+
+// Merge adds every FieldError in errs.
+func (e *ErrorBag) Merge(errs ValidationErrors) {
+	for _, fe := range errs {
+		e.Add(fe.Field, fe.Message)
+	}
+}
+
+// Has reports whether field has at least one message.
+func (e *ErrorBag) Has(field string) bool {
+	return e != nil && len(e.messages[field]) > 0
+}
+
+// First returns the first message for field, or "".
+func (e *ErrorBag) First(field string) string {
+	if !e.Has(field) {
+		return ""
+	}
+	return e.messages[field][0]
+}
+
+// Get returns all messages for field.
+func (e *ErrorBag) Get(field string) []string {
+	if e == nil {
+		return nil
+	}
+	return append([]string(nil), e.messages[field]...)
+}
+
+// Fields returns the fields with errors, in the order they were added.
+func (e *ErrorBag) Fields() []string {
+	if e == nil {
+		return nil
+	}
+	return append([]string(nil), e.fields...)
+}
+
+// Any reports whether the bag holds any message.
+func (e *ErrorBag) Any() bool {
+	return e != nil && len(e.fields) > 0
+}
+
+// Clear removes all messages.
+func (e *ErrorBag) Clear() {
+	if e == nil {
+		return
+	}
+	e.fields = nil
+	e.messages = nil
+}
+
+// errorBagOf returns the component's bag, or nil if it does not embed Base.
+func errorBagOf(c ComponentInterface) *ErrorBag {
+	if eb, ok := c.(interface{ Errors() *ErrorBag }); ok {
+		return eb.Errors()
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package liveflux
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestErrorBag(t *testing.T) {
+	bag := NewErrorBag(ValidationErrors{
+		{Field: "email", Message: "is required"},
+		{Field: "name", Message: "is too short"},
+	})
+	bag.Add("email", "is invalid")
+
+	if !bag.Any() || !bag.Has("email") || bag.Has("missing") {
+		t.Fatalf("unexpected Has/Any results")
+	}
+	if bag.First("email") != "is required" || len(bag.Get("email")) != 2 {
+		t.Fatalf("unexpected messages: %v", bag.Get("email"))
+	}
+	if strings.Join(bag.Fields(), ",") != "email,name" {
+		t.Fatalf("expected insertion order, got %v", bag.Fields())
+	}
+
+	bag.Clear()
+	if bag.Any() || bag.Has("email") {
+		t.Fatalf("expected empty bag after Clear")
+	}
+
+	var nilBag *ErrorBag
+	if nilBag.Any() || nilBag.Has("x") || nilBag.First("x") != "" || nilBag.Fields() != nil {
+		t.Fatalf("expected nil bag to be empty")
+	}
+	nilBag.Clear()
+}
+
+func TestBase_ErrorHelpers(t *testing.T) {
+	var b Base
+	if b.HasError("email") || b.ErrorFor("email") != nil || b.InvalidClass("email") != "" {
+		t.Fatalf("expected no errors on a fresh Base")
+	}
+
+	b.AddError("email", "is <taken>")
+	if !b.HasError("email") || b.InvalidClass("email") != InvalidFieldClass {
+		t.Fatalf("expected email to be invalid")
+	}
+	html := b.ErrorFor("email").ToHTML()
+	if !strings.Contains(html, `data-flux-error="email"`) || !strings.Contains(html, ErrorMessageClass) {
+		t.Fatalf("unexpected error markup: %s", html)
+	}
+	if !strings.Contains(html, "is &lt;taken&gt;") {
+		t.Fatalf("expected message to be escaped: %s", html)
+	}
+
+	b.ClearErrors()
+	if b.HasError("email") {
+		t.Fatalf("expected ClearErrors to reset the bag")
+	}
+}
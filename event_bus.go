@@ -0,0 +1,237 @@
+package liveflux
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// FormLiveComponents carries the other component instances on the page as
+// comma-separated kind:id pairs, both parts URL-escaped. Events dispatched
+// by an action are routed to their On* methods server-side (see
+// routeEvents).
+const FormLiveComponents = "liveflux_components"
+
+const (
+	// maxRoutedComponents caps the instances loaded for one request.
+	maxRoutedComponents = 64
+	// eventLockTimeout bounds the wait for another instance's lock, so two
+	// components dispatching to each other give up instead of deadlocking.
+	eventLockTimeout = 2 * time.Second
+)
+
+// routeEvents delivers the events queued by the source component during the
+// action to the On* methods (see RegisterEventListeners) of the source itself
+// and of the live instances posted in FormLiveComponents, honouring the
+// __target (kind) and __target_id metadata set by DispatchToKind,
+// DispatchToKindAndID and DispatchSelf.
+//
+// Each posted instance must pass the checks of an action request: it is
+// loaded with the request's context (so a SessionStore only yields the
+// session's own instances) and its posted kind is verified per KindCheck.
+// Instances whose listeners ran are persisted and returned as replace
+// fragments, so they are updated by the same response. The source is
+// rendered by the caller as usual. Events delivered to a listener here are
+// taken off the client's queue, so client subscriptions do not run the same
+// listener again; the others, and events dispatched from On* methods, are
+// sent to the client without being routed again. Routing is skipped with a
+// ClientStateStore, which holds no other instances.
+func (h *Handler) routeEvents(ctx context.Context, r *http.Request, source ComponentInterface) []TargetFragment {
+	if _, clientState := h.Store.(*ClientStateStore); clientState {
+		return nil
+	}
+	ea, ok := source.(EventAware)
+	if !ok {
+		return nil
+	}
+	dispatcher := ea.GetEventDispatcher()
+	if dispatcher == nil || !dispatcher.HasEvents() {
+		return nil
+	}
+	events := append([]Event(nil), dispatcher.events...)
+	delivered := make([]bool, len(events))
+
+	// Listeners on the source run in place; the caller renders it anyway
+	listeners, addressed := eventListeners(source, events)
+	if err := triggerEvents(ctx, listeners, events, addressed); err != nil {
+		fmt.Printf("liveflux: event listener error in '%s': %v\n", source.GetKind(), err)
+	} else {
+		markDelivered(delivered, addressed)
+	}
+
+	var fragments []TargetFragment
+	for _, target := range liveComponents(r, source.GetID()) {
+		if fragment, ok := h.routeEventsTo(ctx, target, events, delivered, dispatcher); ok {
+			fragments = append(fragments, fragment)
+		}
+	}
+
+	// Listeners may have queued more events behind the routed ones
+	pending := make([]Event, 0, len(dispatcher.events))
+	for i, event := range dispatcher.events {
+		if i >= len(delivered) || !delivered[i] {
+			pending = append(pending, event)
+		}
+	}
+	dispatcher.events = pending
+	return fragments
+}
+
+// liveComponent is an instance posted in FormLiveComponents.
+type liveComponent struct {
+	kind, id string
+}
+
+// routeEventsTo loads the target instance, runs its listeners for events
+// and renders it. Events the listeners dispatch are queued on dispatcher,
+// and the events they received are marked in delivered. Returns false if no
+// listener ran. The target is only locked when it listens to one of the
+// events.
+func (h *Handler) routeEventsTo(ctx context.Context, target liveComponent, events []Event, delivered []bool, dispatcher *EventDispatcher) (TargetFragment, bool) {
+	if _, _, addressed := h.routeTarget(ctx, target, events); len(addressed) == 0 {
+		return TargetFragment{}, false
+	}
+
+	lockCtx, cancel := context.WithTimeout(ctx, eventLockTimeout)
+	defer cancel()
+	unlock, err := h.lockComponent(lockCtx, target.id)
+	if err != nil {
+		fmt.Printf("liveflux: event lock error for '%s': %v\n", target.id, err)
+		return TargetFragment{}, false
+	}
+	defer unlock()
+
+	// Load again under the lock; another request may have changed it
+	c, listeners, addressed := h.routeTarget(ctx, target, events)
+	if len(addressed) == 0 {
+		return TargetFragment{}, false
+	}
+	if err := afterHydrate(ctx, c); err != nil {
+		fmt.Printf("liveflux: hydrate error: %v\n", err)
+		return TargetFragment{}, false
+	}
+	if err := triggerEvents(ctx, listeners, events, addressed); err != nil {
+		fmt.Printf("liveflux: event listener error in '%s': %v\n", c.GetKind(), err)
+		return TargetFragment{}, false
+	}
+	if err := h.save(ctx, c); err != nil {
+		fmt.Printf("liveflux: dehydrate error: %v\n", err)
+		return TargetFragment{}, false
+	}
+	markDelivered(delivered, addressed)
+
+	// Events dispatched by the listeners go to the client with the source's
+	if ea, ok := c.(EventAware); ok && ea.GetEventDispatcher() != nil {
+		dispatcher.events = append(dispatcher.events, ea.GetEventDispatcher().TakeEvents()...)
+	}
+	return TargetFragment{
+		Selector:            componentSelector(c),
+		Content:             render(contextWithHeld(ctx, c), c),
+		SwapMode:            SwapReplace,
+		NoComponentMetadata: true,
+	}, true
+}
+
+// routeTarget loads target with the request's context and returns it with
+// its listeners and the indexes of the events it receives. None are returned
+// if the instance is missing or its posted kind is not allowed.
+func (h *Handler) routeTarget(ctx context.Context, target liveComponent, events []Event) (ComponentInterface, *EventDispatcher, []int) {
+	c, ok := h.storeGet(ctx, target.id)
+	if !ok || c == nil || !h.kindAllowed(ctx, target.kind, c) {
+		return nil, nil, nil
+	}
+	listeners, addressed := eventListeners(c, events)
+	return c, listeners, addressed
+}
+
+// eventListeners registers c's On* methods and returns them with the
+// indexes of the events addressed to c that it listens to.
+func eventListeners(c ComponentInterface, events []Event) (*EventDispatcher, []int) {
+	listeners := NewEventDispatcher()
+	RegisterEventListeners(c, listeners)
+	var addressed []int
+	for i, event := range events {
+		if len(listeners.listeners[event.Name]) > 0 && eventTargets(event, c) {
+			addressed = append(addressed, i)
+		}
+	}
+	return listeners, addressed
+}
+
+// triggerEvents runs listeners for the addressed events, with the targeting
+// metadata removed from the data passed to them.
+func triggerEvents(ctx context.Context, listeners *EventDispatcher, events []Event, addressed []int) error {
+	for _, i := range addressed {
+		if err := listeners.TriggerLocal(ctx, withoutTargeting(events[i])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func markDelivered(delivered []bool, addressed []int) {
+	for _, i := range addressed {
+		delivered[i] = true
+	}
+}
+
+// eventTargets reports whether event is addressed to c.
+func eventTargets(event Event, c ComponentInterface) bool {
+	if kind, _ := event.Data["__target"].(string); kind != "" && kind != c.GetKind() {
+		return false
+	}
+	if id, _ := event.Data["__target_id"].(string); id != "" && id != c.GetID() {
+		return false
+	}
+	return true
+}
+
+// withoutTargeting returns event with the routing metadata removed.
+func withoutTargeting(event Event) Event {
+	if event.Data == nil {
+		return event
+	}
+	data := make(map[string]any, len(event.Data))
+	for key, value := range event.Data {
+		switch key {
+		case "__target", "__target_id", "__self":
+			continue
+		}
+		data[key] = value
+	}
+	return Event{Name: event.Name, Data: data}
+}
+
+// liveComponents returns the distinct instances posted in
+// FormLiveComponents, without the source. Malformed pairs are skipped.
+func liveComponents(r *http.Request, sourceID string) []liveComponent {
+	seen := map[string]bool{sourceID: true}
+	var targets []liveComponent
+	for _, value := range r.Form[FormLiveComponents] {
+		for _, pair := range strings.Split(value, ",") {
+			kind, id, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			if !ok {
+				continue
+			}
+			kind, kindErr := url.QueryUnescape(kind)
+			id, idErr := url.QueryUnescape(id)
+			if kindErr != nil || idErr != nil || kind == "" || id == "" || seen[id] {
+				continue
+			}
+			seen[id] = true
+			targets = append(targets, liveComponent{kind: kind, id: id})
+			if len(targets) == maxRoutedComponents {
+				return targets
+			}
+		}
+	}
+	return targets
+}
+
+// componentSelector selects the root element of c in the document.
+func componentSelector(c ComponentInterface) string {
+	return TargetAttr(DataFluxComponentKind, c.GetKind()) + TargetAttr(DataFluxComponentID, c.GetID())
+}
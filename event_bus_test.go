@@ -0,0 +1,309 @@
+package liveflux
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/hb"
+)
+
+// creatorComp dispatches events from its actions.
+type creatorComp struct {
+	Base
+	Created int
+}
+
+func (c *creatorComp) GetKind() string                                { return "test.creator-comp" }
+func (c *creatorComp) Mount(context.Context, map[string]string) error { return nil }
+func (c *creatorComp) Handle(_ context.Context, action string, data url.Values) error {
+	payload := map[string]any{"title": data.Get("title")}
+	switch action {
+	case "create":
+		c.Dispatch("item-created", payload)
+	case "create-for-kind":
+		c.DispatchToKind(data.Get("kind"), "item-created", payload)
+	case "create-for-id":
+		c.DispatchToKindAndID("test.listener-comp", data.Get("id"), "item-created", payload)
+	case "create-self":
+		c.DispatchSelf("item-created", payload)
+	}
+	return nil
+}
+func (c *creatorComp) OnItemCreated(context.Context, Event) error {
+	c.Created++
+	return nil
+}
+func (c *creatorComp) Render(context.Context) hb.TagInterface {
+	return c.Root(hb.Text(fmt.Sprintf("created=%d", c.Created)))
+}
+
+// listenerComp collects item-created events.
+type listenerComp struct {
+	Base
+	Items []string
+}
+
+func (c *listenerComp) GetKind() string                                  { return "test.listener-comp" }
+func (c *listenerComp) Mount(context.Context, map[string]string) error   { return nil }
+func (c *listenerComp) Handle(context.Context, string, url.Values) error { return nil }
+func (c *listenerComp) OnItemCreated(_ context.Context, event Event) error {
+	if _, ok := event.Data["__target"]; ok {
+		return fmt.Errorf("targeting metadata leaked")
+	}
+	title, _ := event.Data["title"].(string)
+	c.Items = append(c.Items, title)
+	c.Dispatch("list-changed")
+	return nil
+}
+
+// OnClick does not have the listener signature and must be ignored.
+func (c *listenerComp) OnClick() {}
+
+func (c *listenerComp) Render(context.Context) hb.TagInterface {
+	return c.Root(hb.Text("items=" + strings.Join(c.Items, ",")))
+}
+
+func TestHandler_RoutesEvents(t *testing.T) {
+	store := NewMemoryStore()
+	h := NewHandler(store)
+	creatorKind := registerTestKind(t, &creatorComp{})
+	listenerKind := registerTestKind(t, &listenerComp{})
+
+	creatorID := extractAttr(t, postForm(h, url.Values{FormComponentKind: {creatorKind}}).Body.String(), DataFluxComponentID)
+	listenerID := extractAttr(t, postForm(h, url.Values{FormComponentKind: {listenerKind}}).Body.String(), DataFluxComponentID)
+	otherID := extractAttr(t, postForm(h, url.Values{FormComponentKind: {listenerKind}}).Body.String(), DataFluxComponentID)
+
+	act := func(action string, fields url.Values) string {
+		fields.Set(FormComponentKind, creatorKind)
+		fields.Set(FormComponentID, creatorID)
+		fields.Set(FormAction, action)
+		rec := postForm(h, fields)
+		if rec.Code != 200 {
+			t.Fatalf("%s: %d %q", action, rec.Code, rec.Body.String())
+		}
+		return rec.Body.String()
+	}
+	items := func(id string) string {
+		c, _ := store.Get(id)
+		return strings.Join(c.(*listenerComp).Items, ",")
+	}
+
+	// Only instances listed on the page receive the event
+	rec := postForm(h, url.Values{
+		FormComponentKind:  {creatorKind},
+		FormComponentID:    {creatorID},
+		FormAction:         {"create"},
+		"title":            {"a"},
+		FormLiveComponents: {listenerKind + ":" + listenerID + "," + creatorKind + ":" + creatorID + "," + listenerKind + ":missing"},
+	})
+	body := rec.Body.String()
+	if items(listenerID) != "a" || items(otherID) != "" {
+		t.Fatalf("expected only the listed listener to be updated, got %q and %q", items(listenerID), items(otherID))
+	}
+	selector := componentSelector(&listenerComp{Base: Base{kind: listenerKind, id: listenerID}})
+	if !strings.Contains(body, `<template data-flux-target="`+strings.ReplaceAll(selector, "'", "&#39;")+`" data-flux-swap="replace">`) || !strings.Contains(body, "items=a") {
+		t.Fatalf("expected a replace fragment for the listener, got %q", body)
+	}
+	if !strings.Contains(body, `<template data-flux-component-kind="`+creatorKind+`" data-flux-component-id="`+creatorID+`"><div`) || !strings.Contains(body, "created=1") {
+		t.Fatalf("expected the source's full render with its own listener applied, got %q", body)
+	}
+	// Routed events are not sent to the client again; chained ones are
+	events := rec.Header().Get(EventsHeader)
+	if strings.Contains(events, `"item-created"`) || !strings.Contains(events, `"list-changed"`) {
+		t.Fatalf("expected only the chained event in the header, got %q", events)
+	}
+
+	// Instances posted with another kind are skipped
+	act("create", url.Values{"title": {"x"}, FormLiveComponents: {creatorKind + ":" + otherID}})
+	if items(otherID) != "" {
+		t.Fatalf("expected an instance posted with the wrong kind to be skipped, got %q", items(otherID))
+	}
+
+	both := listenerKind + ":" + listenerID + "," + listenerKind + ":" + otherID
+	act("create-for-kind", url.Values{"title": {"b"}, "kind": {"test.other"}, FormLiveComponents: {both}})
+	if items(listenerID) != "a" || items(otherID) != "" {
+		t.Fatalf("expected events for another kind to be skipped")
+	}
+	act("create-for-kind", url.Values{"title": {"c"}, "kind": {listenerKind}, FormLiveComponents: {both}})
+	act("create-for-id", url.Values{"title": {"d"}, "id": {otherID}, FormLiveComponents: {both}})
+	act("create-self", url.Values{"title": {"e"}, FormLiveComponents: {both}})
+	if items(listenerID) != "a,c" || items(otherID) != "c,d" {
+		t.Fatalf("unexpected routing: %q and %q", items(listenerID), items(otherID))
+	}
+
+	// Events nobody on the server listened to still reach the client
+	rec = postForm(h, url.Values{
+		FormComponentKind:  {creatorKind},
+		FormComponentID:    {creatorID},
+		FormAction:         {"create-for-kind"},
+		"title":            {"g"},
+		"kind":             {"test.other"},
+		FormLiveComponents: {both},
+	})
+	if events := rec.Header().Get(EventsHeader); !strings.Contains(events, `"item-created"`) {
+		t.Fatalf("expected the unrouted event in the header, got %q", events)
+	}
+
+	// Without listeners the response is the plain render
+	if body := act("create", url.Values{"title": {"f"}}); strings.Contains(body, "<template") {
+		t.Fatalf("expected a plain render, got %q", body)
+	}
+}
+
+func TestHandler_RoutesEventsWithinSession(t *testing.T) {
+	store := NewSessionStore(NewMemoryStore(), SessionFromCookie("sid"))
+	h := NewHandler(store)
+	creatorKind := registerTestKind(t, &creatorComp{})
+	listenerKind := registerTestKind(t, &listenerComp{})
+
+	post := func(session string, form url.Values) string {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		req.AddCookie(&http.Cookie{Name: "sid", Value: session})
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec.Body.String()
+	}
+	listenerID := extractAttr(t, post("alice", url.Values{FormComponentKind: {listenerKind}}), DataFluxComponentID)
+	creatorID := extractAttr(t, post("mallory", url.Values{FormComponentKind: {creatorKind}}), DataFluxComponentID)
+
+	body := post("mallory", url.Values{
+		FormComponentKind:  {creatorKind},
+		FormComponentID:    {creatorID},
+		FormAction:         {"create"},
+		"title":            {"a"},
+		FormLiveComponents: {listenerKind + ":" + listenerID},
+	})
+	if c, _ := store.Get(listenerID); len(c.(*listenerComp).Items) != 0 || strings.Contains(body, "<template") {
+		t.Fatalf("expected another session's instance to be skipped, got %q", body)
+	}
+}
+
+func TestHandler_RouteEventsGivesUpOnBusyTarget(t *testing.T) {
+	store := NewMemoryStore()
+	h := NewHandler(store)
+	creatorKind := registerTestKind(t, &creatorComp{})
+	listenerKind := registerTestKind(t, &listenerComp{})
+	cardKind := registerTestKind(t, &shoutCard{})
+
+	creatorID := extractAttr(t, postForm(h, url.Values{FormComponentKind: {creatorKind}}).Body.String(), DataFluxComponentID)
+	listenerID := extractAttr(t, postForm(h, url.Values{FormComponentKind: {listenerKind}}).Body.String(), DataFluxComponentID)
+	cardID := extractAttr(t, postForm(h, url.Values{FormComponentKind: {cardKind}}).Body.String(), DataFluxComponentID)
+
+	act := func(live string) (int, time.Duration) {
+		start := time.Now()
+		done := make(chan int, 1)
+		go func() {
+			done <- postForm(h, url.Values{
+				FormComponentKind:  {creatorKind},
+				FormComponentID:    {creatorID},
+				FormAction:         {"create"},
+				"title":            {"a"},
+				FormLiveComponents: {live},
+			}).Code
+		}()
+		select {
+		case code := <-done:
+			return code, time.Since(start)
+		case <-time.After(eventLockTimeout + 3*time.Second):
+			t.Fatal("expected the action to give up on the busy target")
+			return 0, 0
+		}
+	}
+
+	// A target that does not listen is never locked
+	unlockCard, err := store.Lock(context.Background(), cardID)
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	defer unlockCard()
+	if code, elapsed := act(cardKind + ":" + cardID); code != 200 || elapsed >= eventLockTimeout/2 {
+		t.Fatalf("expected a prompt 200, got %d after %v", code, elapsed)
+	}
+
+	unlockListener, err := store.Lock(context.Background(), listenerID)
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	defer unlockListener()
+	if code, _ := act(listenerKind + ":" + listenerID); code != 200 {
+		t.Fatalf("expected 200, got %d", code)
+	}
+	if c, _ := store.Get(listenerID); len(c.(*listenerComp).Items) != 0 {
+		t.Fatalf("expected the busy listener to be skipped, got %v", c.(*listenerComp).Items)
+	}
+}
+
+// shoutCard dispatches an event its parent listens to.
+type shoutCard struct {
+	Base
+	Count int
+}
+
+func (c *shoutCard) GetKind() string                                { return "test.shout-card" }
+func (c *shoutCard) Mount(context.Context, map[string]string) error { return nil }
+func (c *shoutCard) Handle(context.Context, string, url.Values) error {
+	c.Count++
+	c.Dispatch("card-changed")
+	return nil
+}
+func (c *shoutCard) Render(context.Context) hb.TagInterface {
+	return c.Root(hb.Text(fmt.Sprintf("card=%d", c.Count)))
+}
+
+type shoutBoard struct {
+	Base
+	Changes int
+}
+
+func (c *shoutBoard) GetKind() string                                  { return "test.shout-board" }
+func (c *shoutBoard) Mount(context.Context, map[string]string) error   { return nil }
+func (c *shoutBoard) Handle(context.Context, string, url.Values) error { return nil }
+func (c *shoutBoard) OnCardChanged(context.Context, Event) error {
+	c.Changes++
+	return nil
+}
+func (c *shoutBoard) Render(context.Context) hb.TagInterface {
+	return c.Root(hb.Div().
+		Child(hb.Text(fmt.Sprintf("changes=%d", c.Changes))).
+		Child(Child(c, &shoutCard{}, "card", nil)))
+}
+
+func TestHandler_RoutesEventsFromChildToParent(t *testing.T) {
+	h := NewHandler(NewMemoryStore())
+	boardKind := registerTestKind(t, &shoutBoard{})
+	cardKind := registerTestKind(t, &shoutCard{})
+
+	body := postForm(h, url.Values{FormComponentKind: {boardKind}}).Body.String()
+	boardID := extractAttr(t, body, DataFluxComponentID)
+	cardID := cardIDsOf(t, body, cardKind)
+
+	// The parent re-renders the child from the request's instance instead
+	// of waiting for the child's lock
+	rec := postForm(h, url.Values{
+		FormComponentKind:  {cardKind},
+		FormComponentID:    {cardID},
+		FormAction:         {"inc"},
+		FormLiveComponents: {boardKind + ":" + boardID + "," + cardKind + ":" + cardID},
+	})
+	body = rec.Body.String()
+	if !strings.Contains(body, "changes=1") || strings.Count(body, "card=1") != 2 {
+		t.Fatalf("expected the parent fragment with the updated child, got %q", body)
+	}
+}
+
+func cardIDsOf(t *testing.T, html, kind string) string {
+	t.Helper()
+	marker := DataFluxComponentKind + `="` + kind + `"`
+	idx := strings.Index(html, marker)
+	if idx < 0 {
+		t.Fatalf("no %s in %q", kind, html)
+	}
+	return extractAttr(t, html[strings.LastIndex(html[:idx], "<"):], DataFluxComponentID)
+}
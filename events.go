@@ -18,6 +18,8 @@ type EventDispatcher struct {
 	events []Event
 	// listeners maps event names to handler functions
 	listeners map[string][]EventListener
+	// broadcasts holds the events to publish on PubSub channels
+	broadcasts []Broadcast
 }
 
 // EventListener is a function that handles an event.
@@ -70,6 +72,24 @@ func (ed *EventDispatcher) DispatchToKindAndID(componentKind string, componentID
 	ed.events = append(ed.events, event)
 }
 
+// BroadcastTo queues an event to be published on a PubSub channel after the
+// current request. Components subscribed to the channel over WebSocket (see
+// ChannelSubscriber) receive it, in any tab and for any user.
+func (ed *EventDispatcher) BroadcastTo(channel string, eventName string, data ...map[string]any) {
+	event := Event{Name: eventName}
+	if len(data) > 0 && data[0] != nil {
+		event.Data = data[0]
+	}
+	ed.broadcasts = append(ed.broadcasts, Broadcast{Channel: channel, Event: event})
+}
+
+// TakeBroadcasts returns all queued broadcasts and clears the queue.
+func (ed *EventDispatcher) TakeBroadcasts() []Broadcast {
+	broadcasts := ed.broadcasts
+	ed.broadcasts = nil
+	return broadcasts
+}
+
 // On registers an event listener for the given event name.
 // Multiple listeners can be registered for the same event.
 func (ed *EventDispatcher) On(name string, handler EventListener) {
@@ -174,7 +194,12 @@ func toKebabCase(s string) string {
 	return string(result)
 }
 
+// eventListenerType is the signature of On* methods registered as listeners.
+var eventListenerType = reflect.TypeOf(func(context.Context, Event) error { return nil })
+
 // RegisterEventListeners automatically registers event listeners based on method naming.
+// Methods must have the signature OnEventName(ctx context.Context, event Event) error;
+// other methods starting with "On" are ignored.
 func RegisterEventListeners(component ComponentInterface, dispatcher *EventDispatcher) {
 	attrs := ParseOnAttributes(component)
 	v := reflect.ValueOf(component)
@@ -183,9 +208,9 @@ func RegisterEventListeners(component ComponentInterface, dispatcher *EventDispa
 		methodName := attr.Method
 		eventName := attr.EventName
 
-		// Get the method
+		// Get the method; skip On* methods that are not event listeners
 		method := v.MethodByName(methodName)
-		if !method.IsValid() {
+		if !method.IsValid() || method.Type() != eventListenerType {
 			continue
 		}
 
@@ -30,18 +30,25 @@ func (c *UserList) Mount(ctx context.Context, params map[string]string) error {
 	return nil
 }
 
+// Handle routes actions to the Action* methods below ("dismiss_flash" -> ActionDismissFlash).
 func (c *UserList) Handle(ctx context.Context, action string, form url.Values) error {
-	switch action {
-	case "filter":
-		c.Query = strings.TrimSpace(form.Get("search"))
-	case "clear":
-		c.Query = ""
-	case "dismiss_flash":
-		c.Flash = ""
-	case "create_modal_open":
-		c.ModalCreateOpen = true
-	}
-	return nil
+	return liveflux.DispatchAction(ctx, c, action, form)
+}
+
+func (c *UserList) ActionFilter(_ context.Context, form url.Values) {
+	c.Query = strings.TrimSpace(form.Get("search"))
+}
+
+func (c *UserList) ActionClear(context.Context) {
+	c.Query = ""
+}
+
+func (c *UserList) ActionDismissFlash(context.Context) {
+	c.Flash = ""
+}
+
+func (c *UserList) ActionCreateModalOpen(context.Context) {
+	c.ModalCreateOpen = true
 }
 
 func (c *UserList) Render(ctx context.Context) hb.TagInterface {
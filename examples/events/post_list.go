@@ -23,7 +23,6 @@ type PostList struct {
 
 func (pl *PostList) Mount(ctx context.Context, params map[string]string) error {
 	pl.Posts = []Post{}
-	liveflux.RegisterEventListeners(pl, pl.GetEventDispatcher())
 	return nil
 }
 
@@ -32,22 +31,13 @@ func (pl *PostList) Handle(ctx context.Context, action string, data url.Values)
 	switch action {
 	case "clear":
 		pl.Posts = []Post{}
-	case "add-post":
-		title := data.Get("title")
-		timestamp := data.Get("timestamp")
-		log.Printf("[PostList] Adding post from event: title=%s, timestamp=%s", title, timestamp)
-		if title != "" {
-			pl.Posts = append(pl.Posts, Post{
-				Title:     title,
-				Timestamp: timestamp,
-			})
-			log.Printf("[PostList] Post added. Total posts: %d", len(pl.Posts))
-		}
 	}
 	return nil
 }
 
-// OnPostCreated listens for the "post-created" event.
+// OnPostCreated listens for the "post-created" event. The handler calls it
+// while processing PostCreator's action and returns the re-rendered list in
+// the same response.
 func (pl *PostList) OnPostCreated(ctx context.Context, event liveflux.Event) error {
 	title, _ := event.Data["title"].(string)
 	timestamp, _ := event.Data["timestamp"].(string)
@@ -72,40 +62,6 @@ func (pl *PostList) Render(ctx context.Context) hb.TagInterface {
 		postItems = append(postItems, hb.Li().Class("empty").Text("No posts yet..."))
 	}
 
-	script := hb.Script(`
-        (function(){
-            var root = liveflux.findComponent('` + pl.GetKind() + `', '` + pl.GetID() + `');
-            if(!root) {
-                console.error('[PostList] Could not find component root');
-                return;
-            }
-
-            console.log('[PostList] Initializing post-created listener');
-
-            function setupListener(){
-                if(!root.$wire){
-                    console.log('[PostList] $wire not ready yet, retrying...');
-                    setTimeout(setupListener, 50);
-                    return;
-                }
-
-                console.log('[PostList] $wire ready, registering post-created listener');
-                root.$wire.on('post-created', function(event){
-                    var data = event && event.data ? event.data : {};
-                    var title = data.title || '';
-                    var timestamp = data.timestamp || '';
-                    console.log('[PostList] Event received, calling add-post with', title, timestamp);
-                    root.$wire.call('add-post', {
-                        title: title,
-                        timestamp: timestamp,
-                    });
-                });
-            }
-
-            setupListener();
-        })();
-    `)
-
 	return pl.Root(
 		hb.Div().Class("card").Children([]hb.TagInterface{
 			hb.H2().Text("Post List"),
@@ -114,7 +70,6 @@ func (pl *PostList) Render(ctx context.Context) hb.TagInterface {
 				Attr("data-flux-action", "clear").
 				Class("btn btn-secondary").
 				Text("Clear All"),
-			script,
 		}),
 	)
 }
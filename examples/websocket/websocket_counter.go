@@ -2,7 +2,6 @@ package main
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
 	"log"
 	"net/url"
@@ -31,59 +30,27 @@ func (c *WebSocketCounter) Mount(ctx context.Context, params map[string]string)
 
 // Handle processes actions from the client.
 func (c *WebSocketCounter) Handle(ctx context.Context, action string, data url.Values) error {
-	switch action {
-	case "increment":
-		c.Count++
-	case "decrement":
-		c.Count--
-	case "set":
-		if val := data.Get("value"); val != "" {
-			if num, err := strconv.Atoi(val); err == nil {
-				c.Count = num
-			}
-		}
-	}
-	return nil
+	return liveflux.DispatchAction(ctx, c, action, data)
 }
 
-// HandleWS handles WebSocket messages.
-func (c *WebSocketCounter) HandleWS(ctx context.Context, msg *liveflux.WebSocketMessage) (interface{}, error) {
-	switch msg.Type {
-	case "action":
-		switch msg.Action {
-		case "increment":
-			c.Count++
-		case "decrement":
-			c.Count--
-		case "set":
-			// Accept either numeric or string value from client
-			var anymap map[string]any
-			if err := json.Unmarshal(msg.Data, &anymap); err == nil {
-				if raw, ok := anymap["value"]; ok {
-					switch v := raw.(type) {
-					case float64:
-						c.Count = int(v)
-					case string:
-						if n, err := strconv.Atoi(v); err == nil {
-							c.Count = n
-						}
-					}
-				}
-			}
-		}
-
-		// Return the updated state
-		return map[string]interface{}{
-			"type":        "update",
-			"componentID": c.GetID(),
-			"data": map[string]interface{}{
-				// Return only the inner container so the client replaces the correct node
-				"html": c.inner(ctx).ToHTML(),
-			},
-		}, nil
-	}
+// ActionIncrement increments the counter.
+func (c *WebSocketCounter) ActionIncrement(ctx context.Context) {
+	c.Count++
+}
+
+// ActionDecrement decrements the counter.
+func (c *WebSocketCounter) ActionDecrement(ctx context.Context) {
+	c.Count--
+}
+
+// setInput is decoded from the "set" action's form or JSON payload.
+type setInput struct {
+	Value int `flux:"value"`
+}
 
-	return nil, nil
+// ActionSet sets the counter to the submitted value.
+func (c *WebSocketCounter) ActionSet(ctx context.Context, in setInput) {
+	c.Count = in.Value
 }
 
 // inner builds the inner container that carries data-flux-component-id and WS hints.
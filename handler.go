@@ -3,11 +3,12 @@ package liveflux
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html"
 	"net/http"
-	"sync"
 
+	"github.com/dracory/hb"
 	"github.com/spf13/cast"
 )
 
@@ -16,6 +17,10 @@ const (
 	FormComponentKind = "liveflux_component_kind"
 	FormComponentID   = "liveflux_component_id"
 	FormAction        = "liveflux_action"
+	// FormModel prefixes data-flux-model values, e.g. liveflux_model[Email].
+	FormModel = "liveflux_model"
+	// FormComponentState carries the signed snapshot when using ClientStateStore.
+	FormComponentState = "liveflux_component_state"
 )
 
 // Response header names for client-side redirect handling and events
@@ -23,6 +28,13 @@ const (
 	RedirectHeader      = "X-Liveflux-Redirect"
 	RedirectAfterHeader = "X-Liveflux-Redirect-After"
 	EventsHeader        = "X-Liveflux-Events"
+	// StateHeader carries the refreshed snapshot when using ClientStateStore,
+	// so targeted (fragment-only) responses can update the root's data-flux-state.
+	StateHeader = "X-Liveflux-State"
+	// PollHeader carries the data-flux-poll directive of a component that
+	// changed it during the request (see Base.SetPollInterval), or "0" when
+	// it stopped polling, so targeted responses can update the root too.
+	PollHeader = "X-Liveflux-Poll"
 )
 
 // Handler is an http.Handler that mounts/handles components and returns HTML.
@@ -35,6 +47,35 @@ const (
 // wire a session-backed implementation.
 type Handler struct {
 	Store Store
+
+	// KindCheck controls whether action requests must post the kind of the
+	// stored instance they target. The zero value is KindCheckStrict.
+	KindCheck KindCheckMode
+
+	// OnKindMismatch, if set, is called for every detected kind mismatch
+	// (rejected or not) so it can be logged or counted.
+	OnKindMismatch func(ctx context.Context, mismatch KindMismatch)
+
+	// Uploads stores files posted with multipart requests. Nil uses a
+	// DirTempStorage under os.TempDir().
+	Uploads TempStorage
+
+	// MaxUploadSize caps the body of multipart requests. Zero means
+	// DefaultMaxUploadSize.
+	MaxUploadSize int64
+
+	// ChunkedUploads, if set, enables the chunked upload protocol for files
+	// too large for a single request.
+	ChunkedUploads *ChunkedUploads
+
+	// PubSub carries events sent with BroadcastTo. Nil uses PubSubDefault,
+	// which only reaches the current process.
+	PubSub PubSub
+
+	// OnBroadcastError, if set, receives errors publishing to or receiving
+	// from PubSub channels, which happen after the response or in the
+	// background. Without it they are written to the standard logger.
+	OnBroadcastError func(ctx context.Context, channel string, err error)
 }
 
 // NewHandler creates a Handler using the provided store. If store is nil, StoreDefault is used.
@@ -79,17 +120,47 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err := r.ParseForm(); err != nil {
+	if op := r.URL.Query().Get(FormUploadOp); op != "" {
+		h.serveChunkedUpload(w, r, op)
+		return
+	}
+
+	if err := h.parseForm(w, r); err != nil {
+		if errors.Is(err, ErrUploadTooLarge) {
+			h.writeError(w, http.StatusRequestEntityTooLarge, "upload too large")
+			return
+		}
 		w.WriteHeader(http.StatusBadRequest)
 		_, _ = w.Write([]byte("invalid form"))
 		return
 	}
+	if r.MultipartForm != nil {
+		defer func() { _ = r.MultipartForm.RemoveAll() }()
+	}
 
 	kind := r.FormValue(FormComponentKind)
 	id := r.FormValue(FormComponentID)
 	action := r.FormValue(FormAction)
 
-	ctx := r.Context()
+	ctx := contextWithStore(contextWithRequest(r.Context(), r), h.Store)
+
+	// Copy posted files into temp storage; they are discarded again if the
+	// request fails, otherwise they live until moved, removed or purged
+	uploads, err := h.storeUploads(ctx, r, kind, id)
+	if err != nil {
+		h.writeUploadError(w, err)
+		return
+	}
+	if uploads != nil {
+		ctx = contextWithUploads(ctx, uploads)
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			if rec.status >= http.StatusBadRequest {
+				removeUploads(uploads)
+			}
+		}()
+		w = rec
+	}
 
 	// Mount new component if no ID present
 	if id == "" {
@@ -126,7 +197,7 @@ func (h *Handler) mount(ctx context.Context, w http.ResponseWriter, r *http.Requ
 	params := map[string]string{}
 	for key := range r.Form {
 		// Skip canonical field names
-		if key == FormComponentKind || key == FormComponentID || key == FormAction {
+		if key == FormComponentKind || key == FormComponentID || key == FormAction || key == FormComponentState {
 			continue
 		}
 		params[key] = r.Form.Get(key)
@@ -143,7 +214,10 @@ func (h *Handler) mount(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	h.Store.Set(c)
+	if !h.persist(ctx, w, c, true) {
+		return
+	}
+	h.publishBroadcasts(ctx, c)
 
 	// Check if component supports events
 	if ea, ok := c.(EventAware); ok {
@@ -154,8 +228,10 @@ func (h *Handler) mount(ctx context.Context, w http.ResponseWriter, r *http.Requ
 		}
 	}
 
+	html := render(ctx, c).ToHTML()
+	writePollHeader(w, c)
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	_, _ = w.Write([]byte(c.Render(ctx).ToHTML()))
+	_, _ = w.Write([]byte(html))
 }
 
 func (h *Handler) handle(ctx context.Context, w http.ResponseWriter, r *http.Request, kind, id, action string) {
@@ -168,38 +244,158 @@ func (h *Handler) handle(ctx context.Context, w http.ResponseWriter, r *http.Req
 
 	// Acquire per-component lock to prevent concurrent modifications
 	// This is critical when multiple requests target the same component ID
-	var componentLock *sync.Mutex
-	if memStore, ok := h.Store.(*MemoryStore); ok {
-		componentLock = memStore.LockComponent(id)
-		defer memStore.UnlockComponent(componentLock)
+	unlock, err := h.lockComponent(ctx, id)
+	if err != nil {
+		fmt.Printf("liveflux: lock error: %v\n", err)
+		h.writeError(w, http.StatusConflict, "component busy")
+		return
 	}
+	defer unlock()
 
-	// Retrieve component from store
-	c, ok := h.Store.Get(id)
-	if !ok || c == nil {
-		h.writeError(w, http.StatusNotFound, "component not found")
+	// Retrieve component from store (or from the posted snapshot)
+	c, ok := h.loadComponent(ctx, w, r, kind, id)
+	if !ok {
+		return
+	}
+
+	// Refuse to route one kind's actions to an instance of another kind
+	if !h.verifyKind(ctx, w, kind, c) {
 		return
 	}
+	h.handleLoaded(ctx, w, r, c, action)
+}
+
+// handleLoaded runs an action request against a loaded component, whose
+// lock the caller holds: it applies models and the action, routes events,
+// persists, and writes a redirect or the render.
+func (h *Handler) handleLoaded(ctx context.Context, w http.ResponseWriter, r *http.Request, c ComponentInterface, action string) {
+	ctx = contextWithHeld(ctx, c)
 
-	// Optional: ensure the retrieved instance matches requested kind by type registry kind.
-	// Skipped for simplicity.
+	if err := afterHydrate(ctx, c); err != nil {
+		fmt.Printf("liveflux: hydrate error: %v\n", err)
+		h.writeError(w, http.StatusInternalServerError, "hydrate error")
+		return
+	}
 
-	// Process action if present
-	if action != "" {
+	// Apply bound fields and process the action, if present
+	mutated := action != "" || hasModels(r.Form)
+	if mutated {
 		if !h.processAction(ctx, w, c, r) {
 			return
 		}
-		// persist after mutation
-		h.Store.Set(c)
 	}
 
+	// Deliver dispatched events to On* methods of the instances on the page
+	var routed []TargetFragment
+	if mutated {
+		routed = h.routeEvents(ctx, r, c)
+	}
+
+	// Persist after mutation (client state is re-issued on every response)
+	if !h.persist(ctx, w, c, mutated) {
+		return
+	}
+	h.publishBroadcasts(ctx, c)
+
 	// Handle redirect if requested
 	if h.maybeWriteRedirect(w, c) {
 		return
 	}
 
 	// Render the component
-	h.writeRender(ctx, w, c)
+	h.writeRender(ctx, w, c, routed...)
+}
+
+// loadComponent retrieves the component instance for an action request.
+// With a ClientStateStore the instance is rebuilt from the posted snapshot,
+// otherwise it is read from the Store. Returns false if an error was written.
+func (h *Handler) loadComponent(ctx context.Context, w http.ResponseWriter, r *http.Request, kind, id string) (ComponentInterface, bool) {
+	if cs, ok := h.Store.(*ClientStateStore); ok {
+		c, err := cs.Decode(r.FormValue(FormComponentState))
+		if err != nil {
+			fmt.Printf("liveflux: client state error: %v\n", err)
+			status, msg := clientStateError(err)
+			h.writeError(w, status, msg)
+			return nil, false
+		}
+		if c.GetID() != id {
+			h.writeError(w, http.StatusForbidden, "component state mismatch")
+			return nil, false
+		}
+		return c, true
+	}
+
+	c, ok := h.storeGet(ctx, id)
+	if !ok || c == nil {
+		h.writeError(w, http.StatusNotFound, "component not found")
+		return nil, false
+	}
+	return c, true
+}
+
+// storeGet reads a component, passing the request context to a ContextStore.
+func (h *Handler) storeGet(ctx context.Context, id string) (ComponentInterface, bool) {
+	return storeGetContext(ctx, h.Store, id)
+}
+
+// storeGetDetached reads a component for work outside a request, such as
+// delivering broadcasts. A ContextStore is read through its plain Get, so a
+// SessionStore does not look for the request's session.
+func (h *Handler) storeGetDetached(id string) (ComponentInterface, bool) {
+	return h.Store.Get(id)
+}
+
+// storeSet persists a component, passing the request context to a ContextStore.
+func (h *Handler) storeSet(ctx context.Context, c ComponentInterface) {
+	storeSetContext(ctx, h.Store, c)
+}
+
+// save runs the BeforeDehydrater hook and writes c to the store.
+func (h *Handler) save(ctx context.Context, c ComponentInterface) error {
+	if err := beforeDehydrate(ctx, c); err != nil {
+		return err
+	}
+	h.storeSet(ctx, c)
+	return nil
+}
+
+// persist saves c after a mutation and, with a ClientStateStore, re-issues
+// the signed snapshot (which happens on every response, mutated or not).
+// Returns false if an error was written.
+func (h *Handler) persist(ctx context.Context, w http.ResponseWriter, c ComponentInterface, mutated bool) bool {
+	_, clientState := h.Store.(*ClientStateStore)
+	if !mutated && !clientState {
+		return true
+	}
+	if err := h.save(ctx, c); err != nil {
+		fmt.Printf("liveflux: dehydrate error: %v\n", err)
+		h.writeError(w, http.StatusInternalServerError, "dehydrate error")
+		return false
+	}
+	return h.attachClientState(w, c)
+}
+
+// attachClientState embeds a fresh signed snapshot into the component when the
+// handler uses a ClientStateStore. Returns false if an error was written.
+func (h *Handler) attachClientState(w http.ResponseWriter, c ComponentInterface) bool {
+	cs, ok := h.Store.(*ClientStateStore)
+	if !ok {
+		return true
+	}
+	token, err := cs.attach(c)
+	if err != nil {
+		fmt.Printf("liveflux: client state encode error: %v\n", err)
+		h.writeError(w, http.StatusInternalServerError, "state error")
+		return false
+	}
+	w.Header().Set(StateHeader, token)
+	return true
+}
+
+// lockComponent acquires the per-component lock when the store implements
+// LockingStore. The returned unlock function is always safe to call.
+func (h *Handler) lockComponent(ctx context.Context, id string) (func(), error) {
+	return lockStore(ctx, h.Store, id)
 }
 
 // validateKindAndID ensures required params are present. Returns true if OK.
@@ -211,9 +407,37 @@ func (h *Handler) validateKindAndID(w http.ResponseWriter, kind, id string) bool
 	return true
 }
 
-// processAction invokes the component's Handle for the given action. Returns true if successful.
+// processAction assigns data-flux-model values, then invokes the component's
+// Handle for the given action, wrapped in the BeforeAction/AfterAction hooks.
+// Returns true if rendering should continue, which includes ValidationErrors
+// collected into the error bag.
 func (h *Handler) processAction(ctx context.Context, w http.ResponseWriter, c ComponentInterface, r *http.Request) bool {
-	if err := c.Handle(ctx, r.FormValue(FormAction), r.Form); err != nil {
+	action := r.FormValue(FormAction)
+	bag := errorBagOf(c)
+	bag.Clear()
+	err := applyModels(ctx, c, r.Form)
+	if err == nil && action != "" {
+		err = runAction(ctx, c, action, r.Form, func() error {
+			return c.Handle(ctx, action, r.Form)
+		})
+	}
+	// Validation failures re-render the component with its error bag filled
+	var verrs ValidationErrors
+	if bag != nil && errors.As(err, &verrs) {
+		bag.Merge(verrs)
+		return true
+	}
+	if errors.Is(err, ErrFieldNotBindable) {
+		fmt.Printf("liveflux: model error: %v\n", err)
+		h.writeError(w, http.StatusBadRequest, "field not bindable")
+		return false
+	}
+	if errors.Is(err, ErrUnknownAction) {
+		fmt.Printf("liveflux: handle error: %v\n", err)
+		h.writeError(w, http.StatusBadRequest, "unknown action")
+		return false
+	}
+	if err != nil {
 		fmt.Printf("liveflux: handle error: %v\n", err)
 		h.writeError(w, http.StatusBadRequest, "action error")
 		return false
@@ -252,8 +476,11 @@ func (h *Handler) maybeWriteRedirect(w http.ResponseWriter, c ComponentInterface
 
 // writeRender renders component HTML and sends any queued events.
 // If the component implements TargetRenderer, it will send only the changed fragments
-// instead of the full component.
-func (h *Handler) writeRender(ctx context.Context, w http.ResponseWriter, c ComponentInterface) {
+// instead of the full component. Extra fragments (other components updated by
+// routed events) are appended to the response.
+func (h *Handler) writeRender(ctx context.Context, w http.ResponseWriter, c ComponentInterface, extra ...TargetFragment) {
+	beforeRender(ctx, c)
+
 	// Check if component supports events
 	if ea, ok := c.(EventAware); ok {
 		dispatcher := ea.GetEventDispatcher()
@@ -267,23 +494,34 @@ func (h *Handler) writeRender(ctx context.Context, w http.ResponseWriter, c Comp
 		}
 	}
 
+	html := renderHTML(ctx, c, extra...)
+	writePollHeader(w, c)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	_, _ = w.Write([]byte(html))
+}
+
+// renderHTML renders c for the client: its target fragments when it has
+// any, otherwise its full HTML, with extra fragments appended. The caller
+// runs beforeRender.
+func renderHTML(ctx context.Context, c ComponentInterface, extra ...TargetFragment) string {
 	// Try targeted rendering if component implements TargetRenderer
 	if tr, ok := c.(TargetRenderer); ok {
-		fragments := tr.RenderTargets(ctx)
+		var fragments []TargetFragment
+		renderWithChildren(ctx, c, false, func() { fragments = tr.RenderTargets(ctx) })
 		if len(fragments) > 0 {
 			// Build template response with fragments only (no fallback)
 			// The client will handle fallback if selectors fail
-			response := BuildTargetResponse(fragments, "", c)
-
-			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			_, _ = w.Write([]byte(response))
-			return
+			return BuildTargetResponse(append(fragments, extra...), "", c)
 		}
 	}
 
 	// Fallback to full render
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	_, _ = w.Write([]byte(c.Render(ctx).ToHTML()))
+	var tag hb.TagInterface
+	renderWithChildren(ctx, c, true, func() { tag = c.Render(ctx) })
+	if len(extra) > 0 {
+		return BuildTargetResponse(extra, tag.ToHTML(), c)
+	}
+	return tag.ToHTML()
 }
 
 // writeError writes a status code with a small text message.
@@ -299,6 +537,24 @@ func (h *Handler) writeClientScript(w http.ResponseWriter) {
 	_, _ = w.Write([]byte(JS(ClientOptions{UseWebSocket: true})))
 }
 
+type requestContextKey struct{}
+
+// contextWithRequest attaches the incoming request to ctx.
+func contextWithRequest(ctx context.Context, r *http.Request) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, r)
+}
+
+// RequestFromContext returns the HTTP request (or WebSocket upgrade request)
+// that the handler attached to the context passed to Mount, Handle and
+// ContextStore methods. Returns nil if none is attached.
+func RequestFromContext(ctx context.Context) *http.Request {
+	if ctx == nil {
+		return nil
+	}
+	r, _ := ctx.Value(requestContextKey{}).(*http.Request)
+	return r
+}
+
 // buildRedirectFallbackHTML returns the script + noscript fallback HTML document for a redirect.
 func buildRedirectFallbackHTML(url string, delaySeconds int) string {
 	urlJSON, _ := json.Marshal(url) // safe JS string literal
@@ -52,58 +52,68 @@
     const hdr = response.headers.get('X-Liveflux-Events');
     if(!hdr) return;
     try{
-      const events = JSON.parse(hdr);
-      if(!Array.isArray(events)) return;
-      events.forEach((ev)=>{
-        if(!ev || !ev.name) return;
-        const data = ev.data || {};
-        // handle targeting
-        let payload = data;
-        const targetKind = payload.__target;
-        const targetId = payload.__target_id;
-
-        if(targetKind || targetId){
-          payload = Object.assign({}, payload);
-          delete payload.__target;
-          delete payload.__target_id;
-
-          let handled = false;
-          if(targetKind && targetId && typeof liveflux.dispatchToKindAndId === 'function'){
-            try { liveflux.dispatchToKindAndId(targetKind, targetId, ev.name, payload); handled = true; }
-            catch(e){ console.error('[Liveflux Events] dispatchToKindAndId error', e); }
-          }
-          if(!handled && targetKind && typeof liveflux.dispatchToKind === 'function'){
-            try { liveflux.dispatchToKind(targetKind, ev.name, payload); handled = true; }
-            catch(e){ console.error('[Liveflux Events] dispatchToKind error', e); }
-          }
-          if(!handled && targetId && typeof liveflux.findComponent === 'function' && typeof liveflux.dispatchTo === 'function'){
-            const lookupKind = targetKind || componentKind;
-            try {
-              const targetRoot = lookupKind ? liveflux.findComponent(lookupKind, targetId) : null;
-              if(targetRoot){
-                liveflux.dispatchTo(targetRoot, ev.name, payload);
-                handled = true;
-              }
-            } catch(e){ console.error('[Liveflux Events] dispatchTo target error', e); }
-          }
-
-          if(handled){
-            return;
-          }
+      processEventList(JSON.parse(hdr), componentId, componentKind);
+    } catch(e){ console.error('[Liveflux Events] parse error', e); }
+  }
+
+  /**
+   * Dispatches server events (from the X-Liveflux-Events header or a
+   * WebSocket "events" message), honouring __target/__target_id/__self.
+   * @param {Array<{name: string, data: Object}>} events
+   * @param {string} componentId - The component the events came from.
+   * @param {string} [componentKind]
+   */
+  function processEventList(events, componentId, componentKind){
+    if(!Array.isArray(events)) return;
+    events.forEach((ev)=>{
+      if(!ev || !ev.name) return;
+      const data = ev.data || {};
+      // handle targeting
+      let payload = data;
+      const targetKind = payload.__target;
+      const targetId = payload.__target_id;
+
+      if(targetKind || targetId){
+        payload = Object.assign({}, payload);
+        delete payload.__target;
+        delete payload.__target_id;
+
+        let handled = false;
+        if(targetKind && targetId && typeof liveflux.dispatchToKindAndId === 'function'){
+          try { liveflux.dispatchToKindAndId(targetKind, targetId, ev.name, payload); handled = true; }
+          catch(e){ console.error('[Liveflux Events] dispatchToKindAndId error', e); }
+        }
+        if(!handled && targetKind && typeof liveflux.dispatchToKind === 'function'){
+          try { liveflux.dispatchToKind(targetKind, ev.name, payload); handled = true; }
+          catch(e){ console.error('[Liveflux Events] dispatchToKind error', e); }
+        }
+        if(!handled && targetId && typeof liveflux.findComponent === 'function' && typeof liveflux.dispatchTo === 'function'){
+          const lookupKind = targetKind || componentKind;
+          try {
+            const targetRoot = lookupKind ? liveflux.findComponent(lookupKind, targetId) : null;
+            if(targetRoot){
+              liveflux.dispatchTo(targetRoot, ev.name, payload);
+              handled = true;
+            }
+          } catch(e){ console.error('[Liveflux Events] dispatchTo target error', e); }
         }
 
-        if(payload.__self){
-          const listeners = componentEventListeners[componentId] && componentEventListeners[componentId][ev.name];
-          if(listeners && listeners.length){
-            const selfPayload = Object.assign({}, payload);
-            delete selfPayload.__self;
-            listeners.forEach(cb=>{ try{ cb({ name:ev.name, data:selfPayload, detail:selfPayload }); }catch(e){ console.error(e); } });
-          }
+        if(handled){
           return;
         }
-        dispatch(ev.name, payload);
-      });
-    } catch(e){ console.error('[Liveflux Events] parse error', e); }
+      }
+
+      if(payload.__self){
+        const listeners = componentEventListeners[componentId] && componentEventListeners[componentId][ev.name];
+        if(listeners && listeners.length){
+          const selfPayload = Object.assign({}, payload);
+          delete selfPayload.__self;
+          listeners.forEach(cb=>{ try{ cb({ name:ev.name, data:selfPayload, detail:selfPayload }); }catch(e){ console.error(e); } });
+        }
+        return;
+      }
+      dispatch(ev.name, payload);
+    });
   }
 
   function onComponent(componentId, eventName, callback){
@@ -194,7 +204,7 @@
 
   // Expose as module
   window.liveflux.events = {
-    on, dispatch, processEvents, onComponent, subscribe, bindEventToAction
+    on, dispatch, processEvents, processEventList, onComponent, subscribe, bindEventToAction
   };
   // Convenience top-level
   window.liveflux.on = on;
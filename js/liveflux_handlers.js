@@ -38,6 +38,8 @@
   function handleActionClick(e){
     const btn = e.target.closest(`[${actionAttr}]`);
     if(!btn) return;
+    // Actions of components on a connected WebSocket go over the socket
+    if(liveflux.isWebSocketElement && liveflux.isWebSocketElement(btn)) return;
 
     // Resolve component metadata with fallback chain
     const metadata = liveflux.resolveComponentMetadata(btn, rootSelector);
@@ -73,7 +75,9 @@
 
     const indicatorEls = liveflux.startRequestIndicators(btn, metadata.root);
 
-    liveflux.post(params).then((result)=>{
+    const onUploadProgress = (event)=>liveflux.updateRequestProgress(indicatorEls, event);
+
+    liveflux.post(params, { onUploadProgress }).then((result)=>{
       const rawHtml = result.html || result;
       
       // Check if response contains target templates
@@ -143,6 +147,7 @@
   function handleFormSubmit(e){
     const form = e.target.closest(`${rootSelector} form, form`);
     if(!form) return;
+    if(liveflux.isWebSocketElement && liveflux.isWebSocketElement(form)) return;
     const root = form.closest(rootSelector);
     if(!root) return;
     e.preventDefault();
@@ -175,7 +180,7 @@
     // Use collectAllFields to support data-flux-include and data-flux-exclude on submitter
     const fields = submitter 
       ? liveflux.collectAllFields(submitter, root, form)
-      : liveflux.serializeElement(form);
+      : Object.assign(liveflux.serializeElement(form), liveflux.collectModels ? liveflux.collectModels(root) : {});
 
     const params = Object.assign({}, fields, {
       liveflux_component_kind: metadata.comp,
@@ -188,7 +193,9 @@
 
     const indicatorEls = liveflux.startRequestIndicators(submitter || form, root);
 
-    liveflux.post(params).then((result)=>{
+    const onUploadProgress = (event)=>liveflux.updateRequestProgress(indicatorEls, event);
+
+    liveflux.post(params, { onUploadProgress }).then((result)=>{
       const rawHtml = result.html || result;
       
       // Check if response contains target templates
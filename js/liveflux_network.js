@@ -10,11 +10,30 @@
 
   /**
    * Performs a POST request to the Liveflux endpoint and returns HTML.
-   * @param {Record<string, string | string[]>} params
+   * Params holding File or Blob values are sent as multipart/form-data over
+   * XMLHttpRequest so upload progress can be reported.
+   * @param {Record<string, string | string[] | File | File[]>} params
+   * @param {{onUploadProgress?: function(ProgressEvent): void}} [options]
    * @returns {Promise<{html: string, response: Response}>}
    */
-  function post(params){
-    const body = new URLSearchParams();
+  function post(params, options){
+    params = withLiveComponents(withComponentState(params));
+    options = options || {};
+    const chunkSize = window.liveflux.uploadChunkSize || 0;
+    if(chunkSize > 0 && hasFiles(params, chunkSize)){
+      return uploadLargeFiles(params, chunkSize, options).then(function(upload){
+        return send(upload.params, options).then(function(result){
+          upload.resumeKeys.forEach(forgetUpload);
+          return result;
+        });
+      });
+    }
+    return send(params, options);
+  }
+
+  function send(params, options){
+    const multipart = hasFiles(params);
+    const body = multipart ? new FormData() : new URLSearchParams();
     Object.keys(params || {}).forEach(function(key){
       const value = params[key];
       if(value === undefined || value === null) return;
@@ -25,30 +44,40 @@
       }
     });
     const endpoint = window.liveflux.endpoint || '/liveflux';
-    const headers = Object.assign({
+    const headers = Object.assign(multipart ? {
+      // The browser sets the multipart boundary itself
+      'Accept':'text/html'
+    } : {
       'Content-Type':'application/x-www-form-urlencoded',
       'Accept':'text/html'
     }, window.liveflux.headers || {});
     const credentials = window.liveflux.credentials || 'same-origin';
     const timeoutMs = window.liveflux.timeoutMs || 0;
 
-    const controller = (timeoutMs > 0 && 'AbortController' in window) ? new AbortController() : null;
-    let timeoutId = null;
-    if (controller && timeoutMs > 0) timeoutId = setTimeout(()=>controller.abort(), timeoutMs);
-
     const HDR_REDIRECT = window.liveflux.redirectHeader || 'X-Liveflux-Redirect';
     const HDR_REDIRECT_AFTER = window.liveflux.redirectAfterHeader || 'X-Liveflux-Redirect-After';
 
-    return fetch(endpoint,{
-      method:'POST', headers, body, credentials,
-      signal: controller ? controller.signal : undefined,
-    }).finally(()=>{ if (timeoutId) clearTimeout(timeoutId); })
-      .then(async (res)=>{
+    let request;
+    if(multipart){
+      request = upload(endpoint, headers, body, credentials, timeoutMs, options.onUploadProgress);
+    } else {
+      const controller = (timeoutMs > 0 && 'AbortController' in window) ? new AbortController() : null;
+      let timeoutId = null;
+      if (controller && timeoutMs > 0) timeoutId = setTimeout(()=>controller.abort(), timeoutMs);
+      request = fetch(endpoint,{
+        method:'POST', headers, body, credentials,
+        signal: controller ? controller.signal : undefined,
+      }).finally(()=>{ if (timeoutId) clearTimeout(timeoutId); });
+    }
+
+    return request.then(async (res)=>{
         if(!res.ok) throw new Error(''+res.status);
 
         // Process events from response
         const componentId = params.liveflux_component_id || '';
         const componentKind = params.liveflux_component_kind || '';
+        updateComponentState(res, componentKind, componentId);
+        updatePoll(res, componentKind, componentId);
         if(window.liveflux.events && window.liveflux.events.processEvents){
           window.liveflux.events.processEvents(res, componentId, componentKind);
         }
@@ -62,10 +91,270 @@
           return { html:'', response: res };
         }
         const html = await res.text();
+        // Start or stop polling once the caller swapped the response in
+        if(typeof window.liveflux.initPolls === 'function'){
+          setTimeout(()=>window.liveflux.initPolls(), 0);
+        }
         return { html, response: res };
       });
   }
 
+  // hasFiles reports whether params hold a Blob larger than minSize bytes.
+  function hasFiles(params, minSize){
+    if(typeof Blob === 'undefined') return false;
+    return Object.keys(params || {}).some(function(key){
+      const value = params[key];
+      const values = Array.isArray(value) ? value : [value];
+      return values.some(function(v){ return v instanceof Blob && v.size > (minSize || -1); });
+    });
+  }
+
+  const CHUNK_RETRIES = 3;
+  const RESUME_PREFIX = 'liveflux-upload:';
+
+  /**
+   * Sends files larger than chunkSize with the chunked upload protocol and
+   * replaces them in params with liveflux_chunked_upload[field] references,
+   * which the action request finalizes. Progress is reported once per chunk.
+   * @returns {Promise<{params: Object, resumeKeys: string[]}>}
+   */
+  async function uploadLargeFiles(params, chunkSize, options){
+    const out = Object.assign({}, params);
+    const large = [];
+    Object.keys(params).forEach(function(key){
+      const value = params[key];
+      const values = Array.isArray(value) ? value : [value];
+      const keep = values.filter(function(v){ return !(v instanceof Blob && v.size > chunkSize); });
+      values.forEach(function(v){ if(v instanceof Blob && v.size > chunkSize) large.push({ field: key, file: v }); });
+      if(keep.length === values.length) return;
+      if(keep.length) out[key] = Array.isArray(value) ? keep : keep[0];
+      else delete out[key];
+    });
+
+    const total = large.reduce(function(sum, item){ return sum + item.file.size; }, 0);
+    let done = 0;
+    const resumeKeys = [];
+    for(const item of large){
+      const upload = await uploadChunked(item.file, item.field, params, chunkSize, function(offset, chunk, chunks){
+        if(typeof options.onUploadProgress !== 'function') return;
+        options.onUploadProgress({ lengthComputable: true, loaded: done + offset, total: total, chunk: chunk, chunks: chunks });
+      });
+      done += item.file.size;
+      resumeKeys.push(upload.resumeKey);
+      const ref = 'liveflux_chunked_upload[' + item.field + ']';
+      out[ref] = (out[ref] || []).concat(upload.id);
+    }
+    return { params: out, resumeKeys: resumeKeys };
+  }
+
+  /**
+   * Uploads one file chunk by chunk. Interrupted uploads resume from the
+   * server's offset, also after a page reload (the upload id is remembered
+   * in localStorage). Failed chunks are retried a few times.
+   * @returns {Promise<{id: string, resumeKey: string}>}
+   */
+  async function uploadChunked(file, field, params, chunkSize, onChunk){
+    const kind = params.liveflux_component_kind || '';
+    const componentId = params.liveflux_component_id || '';
+    const resumeKey = RESUME_PREFIX + [kind, componentId, field, file.name || '', file.size, file.lastModified || 0].join(':');
+
+    const initRes = await uploadRequest({
+      liveflux_upload: 'init',
+      liveflux_upload_id: recallUpload(resumeKey),
+      liveflux_component_kind: kind,
+      liveflux_component_id: componentId,
+      // The server checks the component before accepting the upload
+      liveflux_component_state: params.liveflux_component_state,
+      liveflux_upload_field: field,
+      liveflux_upload_filename: file.name || '',
+      liveflux_upload_type: file.type || '',
+      liveflux_upload_size: String(file.size),
+    });
+    if(!initRes.ok) throw new Error(''+initRes.status);
+    const status = await initRes.json();
+    rememberUpload(resumeKey, status.id);
+
+    const size = Math.min(chunkSize, status.chunk_size || chunkSize);
+    const chunks = Math.max(1, Math.ceil(file.size / size));
+    let offset = status.offset || 0;
+    let failures = 0;
+    while(offset < file.size){
+      const chunk = file.slice(offset, offset + size);
+      let res = null;
+      try {
+        res = await uploadRequest({
+          liveflux_upload: 'chunk',
+          liveflux_upload_id: status.id,
+          liveflux_component_kind: kind,
+          liveflux_component_id: componentId,
+          liveflux_upload_offset: String(offset),
+          liveflux_upload_checksum: await sha256Hex(chunk),
+        }, chunk);
+      } catch(err) {
+        res = null;
+      }
+      if(res && (res.ok || res.status === 409)){
+        // 409: the server already holds more (or less) of the file
+        offset = (await res.json()).offset;
+        failures = 0;
+        onChunk(offset, Math.ceil(offset / size), chunks);
+        continue;
+      }
+      if(res && (res.status === 404 || res.status === 413)){
+        forgetUpload(resumeKey);
+        throw new Error(''+res.status);
+      }
+      if(++failures > CHUNK_RETRIES) throw new Error(res ? ''+res.status : 'upload failed');
+    }
+    return { id: status.id, resumeKey: resumeKey };
+  }
+
+  function uploadRequest(query, body){
+    const endpoint = window.liveflux.endpoint || '/liveflux';
+    const search = new URLSearchParams();
+    Object.keys(query).forEach(function(key){
+      if(query[key]) search.append(key, query[key]);
+    });
+    const headers = Object.assign({
+      'Content-Type':'application/octet-stream',
+      'Accept':'application/json'
+    }, window.liveflux.headers || {});
+    return fetch(endpoint + (endpoint.indexOf('?') >= 0 ? '&' : '?') + search.toString(), {
+      method:'POST', headers, body: body || null,
+      credentials: window.liveflux.credentials || 'same-origin',
+    });
+  }
+
+  // sha256Hex returns the chunk checksum, or '' where Web Crypto is
+  // unavailable (non-secure contexts); the server then skips the check.
+  async function sha256Hex(blob){
+    const subtle = window.crypto && window.crypto.subtle;
+    if(!subtle || typeof blob.arrayBuffer !== 'function') return '';
+    const digest = await subtle.digest('SHA-256', await blob.arrayBuffer());
+    return Array.from(new Uint8Array(digest)).map(function(b){ return b.toString(16).padStart(2, '0'); }).join('');
+  }
+
+  function recallUpload(key){
+    try { return window.localStorage.getItem(key) || ''; } catch(err) { return ''; }
+  }
+
+  function rememberUpload(key, id){
+    try { window.localStorage.setItem(key, id); } catch(err) { /* storage unavailable */ }
+  }
+
+  function forgetUpload(key){
+    try { window.localStorage.removeItem(key); } catch(err) { /* storage unavailable */ }
+  }
+
+  /**
+   * Sends a multipart body with XMLHttpRequest, which unlike fetch reports
+   * upload progress, and resolves with a Response so the caller can treat
+   * both paths alike.
+   * @returns {Promise<Response>}
+   */
+  function upload(endpoint, headers, body, credentials, timeoutMs, onProgress){
+    return new Promise(function(resolve, reject){
+      const xhr = new XMLHttpRequest();
+      xhr.open('POST', endpoint, true);
+      xhr.withCredentials = credentials === 'include';
+      if(timeoutMs > 0) xhr.timeout = timeoutMs;
+      Object.keys(headers).forEach(function(name){ xhr.setRequestHeader(name, headers[name]); });
+      if(typeof onProgress === 'function' && xhr.upload){
+        xhr.upload.addEventListener('progress', onProgress);
+      }
+      xhr.onload = function(){
+        // Null-body statuses reject a body in the Response constructor
+        const text = [204, 205, 304].indexOf(xhr.status) >= 0 ? null : xhr.responseText;
+        resolve(new Response(text, {
+          status: xhr.status,
+          statusText: xhr.statusText,
+          headers: parseHeaders(xhr.getAllResponseHeaders()),
+        }));
+      };
+      xhr.onerror = function(){ reject(new TypeError('Network request failed')); };
+      xhr.ontimeout = function(){ reject(new Error('timeout')); };
+      xhr.onabort = function(){ reject(new Error('aborted')); };
+      xhr.send(body);
+    });
+  }
+
+  function parseHeaders(raw){
+    const headers = new Headers();
+    (raw || '').trim().split(/[\r\n]+/).forEach(function(line){
+      const idx = line.indexOf(':');
+      if(idx <= 0) return;
+      headers.append(line.slice(0, idx).trim(), line.slice(idx + 1).trim());
+    });
+    return headers;
+  }
+
+  /**
+   * Adds the signed client-side snapshot (data-flux-state on the component root)
+   * to action requests. Only present when the server uses ClientStateStore.
+   * @param {Record<string, string | string[]>} params
+   * @returns {Record<string, string | string[]>}
+   */
+  function withComponentState(params){
+    if(!params || !params.liveflux_component_id || params.liveflux_component_state !== undefined) return params;
+    const root = findRoot(params.liveflux_component_kind, params.liveflux_component_id);
+    const stateAttr = window.liveflux.dataFluxState || 'data-flux-state';
+    const state = root ? root.getAttribute(stateAttr) : null;
+    if(!state) return params;
+    return Object.assign({}, params, { liveflux_component_state: state });
+  }
+
+  // withLiveComponents lists the other component instances on the page in
+  // liveflux_components as kind:id pairs, so the server can route dispatched
+  // events to them and return their updated HTML with the action's response.
+  function withLiveComponents(params){
+    if(!params || !params.liveflux_component_id || params.liveflux_components !== undefined) return params;
+    if(typeof window.liveflux.findAllComponents !== 'function') return params;
+    const kindAttr = window.liveflux.dataFluxComponentKind || 'data-flux-component-kind';
+    const idAttr = window.liveflux.dataFluxComponentID || 'data-flux-component-id';
+    const ids = [];
+    const pairs = [];
+    window.liveflux.findAllComponents().forEach(function(root){
+      const kind = root.getAttribute(kindAttr);
+      const id = root.getAttribute(idAttr);
+      if(!kind || !id || id === params.liveflux_component_id || ids.indexOf(id) !== -1) return;
+      ids.push(id);
+      pairs.push(encodeURIComponent(kind) + ':' + encodeURIComponent(id));
+    });
+    if(!pairs.length) return params;
+    return Object.assign({}, params, { liveflux_components: pairs.join(',') });
+  }
+
+  // updateComponentState refreshes data-flux-state from the response header so
+  // targeted (fragment-only) responses keep the root snapshot current.
+  function updateComponentState(res, componentKind, componentId){
+    const hdr = window.liveflux.stateHeader || 'X-Liveflux-State';
+    const state = res.headers.get(hdr);
+    if(!state || !componentId) return;
+    const root = findRoot(componentKind, componentId);
+    if(root) root.setAttribute(window.liveflux.dataFluxState || 'data-flux-state', state);
+  }
+
+  // updatePoll applies a poll directive changed by the server (see
+  // Base.SetPollInterval) to the root's data-flux-poll; "0" stops polling.
+  function updatePoll(res, componentKind, componentId){
+    const hdr = window.liveflux.pollHeader || 'X-Liveflux-Poll';
+    const directive = res.headers.get(hdr);
+    if(directive === null || !componentId) return;
+    const root = findRoot(componentKind, componentId);
+    if(!root) return;
+    const attr = window.liveflux.dataFluxPoll || 'data-flux-poll';
+    if(directive === '0') root.removeAttribute(attr);
+    else root.setAttribute(attr, directive);
+  }
+
+  function findRoot(componentKind, componentId){
+    if(typeof window.liveflux.findComponent === 'function'){
+      return window.liveflux.findComponent(componentKind, componentId);
+    }
+    return null;
+  }
+
   // Expose on liveflux
   window.liveflux.post = post;
+  window.liveflux.withLiveComponents = withLiveComponents;
 })();
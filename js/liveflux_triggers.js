@@ -60,11 +60,16 @@
         modifiers: {}
       };
 
-      tokens.forEach(token => {
-        if (!token) return;
+      for (let i = 0; i < tokens.length; i++) {
+        const token = tokens[i];
+        if (!token) continue;
 
+        // "every <duration>" fires on an interval instead of an event
+        if (token === 'every') {
+          definition.every = parseDuration(tokens[++i]);
+        }
         // Check for modifiers (contains colon)
-        if (token.includes(':')) {
+        else if (token.includes(':')) {
           const [key, value] = token.split(':');
           if (key === 'delay' || key === 'throttle') {
             definition.modifiers[key] = parseDuration(value);
@@ -82,10 +87,10 @@
         else {
           definition.events.push(token);
         }
-      });
+      }
 
       // If no events specified, infer default based on element type
-      if (definition.events.length === 0) {
+      if (definition.events.length === 0 && !definition.every) {
         const defaultEvents = inferDefaultEvents(el);
         definition.events = defaultEvents.split(/\s+/).filter(Boolean);
       }
@@ -239,6 +244,8 @@
 
   /**
    * Fire the action for a trigger
+   * @returns {Promise<boolean>|undefined} The request (see sendTriggerRequest),
+   *   or undefined when there is no action to fire
    */
   function fireTriggerAction(el, eventName, metadata) {
     if (!metadata) {
@@ -268,6 +275,23 @@
       liveflux_action: action
     });
 
+    const request = sendTriggerRequest(el, eventName, metadata, params);
+
+    // Mark as fired for 'once' filter
+    const state = triggerRegistry.get(el);
+    if (state) {
+      state.fired = true;
+    }
+
+    return request;
+  }
+
+  /**
+   * Post trigger params and swap the response into the component root.
+   * onSwap(newRoot) runs after a full root replacement.
+   * @returns {Promise<boolean>} Resolves to whether the request succeeded
+   */
+  function sendTriggerRequest(el, eventName, metadata, params, onSwap) {
     // Store trigger event name for header
     const triggerEventName = eventName;
 
@@ -280,7 +304,9 @@
       'X-Liveflux-Trigger': triggerEventName
     });
 
-    liveflux.post(params).then((result) => {
+    const onUploadProgress = (event) => liveflux.updateRequestProgress(indicatorEls, event);
+
+    return liveflux.post(params, { onUploadProgress }).then((result) => {
       // Restore original headers
       liveflux.headers = originalHeaders;
       const rawHtml = result.html || result;
@@ -296,12 +322,13 @@
           if (newNode && metadata.root) {
             metadata.root.replaceWith(newNode);
             liveflux.executeScripts(newNode);
+            if (onSwap) onSwap(newNode);
           }
         }
         if (liveflux.initWire) liveflux.initWire();
         // Re-init triggers after DOM update
         if (liveflux.initTriggers) liveflux.initTriggers();
-        return;
+        return true;
       }
       
       // Traditional full replacement
@@ -311,23 +338,20 @@
       if (newNode && metadata.root) {
         metadata.root.replaceWith(newNode);
         liveflux.executeScripts(newNode);
+        if (onSwap) onSwap(newNode);
         if (liveflux.initWire) liveflux.initWire();
         // Re-init triggers after DOM update
         if (liveflux.initTriggers) liveflux.initTriggers();
       }
+      return true;
     }).catch((err) => {
       // Restore original headers on error
       liveflux.headers = originalHeaders;
       console.error(`${TRIGGER_LOG_PREFIX} Action failed:`, err);
+      return false;
     }).finally(() => {
       liveflux.endRequestIndicators(indicatorEls);
     });
-
-    // Mark as fired for 'once' filter
-    const state = triggerRegistry.get(el);
-    if (state) {
-      state.fired = true;
-    }
   }
 
   /**
@@ -428,6 +452,11 @@
     const listeners = [];
 
     definitions.forEach(definition => {
+      if (definition.every) {
+        // Stops by itself once the element leaves the DOM
+        startPoll(el, definition.every, () => el.isConnected ? fireTriggerAction(el, 'every', metadata) : null);
+      }
+
       const handler = createTriggerHandler(el, definition, metadata);
       
       definition.events.forEach(eventName => {
@@ -466,6 +495,8 @@
       timers.clear();
     }
 
+    stopPoll(el);
+
     // Clean up state
     triggerRegistry.delete(el);
     valueCache.delete(el);
@@ -478,10 +509,13 @@
   function initTriggers(root) {
     if (!config.enableTriggers) return;
 
+    initModels();
+
     const searchRoot = root || document;
     const elements = searchRoot.querySelectorAll('[data-flux-trigger], [flux-trigger]');
     
     elements.forEach(registerTriggers);
+    initPolls(root);
     
     console.log(`${TRIGGER_LOG_PREFIX} Initialized ${elements.length} trigger element(s)`);
   }
@@ -501,6 +535,327 @@
     }
   }
 
+  /**
+   * Polling (data-flux-poll and the "every" trigger)
+   *
+   * data-flux-poll="5s"           re-render the component every 5 seconds
+   * data-flux-poll="5s refresh"   call the "refresh" action instead
+   * data-flux-trigger="every 5s"  fire the element's action every 5 seconds
+   *
+   * Polls pause while the page is hidden and fire once, if they came due,
+   * when it becomes visible again. After a failed request the interval
+   * doubles, up to POLL_MAX_BACKOFF times, until a request succeeds. The
+   * server changes or stops a component's polling with data-flux-poll (see
+   * Base.SetPollInterval).
+   */
+  const POLL_MAX_BACKOFF = 8;
+  // key (component "kind:id" or trigger element) -> poll state
+  const polls = new Map();
+  let visibilityInitialized = false;
+
+  function pollAttr() {
+    return liveflux.dataFluxPoll || 'data-flux-poll';
+  }
+
+  /**
+   * Parse a data-flux-poll value
+   * @returns {{interval: number, action: string}|null} null when polling is off
+   */
+  function parsePoll(value) {
+    const [duration, action] = (value || '').trim().split(/\s+/);
+    const interval = parseDuration(duration);
+    if (!interval) return null;
+    return { interval, action: action || '' };
+  }
+
+  /**
+   * Start polling: run() is called every interval and returns a
+   * Promise<boolean> for the request it sent, or null to stop polling.
+   * Starting an existing poll with the same interval only replaces run().
+   */
+  function startPoll(key, interval, run) {
+    const existing = polls.get(key);
+    if (existing && existing.interval === interval) {
+      existing.run = run;
+      return;
+    }
+    stopPoll(key);
+
+    const poll = { interval, run, timer: null, failures: 0, due: false };
+    polls.set(key, poll);
+    schedulePoll(key, poll);
+    initVisibility();
+  }
+
+  function stopPoll(key) {
+    const poll = polls.get(key);
+    if (!poll) return;
+    clearTimeout(poll.timer);
+    polls.delete(key);
+  }
+
+  function schedulePoll(key, poll) {
+    const backoff = Math.min(Math.pow(2, poll.failures), POLL_MAX_BACKOFF);
+    poll.timer = setTimeout(() => tickPoll(key, poll), poll.interval * backoff);
+  }
+
+  function tickPoll(key, poll) {
+    poll.timer = null;
+    if (polls.get(key) !== poll) return;
+
+    // Wait for the page to become visible again
+    if (document.hidden) {
+      poll.due = true;
+      return;
+    }
+    poll.due = false;
+
+    const request = poll.run();
+    if (!request) {
+      stopPoll(key);
+      return;
+    }
+    request.then(ok => {
+      // Stopped or restarted meanwhile
+      if (polls.get(key) !== poll) return;
+      poll.failures = ok ? 0 : poll.failures + 1;
+      schedulePoll(key, poll);
+    });
+  }
+
+  /**
+   * Fire the polls that came due while the page was hidden, once it is
+   * visible again
+   */
+  function initVisibility() {
+    if (visibilityInitialized) return;
+    visibilityInitialized = true;
+    document.addEventListener('visibilitychange', () => {
+      if (document.hidden) return;
+      polls.forEach((poll, key) => {
+        if (poll.due) tickPoll(key, poll);
+      });
+    });
+  }
+
+  /**
+   * Refresh the component, or call its poll action, with its bound values.
+   * The root is looked up on every tick, as responses replace it.
+   */
+  function pollComponent(kind, id) {
+    const root = liveflux.findComponent(kind, id);
+    const poll = root && parsePoll(root.getAttribute(pollAttr()));
+    if (!poll) return null;
+
+    const params = Object.assign({}, collectModels(root), {
+      liveflux_component_kind: kind,
+      liveflux_component_id: id
+    });
+    if (poll.action) params.liveflux_action = poll.action;
+
+    return sendTriggerRequest(root, 'poll', { comp: kind, id, root }, params);
+  }
+
+  /**
+   * Start polling for every component root with data-flux-poll in root
+   * (default document), including root itself
+   */
+  function initPolls(root) {
+    if (!config.enableTriggers) return;
+
+    const searchRoot = root || document;
+    const attr = pollAttr();
+    const roots = Array.from(searchRoot.querySelectorAll(`[${attr}]`));
+    if (searchRoot.hasAttribute && searchRoot.hasAttribute(attr)) {
+      roots.push(searchRoot);
+    }
+
+    roots.forEach(el => {
+      if (!liveflux.isComponentRootNode(el)) return;
+      const kind = el.getAttribute(liveflux.dataFluxComponentKind || 'data-flux-component-kind');
+      const id = el.getAttribute(liveflux.dataFluxComponentID || 'data-flux-component-id');
+      const poll = parsePoll(el.getAttribute(attr));
+      const key = `${kind}:${id}`;
+      if (!poll) {
+        stopPoll(key);
+        return;
+      }
+      startPoll(key, poll.interval, () => pollComponent(kind, id));
+    });
+  }
+
+  /**
+   * Two-way binding (data-flux-model)
+   *
+   * data-flux-model="Email"                  sync on input, debounced 150ms
+   * data-flux-model.debounce.500ms="Email"  sync on input, debounced 500ms
+   * data-flux-model.lazy="Email"             sync on change
+   * data-flux-model.blur="Email"             sync on blur
+   *
+   * Values are posted as liveflux_model[Field] and are also sent with every
+   * action from the component, so lazy fields are current when it runs.
+   * Listeners are delegated from document, so re-rendered inputs need no
+   * registration.
+   */
+  const MODEL_ATTR = 'data-flux-model';
+  const MODEL_PARAM_PREFIX = 'liveflux_model';
+  const MODEL_DEFAULT_DEBOUNCE = 150;
+  const modelTimers = new WeakMap();
+  let modelsInitialized = false;
+
+  /**
+   * Read the bound field and modifiers from data-flux-model[.modifiers]
+   * @returns {{field: string, modifiers: string[]}|null}
+   */
+  function readModel(el) {
+    if (!el || !el.attributes) return null;
+    for (const attr of Array.from(el.attributes)) {
+      if (attr.name === MODEL_ATTR || attr.name.startsWith(MODEL_ATTR + '.')) {
+        const field = (attr.value || '').trim();
+        if (!field) return null;
+        return { field, modifiers: attr.name.split('.').slice(1) };
+      }
+    }
+    return null;
+  }
+
+  /**
+   * Resolve the sync event and debounce delay from model modifiers
+   */
+  function modelTiming(el, modifiers) {
+    const type = (el.type || '').toLowerCase();
+    const isToggle = el.tagName === 'SELECT' || type === 'checkbox' || type === 'radio';
+    let event = isToggle ? 'change' : 'input';
+    let delay = isToggle ? 0 : MODEL_DEFAULT_DEBOUNCE;
+
+    if (modifiers.includes('lazy')) {
+      event = 'change';
+      delay = 0;
+    }
+    if (modifiers.includes('blur')) {
+      event = 'blur';
+      delay = 0;
+    }
+    const i = modifiers.indexOf('debounce');
+    if (i !== -1) {
+      delay = modifiers[i + 1] ? parseDuration(modifiers[i + 1]) : MODEL_DEFAULT_DEBOUNCE;
+    }
+    return { event, delay };
+  }
+
+  /**
+   * Find the bound elements inside a component root
+   */
+  function modelElements(root) {
+    if (!root || !root.querySelectorAll) return [];
+    return Array.from(root.querySelectorAll('input, select, textarea')).filter(el => readModel(el));
+  }
+
+  /**
+   * Read the current value of a bound field. Checkbox groups and multiple
+   * selects produce arrays; a single checkbox produces "true"/"false".
+   */
+  function modelValue(el, root) {
+    const { field } = readModel(el);
+    const type = (el.type || '').toLowerCase();
+
+    if (type === 'checkbox') {
+      const group = modelElements(root).filter(other => other.type === 'checkbox' && readModel(other).field === field);
+      if (group.length > 1) {
+        return group.filter(box => box.checked).map(box => box.value);
+      }
+      return el.checked ? 'true' : 'false';
+    }
+    if (type === 'radio') {
+      const checked = modelElements(root).find(other => other.type === 'radio' && other.checked && readModel(other).field === field);
+      return checked ? checked.value : '';
+    }
+    if (el.tagName === 'SELECT' && el.multiple) {
+      return Array.from(el.options).filter(o => o.selected).map(o => o.value);
+    }
+    return el.value;
+  }
+
+  /**
+   * Collect liveflux_model[Field] params for every bound element in root
+   */
+  function collectModels(root) {
+    const params = {};
+    modelElements(root).forEach(el => {
+      const key = `${MODEL_PARAM_PREFIX}[${readModel(el).field}]`;
+      if (params[key] === undefined) {
+        const value = modelValue(el, root);
+        // An empty group still posts the key so the server can clear it
+        params[key] = Array.isArray(value) && value.length === 0 ? '' : value;
+      }
+    });
+    return params;
+  }
+
+  /**
+   * Post the bound values of the element's component and restore focus
+   * to the same field after the root is replaced
+   */
+  function fireModelUpdate(el, eventName, metadata) {
+    const params = Object.assign({}, collectModels(metadata.root), {
+      liveflux_component_kind: metadata.comp,
+      liveflux_component_id: metadata.id
+    });
+
+    const { field } = readModel(el);
+    const hadFocus = document.activeElement === el;
+    const selection = hadFocus && typeof el.selectionStart === 'number'
+      ? [el.selectionStart, el.selectionEnd]
+      : null;
+
+    sendTriggerRequest(el, eventName, metadata, params, (newRoot) => {
+      if (!hadFocus) return;
+      const next = modelElements(newRoot).find(other => readModel(other).field === field);
+      if (!next) return;
+      next.focus();
+      if (selection && typeof next.setSelectionRange === 'function') {
+        try { next.setSelectionRange(selection[0], selection[1]); } catch (_) {}
+      }
+    });
+  }
+
+  /**
+   * Delegated handler for input, change and focusout events on bound fields
+   */
+  function handleModelEvent(event) {
+    const el = event.target;
+    const model = readModel(el);
+    if (!model) return;
+
+    const eventName = event.type === 'focusout' ? 'blur' : event.type;
+    const timing = modelTiming(el, model.modifiers);
+    if (eventName !== timing.event) return;
+
+    const metadata = liveflux.resolveComponentMetadata(el, liveflux.getComponentRootSelector());
+    if (!metadata) {
+      console.warn(`${TRIGGER_LOG_PREFIX} No component metadata found for model`, el);
+      return;
+    }
+
+    clearTimeout(modelTimers.get(el));
+    if (timing.delay) {
+      modelTimers.set(el, setTimeout(() => fireModelUpdate(el, eventName, metadata), timing.delay));
+    } else {
+      fireModelUpdate(el, eventName, metadata);
+    }
+  }
+
+  /**
+   * Install the delegated model listeners once
+   */
+  function initModels() {
+    if (modelsInitialized || !config.enableTriggers) return;
+    modelsInitialized = true;
+    ['input', 'change', 'focusout'].forEach(type => {
+      document.addEventListener(type, handleModelEvent);
+    });
+  }
+
   /**
    * Configure trigger system
    */
@@ -520,5 +875,8 @@
   liveflux.initTriggers = initTriggers;
   liveflux.cleanupTriggers = cleanupTriggers;
   liveflux.configureTriggers = configureTriggers;
+  liveflux.initPolls = initPolls;
+  liveflux.readModel = readModel;
+  liveflux.collectModels = collectModels;
 
 })();
@@ -8,6 +8,8 @@
   const { dataFluxParam, dataFluxIndicator, dataFluxSelect } = liveflux;
   const REQUEST_CLASS = 'flux-request';
   const INDICATOR_ORIGINAL_DISPLAY_ATTR = 'data-liveflux-indicator-original-display';
+  const UPLOADING_CLASS = 'flux-uploading';
+  const PROGRESS_ATTR = 'data-flux-progress';
   const dataParamPrefix = `${dataFluxParam}-`;
   const SELECT_LOG_PREFIX = '[Liveflux Select]';
 
@@ -46,6 +48,11 @@
       const name = field.name; if(!name) return;
       const type = (field.type||'').toLowerCase();
       if((type === 'checkbox' || type === 'radio') && !field.checked) return;
+      if(type === 'file'){
+        // File objects switch liveflux.post to a multipart upload
+        Array.from(field.files || []).forEach((file)=>addValue(name, file));
+        return;
+      }
       if(field.tagName === 'SELECT' && field.multiple){
         const selected = Array.from(field.options).filter(o=>o.selected).map(o=>o.value);
         if(selected.length === 0) return;
@@ -137,7 +144,8 @@
   // collectAllFields implements the form-less submission feature.
   // It collects fields from the default scope (form or root), then merges
   // fields from elements specified in data-flux-include, removes fields
-  // from data-flux-exclude, and finally merges button params.
+  // from data-flux-exclude, adds data-flux-model values and finally merges
+  // button params.
   function collectAllFields(btn, root, assocForm){
     if(!btn) return {};
 
@@ -214,7 +222,12 @@
       });
     }
 
-    // 4. Merge button params (highest precedence)
+    // 4. Merge data-flux-model values so bound fields are current when the action runs
+    if(root && typeof liveflux.collectModels === 'function'){
+      Object.assign(fields, liveflux.collectModels(root));
+    }
+
+    // 5. Merge button params (highest precedence)
     const btnParams = readParams(btn);
     if(btn.name){
       btnParams[btn.name] = btn.value;
@@ -354,6 +367,9 @@
     if(!elements) return;
     elements.forEach(function(el){
       el.classList.remove(REQUEST_CLASS);
+      el.classList.remove(UPLOADING_CLASS);
+      el.removeAttribute(PROGRESS_ATTR);
+      el.style.removeProperty('--flux-progress');
       if(el.hasAttribute(INDICATOR_ORIGINAL_DISPLAY_ATTR)){
         const originalDisplay = el.getAttribute(INDICATOR_ORIGINAL_DISPLAY_ATTR);
         const shouldRestore = el.style.display === 'inline-block';
@@ -369,6 +385,31 @@
     });
   }
 
+  /**
+   * Reflects upload progress on the active indicators: adds the
+   * flux-uploading class, sets data-flux-progress and the --flux-progress
+   * CSS variable (0-100), and dispatches a bubbling flux-upload-progress
+   * event with {loaded, total, percent}. Chunked uploads report once per
+   * chunk and add {chunk, chunks} to the detail.
+   * @param {Element[]} elements
+   * @param {ProgressEvent} event
+   */
+  function updateRequestProgress(elements, event){
+    if(!elements || !event) return;
+    const total = event.lengthComputable ? event.total : 0;
+    const percent = total > 0 ? Math.min(100, Math.round(event.loaded / total * 100)) : 0;
+    elements.forEach(function(el){
+      el.classList.add(UPLOADING_CLASS);
+      el.setAttribute(PROGRESS_ATTR, String(percent));
+      el.style.setProperty('--flux-progress', String(percent));
+      el.dispatchEvent(new CustomEvent('flux-upload-progress', {
+        bubbles: true,
+        detail: Object.assign({ loaded: event.loaded, total: total, percent: percent },
+          event.chunks ? { chunk: event.chunk, chunks: event.chunks } : {})
+      }));
+    });
+  }
+
   // Expose on liveflux
   liveflux.executeScripts = executeScripts;
   liveflux.serializeElement = serializeElement;
@@ -382,5 +423,6 @@
   liveflux.resolveIndicators = resolveIndicators;
   liveflux.startRequestIndicators = startRequestIndicators;
   liveflux.endRequestIndicators = endRequestIndicators;
+  liveflux.updateRequestProgress = updateRequestProgress;
 
 })();
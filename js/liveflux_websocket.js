@@ -17,6 +17,20 @@
   const componentIdSelector = (id) => `[${dataFluxComponentID}="${id}"]`;
   const wsSelector = `[${dataFluxWS}]`;
 
+  const SEEN_BROADCASTS_MAX = 100;
+  const seenBroadcasts = [];
+
+  // One connection per URL, shared by every component on the page
+  const connections = {};
+
+  // Transports to try in order for each ClientOptions.Transport value
+  const TRANSPORTS = {
+    auto: ['websocket', 'sse', 'polling'],
+    websocket: ['websocket'],
+    sse: ['sse', 'polling'],
+    polling: ['polling'],
+  };
+
   class LiveFluxWS {
     constructor(url, options = {}){
       this.url = url;
@@ -25,16 +39,55 @@
       this.reconnectAttempts = 0;
       this.maxReconnectAttempts = options.maxReconnectAttempts || 5;
       this.reconnectDelay = options.reconnectDelay || 1000;
-      this.componentID = options.componentID || null;
-      this.rootEl = options.rootEl || document;
-      this.onOpen = options.onOpen || (()=>{});
-      this.onMessage = options.onMessage || (()=>{});
-      this.onClose = options.onClose || (()=>{});
-      this.onError = options.onError || (()=>{});
+      this.transports = (TRANSPORTS[options.transport || liveflux.transport || 'auto'] || TRANSPORTS.auto).slice();
+      this.transport = this.transports[0];
+      // Whether the current transport has ever connected
+      this.opened = false;
+      this.es = null;
+      this.pollTimer = null;
+      this.restartTimer = null;
+      this.componentID = null;
+      // componentID -> { rootEl, onOpen, onMessage, onClose, onError }
+      this.components = {};
+      // componentID -> last version seen, sent on reconnect to catch up
+      this.versions = {};
       this.connect();
       this.setupFormHandling();
+      if(options.componentID){ this.attach(options.componentID, options); }
+    }
+    // shared returns the page's connection for url, creating it on first use.
+    static shared(url, options = {}){
+      if(!connections[url]){ connections[url] = new LiveFluxWS(url, Object.assign({}, options, { componentID: null })); }
+      return connections[url];
+    }
+    // attach routes messages for componentID to this connection.
+    attach(componentID, options = {}){
+      const known = !!this.components[componentID];
+      this.components[componentID] = {
+        kind: options.kind || '',
+        rootEl: options.rootEl || document,
+        onOpen: options.onOpen || (()=>{}),
+        onMessage: options.onMessage || (()=>{}),
+        onClose: options.onClose || (()=>{}),
+        onError: options.onError || (()=>{}),
+      };
+      // Keep the first component as the default for messages without an ID
+      if(!this.componentID){ this.componentID = componentID; }
+      if(!known && this.connected){ this.send(this.initMessage(componentID)); }
+      if(!known) this.scheduleRestart();
+    }
+    // detach stops routing messages for componentID and tells the server.
+    detach(componentID){
+      if(!this.components[componentID]) return;
+      delete this.components[componentID];
+      delete this.versions[componentID];
+      if(this.componentID === componentID){ this.componentID = Object.keys(this.components)[0] || null; }
+      this.send({ type:'leave', componentID });
+      this.scheduleRestart();
     }
     connect(){
+      if(this.transport === 'sse') return this.connectSSE();
+      if(this.transport === 'polling') return this.startPolling();
       try {
         this.ws = new WebSocket(this.url);
         this.ws.onopen = this.handleOpen.bind(this);
@@ -43,34 +96,186 @@
         this.ws.onerror = this.handleError.bind(this);
       } catch (e){ console.error('[LFWS] connection error', e); this.handleError(e); }
     }
+    // connectSSE opens a Server-Sent Events stream for the attached
+    // components. Actions keep going over POST.
+    connectSSE(){
+      if(this.es){ this.es.close(); this.es = null; }
+      if(Object.keys(this.components).length === 0) return;
+      try {
+        const es = new EventSource(this.streamURL(), { withCredentials: liveflux.credentials === 'include' });
+        this.es = es;
+        es.onopen = this.handleOpen.bind(this);
+        es.onmessage = this.handleMessage.bind(this);
+        // Reconnect ourselves, so the stream resumes from the latest versions
+        es.onerror = (e)=>{ es.close(); if(this.es === es){ this.es = null; this.handleError(e); this.handleClose(e); } };
+      } catch (e){ console.error('[LFWS] connection error', e); this.handleError(e); this.handleClose(e); }
+    }
+    // startPolling asks the server for missed messages every pollIntervalMs.
+    startPolling(){
+      this.stopPolling();
+      const interval = liveflux.pollIntervalMs || 5000;
+      const tick = ()=>{
+        this.poll().finally(()=>{
+          const giveUp = !this.opened && this.reconnectAttempts >= this.maxReconnectAttempts;
+          if(this.transport === 'polling' && !giveUp) this.pollTimer = setTimeout(tick, interval);
+        });
+      };
+      this.pollTimer = setTimeout(tick, 0);
+    }
+    stopPolling(){ if(this.pollTimer){ clearTimeout(this.pollTimer); this.pollTimer = null; } }
+    poll(){
+      if(Object.keys(this.components).length === 0) return Promise.resolve();
+      const headers = Object.assign({ 'Accept':'application/json' }, liveflux.headers || {});
+      return fetch(this.streamURL(), { headers, credentials: liveflux.credentials || 'same-origin' })
+        .then((res)=>{ if(!res.ok) throw new Error(''+res.status); return res.json(); })
+        .then((body)=>{
+          if(!this.connected) this.handleOpen();
+          (body.messages || []).forEach((message)=>this.receive(message));
+          const versions = body.versions || {};
+          Object.keys(versions).forEach((id)=>{ if(versions[id] > (this.versions[id] || 0)) this.versions[id] = versions[id]; });
+        })
+        .catch((e)=>{
+          this.reconnectAttempts++;
+          if(this.connected){ this.connected = false; this.eachComponent((c)=>c.onClose(e)); }
+          this.handleError(e);
+        });
+    }
+    // streamURL returns the HTTP URL for an SSE stream or poll of the
+    // attached components, with the last version seen of each.
+    streamURL(){
+      const base = this.url.replace(/^ws(s?):/, 'http$1:');
+      const entries = Object.keys(this.components).map((id)=>this.versions[id] ? id + ':' + this.versions[id] : id);
+      return base + (base.indexOf('?') >= 0 ? '&' : '?') + 'liveflux_stream=' + encodeURIComponent(entries.join(','));
+    }
+    // scheduleRestart reopens an SSE stream once the attached components
+    // change, so the server sends messages for the new set.
+    scheduleRestart(){
+      if(this.transport !== 'sse' || this.restartTimer) return;
+      this.restartTimer = setTimeout(()=>{ this.restartTimer = null; this.connectSSE(); }, 0);
+    }
+    // fallBack switches to the next transport, if any.
+    fallBack(){
+      if(this.transports.length < 2) return false;
+      this.transports.shift();
+      this.transport = this.transports[0];
+      this.opened = false; this.reconnectAttempts = 0;
+      console.log('[LFWS] falling back to ' + this.transport);
+      this.connect();
+      return true;
+    }
+    // carriesActions reports whether actions go over this connection rather
+    // than POST requests.
+    carriesActions(){ return this.connected && this.transport === 'websocket'; }
+    // initMessage joins componentID, asking for the updates missed since the
+    // last version seen.
+    initMessage(componentID){
+      const message = { type:'init', componentID };
+      // The server checks the kind before the connection joins
+      const component = this.components[componentID];
+      if(component && component.kind) message.kind = component.kind;
+      if(this.versions[componentID]) message.version = this.versions[componentID];
+      return message;
+    }
+    // seen records a message's version and reports whether it was already
+    // handled; a resume may repeat messages that also arrived live.
+    seen(message){
+      if(!message.version || !message.componentID) return false;
+      const last = this.versions[message.componentID] || 0;
+      const resync = message.data && message.data.resync;
+      if(message.version <= last && !resync) return true;
+      this.versions[message.componentID] = message.version;
+      return false;
+    }
+    eachComponent(fn){
+      Object.keys(this.components).forEach((id)=>{ try { fn(this.components[id], id); } catch(e){ console.error('[LFWS] callback error', e); } });
+    }
     handleOpen(){
-      this.connected = true; this.reconnectAttempts = 0; this.onOpen();
-      if(this.componentID){ this.send({ type:'init', componentID: this.componentID }); }
+      this.connected = true; this.opened = true; this.reconnectAttempts = 0;
+      // Re-join every component, also after a reconnect
+      this.eachComponent((c, id)=>{ this.send(this.initMessage(id)); c.onOpen(); });
     }
     handleMessage(event){
-      try { const message = JSON.parse(event.data); this.onMessage(message); if(message.type==='update') this.handleUpdate(message); if(message.type==='redirect') window.location.href = message.url; } catch(e){ console.error('[LFWS] message error', e); }
+      try { this.receive(JSON.parse(event.data)); } catch(e){ console.error('[LFWS] message error', e); }
+    }
+    receive(message){
+      try {
+        if(this.seen(message)) return;
+        const component = this.components[message.componentID];
+        if(component){ component.onMessage(message); }
+        else if(!message.componentID){ this.eachComponent((c)=>c.onMessage(message)); }
+        if(message.type==='update') this.handleUpdate(message);
+        if(message.type==='events') this.handleEvents(message);
+        if(message.type==='redirect') this.handleRedirect(message);
+      } catch(e){ console.error('[LFWS] message error', e); }
+    }
+    handleRedirect(message){
+      const data = message.data || message;
+      if(!data.url) return;
+      const delay = (parseInt(data.after, 10) || 0) * 1000;
+      if(delay > 0){ setTimeout(()=>{ window.location.href = data.url; }, delay); }
+      else { window.location.href = data.url; }
+    }
+    handleEvents(message){
+      const data = message.data || {};
+      // A broadcast reaches every subscribed component; dispatch it once per page
+      if(data.id){
+        if(seenBroadcasts.indexOf(data.id) !== -1) return;
+        seenBroadcasts.push(data.id);
+        if(seenBroadcasts.length > SEEN_BROADCASTS_MAX) seenBroadcasts.shift();
+      }
+      if(liveflux.events && liveflux.events.processEventList){
+        liveflux.events.processEventList(data.events, message.componentID);
+      }
     }
     handleClose(event){
-      this.connected = false; this.onClose(event);
+      this.connected = false;
+      this.eachComponent((c)=>c.onClose(event));
+      // A transport that never connected is likely blocked, e.g. by a proxy
+      if(!this.opened && this.fallBack()) return;
       if (this.reconnectAttempts < this.maxReconnectAttempts){
         this.reconnectAttempts++; const delay = this.reconnectDelay * Math.pow(2, this.reconnectAttempts - 1);
         setTimeout(()=>this.connect(), delay);
+      } else {
+        this.fallBack();
+      }
+    }
+    handleError(error){ console.error('[LFWS] error', error); this.eachComponent((c)=>c.onError(error)); }
+    send(message){ if(this.carriesActions() && this.ws){ this.ws.send(JSON.stringify(message)); } }
+    sendAction(componentID, action, data={}){
+      // List the other components on the page so the server can route events to them
+      if(typeof liveflux.withLiveComponents === 'function'){
+        const params = liveflux.withLiveComponents({ liveflux_component_id: componentID });
+        if(params.liveflux_components && data.liveflux_components === undefined){
+          data = Object.assign({}, data, { liveflux_components: params.liveflux_components });
+        }
       }
+      this.send({ type:'action', componentID, action, data });
     }
-    handleError(error){ console.error('[LFWS] error', error); this.onError(error); }
-    send(message){ if(this.connected && this.ws){ this.ws.send(JSON.stringify(message)); } }
-    sendAction(componentID, action, data={}){ this.send({ type:'action', componentID, action, data }); }
+    // componentFor returns the attached component ID an element belongs to.
+    componentFor(el){
+      const own = el.getAttribute(dataFluxComponentID) || (el.dataset && el.dataset.fluxComponentId);
+      if(own && this.components[own]) return own;
+      let node = el.closest(wsSelector);
+      while(node){
+        const id = node.getAttribute(dataFluxComponentID) || (node.dataset && node.dataset.fluxComponentId);
+        if(id && this.components[id]) return id;
+        node = node.parentElement ? node.parentElement.closest(wsSelector) : null;
+      }
+      return null;
+    }
+    // setupFormHandling delegates from the document once per connection, so
+    // re-rendered components keep working without re-binding.
     setupFormHandling(){
-      this.rootEl.addEventListener('submit', (e)=>{
+      document.addEventListener('submit', (e)=>{
         const form = e.target.closest('form'); if(!form) return;
-        const componentID = form.getAttribute(dataFluxComponentID) || form.dataset.fluxComponentId || this.componentID;
+        const componentID = this.componentFor(form); if(!componentID) return;
         const action = form.getAttribute(dataFluxAction) || form.dataset.fluxAction || 'submit';
-        if(this.connected && componentID){ e.preventDefault(); const fd = new FormData(form); const data = {}; for(const [k,v] of fd.entries()) data[k]=v; this.sendAction(componentID, action, data); }
+        if(this.carriesActions()){ e.preventDefault(); const fd = new FormData(form); const data = {}; for(const [k,v] of fd.entries()) data[k]=v; this.sendAction(componentID, action, data); }
       });
-      this.rootEl.addEventListener('click', (e)=>{
-        const el = e.target.closest(actionSelectorWithFallback); if(!el || !this.connected) return;
+      document.addEventListener('click', (e)=>{
+        const el = e.target.closest(actionSelectorWithFallback); if(!el || !this.carriesActions()) return;
         if(el.tagName === 'FORM') return;
-        const componentID = el.getAttribute(dataFluxComponentID) || el.dataset.fluxComponentId || this.componentID; const action = el.getAttribute(dataFluxAction) || el.dataset.fluxAction; if(!componentID||!action) return; e.preventDefault();
+        const componentID = this.componentFor(el); const action = el.getAttribute(dataFluxAction) || el.dataset.fluxAction; if(!componentID||!action) return; e.preventDefault();
         const data = {}; for(const [key, value] of Object.entries(el.dataset)){ if(key.startsWith('fluxData')){ const k = key.replace(/^fluxData([A-Z])/, (_, p1) => p1.toLowerCase()); data[k]=value; } }
         this.sendAction(componentID, action, data);
       });
@@ -79,7 +284,7 @@
       const element = document.querySelector(componentIdSelector(message.componentID));
       if(element && message.data && message.data.html){
         const html = message.data.html;
-        
+
         // Check if response contains target templates
         if(liveflux.hasTargetTemplates && liveflux.hasTargetTemplates(html)){
           const fallback = liveflux.applyTargets(html, element);
@@ -91,44 +296,78 @@
           // Traditional full replacement
           element.outerHTML = html;
         }
-        
+
         if(this.connected){
           const refreshed = document.querySelector(componentIdSelector(message.componentID));
           if(refreshed){
             const status = refreshed.querySelector('.status'); if(status) status.textContent = 'Connected';
-            this.rootEl = refreshed; this.setupFormHandling();
           }
         }
+        // Pick up components the update added and let go of removed ones
+        autoInit();
+        this.pruneComponents();
       }
     }
-    close(){ if(this.ws){ this.ws.close(); } }
+    // pruneComponents detaches components no longer in the document.
+    pruneComponents(){
+      Object.keys(this.components).forEach((id)=>{
+        if(!document.querySelector(componentIdSelector(id))) this.detach(id);
+      });
+    }
+    close(){
+      if(this.ws){ this.ws.close(); }
+      if(this.es){ this.es.close(); this.es = null; }
+      this.stopPolling();
+    }
+  }
+
+  function wsURL(el){
+    const urlAttr = el.getAttribute(dataFluxWSURL) || el.dataset.fluxWsUrl;
+    if(urlAttr) return urlAttr;
+    const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
+    const cfg = window.liveflux || {};
+    const wsPath = cfg.wsEndpoint || cfg.endpoint || '/liveflux';
+    return `${protocol}//${window.location.host}${wsPath.startsWith('/') ? wsPath : ('/' + wsPath)}`;
   }
 
   function autoInit(){
     const wsElements = document.querySelectorAll(wsSelector);
     wsElements.forEach(el => {
-      const urlAttr = el.getAttribute(dataFluxWSURL) || el.dataset.fluxWsUrl;
-      const url = urlAttr || (()=>{
-        const protocol = window.location.protocol === 'https:' ? 'wss:' : 'ws:';
-        const cfg = window.liveflux || {};
-        const wsPath = cfg.wsEndpoint || cfg.endpoint || '/liveflux';
-        return `${protocol}//${window.location.host}${wsPath.startsWith('/') ? wsPath : ('/' + wsPath)}`;
-      })();
+      if(el._lfws) return;
       const componentID = el.getAttribute(dataFluxComponentID) || el.dataset.fluxComponentId || null;
-      const client = new LiveFluxWS(url, {
-        componentID,
+      if(!componentID) return;
+      const client = LiveFluxWS.shared(wsURL(el));
+      client.attach(componentID, {
+        kind: el.getAttribute(liveflux.dataFluxComponentKind || 'data-flux-component-kind') || '',
         rootEl: el,
-        onOpen: ()=>{ try{ el.dispatchEvent(new Event('flux-ws-open')); }catch(_){} const s = el.querySelector('.status'); if(s) s.textContent = 'Connected'; },
-        onClose: ()=>{ try{ el.dispatchEvent(new Event('flux-ws-close')); }catch(_){} const s = el.querySelector('.status'); if(s) s.textContent = 'Disconnected'; },
-        onError: (error)=>{ try{ const ev = new Event('flux-ws-error'); ev.error = error; el.dispatchEvent(ev); }catch(_){} const s = el.querySelector('.status'); if(s) s.textContent = 'Error'; },
-        onMessage: (message)=>{ try{ const ev = new Event('flux-ws-message'); ev.data = message; el.dispatchEvent(ev); }catch(_){} }
+        onOpen: ()=>{ const cur = currentRoot(componentID, el); try{ cur.dispatchEvent(new Event('flux-ws-open')); }catch(_){} const s = cur.querySelector('.status'); if(s) s.textContent = 'Connected'; },
+        onClose: ()=>{ const cur = currentRoot(componentID, el); try{ cur.dispatchEvent(new Event('flux-ws-close')); }catch(_){} const s = cur.querySelector('.status'); if(s) s.textContent = 'Disconnected'; },
+        onError: (error)=>{ const cur = currentRoot(componentID, el); try{ const ev = new Event('flux-ws-error'); ev.error = error; cur.dispatchEvent(ev); }catch(_){} const s = cur.querySelector('.status'); if(s) s.textContent = 'Error'; },
+        onMessage: (message)=>{ const cur = currentRoot(componentID, el); try{ const ev = new Event('flux-ws-message'); ev.data = message; cur.dispatchEvent(ev); }catch(_){} }
       });
       try { el._lfws = client; } catch(_){}
     });
   }
 
+  // currentRoot returns the component's live data-flux-ws element, which a
+  // full re-render replaces.
+  function currentRoot(componentID, fallback){
+    if(fallback && fallback.isConnected) return fallback;
+    const matches = document.querySelectorAll(componentIdSelector(componentID));
+    for(const el of matches){ if(el.matches(wsSelector)) return el; }
+    return matches[0] || fallback;
+  }
+
+  // isWebSocketElement reports whether a connected socket carries el's
+  // actions, so the HTTP handlers leave them alone.
+  function isWebSocketElement(el){
+    return Object.keys(connections).some((url)=>connections[url].carriesActions() && !!connections[url].componentFor(el));
+  }
+
   // Expose
   window.liveflux.LiveFluxWS = LiveFluxWS;
+  window.liveflux.isWebSocketElement = isWebSocketElement;
+  window.liveflux.initWebSockets = autoInit;
   try { window.LiveFluxWS = LiveFluxWS; } catch(_){}
 
   if (document.readyState === 'loading') document.addEventListener('DOMContentLoaded', autoInit); else autoInit();
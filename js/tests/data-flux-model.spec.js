@@ -0,0 +1,138 @@
+describe('Liveflux Model Binding', function() {
+    let originalPost;
+    let originalResolveComponentMetadata;
+    let originalStartRequestIndicators;
+    let originalEndRequestIndicators;
+    let root;
+
+    beforeAll(function() {
+        originalPost = window.liveflux.post;
+        originalResolveComponentMetadata = window.liveflux.resolveComponentMetadata;
+        originalStartRequestIndicators = window.liveflux.startRequestIndicators;
+        originalEndRequestIndicators = window.liveflux.endRequestIndicators;
+    });
+
+    beforeEach(function() {
+        root = document.createElement('div');
+        root.setAttribute('data-flux-component-kind', 'profile');
+        root.setAttribute('data-flux-component-id', 'profile-1');
+        document.body.appendChild(root);
+
+        window.liveflux.post = jasmine.createSpy('post').and.returnValue(Promise.resolve({ html: '' }));
+        window.liveflux.resolveComponentMetadata = jasmine.createSpy('resolveComponentMetadata').and.returnValue({
+            comp: 'profile',
+            id: 'profile-1',
+            root: root
+        });
+        window.liveflux.startRequestIndicators = jasmine.createSpy('startRequestIndicators').and.returnValue([]);
+        window.liveflux.endRequestIndicators = jasmine.createSpy('endRequestIndicators');
+
+        window.liveflux.configureTriggers({ enableTriggers: true });
+        window.liveflux.initTriggers(root);
+    });
+
+    afterEach(function() {
+        root.remove();
+        window.liveflux.post = originalPost;
+        window.liveflux.resolveComponentMetadata = originalResolveComponentMetadata;
+        window.liveflux.startRequestIndicators = originalStartRequestIndicators;
+        window.liveflux.endRequestIndicators = originalEndRequestIndicators;
+    });
+
+    describe('readModel', function() {
+        it('should read the field without modifiers', function() {
+            const el = document.createElement('input');
+            el.setAttribute('data-flux-model', 'Email');
+
+            const model = window.liveflux.readModel(el);
+
+            expect(model.field).toBe('Email');
+            expect(model.modifiers).toEqual([]);
+        });
+
+        it('should read modifiers from the attribute name', function() {
+            const el = document.createElement('input');
+            el.setAttribute('data-flux-model.debounce.500ms', 'Email');
+
+            const model = window.liveflux.readModel(el);
+
+            expect(model.field).toBe('Email');
+            expect(model.modifiers).toEqual(['debounce', '500ms']);
+        });
+
+        it('should ignore elements without a model', function() {
+            expect(window.liveflux.readModel(document.createElement('input'))).toBeNull();
+        });
+    });
+
+    describe('collectModels', function() {
+        it('should collect text, checkbox and select values', function() {
+            root.innerHTML =
+                '<input data-flux-model="Email" value="a@example.com">' +
+                '<input type="checkbox" data-flux-model="Subscribed" checked>' +
+                '<input type="checkbox" data-flux-model="Tags" value="go" checked>' +
+                '<input type="checkbox" data-flux-model="Tags" value="js">' +
+                '<select data-flux-model.lazy="Role"><option value="user">User</option><option value="admin" selected>Admin</option></select>';
+
+            const params = window.liveflux.collectModels(root);
+
+            expect(params['liveflux_model[Email]']).toBe('a@example.com');
+            expect(params['liveflux_model[Subscribed]']).toBe('true');
+            expect(params['liveflux_model[Tags]']).toEqual(['go']);
+            expect(params['liveflux_model[Role]']).toBe('admin');
+        });
+
+        it('should post an empty value for an empty checkbox group', function() {
+            root.innerHTML =
+                '<input type="checkbox" data-flux-model="Tags" value="go">' +
+                '<input type="checkbox" data-flux-model="Tags" value="js">';
+
+            expect(window.liveflux.collectModels(root)['liveflux_model[Tags]']).toBe('');
+        });
+    });
+
+    describe('syncing', function() {
+        it('should sync .lazy fields on change only', function() {
+            root.innerHTML = '<input data-flux-model.lazy="Name" value="Ann">';
+            const input = root.querySelector('input');
+
+            input.dispatchEvent(new Event('input', { bubbles: true }));
+            expect(window.liveflux.post).not.toHaveBeenCalled();
+
+            input.dispatchEvent(new Event('change', { bubbles: true }));
+            expect(window.liveflux.post).toHaveBeenCalled();
+
+            const params = window.liveflux.post.calls.mostRecent().args[0];
+            expect(params['liveflux_model[Name]']).toBe('Ann');
+            expect(params.liveflux_component_id).toBe('profile-1');
+            expect(params.liveflux_action).toBeUndefined();
+        });
+
+        it('should debounce live fields', function(done) {
+            root.innerHTML = '<input data-flux-model.debounce.20ms="Name" value="A">';
+            const input = root.querySelector('input');
+
+            input.dispatchEvent(new Event('input', { bubbles: true }));
+            input.value = 'Ab';
+            input.dispatchEvent(new Event('input', { bubbles: true }));
+            expect(window.liveflux.post).not.toHaveBeenCalled();
+
+            setTimeout(function() {
+                expect(window.liveflux.post.calls.count()).toBe(1);
+                expect(window.liveflux.post.calls.mostRecent().args[0]['liveflux_model[Name]']).toBe('Ab');
+                done();
+            }, 50);
+        });
+
+        it('should sync .blur fields on focusout', function() {
+            root.innerHTML = '<input data-flux-model.blur="Name" value="Ann">';
+            const input = root.querySelector('input');
+
+            input.dispatchEvent(new Event('change', { bubbles: true }));
+            expect(window.liveflux.post).not.toHaveBeenCalled();
+
+            input.dispatchEvent(new FocusEvent('focusout', { bubbles: true }));
+            expect(window.liveflux.post).toHaveBeenCalled();
+        });
+    });
+});
@@ -114,6 +114,42 @@ describe('Liveflux Events', function() {
         });
     });
 
+    describe('server-side routing', function() {
+        let originalFetch;
+        let container;
+
+        beforeEach(function() {
+            originalFetch = window.fetch;
+            window.fetch = jasmine.createSpy('fetch').and.returnValue(Promise.resolve(new Response('<div></div>')));
+            container = document.createElement('div');
+            container.innerHTML =
+                '<div data-flux-component-kind="creator" data-flux-component-id="c1"></div>' +
+                '<div data-flux-component-kind="list" data-flux-component-id="l1"></div>' +
+                '<div data-flux-component-kind="list" data-flux-component-id="l2"></div>';
+            document.body.appendChild(container);
+        });
+
+        afterEach(function() {
+            window.fetch = originalFetch;
+            container.remove();
+        });
+
+        it('should post the other components on the page with actions', function() {
+            window.liveflux.post({ liveflux_component_kind: 'creator', liveflux_component_id: 'c1', liveflux_action: 'create' });
+
+            const body = new URLSearchParams(window.fetch.calls.mostRecent().args[1].body.toString());
+            expect(body.get('liveflux_components').split(',')).toEqual(jasmine.arrayContaining(['list:l1', 'list:l2']));
+            expect(body.get('liveflux_components').split(',')).not.toContain('creator:c1');
+        });
+
+        it('should not list components on mount requests', function() {
+            window.liveflux.post({ liveflux_component_kind: 'creator' });
+
+            const body = new URLSearchParams(window.fetch.calls.mostRecent().args[1].body.toString());
+            expect(body.get('liveflux_components')).toBeNull();
+        });
+    });
+
     describe('onComponent', function() {
         it('should exist and be callable', function() {
             expect(typeof window.liveflux.events.onComponent).toBe('function');
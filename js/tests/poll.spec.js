@@ -0,0 +1,159 @@
+describe('Liveflux Polling', function() {
+    let originalPost;
+    let originalStartRequestIndicators;
+    let originalEndRequestIndicators;
+    let root;
+    let hidden;
+    let nextId = 0;
+
+    // flush lets the request promise chains settle
+    async function flush() {
+        for (let i = 0; i < 20; i++) await Promise.resolve();
+    }
+
+    function setHidden(value) {
+        hidden = value;
+        document.dispatchEvent(new Event('visibilitychange'));
+    }
+
+    beforeAll(function() {
+        originalPost = window.liveflux.post;
+        originalStartRequestIndicators = window.liveflux.startRequestIndicators;
+        originalEndRequestIndicators = window.liveflux.endRequestIndicators;
+    });
+
+    beforeEach(function() {
+        jasmine.clock().install();
+        hidden = false;
+        Object.defineProperty(document, 'hidden', { configurable: true, get: () => hidden });
+
+        // A new ID per test, so polls left over from earlier tests don't match
+        nextId++;
+        root = document.createElement('div');
+        root.setAttribute('data-flux-component-kind', 'poller');
+        root.setAttribute('data-flux-component-id', 'poll-' + nextId);
+        root.setAttribute('data-flux-poll', '1s');
+        document.body.appendChild(root);
+
+        // Responses re-render the component as it currently is
+        window.liveflux.post = jasmine.createSpy('post').and.callFake(function() {
+            const current = window.liveflux.findComponent('poller', 'poll-' + nextId);
+            return Promise.resolve({ html: current ? current.outerHTML : '' });
+        });
+        window.liveflux.startRequestIndicators = jasmine.createSpy('startRequestIndicators').and.returnValue([]);
+        window.liveflux.endRequestIndicators = jasmine.createSpy('endRequestIndicators');
+        window.liveflux.configureTriggers({ enableTriggers: true });
+    });
+
+    afterEach(function() {
+        const current = window.liveflux.findComponent('poller', 'poll-' + nextId);
+        if (current) current.remove();
+        delete document.hidden;
+        jasmine.clock().uninstall();
+        window.liveflux.post = originalPost;
+        window.liveflux.startRequestIndicators = originalStartRequestIndicators;
+        window.liveflux.endRequestIndicators = originalEndRequestIndicators;
+    });
+
+    it('should parse the every trigger without default events', function() {
+        const button = document.createElement('button');
+        button.setAttribute('data-flux-trigger', 'every 2s');
+
+        const definitions = window.liveflux.parseTriggers(button);
+
+        expect(definitions.length).toBe(1);
+        expect(definitions[0].every).toBe(2000);
+        expect(definitions[0].events).toEqual([]);
+    });
+
+    it('should refresh the component every interval', async function() {
+        let triggerHeader;
+        window.liveflux.post.and.callFake(function() {
+            triggerHeader = window.liveflux.headers['X-Liveflux-Trigger'];
+            return Promise.resolve({ html: root.outerHTML });
+        });
+        window.liveflux.initPolls();
+
+        jasmine.clock().tick(999);
+        expect(window.liveflux.post).not.toHaveBeenCalled();
+
+        jasmine.clock().tick(1);
+        expect(window.liveflux.post).toHaveBeenCalledTimes(1);
+        const params = window.liveflux.post.calls.mostRecent().args[0];
+        expect(params.liveflux_component_kind).toBe('poller');
+        expect(params.liveflux_component_id).toBe('poll-' + nextId);
+        expect(params.liveflux_action).toBeUndefined();
+        expect(triggerHeader).toBe('poll');
+
+        await flush();
+        jasmine.clock().tick(1000);
+        expect(window.liveflux.post).toHaveBeenCalledTimes(2);
+    });
+
+    it('should call the named action', function() {
+        root.setAttribute('data-flux-poll', '500ms refresh');
+        window.liveflux.initPolls();
+
+        jasmine.clock().tick(500);
+
+        expect(window.liveflux.post.calls.mostRecent().args[0].liveflux_action).toBe('refresh');
+    });
+
+    it('should pause while the page is hidden', function() {
+        window.liveflux.initPolls();
+        setHidden(true);
+
+        jasmine.clock().tick(5000);
+        expect(window.liveflux.post).not.toHaveBeenCalled();
+
+        setHidden(false);
+        expect(window.liveflux.post).toHaveBeenCalledTimes(1);
+    });
+
+    it('should back off after failed requests', async function() {
+        window.liveflux.post.and.callFake(() => Promise.reject(new Error('500')));
+        window.liveflux.initPolls();
+
+        jasmine.clock().tick(1000);
+        expect(window.liveflux.post).toHaveBeenCalledTimes(1);
+        await flush();
+
+        // The interval doubles after a failure
+        jasmine.clock().tick(1000);
+        expect(window.liveflux.post).toHaveBeenCalledTimes(1);
+        jasmine.clock().tick(1000);
+        expect(window.liveflux.post).toHaveBeenCalledTimes(2);
+    });
+
+    it('should stop when the server removes data-flux-poll', async function() {
+        window.liveflux.initPolls();
+        jasmine.clock().tick(1000);
+        expect(window.liveflux.post).toHaveBeenCalledTimes(1);
+        await flush();
+
+        window.liveflux.findComponent('poller', 'poll-' + nextId).removeAttribute('data-flux-poll');
+        jasmine.clock().tick(5000);
+
+        expect(window.liveflux.post).toHaveBeenCalledTimes(1);
+    });
+
+    it('should fire every triggers until the element is removed', async function() {
+        const button = document.createElement('button');
+        button.setAttribute('data-flux-action', 'tick');
+        button.setAttribute('data-flux-trigger', 'every 1s');
+        root.removeAttribute('data-flux-poll');
+        root.appendChild(button);
+        // Leave the component in place
+        window.liveflux.post.and.returnValue(Promise.resolve({ html: ' ' }));
+        window.liveflux.registerTriggers(button);
+
+        jasmine.clock().tick(1000);
+        expect(window.liveflux.post).toHaveBeenCalledTimes(1);
+        expect(window.liveflux.post.calls.mostRecent().args[0].liveflux_action).toBe('tick');
+        await flush();
+
+        button.remove();
+        jasmine.clock().tick(5000);
+        expect(window.liveflux.post).toHaveBeenCalledTimes(1);
+    });
+});
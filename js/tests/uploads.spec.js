@@ -0,0 +1,200 @@
+describe('Liveflux Uploads', function() {
+    describe('serializeElement', function() {
+        it('should collect File objects from file inputs', function() {
+            const form = document.createElement('form');
+            form.innerHTML = '<input type="file" name="avatar"><input name="title" value="Hi">';
+            const input = form.querySelector('input[type=file]');
+            const file = new File(['hello'], 'hello.txt', { type: 'text/plain' });
+            Object.defineProperty(input, 'files', { value: [file] });
+
+            const params = window.liveflux.serializeElement(form);
+
+            expect(params.avatar).toBe(file);
+            expect(params.title).toBe('Hi');
+        });
+
+        it('should skip empty file inputs', function() {
+            const form = document.createElement('form');
+            form.innerHTML = '<input type="file" name="avatar">';
+
+            expect(window.liveflux.serializeElement(form).avatar).toBeUndefined();
+        });
+    });
+
+    describe('updateRequestProgress', function() {
+        it('should reflect progress on indicators and clear it on end', function() {
+            const indicator = document.createElement('div');
+            document.body.appendChild(indicator);
+            const seen = [];
+            indicator.addEventListener('flux-upload-progress', function(e) { seen.push(e.detail.percent); });
+
+            window.liveflux.updateRequestProgress([indicator], { lengthComputable: true, loaded: 25, total: 100 });
+
+            expect(indicator.classList.contains('flux-uploading')).toBeTrue();
+            expect(indicator.getAttribute('data-flux-progress')).toBe('25');
+            expect(indicator.style.getPropertyValue('--flux-progress')).toBe('25');
+            expect(seen).toEqual([25]);
+
+            window.liveflux.endRequestIndicators([indicator]);
+
+            expect(indicator.classList.contains('flux-uploading')).toBeFalse();
+            expect(indicator.hasAttribute('data-flux-progress')).toBeFalse();
+            indicator.remove();
+        });
+    });
+
+    describe('post', function() {
+        let originalXHR;
+        let originalFetch;
+        let sent;
+
+        function FakeXHR() {
+            this.upload = document.createElement('div');
+            this.headers = {};
+            sent = this;
+        }
+        FakeXHR.prototype.open = function(method, url) { this.method = method; this.url = url; };
+        FakeXHR.prototype.setRequestHeader = function(name, value) { this.headers[name] = value; };
+        FakeXHR.prototype.getAllResponseHeaders = function() { return 'content-type: text/html\r\n'; };
+        FakeXHR.prototype.send = function(body) {
+            this.body = body;
+            const progress = new Event('progress');
+            progress.lengthComputable = true;
+            progress.loaded = 5;
+            progress.total = 10;
+            this.upload.dispatchEvent(progress);
+            this.status = 200;
+            this.statusText = 'OK';
+            this.responseText = '<div>done</div>';
+            this.onload();
+        };
+
+        beforeEach(function() {
+            originalXHR = window.XMLHttpRequest;
+            originalFetch = window.fetch;
+            window.XMLHttpRequest = FakeXHR;
+            window.fetch = jasmine.createSpy('fetch');
+            sent = null;
+        });
+
+        afterEach(function() {
+            window.XMLHttpRequest = originalXHR;
+            window.fetch = originalFetch;
+        });
+
+        it('should send files as multipart over XHR and report progress', async function() {
+            const file = new File(['hello'], 'hello.txt', { type: 'text/plain' });
+            const onUploadProgress = jasmine.createSpy('onUploadProgress');
+
+            const result = await window.liveflux.post({ liveflux_action: 'upload', avatar: file }, { onUploadProgress });
+
+            expect(window.fetch).not.toHaveBeenCalled();
+            expect(sent.body instanceof FormData).toBeTrue();
+            expect(sent.body.get('avatar').name).toBe('hello.txt');
+            expect(sent.body.get('liveflux_action')).toBe('upload');
+            expect(sent.headers['Content-Type']).toBeUndefined();
+            expect(onUploadProgress).toHaveBeenCalled();
+            expect(result.html).toBe('<div>done</div>');
+        });
+
+        it('should keep using fetch without files', function() {
+            window.fetch.and.returnValue(Promise.resolve(new Response('<div></div>')));
+
+            window.liveflux.post({ liveflux_action: 'save' });
+
+            expect(window.fetch).toHaveBeenCalled();
+            expect(sent).toBeNull();
+        });
+    });
+
+    describe('chunked uploads', function() {
+        let originalFetch;
+        let requests;
+
+        function jsonResponse(status, body) {
+            return Promise.resolve(new Response(JSON.stringify(body), { status: status }));
+        }
+
+        beforeEach(function() {
+            originalFetch = window.fetch;
+            requests = [];
+            window.liveflux.uploadChunkSize = 4;
+            window.localStorage.clear();
+            let offset = 0;
+            window.fetch = jasmine.createSpy('fetch').and.callFake(function(url, init) {
+                const query = new URL(url, window.location.href).searchParams;
+                requests.push({ op: query.get('liveflux_upload'), query: query, init: init });
+                if (query.get('liveflux_upload') === 'init') {
+                    return jsonResponse(200, { id: 'u1', offset: offset, chunk_size: 4 });
+                }
+                if (query.get('liveflux_upload') === 'chunk') {
+                    offset += init.body.size;
+                    return jsonResponse(200, { id: 'u1', offset: offset });
+                }
+                return Promise.resolve(new Response('<div>done</div>'));
+            });
+        });
+
+        afterEach(function() {
+            window.fetch = originalFetch;
+            delete window.liveflux.uploadChunkSize;
+        });
+
+        it('should upload large files in chunks and reference them in the action', async function() {
+            const file = new File(['0123456789'], 'data.csv', { type: 'text/csv' });
+            const progress = jasmine.createSpy('onUploadProgress');
+
+            const result = await window.liveflux.post({
+                liveflux_component_kind: 'importer',
+                liveflux_component_id: 'imp-1',
+                liveflux_action: 'import',
+                data: file
+            }, { onUploadProgress: progress });
+
+            expect(requests.map(function(r) { return r.op; })).toEqual(['init', 'chunk', 'chunk', 'chunk', null]);
+            expect(requests[0].query.get('liveflux_upload_size')).toBe('10');
+            expect(requests[2].query.get('liveflux_upload_offset')).toBe('4');
+            expect(requests[2].query.get('liveflux_component_kind')).toBe('importer');
+            expect(requests[2].query.get('liveflux_component_id')).toBe('imp-1');
+
+            const body = new URLSearchParams(requests[4].init.body.toString());
+            expect(body.get('liveflux_chunked_upload[data]')).toBe('u1');
+            expect(body.get('data')).toBeNull();
+
+            expect(progress.calls.count()).toBe(3);
+            expect(progress.calls.mostRecent().args[0].loaded).toBe(10);
+            expect(progress.calls.mostRecent().args[0].chunks).toBe(3);
+            expect(result.html).toBe('<div>done</div>');
+        });
+
+        it('should send the component state with init', async function() {
+            await window.liveflux.post({
+                liveflux_component_kind: 'importer',
+                liveflux_component_id: 'imp-1',
+                liveflux_component_state: 'signed-state',
+                data: new File(['0123456789'], 'data.csv')
+            });
+
+            expect(requests[0].query.get('liveflux_component_state')).toBe('signed-state');
+        });
+
+        it('should continue from the offset the server reports on 409', async function() {
+            let calls = 0;
+            window.fetch.and.callFake(function(url, init) {
+                const query = new URL(url, window.location.href).searchParams;
+                requests.push({ op: query.get('liveflux_upload'), query: query });
+                if (query.get('liveflux_upload') === 'init') return jsonResponse(200, { id: 'u1', offset: 0, chunk_size: 4 });
+                if (query.get('liveflux_upload') === 'chunk') {
+                    calls++;
+                    return calls === 1 ? jsonResponse(409, { id: 'u1', offset: 8 }) : jsonResponse(200, { id: 'u1', offset: 10 });
+                }
+                return Promise.resolve(new Response('<div></div>'));
+            });
+
+            await window.liveflux.post({ liveflux_action: 'import', data: new File(['0123456789'], 'data.csv') });
+
+            const offsets = requests.filter(function(r) { return r.op === 'chunk'; }).map(function(r) { return r.query.get('liveflux_upload_offset'); });
+            expect(offsets).toEqual(['0', '8']);
+        });
+    });
+});
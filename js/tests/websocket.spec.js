@@ -0,0 +1,158 @@
+describe('Liveflux WebSocket', function() {
+    let originalWebSocket;
+    let originalEventSource;
+    let sockets;
+    let streams;
+    let container;
+    let suffix;
+
+    // FakeWebSocket records sent messages and lets tests deliver frames.
+    function FakeWebSocket(url) {
+        this.url = url;
+        this.sent = [];
+        sockets.push(this);
+    }
+    FakeWebSocket.prototype.send = function(data) { this.sent.push(JSON.parse(data)); };
+    FakeWebSocket.prototype.close = function() {};
+    FakeWebSocket.prototype.open = function() { this.onopen(); };
+    FakeWebSocket.prototype.receive = function(message) { this.onmessage({ data: JSON.stringify(message) }); };
+
+    // FakeEventSource records opened streams.
+    function FakeEventSource(url) {
+        this.url = url;
+        this.closed = false;
+        streams.push(this);
+    }
+    FakeEventSource.prototype.close = function() { this.closed = true; };
+
+    // Each test uses fresh IDs and URL, as connections live for the page
+    function id(n) { return 'w' + n + '-' + suffix; }
+
+    function widget(n, count) {
+        return '<div data-flux-component-kind="widget" data-flux-component-id="' + id(n) + '" data-flux-ws="1" data-flux-ws-url="ws://test/' + suffix + '">' +
+            '<span class="count">' + count + '</span>' +
+            '<button data-flux-action="increment">+</button>' +
+            '</div>';
+    }
+
+    beforeEach(function() {
+        originalWebSocket = window.WebSocket;
+        originalEventSource = window.EventSource;
+        sockets = [];
+        streams = [];
+        window.WebSocket = FakeWebSocket;
+        window.EventSource = FakeEventSource;
+        suffix = Math.random().toString(36).slice(2);
+        container = document.createElement('div');
+        container.innerHTML = widget(1, 0) + widget(2, 0) + widget(3, 0);
+        document.body.appendChild(container);
+        window.liveflux.initWebSockets();
+    });
+
+    afterEach(function() {
+        window.WebSocket = originalWebSocket;
+        window.EventSource = originalEventSource;
+        container.remove();
+    });
+
+    it('should share one socket between the components on the page', function() {
+        expect(sockets.length).toBe(1);
+
+        sockets[0].open();
+
+        const inits = sockets[0].sent.filter(function(m) { return m.type === 'init'; });
+        expect(inits.map(function(m) { return m.componentID; })).toEqual([id(1), id(2), id(3)]);
+    });
+
+    it('should route updates by component ID', function() {
+        sockets[0].open();
+
+        sockets[0].receive({ type: 'update', componentID: id(2), data: { html: widget(2, 5) } });
+
+        const counts = Array.from(container.querySelectorAll('.count')).map(function(el) { return el.textContent; });
+        expect(counts).toEqual(['0', '5', '0']);
+    });
+
+    it('should send actions for the clicked component over the shared socket', function() {
+        sockets[0].open();
+
+        container.querySelector('[data-flux-component-id="' + id(3) + '"] button').click();
+
+        const action = sockets[0].sent.filter(function(m) { return m.type === 'action'; })[0];
+        expect(action.componentID).toBe(id(3));
+        expect(action.action).toBe('increment');
+        expect(window.liveflux.isWebSocketElement(container.querySelector('button'))).toBeTrue();
+    });
+
+    it('should leave components removed by an update', function() {
+        sockets[0].open();
+        container.querySelector('[data-flux-component-id="' + id(3) + '"]').remove();
+
+        sockets[0].receive({ type: 'update', componentID: id(1), data: { html: widget(1, 1) } });
+
+        const leaves = sockets[0].sent.filter(function(m) { return m.type === 'leave'; });
+        expect(leaves.map(function(m) { return m.componentID; })).toEqual([id(3)]);
+    });
+
+    it('should skip seen versions and resume from the last one after reconnecting', function() {
+        sockets[0].open();
+        sockets[0].receive({ type: 'update', componentID: id(1), version: 5, data: { html: widget(1, 1) } });
+        sockets[0].receive({ type: 'update', componentID: id(1), version: 5, data: { html: widget(1, 9) } });
+        expect(container.querySelector('.count').textContent).toBe('1');
+
+        jasmine.clock().install();
+        try {
+            window.liveflux.LiveFluxWS.shared('ws://test/' + suffix).handleClose({});
+            jasmine.clock().tick(1000);
+        } finally {
+            jasmine.clock().uninstall();
+        }
+        sockets[1].open();
+
+        const inits = sockets[1].sent.filter(function(m) { return m.type === 'init'; });
+        expect(inits[0]).toEqual({ type: 'init', componentID: id(1), kind: 'widget', version: 5 });
+        expect(inits[1]).toEqual({ type: 'init', componentID: id(2), kind: 'widget' });
+
+        sockets[1].receive({ type: 'update', componentID: id(1), version: 3, data: { html: widget(1, 7), resync: true } });
+        expect(container.querySelector('.count').textContent).toBe('7');
+    });
+
+    it('should fall back to Server-Sent Events, then polling, when WebSocket never connects', function() {
+        const client = window.liveflux.LiveFluxWS.shared('ws://test/' + suffix);
+        sockets[0].onclose({});
+
+        expect(client.transport).toBe('sse');
+        expect(streams.length).toBe(1);
+        expect(streams[0].url).toBe('http://test/' + suffix + '?liveflux_stream=' + encodeURIComponent([id(1), id(2), id(3)].join(',')));
+
+        streams[0].onopen();
+        streams[0].onmessage({ data: JSON.stringify({ type: 'update', componentID: id(2), version: 8, data: { html: widget(2, 4) } }) });
+        expect(container.querySelectorAll('.count')[1].textContent).toBe('4');
+        // Actions go over POST requests with Server-Sent Events
+        expect(window.liveflux.isWebSocketElement(container.querySelector('button'))).toBeFalse();
+        expect(client.streamURL()).toContain(encodeURIComponent(id(2) + ':8'));
+
+        // A stream that fails after connecting reconnects rather than falling back
+        jasmine.clock().install();
+        try {
+            streams[0].onerror({});
+            jasmine.clock().tick(1000);
+            expect(client.transport).toBe('sse');
+            expect(streams.length).toBe(2);
+        } finally {
+            jasmine.clock().uninstall();
+        }
+        client.close();
+    });
+
+    it('should fall back to polling when Server-Sent Events never connect', function() {
+        const client = window.liveflux.LiveFluxWS.shared('ws://test/' + suffix);
+        sockets[0].onclose({});
+        streams[0].onerror({});
+
+        expect(streams[0].closed).toBeTrue();
+        expect(client.transport).toBe('polling');
+        expect(client.fallBack()).toBeFalse();
+        client.close();
+    });
+});
@@ -0,0 +1,99 @@
+package liveflux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+)
+
+// KindCheckMode controls how the handler treats action requests whose posted
+// kind does not match the stored component instance.
+type KindCheckMode int
+
+const (
+	// KindCheckStrict rejects mismatched requests with 400 Bad Request. This
+	// is the default.
+	KindCheckStrict KindCheckMode = iota
+	// KindCheckReport reports mismatches via OnKindMismatch and the log but
+	// still dispatches the action. Useful while migrating existing clients.
+	KindCheckReport
+	// KindCheckOff disables the check entirely.
+	KindCheckOff
+)
+
+// KindMismatch describes an action request whose posted kind does not match
+// the stored component instance.
+type KindMismatch struct {
+	ComponentID   string
+	RequestedKind string
+	// StoredKind is the kind reported by the stored instance (GetKind, or the
+	// registry kind of its type when GetKind is empty).
+	StoredKind string
+	// Rejected is true when the request was refused (KindCheckStrict).
+	Rejected bool
+}
+
+// kindMismatchResponse is the JSON body written for rejected requests. The
+// stored kind is deliberately not echoed back to the client.
+type kindMismatchResponse struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// kindMatches reports whether c may be driven by requests posted for kind:
+// either the instance reports that kind, or kind is registered for the
+// instance's concrete type.
+func kindMatches(kind string, c ComponentInterface) bool {
+	if c.GetKind() == kind {
+		return true
+	}
+	proto, ok := registry[kind]
+	return ok && reflect.TypeOf(proto) == reflect.TypeOf(c)
+}
+
+// storedKind returns the best description of c's kind for reporting.
+func storedKind(c ComponentInterface) string {
+	if kind := c.GetKind(); kind != "" {
+		return kind
+	}
+	return KindOf(c)
+}
+
+// verifyKind enforces h.KindCheck for a loaded instance. Returns false if an
+// error response was written.
+func (h *Handler) verifyKind(ctx context.Context, w http.ResponseWriter, kind string, c ComponentInterface) bool {
+	if h.kindAllowed(ctx, kind, c) {
+		return true
+	}
+	body, _ := json.Marshal(kindMismatchResponse{
+		Error:   "kind_mismatch",
+		Message: "component kind does not match the requested component",
+	})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	h.writeError(w, http.StatusBadRequest, string(body))
+	return false
+}
+
+// kindAllowed reports whether h.KindCheck lets a request posted for kind
+// drive c, reporting mismatches.
+func (h *Handler) kindAllowed(ctx context.Context, kind string, c ComponentInterface) bool {
+	if h.KindCheck == KindCheckOff || kindMatches(kind, c) {
+		return true
+	}
+
+	mismatch := KindMismatch{
+		ComponentID:   c.GetID(),
+		RequestedKind: kind,
+		StoredKind:    storedKind(c),
+		Rejected:      h.KindCheck == KindCheckStrict,
+	}
+	fmt.Printf("liveflux: kind mismatch: id=%s requested=%s stored=%s rejected=%t\n",
+		mismatch.ComponentID, mismatch.RequestedKind, mismatch.StoredKind, mismatch.Rejected)
+	if h.OnKindMismatch != nil {
+		h.OnKindMismatch(ctx, mismatch)
+	}
+
+	return !mismatch.Rejected
+}
@@ -0,0 +1,96 @@
+package liveflux
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// newMismatchFixture stores a handlerComp and returns a handler plus a kind
+// registered for an unrelated type.
+func newMismatchFixture(t *testing.T) (*Handler, string, string) {
+	t.Helper()
+	store := NewMemoryStore()
+	h := NewHandler(store)
+
+	kind := registerTestKind(t, &handlerComp{})
+	c := &handlerComp{}
+	c.SetKind(kind)
+	c.SetID("victim")
+	store.Set(c)
+
+	otherKind := registerTestKind(t, &storeComp{})
+	return h, kind, otherKind
+}
+
+func TestHandler_KindMismatchStrict(t *testing.T) {
+	h, _, otherKind := newMismatchFixture(t)
+
+	var got []KindMismatch
+	h.OnKindMismatch = func(_ context.Context, m KindMismatch) { got = append(got, m) }
+
+	rec := postForm(h, url.Values{FormComponentKind: {otherKind}, FormComponentID: {"victim"}, FormAction: {"inc"}})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "application/json") {
+		t.Fatalf("expected JSON error, got %q", ct)
+	}
+	var body kindMismatchResponse
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil || body.Error != "kind_mismatch" {
+		t.Fatalf("unexpected body %q (%v)", rec.Body.String(), err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("expected hook to be called once, got %d", len(got))
+	}
+	m := got[0]
+	if m.ComponentID != "victim" || m.RequestedKind != otherKind || !m.Rejected {
+		t.Fatalf("unexpected mismatch report: %+v", m)
+	}
+	if m.StoredKind == "" || m.StoredKind == otherKind {
+		t.Fatalf("expected stored kind to be reported, got %q", m.StoredKind)
+	}
+}
+
+func TestHandler_KindMismatchReportAndOff(t *testing.T) {
+	h, _, otherKind := newMismatchFixture(t)
+	calls := 0
+	h.OnKindMismatch = func(_ context.Context, m KindMismatch) {
+		calls++
+		if m.Rejected {
+			t.Errorf("expected report-only mismatch")
+		}
+	}
+	form := url.Values{FormComponentKind: {otherKind}, FormComponentID: {"victim"}, FormAction: {"inc"}}
+
+	h.KindCheck = KindCheckReport
+	if rec := postForm(h, form); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "count=1") {
+		t.Fatalf("expected action to proceed in report mode, got %d %q", rec.Code, rec.Body.String())
+	}
+	if calls != 1 {
+		t.Fatalf("expected hook in report mode, got %d calls", calls)
+	}
+
+	h.KindCheck = KindCheckOff
+	if rec := postForm(h, form); rec.Code != http.StatusOK {
+		t.Fatalf("expected action to proceed with check off, got %d", rec.Code)
+	}
+	if calls != 1 {
+		t.Fatalf("expected no hook with check off, got %d calls", calls)
+	}
+}
+
+func TestHandler_KindMatchUsesRegistryType(t *testing.T) {
+	// handlerComp reports an empty GetKind, so the registry type decides
+	h, kind, _ := newMismatchFixture(t)
+	h.OnKindMismatch = func(context.Context, KindMismatch) { t.Errorf("unexpected mismatch") }
+
+	rec := postForm(h, url.Values{FormComponentKind: {kind}, FormComponentID: {"victim"}, FormAction: {"inc"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %q", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,110 @@
+package liveflux
+
+import (
+	"context"
+	"net/url"
+
+	"github.com/dracory/hb"
+)
+
+// Optional lifecycle hooks. The HTTP handler, the WebSocket handler and SSR
+// detect them with type assertions and call them in the same order:
+//
+//	mount:  Mount -> BeforeDehydrate -> persist -> BeforeRender -> Render
+//	action: load -> AfterHydrate -> BeforeAction -> Handle -> AfterAction -> BeforeDehydrate -> persist -> BeforeRender -> Render
+//
+// Re-render requests without an action skip the action and persist steps.
+// Over WebSocket, HandleWS takes the place of Handle. Unmount runs when a
+// store deletes or evicts the instance.
+
+// AfterHydrater is called after a component is loaded from the store, before
+// any action runs. Use it to reattach dependencies that are not persisted
+// (the current user, DB rows, services). A non-nil error aborts the request.
+type AfterHydrater interface {
+	AfterHydrate(ctx context.Context) error
+}
+
+// BeforeDehydrater is called before a component is persisted. Use it to
+// clear transient state. A non-nil error aborts the request before
+// persisting.
+type BeforeDehydrater interface {
+	BeforeDehydrate(ctx context.Context) error
+}
+
+// BeforeActioner is called before Handle. A non-nil error skips the action
+// and is reported like a Handle error.
+type BeforeActioner interface {
+	BeforeAction(ctx context.Context, action string, data url.Values) error
+}
+
+// AfterActioner is called after Handle succeeded. A non-nil error is
+// reported like a Handle error and the component is not persisted.
+type AfterActioner interface {
+	AfterAction(ctx context.Context, action string, data url.Values) error
+}
+
+// BeforeRenderer is called right before Render (or RenderTargets).
+type BeforeRenderer interface {
+	BeforeRender(ctx context.Context)
+}
+
+// Unmounter is called when a store deletes or evicts the component, so it
+// can release resources it holds.
+type Unmounter interface {
+	Unmount(ctx context.Context)
+}
+
+// Unmount calls c.Unmount if c implements Unmounter. Store implementations
+// call it when they delete or evict a component.
+func Unmount(ctx context.Context, c ComponentInterface) {
+	if u, ok := c.(Unmounter); ok {
+		u.Unmount(ctx)
+	}
+}
+
+// afterHydrate runs the AfterHydrater hook.
+func afterHydrate(ctx context.Context, c ComponentInterface) error {
+	if h, ok := c.(AfterHydrater); ok {
+		return h.AfterHydrate(ctx)
+	}
+	return nil
+}
+
+// beforeDehydrate runs the BeforeDehydrater hook.
+func beforeDehydrate(ctx context.Context, c ComponentInterface) error {
+	if d, ok := c.(BeforeDehydrater); ok {
+		return d.BeforeDehydrate(ctx)
+	}
+	return nil
+}
+
+// runAction wraps handle with the BeforeAction/AfterAction hooks.
+func runAction(ctx context.Context, c ComponentInterface, action string, data url.Values, handle func() error) error {
+	if b, ok := c.(BeforeActioner); ok {
+		if err := b.BeforeAction(ctx, action, data); err != nil {
+			return err
+		}
+	}
+	if err := handle(); err != nil {
+		return err
+	}
+	if a, ok := c.(AfterActioner); ok {
+		return a.AfterAction(ctx, action, data)
+	}
+	return nil
+}
+
+// beforeRender runs the BeforeRenderer hook.
+func beforeRender(ctx context.Context, c ComponentInterface) {
+	if b, ok := c.(BeforeRenderer); ok {
+		b.BeforeRender(ctx)
+	}
+}
+
+// render runs the BeforeRenderer hook and renders c.
+func render(ctx context.Context, c ComponentInterface) hb.TagInterface {
+	beforeRender(ctx, c)
+	var tag hb.TagInterface
+	renderWithChildren(ctx, c, true, func() { tag = c.Render(ctx) })
+	return tag
+}
@@ -0,0 +1,197 @@
+package liveflux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dracory/hb"
+)
+
+// callLog records hook invocations; it is shared with the handler goroutine
+// in WebSocket tests, hence the mutex.
+type callLog struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (l *callLog) add(call string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.calls = append(l.calls, call)
+}
+
+func (l *callLog) take() string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	out := strings.Join(l.calls, ",")
+	l.calls = nil
+	return out
+}
+
+var hookLog = &callLog{}
+
+// hookComp implements every lifecycle hook.
+type hookComp struct {
+	Base
+	Count int
+}
+
+func (c *hookComp) GetKind() string { return "test.hook-comp" }
+func (c *hookComp) Mount(context.Context, map[string]string) error {
+	hookLog.add("Mount")
+	return nil
+}
+func (c *hookComp) Handle(_ context.Context, action string, _ url.Values) error {
+	hookLog.add("Handle:" + action)
+	c.Count++
+	return nil
+}
+func (c *hookComp) Render(context.Context) hb.TagInterface {
+	return c.Root(hb.Text("hook"))
+}
+func (c *hookComp) HandleWS(context.Context, *WebSocketMessage) (any, error) {
+	hookLog.add("HandleWS")
+	return map[string]any{"type": "update"}, nil
+}
+func (c *hookComp) AfterHydrate(context.Context) error {
+	hookLog.add("AfterHydrate")
+	return nil
+}
+func (c *hookComp) BeforeDehydrate(context.Context) error {
+	hookLog.add("BeforeDehydrate")
+	return nil
+}
+func (c *hookComp) BeforeAction(_ context.Context, action string, _ url.Values) error {
+	hookLog.add("BeforeAction:" + action)
+	if action == "forbidden" {
+		return errors.New("not allowed")
+	}
+	return nil
+}
+func (c *hookComp) AfterAction(_ context.Context, action string, _ url.Values) error {
+	hookLog.add("AfterAction:" + action)
+	return nil
+}
+func (c *hookComp) BeforeRender(context.Context) { hookLog.add("BeforeRender") }
+func (c *hookComp) Unmount(context.Context)      { hookLog.add("Unmount") }
+
+func TestLifecycle_HTTPOrder(t *testing.T) {
+	h := NewHandler(NewMemoryStore())
+	kind := registerTestKind(t, &hookComp{})
+	hookLog.take()
+
+	rec := postForm(h, url.Values{FormComponentKind: {kind}})
+	if got := hookLog.take(); got != "Mount,BeforeDehydrate,BeforeRender" {
+		t.Fatalf("unexpected mount order: %s", got)
+	}
+	id := extractAttr(t, rec.Body.String(), DataFluxComponentID)
+
+	postForm(h, url.Values{FormComponentKind: {kind}, FormComponentID: {id}, FormAction: {"inc"}})
+	want := "AfterHydrate,BeforeAction:inc,Handle:inc,AfterAction:inc,BeforeDehydrate,BeforeRender"
+	if got := hookLog.take(); got != want {
+		t.Fatalf("unexpected action order:\n got %s\nwant %s", got, want)
+	}
+
+	// Re-render without an action does not persist
+	postForm(h, url.Values{FormComponentKind: {kind}, FormComponentID: {id}})
+	if got := hookLog.take(); got != "AfterHydrate,BeforeRender" {
+		t.Fatalf("unexpected re-render order: %s", got)
+	}
+}
+
+func TestLifecycle_BeforeActionErrorSkipsAction(t *testing.T) {
+	store := NewMemoryStore()
+	h := NewHandler(store)
+	kind := registerTestKind(t, &hookComp{})
+
+	rec := postForm(h, url.Values{FormComponentKind: {kind}})
+	id := extractAttr(t, rec.Body.String(), DataFluxComponentID)
+	hookLog.take()
+
+	rec = postForm(h, url.Values{FormComponentKind: {kind}, FormComponentID: {id}, FormAction: {"forbidden"}})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", rec.Code)
+	}
+	if got := hookLog.take(); got != "AfterHydrate,BeforeAction:forbidden" {
+		t.Fatalf("expected Handle and persist to be skipped, got %s", got)
+	}
+}
+
+func TestLifecycle_SSROrder(t *testing.T) {
+	oldStore := StoreDefault
+	defer func() { StoreDefault = oldStore }()
+	StoreDefault = NewMemoryStore()
+	hookLog.take()
+
+	SSR(&hookComp{})
+	if got := hookLog.take(); got != "Mount,BeforeDehydrate,BeforeRender" {
+		t.Fatalf("unexpected SSR order: %s", got)
+	}
+}
+
+func TestLifecycle_WebSocketOrder(t *testing.T) {
+	store := NewMemoryStore()
+	h := NewWebSocketHandler(store)
+	c := &hookComp{}
+	c.SetKind(c.GetKind())
+	c.SetID(NewID())
+	store.Set(c)
+	hookLog.take()
+
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+	conn, _, err := dialWS(t, ts.URL)
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	if err := conn.WriteJSON(WebSocketMessage{Type: "action", ComponentID: c.GetID(), Kind: c.GetKind(), Action: "inc"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var resp map[string]any
+	if err := conn.ReadJSON(&resp); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	want := "AfterHydrate,BeforeAction:inc,HandleWS,AfterAction:inc,BeforeDehydrate"
+	if got := hookLog.take(); got != want {
+		t.Fatalf("unexpected WebSocket order:\n got %s\nwant %s", got, want)
+	}
+}
+
+func TestLifecycle_UnmountOnDeleteAndEviction(t *testing.T) {
+	s := NewMemoryStore(WithMemoryStoreMaxEntries(1))
+	newHook := func(id string) *hookComp {
+		c := &hookComp{}
+		c.SetKind(c.GetKind())
+		c.SetID(id)
+		return c
+	}
+	hookLog.take()
+
+	s.Set(newHook("a"))
+	s.Delete("a")
+	if got := hookLog.take(); got != "Unmount" {
+		t.Fatalf("expected Unmount on Delete, got %q", got)
+	}
+
+	s.Set(newHook("b"))
+	s.Set(newHook("c")) // evicts b
+	if got := hookLog.take(); got != "Unmount" {
+		t.Fatalf("expected Unmount on eviction, got %q", got)
+	}
+
+	s.Delete("missing")
+	if got := hookLog.take(); got != "" {
+		t.Fatalf("expected no Unmount for missing id, got %q", got)
+	}
+}
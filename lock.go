@@ -0,0 +1,213 @@
+package liveflux
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// ErrLockTimeout is returned when a component lock cannot be acquired in time.
+var ErrLockTimeout = errors.New("liveflux: component lock timeout")
+
+// Locker serializes access to a component instance by ID. The returned
+// unlock function must be called exactly once when the operation completes;
+// implementations in this package tolerate repeated calls.
+type Locker interface {
+	Lock(ctx context.Context, id string) (func(), error)
+}
+
+// LockingStore is an optional Store extension. When the configured store
+// implements it, the HTTP and WebSocket handlers hold the component lock
+// while loading, mutating and persisting an instance.
+type LockingStore interface {
+	Store
+	Locker
+}
+
+// NewLockingStore adds per-component locking to any Store using locker.
+// If locker is nil an in-process MutexLocker is used.
+func NewLockingStore(store Store, locker Locker) LockingStore {
+	if locker == nil {
+		locker = NewMutexLocker()
+	}
+	return &lockingStore{Store: store, locker: locker}
+}
+
+type lockingStore struct {
+	Store
+	locker Locker
+}
+
+// Lock implements Locker.
+func (s *lockingStore) Lock(ctx context.Context, id string) (func(), error) {
+	return s.locker.Lock(ctx, id)
+}
+
+// MutexLocker is the default in-process Locker. Waiting callers give up when
+// their context is cancelled. Entries are removed once no caller holds or
+// waits for a lock.
+type MutexLocker struct {
+	mu    sync.Mutex
+	locks map[string]*mutexEntry
+}
+
+type mutexEntry struct {
+	ch   chan struct{}
+	refs int
+}
+
+// NewMutexLocker creates a MutexLocker.
+func NewMutexLocker() *MutexLocker {
+	return &MutexLocker{locks: map[string]*mutexEntry{}}
+}
+
+// Lock implements Locker.
+func (l *MutexLocker) Lock(ctx context.Context, id string) (func(), error) {
+	l.mu.Lock()
+	e := l.locks[id]
+	if e == nil {
+		e = &mutexEntry{ch: make(chan struct{}, 1)}
+		l.locks[id] = e
+	}
+	e.refs++
+	l.mu.Unlock()
+
+	select {
+	case e.ch <- struct{}{}:
+		var once sync.Once
+		return func() {
+			once.Do(func() {
+				<-e.ch
+				l.release(id, e)
+			})
+		}, nil
+	case <-ctx.Done():
+		l.release(id, e)
+		return nil, ctx.Err()
+	}
+}
+
+// release drops a reference and removes the entry when unused.
+func (l *MutexLocker) release(id string, e *mutexEntry) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	e.refs--
+	if e.refs == 0 && l.locks[id] == e {
+		delete(l.locks, id)
+	}
+}
+
+// LeaseBackend stores component leases. Implement it on top of a shared
+// system (e.g. Redis SET NX PX, a SQL row with an expiry column) to serialize
+// access across processes.
+type LeaseBackend interface {
+	// Acquire takes the lease for id if it is free or expired. token identifies
+	// the holder; ttl bounds how long the lease is held if never released.
+	Acquire(ctx context.Context, id, token string, ttl time.Duration) (bool, error)
+	// Release frees the lease only if it is still held by token.
+	Release(ctx context.Context, id, token string) error
+}
+
+// LeaseLocker acquires time-limited leases through a LeaseBackend. A lease
+// expires after TTL even if the holder never releases it (e.g. crashed), and
+// callers stop waiting after Timeout. TTL must exceed the longest action.
+type LeaseLocker struct {
+	backend       LeaseBackend
+	ttl           time.Duration
+	timeout       time.Duration
+	retryInterval time.Duration
+}
+
+// NewLeaseLocker creates a LeaseLocker. A nil backend uses an in-process
+// backend. Non-positive ttl defaults to 30s; non-positive timeout waits
+// until the context is cancelled.
+func NewLeaseLocker(backend LeaseBackend, ttl, timeout time.Duration) *LeaseLocker {
+	if backend == nil {
+		backend = NewMemoryLeaseBackend()
+	}
+	if ttl <= 0 {
+		ttl = 30 * time.Second
+	}
+	return &LeaseLocker{
+		backend:       backend,
+		ttl:           ttl,
+		timeout:       timeout,
+		retryInterval: 10 * time.Millisecond,
+	}
+}
+
+// Lock implements Locker. Returns ErrLockTimeout when Timeout elapses.
+func (l *LeaseLocker) Lock(ctx context.Context, id string) (func(), error) {
+	token := NewID()
+
+	var deadline <-chan time.Time
+	if l.timeout > 0 {
+		timer := time.NewTimer(l.timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		ok, err := l.backend.Acquire(ctx, id, token, l.ttl)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			var once sync.Once
+			return func() {
+				once.Do(func() {
+					_ = l.backend.Release(context.Background(), id, token)
+				})
+			}, nil
+		}
+
+		select {
+		case <-time.After(l.retryInterval):
+		case <-deadline:
+			return nil, ErrLockTimeout
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// MemoryLeaseBackend is an in-process LeaseBackend, useful for tests and
+// single-instance deployments that want lease expiry semantics.
+type MemoryLeaseBackend struct {
+	mu     sync.Mutex
+	leases map[string]memoryLease
+	now    func() time.Time
+}
+
+type memoryLease struct {
+	token   string
+	expires time.Time
+}
+
+// NewMemoryLeaseBackend creates a MemoryLeaseBackend.
+func NewMemoryLeaseBackend() *MemoryLeaseBackend {
+	return &MemoryLeaseBackend{leases: map[string]memoryLease{}, now: time.Now}
+}
+
+// Acquire implements LeaseBackend.
+func (b *MemoryLeaseBackend) Acquire(_ context.Context, id, token string, ttl time.Duration) (bool, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	now := b.now()
+	if lease, ok := b.leases[id]; ok && now.Before(lease.expires) {
+		return false, nil
+	}
+	b.leases[id] = memoryLease{token: token, expires: now.Add(ttl)}
+	return true, nil
+}
+
+// Release implements LeaseBackend.
+func (b *MemoryLeaseBackend) Release(_ context.Context, id, token string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if lease, ok := b.leases[id]; ok && lease.token == token {
+		delete(b.leases, id)
+	}
+	return nil
+}
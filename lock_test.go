@@ -0,0 +1,170 @@
+package liveflux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var (
+	_ LockingStore = (*MemoryStore)(nil)
+	_ Locker       = (*MutexLocker)(nil)
+	_ Locker       = (*LeaseLocker)(nil)
+)
+
+func TestMutexLocker_Serializes(t *testing.T) {
+	l := NewMutexLocker()
+	var inside, maxInside int32
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			unlock, err := l.Lock(context.Background(), "a")
+			if err != nil {
+				t.Errorf("Lock: %v", err)
+				return
+			}
+			n := atomic.AddInt32(&inside, 1)
+			for {
+				m := atomic.LoadInt32(&maxInside)
+				if n <= m || atomic.CompareAndSwapInt32(&maxInside, m, n) {
+					break
+				}
+			}
+			time.Sleep(time.Millisecond)
+			atomic.AddInt32(&inside, -1)
+			unlock()
+		}()
+	}
+	wg.Wait()
+
+	if maxInside != 1 {
+		t.Fatalf("expected at most one holder, saw %d", maxInside)
+	}
+	if len(l.locks) != 0 {
+		t.Fatalf("expected lock entries to be cleaned up, got %d", len(l.locks))
+	}
+}
+
+func TestMutexLocker_ContextCancel(t *testing.T) {
+	l := NewMutexLocker()
+	unlock, err := l.Lock(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	defer unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := l.Lock(ctx, "a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+
+	// Different IDs do not block each other
+	unlockB, err := l.Lock(context.Background(), "b")
+	if err != nil {
+		t.Fatalf("Lock b: %v", err)
+	}
+	unlockB()
+	unlockB() // repeated unlock is tolerated
+}
+
+func TestLeaseLocker_Timeout(t *testing.T) {
+	l := NewLeaseLocker(nil, time.Minute, 30*time.Millisecond)
+	unlock, err := l.Lock(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+
+	if _, err := l.Lock(context.Background(), "a"); !errors.Is(err, ErrLockTimeout) {
+		t.Fatalf("expected ErrLockTimeout, got %v", err)
+	}
+
+	unlock()
+	unlock2, err := l.Lock(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("expected lock after release, got %v", err)
+	}
+	unlock2()
+}
+
+func TestLeaseLocker_LeaseExpires(t *testing.T) {
+	backend := NewMemoryLeaseBackend()
+	now := time.Now()
+	backend.now = func() time.Time { return now }
+	l := NewLeaseLocker(backend, time.Second, 50*time.Millisecond)
+
+	staleUnlock, err := l.Lock(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+
+	// Holder never releases; once the lease expires another caller may take it
+	now = now.Add(2 * time.Second)
+	unlock, err := l.Lock(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("expected expired lease to be acquirable, got %v", err)
+	}
+
+	// The stale holder's release must not free the new lease
+	staleUnlock()
+	if ok, _ := backend.Acquire(context.Background(), "a", "intruder", time.Second); ok {
+		t.Fatalf("stale release freed a lease it no longer owns")
+	}
+	unlock()
+}
+
+// countingLockStore records Lock calls to verify handler integration.
+type countingLockStore struct {
+	*MemoryStore
+	locks int32
+}
+
+func (s *countingLockStore) Lock(ctx context.Context, id string) (func(), error) {
+	atomic.AddInt32(&s.locks, 1)
+	return s.MemoryStore.Lock(ctx, id)
+}
+
+func TestHandler_UsesLockingStore(t *testing.T) {
+	store := &countingLockStore{MemoryStore: NewMemoryStore()}
+	h := NewHandler(store)
+	kind := registerTestKind(t, &handlerComp{})
+
+	mountRec := postForm(h, url.Values{FormComponentKind: {kind}})
+	id := extractAttr(t, mountRec.Body.String(), "data-id")
+
+	rec := postForm(h, url.Values{FormComponentKind: {kind}, FormComponentID: {id}, FormAction: {"inc"}})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if atomic.LoadInt32(&store.locks) != 1 {
+		t.Fatalf("expected handler to lock once, got %d", store.locks)
+	}
+}
+
+// failingLocker always fails to acquire.
+type failingLocker struct{}
+
+func (failingLocker) Lock(context.Context, string) (func(), error) { return nil, ErrLockTimeout }
+
+func TestHandler_LockFailureReturnsConflict(t *testing.T) {
+	mem := NewMemoryStore()
+	h := NewHandler(NewLockingStore(mem, failingLocker{}))
+	kind := registerTestKind(t, &handlerComp{})
+
+	c := &handlerComp{}
+	c.SetKind(kind)
+	c.SetID("locked-id")
+	mem.Set(c)
+
+	rec := postForm(h, url.Values{FormComponentKind: {kind}, FormComponentID: {"locked-id"}, FormAction: {"inc"}})
+	if rec.Code != http.StatusConflict {
+		t.Fatalf("expected 409, got %d %q", rec.Code, rec.Body.String())
+	}
+}
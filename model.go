@@ -0,0 +1,175 @@
+package liveflux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// ErrFieldNotBindable is returned when a data-flux-model value targets a
+// field the component does not allow-list. The handler answers it with 400
+// "field not bindable".
+var ErrFieldNotBindable = errors.New("liveflux: field is not bindable")
+
+// ModelAllowList lists the exported fields that data-flux-model may assign,
+// e.g. []string{"Email", "Address.City"}. Components without it accept no
+// bound values.
+type ModelAllowList interface {
+	BindableFields() []string
+}
+
+// hasModels reports whether data carries data-flux-model values.
+func hasModels(data url.Values) bool {
+	for key := range data {
+		if _, ok := modelName(key); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// modelName extracts "Email" from "liveflux_model[Email]".
+func modelName(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, FormModel+"[")
+	if !ok {
+		return "", false
+	}
+	name, ok := strings.CutSuffix(rest, "]")
+	return name, ok && name != ""
+}
+
+// applyModels assigns the data-flux-model values posted in data onto c's
+// fields and calls Updated<Field> hooks for the fields that changed. Fields
+// are assigned in name order. Conversion failures are collected into
+// ValidationErrors; other fields are still assigned.
+func applyModels(ctx context.Context, c ComponentInterface, data url.Values) error {
+	models := map[string][]string{}
+	for key, vals := range data {
+		if name, ok := modelName(key); ok {
+			models[name] = vals
+		}
+	}
+	if len(models) == 0 {
+		return nil
+	}
+
+	allowed := map[string]bool{}
+	if al, ok := c.(ModelAllowList); ok {
+		for _, name := range al.BindableFields() {
+			allowed[name] = true
+		}
+	}
+	names := make([]string, 0, len(models))
+	for name := range models {
+		if !allowed[name] {
+			return fmt.Errorf("%w: %q on component '%s'", ErrFieldNotBindable, name, c.GetKind())
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var verrs ValidationErrors
+	for _, name := range names {
+		field, err := modelField(c, name)
+		if err != nil {
+			return err
+		}
+		changed, err := assignModel(field, models[name])
+		if err != nil {
+			verrs = append(verrs, FieldError{Field: name, Message: err.Error()})
+			continue
+		}
+		if !changed {
+			continue
+		}
+		if err := callUpdated(ctx, c, name); err != nil {
+			return err
+		}
+	}
+	if len(verrs) > 0 {
+		return verrs
+	}
+	return nil
+}
+
+// modelField resolves a dotted path of exported fields, allocating nil
+// struct pointers along the way.
+func modelField(c ComponentInterface, path string) (reflect.Value, error) {
+	v := reflect.ValueOf(c)
+	for _, name := range strings.Split(path, ".") {
+		for v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		if v.Kind() != reflect.Struct {
+			return reflect.Value{}, fmt.Errorf("liveflux: model %q: %s is not a struct", path, v.Type())
+		}
+		sf, ok := v.Type().FieldByName(name)
+		if !ok || !sf.IsExported() {
+			return reflect.Value{}, fmt.Errorf("liveflux: model %q: no exported field %s on %s", path, name, v.Type())
+		}
+		v = v.FieldByIndex(sf.Index)
+	}
+	return v, nil
+}
+
+// assignModel converts vals with the DecodeForm leaf rules and sets field
+// if the value differs. Slices take every non-empty value.
+func assignModel(field reflect.Value, vals []string) (bool, error) {
+	t := field.Type()
+	next := reflect.New(t).Elem()
+	switch {
+	case isLeafType(t):
+		raw := ""
+		if len(vals) > 0 {
+			raw = vals[0]
+		}
+		if err := setLeaf(next, strings.TrimSpace(raw)); err != nil {
+			return false, err
+		}
+	case t.Kind() == reflect.Slice && isLeafType(t.Elem()):
+		for _, raw := range vals {
+			if raw = strings.TrimSpace(raw); raw == "" {
+				continue
+			}
+			ev := reflect.New(t.Elem()).Elem()
+			if err := setLeaf(ev, raw); err != nil {
+				return false, err
+			}
+			next = reflect.Append(next, ev)
+		}
+	default:
+		return false, fmt.Errorf("cannot bind values of type %s", t)
+	}
+
+	if reflect.DeepEqual(field.Interface(), next.Interface()) {
+		return false, nil
+	}
+	field.Set(next)
+	return true, nil
+}
+
+// callUpdated runs the optional Updated<Field> hook, e.g. UpdatedEmail for
+// "Email" or UpdatedAddressCity for "Address.City". The hook takes a
+// context and may return an error.
+func callUpdated(ctx context.Context, c ComponentInterface, path string) error {
+	method := reflect.ValueOf(c).MethodByName("Updated" + strings.ReplaceAll(path, ".", ""))
+	if !method.IsValid() {
+		return nil
+	}
+	mt := method.Type()
+	if mt.NumIn() != 1 || mt.In(0) != contextType || mt.NumOut() > 1 || (mt.NumOut() == 1 && mt.Out(0) != errorType) {
+		return fmt.Errorf("liveflux: Updated hook for %q must be func(context.Context) or func(context.Context) error", path)
+	}
+	results := method.Call([]reflect.Value{reflect.ValueOf(ctx)})
+	if len(results) == 1 && !results[0].IsNil() {
+		return results[0].Interface().(error)
+	}
+	return nil
+}
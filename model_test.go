@@ -0,0 +1,155 @@
+package liveflux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/dracory/hb"
+)
+
+type modelAddress struct {
+	City string
+}
+
+// modelComp binds a handful of fields through data-flux-model.
+type modelComp struct {
+	Base
+	Email      string
+	Age        int
+	Subscribed bool
+	Tags       []string
+	Address    *modelAddress
+	Secret     string
+	Log        []string
+}
+
+func (c *modelComp) GetKind() string                                { return "test.model-comp" }
+func (c *modelComp) Mount(context.Context, map[string]string) error { return nil }
+func (c *modelComp) BindableFields() []string {
+	return []string{"Email", "Age", "Subscribed", "Tags", "Address.City"}
+}
+func (c *modelComp) Handle(_ context.Context, action string, _ url.Values) error {
+	c.Log = append(c.Log, action+":"+c.Email)
+	return nil
+}
+func (c *modelComp) Render(context.Context) hb.TagInterface {
+	return c.Root(hb.Wrap().
+		Child(hb.Text(fmt.Sprintf("email=%s age=%d log=%s", c.Email, c.Age, strings.Join(c.Log, ",")))).
+		Child(c.ErrorFor("Age")))
+}
+
+func (c *modelComp) UpdatedEmail(context.Context) error {
+	c.Log = append(c.Log, "updated-email")
+	return nil
+}
+
+func (c *modelComp) UpdatedAddressCity(context.Context) {
+	c.Log = append(c.Log, "updated-city")
+}
+
+func models(pairs ...string) url.Values {
+	values := url.Values{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		values.Add(FormModel+"["+pairs[i]+"]", pairs[i+1])
+	}
+	return values
+}
+
+func TestApplyModels(t *testing.T) {
+	c := &modelComp{}
+	data := models("Email", "a@example.com", "Age", "42", "Subscribed", "true", "Tags", "go", "Tags", "", "Tags", "js", "Address.City", "Sofia")
+
+	if err := applyModels(context.Background(), c, data); err != nil {
+		t.Fatalf("applyModels: %v", err)
+	}
+	if c.Email != "a@example.com" || c.Age != 42 || !c.Subscribed || strings.Join(c.Tags, ",") != "go,js" {
+		t.Fatalf("unexpected fields: %+v", c)
+	}
+	if c.Address == nil || c.Address.City != "Sofia" {
+		t.Fatalf("expected nested field to be allocated and set, got %+v", c.Address)
+	}
+	if strings.Join(c.Log, ",") != "updated-city,updated-email" {
+		t.Fatalf("unexpected hooks: %v", c.Log)
+	}
+
+	// Unchanged values do not fire hooks again
+	c.Log = nil
+	if err := applyModels(context.Background(), c, data); err != nil {
+		t.Fatalf("applyModels: %v", err)
+	}
+	if len(c.Log) != 0 {
+		t.Fatalf("expected no hooks for unchanged values, got %v", c.Log)
+	}
+}
+
+func TestApplyModels_RejectsFieldsOutsideAllowList(t *testing.T) {
+	c := &modelComp{}
+	err := applyModels(context.Background(), c, models("Email", "x", "Secret", "leak"))
+	if !errors.Is(err, ErrFieldNotBindable) {
+		t.Fatalf("expected ErrFieldNotBindable, got %v", err)
+	}
+	if c.Secret != "" || c.Email != "" {
+		t.Fatalf("expected nothing to be assigned, got %+v", c)
+	}
+
+	var plain struct{ handlerComp }
+	if err := applyModels(context.Background(), &plain, models("Count", "1")); !errors.Is(err, ErrFieldNotBindable) {
+		t.Fatalf("expected components without an allow-list to refuse models, got %v", err)
+	}
+}
+
+func TestApplyModels_ConversionErrors(t *testing.T) {
+	c := &modelComp{Age: 7}
+	err := applyModels(context.Background(), c, models("Age", "old", "Email", "a@example.com"))
+
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) || len(verrs) != 1 || verrs[0].Field != "Age" {
+		t.Fatalf("expected a validation error for Age, got %v", err)
+	}
+	if c.Age != 7 || c.Email != "a@example.com" {
+		t.Fatalf("expected Age to keep its value and Email to be set, got %+v", c)
+	}
+}
+
+func TestHandler_Models(t *testing.T) {
+	h := NewHandler(NewMemoryStore())
+	kind := registerTestKind(t, &modelComp{})
+
+	rec := postForm(h, url.Values{FormComponentKind: {kind}})
+	id := extractAttr(t, rec.Body.String(), DataFluxComponentID)
+	post := func(fields url.Values) (int, string) {
+		fields.Set(FormComponentKind, kind)
+		fields.Set(FormComponentID, id)
+		rec := postForm(h, fields)
+		return rec.Code, rec.Body.String()
+	}
+
+	// A model update without an action is applied and persisted
+	code, body := post(models("Email", "a@example.com"))
+	if code != http.StatusOK || !strings.Contains(body, "email=a@example.com") {
+		t.Fatalf("expected model update to render, got %d %q", code, body)
+	}
+
+	// Models are assigned before the action runs
+	fields := models("Email", "b@example.com")
+	fields.Set(FormAction, "save")
+	_, body = post(fields)
+	if !strings.Contains(body, "log=updated-email,updated-email,save:b@example.com") {
+		t.Fatalf("expected action to see the bound value, got %q", body)
+	}
+
+	code, body = post(models("Age", "old"))
+	if code != http.StatusOK || !strings.Contains(body, "must be a whole number") {
+		t.Fatalf("expected conversion error to re-render, got %d %q", code, body)
+	}
+
+	code, body = post(models("Secret", "x"))
+	if code != http.StatusBadRequest || body != "field not bindable" {
+		t.Fatalf("expected 400 field not bindable, got %d %q", code, body)
+	}
+}
@@ -0,0 +1,47 @@
+package liveflux
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// poller is implemented by Base.
+type poller interface {
+	pollDirective() string
+	setPollDirective(directive string)
+	takePollChange() (directive string, changed bool)
+}
+
+// formatPoll returns the data-flux-poll value for interval and action, or
+// "" for an interval <= 0.
+func formatPoll(interval time.Duration, action string) string {
+	if interval <= 0 {
+		return ""
+	}
+	ms := max(interval.Milliseconds(), 1)
+	directive := strconv.FormatInt(ms, 10) + "ms"
+	if ms%1000 == 0 {
+		directive = strconv.FormatInt(ms/1000, 10) + "s"
+	}
+	if action != "" {
+		directive += " " + action
+	}
+	return directive
+}
+
+// writePollHeader sends PollHeader when c changed its poll directive.
+func writePollHeader(w http.ResponseWriter, c ComponentInterface) {
+	p, ok := c.(poller)
+	if !ok {
+		return
+	}
+	directive, changed := p.takePollChange()
+	if !changed {
+		return
+	}
+	if directive == "" {
+		directive = "0"
+	}
+	w.Header().Set(PollHeader, directive)
+}
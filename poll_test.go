@@ -0,0 +1,107 @@
+package liveflux
+
+import (
+	"context"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/hb"
+)
+
+type pollComp struct {
+	Base
+	Done bool
+}
+
+func (c *pollComp) GetKind() string { return "test.poll-comp" }
+
+func (c *pollComp) Mount(context.Context, map[string]string) error {
+	c.SetPollInterval(2*time.Second, "refresh")
+	return nil
+}
+
+func (c *pollComp) Handle(_ context.Context, action string, _ url.Values) error {
+	if action == "finish" {
+		c.Done = true
+		c.SetPollInterval(0)
+	}
+	return nil
+}
+
+func (c *pollComp) Render(context.Context) hb.TagInterface {
+	return c.Root(hb.Span().Text("polling"))
+}
+
+func TestFormatPoll(t *testing.T) {
+	cases := []struct {
+		interval time.Duration
+		action   string
+		want     string
+	}{
+		{5 * time.Second, "", "5s"},
+		{1500 * time.Millisecond, "", "1500ms"},
+		{time.Minute, "refresh", "60s refresh"},
+		{time.Microsecond, "", "1ms"},
+		{0, "refresh", ""},
+		{-time.Second, "", ""},
+	}
+	for _, tc := range cases {
+		if got := formatPoll(tc.interval, tc.action); got != tc.want {
+			t.Fatalf("formatPoll(%v, %q) = %q, want %q", tc.interval, tc.action, got, tc.want)
+		}
+	}
+}
+
+func TestHandler_PollInterval(t *testing.T) {
+	registerTestKind(t, &pollComp{})
+	h := NewHandler(NewMemoryStore())
+
+	mountRec := postForm(h, url.Values{FormComponentKind: {"test.poll-comp"}})
+	html := mountRec.Body.String()
+	if got := extractAttr(t, html, DataFluxPoll); got != "2s refresh" {
+		t.Fatalf("expected data-flux-poll=\"2s refresh\", got %q", got)
+	}
+	if got := mountRec.Header().Get(PollHeader); got != "2s refresh" {
+		t.Fatalf("expected the poll header on mount, got %q", got)
+	}
+	id := extractAttr(t, html, DataFluxComponentID)
+
+	// A refresh keeps polling and leaves the header out
+	rec := postForm(h, url.Values{FormComponentKind: {"test.poll-comp"}, FormComponentID: {id}, FormAction: {"refresh"}})
+	if got := rec.Header().Get(PollHeader); got != "" {
+		t.Fatalf("expected no poll header, got %q", got)
+	}
+	if !strings.Contains(rec.Body.String(), DataFluxPoll+`="2s refresh"`) {
+		t.Fatalf("expected polling to continue, got %s", rec.Body.String())
+	}
+
+	rec = postForm(h, url.Values{FormComponentKind: {"test.poll-comp"}, FormComponentID: {id}, FormAction: {"finish"}})
+	if got := rec.Header().Get(PollHeader); got != "0" {
+		t.Fatalf("expected the poll header to stop polling, got %q", got)
+	}
+	if strings.Contains(rec.Body.String(), DataFluxPoll) {
+		t.Fatalf("expected no data-flux-poll, got %s", rec.Body.String())
+	}
+}
+
+func TestDehydrateHydrate_PollDirective(t *testing.T) {
+	registerTestKind(t, &pollComp{})
+	c := &pollComp{}
+	c.SetKind(c.GetKind())
+	c.SetID("poll-id")
+	c.SetPollInterval(500*time.Millisecond, "refresh")
+
+	data, err := Dehydrate(c, nil)
+	if err != nil {
+		t.Fatalf("Dehydrate: %v", err)
+	}
+	got, err := Hydrate(data, nil)
+	if err != nil {
+		t.Fatalf("Hydrate: %v", err)
+	}
+	if directive := got.(*pollComp).pollDirective(); directive != "500ms refresh" {
+		t.Fatalf("expected the poll directive to be restored, got %q", directive)
+	}
+}
@@ -0,0 +1,153 @@
+package liveflux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+)
+
+// PubSub fans messages out to subscribers of a channel. MemoryPubSub
+// reaches subscribers in the same process; implementations backed by a
+// shared broker (see package pubsub) reach every instance of an app.
+type PubSub interface {
+	// Publish sends payload to every subscriber of channel.
+	Publish(ctx context.Context, channel string, payload []byte) error
+	// Subscribe calls fn for every payload published on channel until the
+	// returned cancel function is called. fn must not block for long.
+	Subscribe(ctx context.Context, channel string, fn func(payload []byte)) (func(), error)
+}
+
+// PubSubDefault is used by handlers without a PubSub of their own.
+var PubSubDefault PubSub = NewMemoryPubSub()
+
+// ErrBroadcastDropped is reported to Handler.OnBroadcastError when a
+// component receives broadcasts faster than its listeners handle them.
+var ErrBroadcastDropped = errors.New("liveflux: broadcast queue full, event dropped")
+
+// MemoryPubSub is an in-process PubSub. Publish calls the subscribers
+// synchronously, in the order they subscribed.
+type MemoryPubSub struct {
+	mu     sync.RWMutex
+	next   int
+	topics map[string][]memorySubscriber
+}
+
+type memorySubscriber struct {
+	id int
+	fn func(payload []byte)
+}
+
+// NewMemoryPubSub creates a MemoryPubSub.
+func NewMemoryPubSub() *MemoryPubSub {
+	return &MemoryPubSub{topics: map[string][]memorySubscriber{}}
+}
+
+// Publish implements PubSub.
+func (p *MemoryPubSub) Publish(_ context.Context, channel string, payload []byte) error {
+	p.mu.RLock()
+	subs := append([]memorySubscriber(nil), p.topics[channel]...)
+	p.mu.RUnlock()
+
+	for _, sub := range subs {
+		sub.fn(payload)
+	}
+	return nil
+}
+
+// Subscribe implements PubSub.
+func (p *MemoryPubSub) Subscribe(_ context.Context, channel string, fn func(payload []byte)) (func(), error) {
+	if fn == nil {
+		return nil, fmt.Errorf("liveflux: nil subscriber for channel %q", channel)
+	}
+	p.mu.Lock()
+	id := p.next
+	p.next++
+	p.topics[channel] = append(p.topics[channel], memorySubscriber{id: id, fn: fn})
+	p.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			subs := p.topics[channel]
+			for i, sub := range subs {
+				if sub.id == id {
+					p.topics[channel] = append(subs[:i:i], subs[i+1:]...)
+					break
+				}
+			}
+			if len(p.topics[channel]) == 0 {
+				delete(p.topics, channel)
+			}
+		})
+	}, nil
+}
+
+// ChannelSubscriber is implemented by components that receive events sent
+// with BroadcastTo while they are connected over WebSocket. Channels is read
+// when the first connection for the instance opens.
+type ChannelSubscriber interface {
+	Channels() []string
+}
+
+// Broadcast is an event queued for a PubSub channel.
+type Broadcast struct {
+	Channel string
+	Event   Event
+}
+
+// broadcastMessage is the payload published for a Broadcast. ID lets
+// clients that receive it over several connections handle it once.
+type broadcastMessage struct {
+	ID    string `json:"id"`
+	Event Event  `json:"event"`
+}
+
+// BroadcastTo publishes an event on channel through the handler's PubSub, for
+// code outside components such as background jobs. Components call
+// Base.BroadcastTo instead, which is published after their action.
+func (h *Handler) BroadcastTo(ctx context.Context, channel, eventName string, data ...map[string]any) error {
+	event := Event{Name: eventName}
+	if len(data) > 0 && data[0] != nil {
+		event.Data = data[0]
+	}
+	payload, err := json.Marshal(broadcastMessage{ID: NewID(), Event: event})
+	if err != nil {
+		return fmt.Errorf("liveflux: encode broadcast: %w", err)
+	}
+	return h.pubSub().Publish(ctx, channel, payload)
+}
+
+// publishBroadcasts publishes the broadcasts queued by c.
+func (h *Handler) publishBroadcasts(ctx context.Context, c ComponentInterface) {
+	ea, ok := c.(EventAware)
+	if !ok || ea.GetEventDispatcher() == nil {
+		return
+	}
+	for _, b := range ea.GetEventDispatcher().TakeBroadcasts() {
+		if err := h.BroadcastTo(ctx, b.Channel, b.Event.Name, b.Event.Data); err != nil {
+			h.broadcastError(ctx, b.Channel, err)
+		}
+	}
+}
+
+// broadcastError reports a PubSub error to OnBroadcastError, or logs it.
+func (h *Handler) broadcastError(ctx context.Context, channel string, err error) {
+	if h.OnBroadcastError != nil {
+		h.OnBroadcastError(ctx, channel, err)
+		return
+	}
+	log.Printf("liveflux: broadcast error on %q: %v", channel, err)
+}
+
+// pubSub returns the handler's PubSub or PubSubDefault.
+func (h *Handler) pubSub() PubSub {
+	if h.PubSub != nil {
+		return h.PubSub
+	}
+	return PubSubDefault
+}
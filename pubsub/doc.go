@@ -0,0 +1,9 @@
+// Package pubsub provides liveflux.PubSub implementations backed by a shared
+// broker, so events sent with BroadcastTo reach WebSocket clients connected
+// to any instance of an app.
+//
+//   - PostgresPubSub uses LISTEN/NOTIFY. Publishing goes through database/sql;
+//     listening needs one dedicated driver connection (see PostgresListener).
+//
+// liveflux.MemoryPubSub (the default) covers single-instance deployments.
+package pubsub
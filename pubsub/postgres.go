@@ -0,0 +1,317 @@
+package pubsub
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dracory/liveflux"
+)
+
+// MaxPostgresPayload is the largest payload NOTIFY accepts, in bytes.
+const MaxPostgresPayload = 7999
+
+// maxPostgresChannel is the longest Postgres identifier, in bytes.
+const maxPostgresChannel = 63
+
+// DefaultPostgresChannelPrefix namespaces liveflux channels in Postgres.
+const DefaultPostgresChannelPrefix = "liveflux:"
+
+// ErrPayloadTooLarge is returned by Publish for payloads over MaxPostgresPayload.
+var ErrPayloadTooLarge = errors.New("liveflux: pubsub payload too large")
+
+// Execer runs a statement. *sql.DB, *sql.Conn and *sql.Tx implement it.
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// PostgresListener is the connection PostgresPubSub listens on. database/sql
+// has no notification API, so adapt a dedicated driver connection, e.g. with
+// pgx:
+//
+//	type pgxListener struct{ conn *pgx.Conn }
+//
+//	func (l pgxListener) Exec(ctx context.Context, sql string) error {
+//		_, err := l.conn.Exec(ctx, sql)
+//		return err
+//	}
+//
+//	func (l pgxListener) WaitForNotification(ctx context.Context) (string, string, error) {
+//		n, err := l.conn.WaitForNotification(ctx)
+//		if err != nil {
+//			return "", "", err
+//		}
+//		return n.Channel, n.Payload, nil
+//	}
+//
+// PostgresPubSub uses the connection from one goroutine only; do not share it.
+// When WaitForNotification fails, LISTEN is issued again for every
+// subscribed channel, so an adapter may reconnect behind the interface.
+// Notifications sent while the connection was down are lost.
+type PostgresListener interface {
+	// Exec runs LISTEN and UNLISTEN statements.
+	Exec(ctx context.Context, sql string) error
+	// WaitForNotification blocks until a notification arrives or ctx is done.
+	WaitForNotification(ctx context.Context) (channel, payload string, err error)
+}
+
+// PostgresOption configures a PostgresPubSub.
+type PostgresOption func(*postgresOptions)
+
+type postgresOptions struct {
+	prefix        string
+	retryInterval time.Duration
+	onError       func(op string, err error)
+}
+
+// WithPostgresChannelPrefix sets the prefix added to channel names in
+// Postgres. Defaults to DefaultPostgresChannelPrefix.
+func WithPostgresChannelPrefix(prefix string) PostgresOption {
+	return func(opts *postgresOptions) {
+		opts.prefix = prefix
+	}
+}
+
+// WithPostgresRetryInterval sets the pause after a failed wait for
+// notifications, after which every subscribed channel is listened to again.
+// Defaults to 1s.
+func WithPostgresRetryInterval(interval time.Duration) PostgresOption {
+	return func(opts *postgresOptions) {
+		opts.retryInterval = interval
+	}
+}
+
+// WithPostgresErrorHandler receives listen errors, which happen in the
+// background. By default errors are written to the standard logger.
+func WithPostgresErrorHandler(fn func(op string, err error)) PostgresOption {
+	return func(opts *postgresOptions) {
+		opts.onError = fn
+	}
+}
+
+// PostgresPubSub is a liveflux.PubSub using Postgres LISTEN/NOTIFY. Publish
+// sends pg_notify through db; one goroutine waits for notifications on
+// listener and fans them out to the subscribers in this process. Every
+// instance of an app, including the publishing one, receives a message
+// through Postgres.
+//
+// Payloads must be text (liveflux publishes JSON) of at most
+// MaxPostgresPayload bytes, and prefix plus channel at most 63 bytes.
+type PostgresPubSub struct {
+	db       Execer
+	listener PostgresListener
+	opts     postgresOptions
+	local    *liveflux.MemoryPubSub
+
+	mu        sync.Mutex
+	refs      map[string]int // channel -> subscribers in this process
+	pending   []listenOp     // LISTEN/UNLISTEN statements for the loop
+	interrupt context.CancelFunc
+
+	stop      chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type listenOp struct {
+	sql    string
+	result chan error // nil for statements the loop queued itself
+}
+
+// NewPostgresPubSub creates a PostgresPubSub and starts listening. Stop it
+// with Close.
+func NewPostgresPubSub(db Execer, listener PostgresListener, optFns ...PostgresOption) (*PostgresPubSub, error) {
+	if db == nil || listener == nil {
+		return nil, errors.New("liveflux: postgres pubsub requires a database and a listener")
+	}
+	options := postgresOptions{prefix: DefaultPostgresChannelPrefix, retryInterval: time.Second}
+	for _, fn := range optFns {
+		if fn != nil {
+			fn(&options)
+		}
+	}
+	if options.onError == nil {
+		options.onError = func(op string, err error) {
+			log.Printf("liveflux: postgres pubsub %s error: %v", op, err)
+		}
+	}
+
+	p := &PostgresPubSub{
+		db:       db,
+		listener: listener,
+		opts:     options,
+		local:    liveflux.NewMemoryPubSub(),
+		refs:     map[string]int{},
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	go p.run()
+	return p, nil
+}
+
+// Publish implements liveflux.PubSub.
+func (p *PostgresPubSub) Publish(ctx context.Context, channel string, payload []byte) error {
+	name, err := p.channelName(channel)
+	if err != nil {
+		return err
+	}
+	if len(payload) > MaxPostgresPayload {
+		return ErrPayloadTooLarge
+	}
+	_, err = p.db.ExecContext(ctx, "SELECT pg_notify($1, $2)", name, string(payload))
+	return err
+}
+
+// Subscribe implements liveflux.PubSub. The first subscriber of a channel
+// in this process issues LISTEN, the last one to cancel issues UNLISTEN.
+func (p *PostgresPubSub) Subscribe(ctx context.Context, channel string, fn func(payload []byte)) (func(), error) {
+	name, err := p.channelName(channel)
+	if err != nil {
+		return nil, err
+	}
+	cancelLocal, err := p.local.Subscribe(ctx, channel, fn)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.refs[channel]++
+	var op *listenOp
+	if p.refs[channel] == 1 {
+		op = p.queue("LISTEN " + quoteIdentifier(name))
+	}
+	p.mu.Unlock()
+
+	unsubscribe := func() {
+		cancelLocal()
+		p.mu.Lock()
+		p.refs[channel]--
+		if p.refs[channel] == 0 {
+			delete(p.refs, channel)
+			p.queue("UNLISTEN " + quoteIdentifier(name))
+		}
+		p.mu.Unlock()
+	}
+
+	if op != nil {
+		select {
+		case err = <-op.result:
+		case <-ctx.Done():
+			err = ctx.Err()
+		case <-p.stop:
+			err = errors.New("liveflux: postgres pubsub closed")
+		}
+		if err != nil {
+			unsubscribe()
+			return nil, err
+		}
+	}
+
+	var once sync.Once
+	return func() { once.Do(unsubscribe) }, nil
+}
+
+// Close stops listening. Subscribers receive no further messages.
+func (p *PostgresPubSub) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.stop)
+		p.mu.Lock()
+		if p.interrupt != nil {
+			p.interrupt()
+		}
+		p.mu.Unlock()
+		<-p.done
+	})
+	return nil
+}
+
+// queue hands a statement to the listen loop and interrupts its wait. The
+// caller holds p.mu, which keeps LISTEN and UNLISTEN of a channel in order.
+func (p *PostgresPubSub) queue(sql string) *listenOp {
+	op := listenOp{sql: sql, result: make(chan error, 1)}
+	p.pending = append(p.pending, op)
+	if p.interrupt != nil {
+		p.interrupt()
+	}
+	return &op
+}
+
+// run owns the listener: it applies queued statements, then waits for the
+// next notification until interrupted.
+func (p *PostgresPubSub) run() {
+	defer close(p.done)
+	for {
+		ctx, cancel := context.WithCancel(context.Background())
+		p.mu.Lock()
+		select {
+		case <-p.stop:
+			p.mu.Unlock()
+			cancel()
+			return
+		default:
+		}
+		p.interrupt = cancel
+		ops := p.pending
+		p.pending = nil
+		p.mu.Unlock()
+
+		for _, op := range ops {
+			err := p.listener.Exec(context.Background(), op.sql)
+			if op.result != nil {
+				op.result <- err
+			} else if err != nil {
+				p.opts.onError("listen", err)
+			}
+		}
+
+		channel, payload, err := p.listener.WaitForNotification(ctx)
+		interrupted := ctx.Err() != nil
+		cancel()
+		if err != nil {
+			if !interrupted {
+				p.opts.onError("listen", err)
+				select {
+				case <-time.After(p.opts.retryInterval):
+				case <-p.stop:
+				}
+				p.relisten()
+			}
+			continue
+		}
+		if name, ok := strings.CutPrefix(channel, p.opts.prefix); ok {
+			_ = p.local.Publish(context.Background(), name, []byte(payload))
+		}
+	}
+}
+
+// relisten queues LISTEN for every subscribed channel after a failed wait,
+// so subscriptions survive a listener that reconnected. Listening to a
+// channel twice is harmless.
+func (p *PostgresPubSub) relisten() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for channel := range p.refs {
+		if name, err := p.channelName(channel); err == nil {
+			p.pending = append(p.pending, listenOp{sql: "LISTEN " + quoteIdentifier(name)})
+		}
+	}
+}
+
+// channelName returns the Postgres channel for channel.
+func (p *PostgresPubSub) channelName(channel string) (string, error) {
+	name := p.opts.prefix + channel
+	if channel == "" || len(name) > maxPostgresChannel {
+		return "", fmt.Errorf("liveflux: invalid postgres channel %q", name)
+	}
+	return name, nil
+}
+
+// quoteIdentifier quotes a Postgres identifier for LISTEN/UNLISTEN.
+func quoteIdentifier(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
@@ -0,0 +1,214 @@
+package pubsub
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dracory/liveflux"
+)
+
+var _ liveflux.PubSub = (*PostgresPubSub)(nil)
+
+// fakePostgres stands in for a database: pg_notify sent through ExecContext
+// reaches WaitForNotification when the channel is listened to. An error sent
+// on drop fails the wait and, like a reconnect, forgets the channels.
+type fakePostgres struct {
+	mu        sync.Mutex
+	listening map[string]bool
+	stmts     []string
+	notes     chan [2]string
+	drop      chan error
+}
+
+func newFakePostgres() *fakePostgres {
+	return &fakePostgres{listening: map[string]bool{}, notes: make(chan [2]string, 16), drop: make(chan error)}
+}
+
+func (f *fakePostgres) ExecContext(_ context.Context, query string, args ...any) (sql.Result, error) {
+	if query != "SELECT pg_notify($1, $2)" || len(args) != 2 {
+		return nil, errors.New("unexpected query " + query)
+	}
+	channel, payload := args[0].(string), args[1].(string)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.listening[channel] {
+		f.notes <- [2]string{channel, payload}
+	}
+	return nil, nil
+}
+
+func (f *fakePostgres) Exec(_ context.Context, stmt string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.stmts = append(f.stmts, stmt)
+	verb, ident, _ := strings.Cut(stmt, " ")
+	channel := strings.ReplaceAll(strings.Trim(ident, `"`), `""`, `"`)
+	switch verb {
+	case "LISTEN":
+		f.listening[channel] = true
+	case "UNLISTEN":
+		delete(f.listening, channel)
+	}
+	return nil
+}
+
+func (f *fakePostgres) WaitForNotification(ctx context.Context) (string, string, error) {
+	select {
+	case n := <-f.notes:
+		return n[0], n[1], nil
+	case err := <-f.drop:
+		f.mu.Lock()
+		f.listening = map[string]bool{}
+		f.mu.Unlock()
+		return "", "", err
+	case <-ctx.Done():
+		return "", "", ctx.Err()
+	}
+}
+
+func (f *fakePostgres) statements() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]string(nil), f.stmts...)
+}
+
+func TestPostgresPubSub_PublishSubscribe(t *testing.T) {
+	pg := newFakePostgres()
+	p, err := NewPostgresPubSub(pg, pg)
+	if err != nil {
+		t.Fatalf("NewPostgresPubSub: %v", err)
+	}
+	defer p.Close()
+
+	ctx := context.Background()
+	got := make(chan string, 4)
+	cancel, err := p.Subscribe(ctx, "orders", func(payload []byte) { got <- string(payload) })
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	cancelSecond, err := p.Subscribe(ctx, "orders", func(payload []byte) { got <- "second:" + string(payload) })
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	if err := p.Publish(ctx, "orders", []byte(`{"id":1}`)); err != nil {
+		t.Fatalf("Publish: %v", err)
+	}
+	for _, want := range []string{`{"id":1}`, `second:{"id":1}`} {
+		select {
+		case payload := <-got:
+			if payload != want {
+				t.Fatalf("expected %q, got %q", want, payload)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for %q", want)
+		}
+	}
+
+	cancel()
+	cancelSecond()
+	deadline := time.Now().Add(time.Second)
+	for len(pg.statements()) < 2 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	want := []string{`LISTEN "liveflux:orders"`, `UNLISTEN "liveflux:orders"`}
+	if got := pg.statements(); strings.Join(got, ";") != strings.Join(want, ";") {
+		t.Fatalf("expected one LISTEN and one UNLISTEN, got %v", got)
+	}
+}
+
+func TestPostgresPubSub_ListensAgainAfterFailedWait(t *testing.T) {
+	pg := newFakePostgres()
+	errs := make(chan error, 4)
+	p, err := NewPostgresPubSub(pg, pg,
+		WithPostgresRetryInterval(time.Millisecond),
+		WithPostgresErrorHandler(func(_ string, err error) { errs <- err }))
+	if err != nil {
+		t.Fatalf("NewPostgresPubSub: %v", err)
+	}
+	defer p.Close()
+
+	ctx := context.Background()
+	got := make(chan string, 16)
+	cancel, err := p.Subscribe(ctx, "orders", func(payload []byte) { got <- string(payload) })
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	defer cancel()
+
+	pg.drop <- errors.New("connection lost")
+	if err := <-errs; err.Error() != "connection lost" {
+		t.Fatalf("expected the wait error to be reported, got %v", err)
+	}
+
+	// Notifications sent before LISTEN is issued again are lost
+	deadline := time.After(time.Second)
+	for {
+		if err := p.Publish(ctx, "orders", []byte("after")); err != nil {
+			t.Fatalf("Publish: %v", err)
+		}
+		select {
+		case payload := <-got:
+			if payload != "after" {
+				t.Fatalf("expected %q, got %q", "after", payload)
+			}
+			return
+		case <-time.After(10 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("expected delivery to resume after the failed wait")
+		}
+	}
+}
+
+func TestPostgresPubSub_Limits(t *testing.T) {
+	pg := newFakePostgres()
+	p, err := NewPostgresPubSub(pg, pg, WithPostgresChannelPrefix("app_"))
+	if err != nil {
+		t.Fatalf("NewPostgresPubSub: %v", err)
+	}
+	defer p.Close()
+
+	ctx := context.Background()
+	if err := p.Publish(ctx, "big", make([]byte, MaxPostgresPayload+1)); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Fatalf("expected ErrPayloadTooLarge, got %v", err)
+	}
+	if _, err := p.Subscribe(ctx, strings.Repeat("x", 60), func([]byte) {}); err == nil {
+		t.Fatal("expected an error for a channel name over 63 bytes")
+	}
+	if _, err := NewPostgresPubSub(nil, pg); err == nil {
+		t.Fatal("expected an error without a database")
+	}
+}
+
+func TestPostgresPubSub_WithHandler(t *testing.T) {
+	pg := newFakePostgres()
+	p, err := NewPostgresPubSub(pg, pg)
+	if err != nil {
+		t.Fatalf("NewPostgresPubSub: %v", err)
+	}
+	defer p.Close()
+
+	got := make(chan string, 1)
+	if _, err := p.Subscribe(context.Background(), "news", func(payload []byte) { got <- string(payload) }); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	h := liveflux.NewHandler(liveflux.NewMemoryStore())
+	h.PubSub = p
+	if err := h.BroadcastTo(context.Background(), "news", "published", map[string]any{"title": "Hi"}); err != nil {
+		t.Fatalf("BroadcastTo: %v", err)
+	}
+	select {
+	case payload := <-got:
+		if !strings.Contains(payload, `"name":"published"`) || !strings.Contains(payload, `"title":"Hi"`) {
+			t.Fatalf("unexpected payload %q", payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for the broadcast")
+	}
+}
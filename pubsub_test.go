@@ -0,0 +1,293 @@
+package liveflux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/hb"
+)
+
+func TestMemoryPubSub_OrderAndCancel(t *testing.T) {
+	ps := NewMemoryPubSub()
+	ctx := context.Background()
+
+	var got []string
+	cancelA, err := ps.Subscribe(ctx, "news", func(p []byte) { got = append(got, "a:"+string(p)) })
+	if err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if _, err := ps.Subscribe(ctx, "news", func(p []byte) { got = append(got, "b:"+string(p)) }); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+	if _, err := ps.Subscribe(ctx, "other", func(p []byte) { got = append(got, "other") }); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	_ = ps.Publish(ctx, "news", []byte("1"))
+	cancelA()
+	cancelA()
+	_ = ps.Publish(ctx, "news", []byte("2"))
+
+	if strings.Join(got, ",") != "a:1,b:1,b:2" {
+		t.Fatalf("unexpected deliveries %v", got)
+	}
+	if _, err := ps.Subscribe(ctx, "news", nil); err == nil {
+		t.Fatal("expected an error for a nil subscriber")
+	}
+}
+
+// announcerComp broadcasts from its actions.
+type announcerComp struct {
+	Base
+}
+
+func (c *announcerComp) GetKind() string                                { return "test.announcer-comp" }
+func (c *announcerComp) Mount(context.Context, map[string]string) error { return nil }
+func (c *announcerComp) Handle(_ context.Context, _ string, data url.Values) error {
+	c.BroadcastTo("news", "announced", map[string]any{"text": data.Get("text")})
+	return nil
+}
+func (c *announcerComp) Render(context.Context) hb.TagInterface { return c.Root(nil) }
+
+func TestHandler_PublishesBroadcasts(t *testing.T) {
+	h := NewHandler(NewMemoryStore())
+	h.PubSub = NewMemoryPubSub()
+	kind := registerTestKind(t, &announcerComp{})
+
+	var got []broadcastMessage
+	if _, err := h.PubSub.Subscribe(context.Background(), "news", func(p []byte) {
+		var msg broadcastMessage
+		if err := json.Unmarshal(p, &msg); err != nil {
+			t.Errorf("decode: %v", err)
+		}
+		got = append(got, msg)
+	}); err != nil {
+		t.Fatalf("Subscribe: %v", err)
+	}
+
+	id := extractAttr(t, postForm(h, url.Values{FormComponentKind: {kind}}).Body.String(), DataFluxComponentID)
+	rec := postForm(h, url.Values{FormComponentKind: {kind}, FormComponentID: {id}, FormAction: {"say"}, "text": {"hi"}})
+	if rec.Code != 200 {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	if strings.Contains(rec.Header().Get(EventsHeader), "announced") {
+		t.Fatal("broadcasts must not be sent as page events")
+	}
+	if err := h.BroadcastTo(context.Background(), "news", "job-done"); err != nil {
+		t.Fatalf("BroadcastTo: %v", err)
+	}
+
+	if len(got) != 2 || got[0].Event.Name != "announced" || got[0].Event.Data["text"] != "hi" || got[1].Event.Name != "job-done" {
+		t.Fatalf("unexpected broadcasts %+v", got)
+	}
+	if got[0].ID == "" || got[0].ID == got[1].ID {
+		t.Fatalf("expected unique broadcast IDs, got %q and %q", got[0].ID, got[1].ID)
+	}
+}
+
+// tickerWSComp listens to the "ticks" channel over WebSocket.
+type tickerWSComp struct {
+	Base
+	Ticks int
+}
+
+func (c *tickerWSComp) GetKind() string                                  { return "test.ticker-ws" }
+func (c *tickerWSComp) Mount(context.Context, map[string]string) error   { return nil }
+func (c *tickerWSComp) Handle(context.Context, string, url.Values) error { return nil }
+func (c *tickerWSComp) HandleWS(context.Context, *WebSocketMessage) (any, error) {
+	return nil, nil
+}
+func (c *tickerWSComp) Channels() []string { return []string{"ticks"} }
+func (c *tickerWSComp) OnTick(context.Context, Event) error {
+	c.Ticks++
+	c.Dispatch("ticked")
+	return nil
+}
+func (c *tickerWSComp) Render(context.Context) hb.TagInterface {
+	return c.Root(hb.Text(fmt.Sprintf("ticks=%d", c.Ticks)))
+}
+
+func TestWebSocketHandler_DeliversBroadcasts(t *testing.T) {
+	store := NewMemoryStore()
+	h := NewWebSocketHandler(store)
+	h.PubSub = NewMemoryPubSub()
+
+	comp := &tickerWSComp{}
+	comp.SetKind(comp.GetKind())
+	comp.SetID(NewID())
+	store.Set(comp)
+
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+	conn, _, err := dialWS(t, ts.URL)
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if err := conn.WriteJSON(WebSocketMessage{Type: "init", ComponentID: comp.GetID(), Kind: comp.GetKind()}); err != nil {
+		t.Fatalf("write init: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		h.mu.RLock()
+		subscribed := h.subscriptions[comp.GetID()] != nil
+		h.mu.RUnlock()
+		if subscribed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("component was not subscribed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := h.BroadcastTo(context.Background(), "ticks", "tick"); err != nil {
+		t.Fatalf("BroadcastTo: %v", err)
+	}
+
+	read := func() (string, map[string]any) {
+		t.Helper()
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var msg struct {
+			Type string         `json:"type"`
+			Data map[string]any `json:"data"`
+		}
+		if err := conn.ReadJSON(&msg); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return msg.Type, msg.Data
+	}
+
+	typ, data := read()
+	if typ != "update" || !strings.Contains(fmt.Sprint(data["html"]), "ticks=1") {
+		t.Fatalf("expected an update with ticks=1, got %s %v", typ, data)
+	}
+	typ, data = read()
+	if typ != "events" || data["id"] == "" || !strings.Contains(fmt.Sprint(data["events"]), "tick") {
+		t.Fatalf("expected the broadcast event, got %s %v", typ, data)
+	}
+	typ, data = read()
+	if typ != "events" || data["id"] != nil || !strings.Contains(fmt.Sprint(data["events"]), "ticked") {
+		t.Fatalf("expected the chained event without an id, got %s %v", typ, data)
+	}
+
+	saved, _ := store.Get(comp.GetID())
+	if saved.(*tickerWSComp).Ticks != 1 {
+		t.Fatalf("expected the listener's change to be saved")
+	}
+
+	// Closing the last connection cancels the subscription
+	_ = conn.Close()
+	deadline = time.Now().Add(2 * time.Second)
+	for {
+		h.mu.RLock()
+		subscribed := h.subscriptions[comp.GetID()] != nil
+		h.mu.RUnlock()
+		if !subscribed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("subscription was not cancelled")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWebSocketHandler_DeliversBroadcastsWithSessionStore(t *testing.T) {
+	store := NewSessionStore(NewMemoryStore(), SessionFromCookie("sid"))
+	h := NewWebSocketHandler(store)
+	h.PubSub = NewMemoryPubSub()
+
+	comp := &tickerWSComp{}
+	comp.SetKind(comp.GetKind())
+	comp.SetID(NewID())
+	store.SetContext(sessionCtx("alice"), comp)
+
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+	conn, _, err := dialWSWithHeader(t, ts.URL, http.Header{"Cookie": {"sid=alice"}})
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+	if err := conn.WriteJSON(WebSocketMessage{Type: "init", ComponentID: comp.GetID(), Kind: comp.GetKind()}); err != nil {
+		t.Fatalf("write init: %v", err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		h.mu.RLock()
+		subscribed := h.subscriptions[comp.GetID()] != nil
+		h.mu.RUnlock()
+		if subscribed {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("component was not subscribed")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// The broadcast has no request, so no session to look the instance up by
+	if err := h.BroadcastTo(context.Background(), "ticks", "tick"); err != nil {
+		t.Fatalf("BroadcastTo: %v", err)
+	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var msg struct {
+		Type string         `json:"type"`
+		Data map[string]any `json:"data"`
+	}
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if msg.Type != "update" || !strings.Contains(fmt.Sprint(msg.Data["html"]), "ticks=1") {
+		t.Fatalf("expected an update with ticks=1, got %s %v", msg.Type, msg.Data)
+	}
+	if saved, ok := store.GetContext(sessionCtx("alice"), comp.GetID()); !ok || saved.(*tickerWSComp).Ticks != 1 {
+		t.Fatalf("expected the listener's change to be saved for the owner")
+	}
+}
+
+// failingPubSub fails every Publish.
+type failingPubSub struct {
+	MemoryPubSub
+}
+
+func (p *failingPubSub) Publish(context.Context, string, []byte) error {
+	return errors.New("broker down")
+}
+
+func TestHandler_OnBroadcastError(t *testing.T) {
+	h := NewHandler(NewMemoryStore())
+	h.PubSub = &failingPubSub{}
+	var channels []string
+	h.OnBroadcastError = func(_ context.Context, channel string, err error) {
+		if err == nil || !strings.Contains(err.Error(), "broker down") {
+			t.Errorf("unexpected error %v", err)
+		}
+		channels = append(channels, channel)
+	}
+	kind := registerTestKind(t, &announcerComp{})
+
+	id := extractAttr(t, postForm(h, url.Values{FormComponentKind: {kind}}).Body.String(), DataFluxComponentID)
+	rec := postForm(h, url.Values{FormComponentKind: {kind}, FormComponentID: {id}, FormAction: {"say"}})
+	if rec.Code != 200 {
+		t.Fatalf("expected the action to succeed, got %d", rec.Code)
+	}
+	if len(channels) != 1 || channels[0] != "news" {
+		t.Fatalf("expected the publish error for news, got %v", channels)
+	}
+}
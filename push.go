@@ -0,0 +1,119 @@
+package liveflux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrComponentNotFound is returned by Push for an unknown component ID.
+var ErrComponentNotFound = errors.New("liveflux: component not found")
+
+// ErrPushClientState is returned by Push when the handler uses a
+// ClientStateStore, whose state lives in the client and cannot be changed
+// from the server.
+var ErrPushClientState = errors.New("liveflux: push requires a server-side store")
+
+// Push updates a component from outside a request, e.g. from a job queue
+// feeding a live dashboard. It locks the component, calls fn on the stored
+// instance, persists it and sends the new HTML (or its target fragments)
+// to every connected WebSocket client, followed by any events fn
+// dispatched, or a redirect if fn called Redirect. Broadcasts fn queued are
+// published.
+//
+// Nothing is saved or sent when fn returns an error. Without connected
+// clients the change is persisted and kept for clients that reconnect (see
+// WithWebSocketReplay); a send error is returned after the change was
+// saved.
+//
+// Push has no request, so the component is read with the store's plain Get:
+// a SessionStore yields it regardless of session, and its owner keeps it.
+// With a ClientStateStore Push returns ErrPushClientState.
+//
+// Example:
+//
+//	err := wsHandler.Push(ctx, dashboardID, func(c liveflux.ComponentInterface) error {
+//		c.(*Dashboard).Jobs = jobs
+//		return nil
+//	})
+func (h *WebSocketHandler) Push(ctx context.Context, componentID string, fn func(c ComponentInterface) error) error {
+	if _, clientState := h.Store.(*ClientStateStore); clientState {
+		return ErrPushClientState
+	}
+	ctx = contextWithStore(ctx, h.Store)
+	unlock, err := h.lockComponent(ctx, componentID)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	c, ok := h.storeGetDetached(componentID)
+	if !ok || c == nil {
+		return ErrComponentNotFound
+	}
+	if err := afterHydrate(ctx, c); err != nil {
+		return fmt.Errorf("liveflux: hydrate: %w", err)
+	}
+	if err := fn(c); err != nil {
+		return err
+	}
+	if err := h.save(ctx, c); err != nil {
+		return fmt.Errorf("liveflux: dehydrate: %w", err)
+	}
+	h.publishBroadcasts(ctx, c)
+	return h.sendChanges(ctx, c)
+}
+
+// sendChanges sends c's requested redirect, or else its new render followed
+// by the events it dispatched, to all of its clients. The caller holds c's
+// lock.
+func (h *WebSocketHandler) sendChanges(ctx context.Context, c ComponentInterface) error {
+	if sent, err := h.sendRedirect(c); sent {
+		return err
+	}
+	if err := h.sendUpdate(c.GetID(), renderForClients(ctx, c)); err != nil {
+		return err
+	}
+	return h.sendQueuedEvents(c)
+}
+
+// sendRedirect sends the redirect c requested, if any, to all of its
+// clients and reports whether it did.
+func (h *WebSocketHandler) sendRedirect(c ComponentInterface) (bool, error) {
+	url, after := takeRedirect(c)
+	if url == "" {
+		return false, nil
+	}
+	data, err := json.Marshal(redirectData{URL: url, After: after})
+	if err != nil {
+		return true, err
+	}
+	return true, h.sendVersioned(WebSocketMessage{Type: "redirect", ComponentID: c.GetID(), Data: data})
+}
+
+// redirectData is the data of a "redirect" message.
+type redirectData struct {
+	URL   string `json:"url"`
+	After int    `json:"after,omitempty"` // delay in seconds
+}
+
+// takeRedirect returns and clears the redirect c requested, if any.
+func takeRedirect(c ComponentInterface) (url string, after int) {
+	redir, ok := c.(interface{ TakeRedirect() string })
+	if !ok {
+		return "", 0
+	}
+	url = redir.TakeRedirect()
+	if delay, ok := c.(interface{ TakeRedirectDelaySeconds() int }); ok {
+		after = delay.TakeRedirectDelaySeconds()
+	}
+	return url, after
+}
+
+// renderForClients renders c, which the caller has locked, for an update
+// message.
+func renderForClients(ctx context.Context, c ComponentInterface) string {
+	beforeRender(ctx, c)
+	return renderHTML(contextWithHeld(ctx, c), c)
+}
@@ -0,0 +1,244 @@
+package liveflux
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/hb"
+	"github.com/gorilla/websocket"
+)
+
+// gaugeWSComp shows a value pushed from the server.
+type gaugeWSComp struct {
+	Base
+	Value   int
+	Targets bool
+}
+
+func (c *gaugeWSComp) GetKind() string                                  { return "test.gauge-ws" }
+func (c *gaugeWSComp) Mount(context.Context, map[string]string) error   { return nil }
+func (c *gaugeWSComp) Handle(context.Context, string, url.Values) error { return nil }
+func (c *gaugeWSComp) HandleWS(context.Context, *WebSocketMessage) (any, error) {
+	return nil, nil
+}
+func (c *gaugeWSComp) Render(context.Context) hb.TagInterface {
+	return c.Root(hb.Span().ID("value").Text(fmt.Sprintf("value=%d", c.Value)))
+}
+func (c *gaugeWSComp) RenderTargets(context.Context) []TargetFragment {
+	if !c.Targets {
+		return nil
+	}
+	return []TargetFragment{{Selector: "#value", Content: hb.Span().ID("value").Text(fmt.Sprintf("value=%d", c.Value))}}
+}
+
+func newGauge(store Store, targets bool) *gaugeWSComp {
+	c := &gaugeWSComp{Targets: targets}
+	c.SetKind(c.GetKind())
+	c.SetID(NewID())
+	store.Set(c)
+	return c
+}
+
+func TestWebSocketHandler_PushWithoutClients(t *testing.T) {
+	store := NewMemoryStore()
+	h := NewWebSocketHandler(store)
+	gauge := newGauge(store, false)
+
+	set := func(v int) func(ComponentInterface) error {
+		return func(c ComponentInterface) error {
+			c.(*gaugeWSComp).Value = v
+			return nil
+		}
+	}
+	if err := h.Push(context.Background(), gauge.GetID(), set(3)); err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	saved, _ := store.Get(gauge.GetID())
+	if saved.(*gaugeWSComp).Value != 3 {
+		t.Fatalf("expected the change to be saved")
+	}
+
+	boom := errors.New("boom")
+	err := h.Push(context.Background(), gauge.GetID(), func(ComponentInterface) error { return boom })
+	if !errors.Is(err, boom) {
+		t.Fatalf("expected fn's error, got %v", err)
+	}
+
+	if err := h.Push(context.Background(), "missing", set(1)); !errors.Is(err, ErrComponentNotFound) {
+		t.Fatalf("expected ErrComponentNotFound, got %v", err)
+	}
+}
+
+func TestWebSocketHandler_PushWithSessionStore(t *testing.T) {
+	store := NewSessionStore(NewMemoryStore(), SessionFromCookie("sid"))
+	h := NewWebSocketHandler(store)
+	gauge := &gaugeWSComp{}
+	gauge.SetKind(gauge.GetKind())
+	gauge.SetID(NewID())
+	store.SetContext(sessionCtx("alice"), gauge)
+
+	err := h.Push(context.Background(), gauge.GetID(), func(c ComponentInterface) error {
+		c.(*gaugeWSComp).Value = 5
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	saved, ok := store.GetContext(sessionCtx("alice"), gauge.GetID())
+	if !ok || saved.(*gaugeWSComp).Value != 5 {
+		t.Fatalf("expected the change to be saved for the owner")
+	}
+	if _, ok := store.GetContext(sessionCtx("bob"), gauge.GetID()); ok {
+		t.Fatalf("expected Push to keep the session binding")
+	}
+}
+
+func TestWebSocketHandler_PushSkipsForeignSessions(t *testing.T) {
+	store := NewSessionStore(NewMemoryStore(), SessionFromCookie("sid"))
+	h := NewWebSocketHandler(store)
+	gauge := &gaugeWSComp{}
+	gauge.SetKind(gauge.GetKind())
+	gauge.SetID(NewID())
+	store.SetContext(sessionCtx("alice"), gauge)
+
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+	join := func(session string) *websocket.Conn {
+		t.Helper()
+		conn, _, err := dialWSWithHeader(t, ts.URL, http.Header{"Cookie": {"sid=" + session}})
+		if err != nil {
+			t.Fatalf("dial error: %v", err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		if err := conn.WriteJSON(WebSocketMessage{Type: "init", ComponentID: gauge.GetID(), Kind: gauge.GetKind()}); err != nil {
+			t.Fatalf("write init: %v", err)
+		}
+		return conn
+	}
+	type reply struct {
+		Type string `json:"type"`
+		Code int    `json:"code"`
+	}
+
+	bob := join("bob")
+	var r reply
+	_ = bob.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := bob.ReadJSON(&r); err != nil || r.Type != "error" || r.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for another session's component, got %+v %v", r, err)
+	}
+	alice := join("alice")
+	waitForClient(t, h, gauge.GetID())
+
+	err := h.Push(context.Background(), gauge.GetID(), func(c ComponentInterface) error {
+		c.(*gaugeWSComp).Value = 7
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	_ = alice.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if err := alice.ReadJSON(&r); err != nil || r.Type != "update" {
+		t.Fatalf("expected the owner to receive the update, got %+v %v", r, err)
+	}
+	_ = bob.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if err := bob.ReadJSON(&r); err == nil {
+		t.Fatalf("expected nothing for another session, got %+v", r)
+	}
+}
+
+func TestWebSocketHandler_PushWithClientState(t *testing.T) {
+	h := NewWebSocketHandler(newTestClientStateStore(t))
+	err := h.Push(context.Background(), "any", func(ComponentInterface) error { return nil })
+	if !errors.Is(err, ErrPushClientState) {
+		t.Fatalf("expected ErrPushClientState, got %v", err)
+	}
+}
+
+func TestWebSocketHandler_PushSendsUpdates(t *testing.T) {
+	store := NewMemoryStore()
+	h := NewWebSocketHandler(store)
+	full := newGauge(store, false)
+	targeted := newGauge(store, true)
+
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	read := func(id string) (string, string) {
+		t.Helper()
+		conn, _, err := dialWS(t, ts.URL)
+		if err != nil {
+			t.Fatalf("dial error: %v", err)
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		if err := conn.WriteJSON(WebSocketMessage{Type: "init", ComponentID: id, Kind: "test.gauge-ws"}); err != nil {
+			t.Fatalf("write init: %v", err)
+		}
+		waitForClient(t, h, id)
+
+		err = h.Push(context.Background(), id, func(c ComponentInterface) error {
+			c.(*gaugeWSComp).Value = 7
+			c.(*gaugeWSComp).Dispatch("gauge-changed")
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Push: %v", err)
+		}
+
+		var msgs []string
+		var html string
+		for i := 0; i < 2; i++ {
+			_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			var msg struct {
+				Type string         `json:"type"`
+				Data map[string]any `json:"data"`
+			}
+			if err := conn.ReadJSON(&msg); err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			msgs = append(msgs, msg.Type)
+			if msg.Type == "update" {
+				html = fmt.Sprint(msg.Data["html"])
+			}
+			if msg.Type == "events" && !strings.Contains(fmt.Sprint(msg.Data["events"]), "gauge-changed") {
+				t.Fatalf("expected the dispatched event, got %v", msg.Data)
+			}
+		}
+		return strings.Join(msgs, ","), html
+	}
+
+	msgs, html := read(full.GetID())
+	if msgs != "update,events" || strings.Contains(html, "<template") || !strings.Contains(html, "value=7") {
+		t.Fatalf("expected a full render then events, got %s %q", msgs, html)
+	}
+	msgs, html = read(targeted.GetID())
+	if msgs != "update,events" || !strings.Contains(html, `<template data-flux-target="#value"`) || !strings.Contains(html, "value=7") {
+		t.Fatalf("expected target fragments then events, got %s %q", msgs, html)
+	}
+}
+
+// waitForClient waits until the handler registered a connection for id.
+func waitForClient(t *testing.T, h *WebSocketHandler, id string) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		h.mu.RLock()
+		connected := len(h.clients[id]) > 0
+		h.mu.RUnlock()
+		if connected {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("no client registered for %s", id)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
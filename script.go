@@ -108,17 +108,24 @@ func JS(opts ...ClientOptions) string {
 		DataFluxMount:         DataFluxMount,
 		DataFluxParam:         DataFluxParam,
 		DataFluxIndicator:     DataFluxIndicator,
+		DataFluxState:         DataFluxState,
+		DataFluxPoll:          DataFluxPoll,
 		DataFluxSubmit:        DataFluxSubmit,
 		DataFluxWS:            DataFluxWS,
 		DataFluxWSURL:         DataFluxWSURL,
 		Endpoint:              o.Endpoint,
 		RedirectHeader:        o.RedirectHeader,
 		RedirectAfterHeader:   o.RedirectAfterHeader,
+		StateHeader:           StateHeader,
+		PollHeader:            PollHeader,
 		UseWebSocket:          o.UseWebSocket,
 		WebSocketURL:          o.WebSocketURL,
+		Transport:             o.Transport,
+		PollIntervalMs:        o.PollIntervalMs,
 		Headers:               o.Headers,
 		Credentials:           o.Credentials,
 		TimeoutMs:             o.TimeoutMs,
+		UploadChunkSize:       o.UploadChunkSize,
 	}
 
 	b, err := json.Marshal(cfgPayload)
@@ -129,7 +136,7 @@ func JS(opts ...ClientOptions) string {
 	// Creating window.liveflux namespace and merging config
 	cfg := "(function(){var o=" + string(b) + ";window.liveflux=Object.assign({},window.liveflux||{},o);})();\n"
 
-	return cfg + baseJS(o.UseWebSocket)
+	return cfg + baseJS(o.UseWebSocket || o.Transport != "")
 }
 
 // Script returns an hb.Script tag containing the client JS with optional configuration.
@@ -169,6 +176,9 @@ type ClientOptions struct {
 	Headers     map[string]string `json:"headers,omitempty"`
 	Credentials string            `json:"credentials,omitempty"` // e.g., "same-origin", "include"
 	TimeoutMs   int               `json:"timeoutMs,omitempty"`   // request timeout; 0 = no timeout
+	// UploadChunkSize sends files larger than this many bytes with the
+	// chunked upload protocol (requires Handler.ChunkedUploads); 0 = never.
+	UploadChunkSize int64 `json:"uploadChunkSize,omitempty"`
 	// Names of response headers used for client-side redirects
 	RedirectHeader      string `json:"redirectHeader,omitempty"`
 	RedirectAfterHeader string `json:"redirectAfterHeader,omitempty"`
@@ -176,8 +186,28 @@ type ClientOptions struct {
 	// WebSocket integration
 	UseWebSocket bool   `json:"useWebSocket,omitempty"`
 	WebSocketURL string `json:"wsEndpoint,omitempty"`
+	// Transport selects how data-flux-ws components receive pushed updates
+	// (see the Transport* constants); empty means TransportAuto. Setting it
+	// includes the WebSocket client like UseWebSocket.
+	Transport string `json:"transport,omitempty"`
+	// PollIntervalMs is the polling transport's interval; 0 = 5000.
+	PollIntervalMs int `json:"pollIntervalMs,omitempty"`
 }
 
+// Values for ClientOptions.Transport. A transport that cannot connect falls
+// back to the next one in the chain; actions go over POST requests with
+// every transport except WebSocket.
+const (
+	// TransportAuto tries WebSocket, then Server-Sent Events, then polling.
+	TransportAuto = "auto"
+	// TransportWebSocket uses WebSocket only.
+	TransportWebSocket = "websocket"
+	// TransportSSE tries Server-Sent Events, then polling.
+	TransportSSE = "sse"
+	// TransportPolling polls for missed updates.
+	TransportPolling = "polling"
+)
+
 type clientConfig struct {
 	DataFluxAction        string            `json:"dataFluxAction"`
 	DataFluxDispatchTo    string            `json:"dataFluxDispatchTo"`
@@ -187,15 +217,22 @@ type clientConfig struct {
 	DataFluxMount         string            `json:"dataFluxMount"`
 	DataFluxParam         string            `json:"dataFluxParam"`
 	DataFluxIndicator     string            `json:"dataFluxIndicator"`
+	DataFluxState         string            `json:"dataFluxState"`
+	DataFluxPoll          string            `json:"dataFluxPoll"`
 	DataFluxSubmit        string            `json:"dataFluxSubmit"`
 	DataFluxWS            string            `json:"dataFluxWS"`
 	DataFluxWSURL         string            `json:"dataFluxWSURL"`
 	Endpoint              string            `json:"endpoint"`
 	RedirectHeader        string            `json:"redirectHeader"`
 	RedirectAfterHeader   string            `json:"redirectAfterHeader"`
+	StateHeader           string            `json:"stateHeader"`
+	PollHeader            string            `json:"pollHeader"`
 	UseWebSocket          bool              `json:"useWebSocket"`
 	WebSocketURL          string            `json:"wsEndpoint,omitempty"`
+	Transport             string            `json:"transport,omitempty"`
+	PollIntervalMs        int               `json:"pollIntervalMs,omitempty"`
 	Headers               map[string]string `json:"headers"`
 	Credentials           string            `json:"credentials"`
 	TimeoutMs             int               `json:"timeoutMs"`
+	UploadChunkSize       int64             `json:"uploadChunkSize,omitempty"`
 }
@@ -109,6 +109,16 @@ func TestJSIncludesRedirectHeadersConfig(t *testing.T) {
 	}
 }
 
+func TestJSTransportOption(t *testing.T) {
+	out := JS(ClientOptions{Transport: TransportSSE, PollIntervalMs: 2000})
+	if !strings.Contains(out, `"transport":"sse"`) || !strings.Contains(out, `"pollIntervalMs":2000`) {
+		t.Fatalf("JS() missing transport config; out: %q", out[:min(400, len(out))])
+	}
+	if !strings.Contains(out, strings.TrimSpace(readJS(t, "liveflux_websocket.js"))) {
+		t.Fatal("expected a transport to include the WebSocket client")
+	}
+}
+
 func min(a, b int) int {
 	if a < b {
 		return a
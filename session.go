@@ -0,0 +1,297 @@
+package liveflux
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// SessionExtractor returns the session key for a request, or "" if the
+// request has no session.
+type SessionExtractor func(r *http.Request) string
+
+// SessionFromCookie extracts the session key from the named cookie.
+func SessionFromCookie(name string) SessionExtractor {
+	return func(r *http.Request) string {
+		if r == nil {
+			return ""
+		}
+		cookie, err := r.Cookie(name)
+		if err != nil {
+			return ""
+		}
+		return cookie.Value
+	}
+}
+
+// SessionStore wraps a Store and binds every component instance to the
+// session that mounted it. The handlers look components up through
+// GetContext, which refuses instances owned by another (or no) session, so
+// knowing a component ID is no longer enough to drive it.
+//
+// Get, Set and Delete without a request delegate to the wrapped store
+// unchecked; they are meant for trusted server-side code.
+//
+// Bindings of components the wrapped store drops on its own are removed as
+// a MemoryStore evicts them; with other stores see Sweep.
+type SessionStore struct {
+	inner     Store
+	extractor SessionExtractor
+	locker    Locker
+	// evictions is set when inner reports its evictions.
+	evictions bool
+
+	mu        sync.RWMutex
+	owners    map[string]string              // component id -> session
+	bySession map[string]map[string]struct{} // session -> component ids
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// SessionStoreOption configures a SessionStore.
+type SessionStoreOption func(*sessionStoreOptions)
+
+type sessionStoreOptions struct {
+	sweepInterval time.Duration
+}
+
+// WithSessionStoreSweepInterval starts a background goroutine that calls
+// Sweep every interval; stop it with Close. Use it with stores that expire
+// or purge entries themselves, such as stores.FileStore or stores.SQLStore.
+func WithSessionStoreSweepInterval(interval time.Duration) SessionStoreOption {
+	return func(opts *sessionStoreOptions) {
+		opts.sweepInterval = interval
+	}
+}
+
+// evictionNotifier is implemented by stores that report evictions, such as
+// MemoryStore.
+type evictionNotifier interface {
+	addEvictListener(fn func(c ComponentInterface, reason EvictionReason))
+}
+
+// NewSessionStore wraps inner (StoreDefault if nil) and reads session keys
+// with extractor.
+func NewSessionStore(inner Store, extractor SessionExtractor, optFns ...SessionStoreOption) *SessionStore {
+	if inner == nil {
+		inner = StoreDefault
+	}
+	options := sessionStoreOptions{}
+	for _, fn := range optFns {
+		if fn != nil {
+			fn(&options)
+		}
+	}
+
+	s := &SessionStore{
+		inner:     inner,
+		extractor: extractor,
+		owners:    map[string]string{},
+		bySession: map[string]map[string]struct{}{},
+		stop:      make(chan struct{}),
+	}
+	if ls, ok := inner.(LockingStore); ok {
+		s.locker = ls
+	} else {
+		s.locker = NewMutexLocker()
+	}
+	if n, ok := inner.(evictionNotifier); ok {
+		s.evictions = true
+		n.addEvictListener(func(c ComponentInterface, _ EvictionReason) {
+			s.mu.Lock()
+			defer s.mu.Unlock()
+			s.unbindLocked(c.GetID())
+		})
+	}
+	if options.sweepInterval > 0 {
+		go s.sweeper(options.sweepInterval)
+	}
+	return s
+}
+
+// Get returns a component by id without a session check.
+func (s *SessionStore) Get(id string) (ComponentInterface, bool) {
+	return s.inner.Get(id)
+}
+
+// Set stores a component without binding it to a session.
+func (s *SessionStore) Set(c ComponentInterface) {
+	s.inner.Set(c)
+}
+
+// Delete removes a component and its session binding.
+func (s *SessionStore) Delete(id string) {
+	s.inner.Delete(id)
+	s.mu.Lock()
+	s.unbindLocked(id)
+	s.mu.Unlock()
+}
+
+// GetContext returns the component only if it belongs to the session of the
+// request carried by ctx.
+func (s *SessionStore) GetContext(ctx context.Context, id string) (ComponentInterface, bool) {
+	session := s.session(RequestFromContext(ctx))
+	if session == "" {
+		return nil, false
+	}
+
+	s.mu.RLock()
+	owner, ok := s.owners[id]
+	s.mu.RUnlock()
+	if !ok || owner != session {
+		return nil, false
+	}
+
+	c, ok := s.inner.Get(id)
+	if !ok {
+		// The wrapped store dropped the entry (e.g. eviction); forget the binding.
+		s.mu.Lock()
+		s.unbindLocked(id)
+		s.mu.Unlock()
+		return nil, false
+	}
+	return c, true
+}
+
+// SetContext stores the component and binds it to the request's session on
+// first write. Components stored without a session cannot be retrieved
+// through GetContext.
+func (s *SessionStore) SetContext(ctx context.Context, c ComponentInterface) {
+	if c == nil || c.GetID() == "" {
+		return
+	}
+	s.inner.Set(c)
+	if session := s.session(RequestFromContext(ctx)); session != "" {
+		s.bind(session, c.GetID())
+	}
+}
+
+// Bind ties an already stored component to the session of r. Use it after
+// SSR, which stores components without a request.
+func (s *SessionStore) Bind(r *http.Request, id string) {
+	if session := s.session(r); session != "" && id != "" {
+		s.bind(session, id)
+	}
+}
+
+// Components returns the IDs of all components bound to session.
+func (s *SessionStore) Components(session string) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	ids := make([]string, 0, len(s.bySession[session]))
+	for id := range s.bySession[session] {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// Purge deletes every component bound to session (e.g. on logout) and
+// returns how many were removed.
+func (s *SessionStore) Purge(session string) int {
+	s.mu.Lock()
+	ids := make([]string, 0, len(s.bySession[session]))
+	for id := range s.bySession[session] {
+		ids = append(ids, id)
+		s.unbindLocked(id)
+	}
+	s.mu.Unlock()
+
+	for _, id := range ids {
+		s.inner.Delete(id)
+	}
+	return len(ids)
+}
+
+// Sweep drops the bindings of components the wrapped store no longer has
+// and returns how many were dropped. Stores that report evictions, like
+// MemoryStore, are pruned as they evict, so Sweep does nothing for them
+// (looking entries up would also refresh their idle TTL).
+func (s *SessionStore) Sweep() int {
+	if s.evictions {
+		return 0
+	}
+
+	s.mu.RLock()
+	ids := make([]string, 0, len(s.owners))
+	for id := range s.owners {
+		ids = append(ids, id)
+	}
+	s.mu.RUnlock()
+
+	var gone []string
+	for _, id := range ids {
+		if _, ok := s.inner.Get(id); !ok {
+			gone = append(gone, id)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, id := range gone {
+		s.unbindLocked(id)
+	}
+	return len(gone)
+}
+
+// Close stops the sweep goroutine. It is safe to call more than once.
+func (s *SessionStore) Close() error {
+	s.closeOnce.Do(func() { close(s.stop) })
+	return nil
+}
+
+func (s *SessionStore) sweeper(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.Sweep()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Lock implements LockingStore, delegating to the wrapped store when it
+// supports locking.
+func (s *SessionStore) Lock(ctx context.Context, id string) (func(), error) {
+	return s.locker.Lock(ctx, id)
+}
+
+func (s *SessionStore) session(r *http.Request) string {
+	if r == nil || s.extractor == nil {
+		return ""
+	}
+	return s.extractor(r)
+}
+
+// bind records the owner of id; an existing owner is never replaced.
+func (s *SessionStore) bind(session, id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.owners[id]; ok {
+		return
+	}
+	s.owners[id] = session
+	if s.bySession[session] == nil {
+		s.bySession[session] = map[string]struct{}{}
+	}
+	s.bySession[session][id] = struct{}{}
+}
+
+// unbindLocked removes the binding for id. Callers must hold s.mu.
+func (s *SessionStore) unbindLocked(id string) {
+	session, ok := s.owners[id]
+	if !ok {
+		return
+	}
+	delete(s.owners, id)
+	if ids := s.bySession[session]; ids != nil {
+		delete(ids, id)
+		if len(ids) == 0 {
+			delete(s.bySession, session)
+		}
+	}
+}
@@ -0,0 +1,227 @@
+package liveflux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var _ ContextStore = (*SessionStore)(nil)
+var _ LockingStore = (*SessionStore)(nil)
+
+func sessionRequest(session string) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, "/", nil)
+	if session != "" {
+		r.AddCookie(&http.Cookie{Name: "sid", Value: session})
+	}
+	return r
+}
+
+func sessionCtx(session string) context.Context {
+	return contextWithRequest(context.Background(), sessionRequest(session))
+}
+
+func TestSessionStore_GetContextChecksOwner(t *testing.T) {
+	s := NewSessionStore(NewMemoryStore(), SessionFromCookie("sid"))
+	s.SetContext(sessionCtx("alice"), newStoreComp("a"))
+
+	if _, ok := s.GetContext(sessionCtx("alice"), "a"); !ok {
+		t.Fatalf("expected owner to retrieve component")
+	}
+	if _, ok := s.GetContext(sessionCtx("mallory"), "a"); ok {
+		t.Fatalf("expected other session to be refused")
+	}
+	if _, ok := s.GetContext(sessionCtx(""), "a"); ok {
+		t.Fatalf("expected request without session to be refused")
+	}
+	if _, ok := s.GetContext(context.Background(), "a"); ok {
+		t.Fatalf("expected context without request to be refused")
+	}
+
+	// Re-saving under a different session does not steal ownership
+	s.SetContext(sessionCtx("mallory"), newStoreComp("a"))
+	if _, ok := s.GetContext(sessionCtx("mallory"), "a"); ok {
+		t.Fatalf("expected ownership to be immutable")
+	}
+}
+
+func TestSessionStore_UnboundComponentIsRefused(t *testing.T) {
+	s := NewSessionStore(NewMemoryStore(), SessionFromCookie("sid"))
+	s.Set(newStoreComp("a"))
+
+	if _, ok := s.GetContext(sessionCtx("alice"), "a"); ok {
+		t.Fatalf("expected unbound component to be refused")
+	}
+
+	s.Bind(sessionRequest("alice"), "a")
+	if _, ok := s.GetContext(sessionCtx("alice"), "a"); !ok {
+		t.Fatalf("expected Bind to grant access")
+	}
+}
+
+func TestSessionStore_ComponentsAndPurge(t *testing.T) {
+	inner := NewMemoryStore()
+	s := NewSessionStore(inner, SessionFromCookie("sid"))
+	s.SetContext(sessionCtx("alice"), newStoreComp("a1"))
+	s.SetContext(sessionCtx("alice"), newStoreComp("a2"))
+	s.SetContext(sessionCtx("bob"), newStoreComp("b1"))
+
+	ids := s.Components("alice")
+	sort.Strings(ids)
+	if strings.Join(ids, ",") != "a1,a2" {
+		t.Fatalf("unexpected components for alice: %v", ids)
+	}
+
+	if n := s.Purge("alice"); n != 2 {
+		t.Fatalf("expected 2 purged, got %d", n)
+	}
+	if _, ok := inner.Get("a1"); ok {
+		t.Fatalf("expected purged component to be deleted from inner store")
+	}
+	if len(s.Components("alice")) != 0 {
+		t.Fatalf("expected no components after purge")
+	}
+	if _, ok := s.GetContext(sessionCtx("bob"), "b1"); !ok {
+		t.Fatalf("expected other sessions to be untouched")
+	}
+}
+
+func TestSessionStore_DeleteAndEvictionUnbind(t *testing.T) {
+	inner := NewMemoryStore()
+	s := NewSessionStore(inner, SessionFromCookie("sid"))
+	s.SetContext(sessionCtx("alice"), newStoreComp("a1"))
+	s.SetContext(sessionCtx("alice"), newStoreComp("a2"))
+
+	s.Delete("a1")
+	inner.Delete("a2") // simulate eviction in the wrapped store
+	if _, ok := s.GetContext(sessionCtx("alice"), "a2"); ok {
+		t.Fatalf("expected evicted component to miss")
+	}
+	if len(s.Components("alice")) != 0 {
+		t.Fatalf("expected bindings to be cleaned up, got %v", s.Components("alice"))
+	}
+}
+
+func TestSessionStore_EvictionDropsBinding(t *testing.T) {
+	inner := NewMemoryStore(
+		WithMemoryStoreIdleTTL(20*time.Millisecond),
+		WithMemoryStoreJanitorInterval(10*time.Millisecond),
+	)
+	defer inner.Close()
+	s := NewSessionStore(inner, SessionFromCookie("sid"))
+	s.SetContext(sessionCtx("alice"), newStoreComp("a"))
+
+	// Dropped when the janitor evicts the entry, without any lookup through
+	// the SessionStore
+	bound := func() int {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return len(s.owners) + len(s.bySession)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for bound() != 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the binding to be removed after the idle TTL")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+// forgetfulStore is a Store that drops entries without reporting it, like a
+// FileStore or SQLStore purging expired rows.
+type forgetfulStore struct {
+	mu sync.Mutex
+	m  map[string]ComponentInterface
+}
+
+func (s *forgetfulStore) Get(id string) (ComponentInterface, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.m[id]
+	return c, ok
+}
+
+func (s *forgetfulStore) Set(c ComponentInterface) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.m[c.GetID()] = c
+}
+
+func (s *forgetfulStore) Delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.m, id)
+}
+
+func TestSessionStore_SweepDropsMissingBindings(t *testing.T) {
+	inner := &forgetfulStore{m: map[string]ComponentInterface{}}
+	s := NewSessionStore(inner, SessionFromCookie("sid"), WithSessionStoreSweepInterval(10*time.Millisecond))
+	defer s.Close()
+	s.SetContext(sessionCtx("alice"), newStoreComp("a1"))
+	s.SetContext(sessionCtx("alice"), newStoreComp("a2"))
+
+	inner.Delete("a1") // purged by the wrapped store
+	deadline := time.Now().Add(2 * time.Second)
+	for len(s.Components("alice")) != 1 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the sweep to drop a1, got %v", s.Components("alice"))
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if ids := s.Components("alice"); ids[0] != "a2" {
+		t.Fatalf("expected a2 to stay bound, got %v", ids)
+	}
+}
+
+// requestAwareComp records whether the handler exposed the request.
+type requestAwareComp struct {
+	handlerComp
+	SawRequest bool
+}
+
+func (c *requestAwareComp) Mount(ctx context.Context, _ map[string]string) error {
+	c.SawRequest = RequestFromContext(ctx) != nil
+	return nil
+}
+
+func TestHandler_SessionStore(t *testing.T) {
+	s := NewSessionStore(NewMemoryStore(), SessionFromCookie("sid"))
+	h := NewHandler(s)
+	kind := registerTestKind(t, &requestAwareComp{})
+
+	post := func(session string, form url.Values) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if session != "" {
+			req.AddCookie(&http.Cookie{Name: "sid", Value: session})
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	mountRec := post("alice", url.Values{FormComponentKind: {kind}})
+	id := extractAttr(t, mountRec.Body.String(), "data-id")
+
+	c, ok := s.Get(id)
+	if !ok || !c.(*requestAwareComp).SawRequest {
+		t.Fatalf("expected Mount context to carry the request")
+	}
+
+	act := url.Values{FormComponentKind: {kind}, FormComponentID: {id}, FormAction: {"inc"}}
+	if rec := post("mallory", act); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for foreign session, got %d", rec.Code)
+	}
+	if rec := post("", act); rec.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 without session, got %d", rec.Code)
+	}
+	if rec := post("alice", act); rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), "count=1") {
+		t.Fatalf("expected owner action to succeed, got %d %q", rec.Code, rec.Body.String())
+	}
+}
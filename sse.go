@@ -0,0 +1,230 @@
+package liveflux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// FormStream lists the components a Server-Sent Events stream or a poll
+// request receives messages for: comma-separated IDs, each optionally
+// followed by ":" and the last version the client saw, e.g. "a1:42,b2".
+const FormStream = "liveflux_stream"
+
+// streamComponent is one entry of FormStream.
+type streamComponent struct {
+	id      string
+	version uint64
+}
+
+// parseStream parses the FormStream value.
+func parseStream(value string) ([]streamComponent, error) {
+	var components []streamComponent
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		id, version, hasVersion := strings.Cut(entry, ":")
+		c := streamComponent{id: id}
+		if hasVersion {
+			v, err := strconv.ParseUint(version, 10, 64)
+			if err != nil || id == "" {
+				return nil, fmt.Errorf("liveflux: invalid stream component %q", entry)
+			}
+			c.version = v
+		}
+		components = append(components, c)
+	}
+	if len(components) == 0 {
+		return nil, errors.New("liveflux: missing component ID")
+	}
+	if len(components) > maxComponentsPerConnection {
+		return nil, errors.New("liveflux: too many components")
+	}
+	return components, nil
+}
+
+// acceptsEventStream reports whether the request asks for Server-Sent Events.
+func acceptsEventStream(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), "text/event-stream")
+}
+
+// sseConn is a Server-Sent Events stream. It receives the same messages as
+// a WebSocket, written by the request's goroutine; actions arrive as regular
+// POST requests.
+type sseConn struct {
+	sendQueue
+}
+
+// handleSSE streams the messages for the components in FormStream, after
+// catching up on those they missed, until the client disconnects.
+// Components the request cannot load are answered with an error message and
+// not streamed. Each
+// message is one "data:" line holding the JSON a WebSocket client receives.
+func (h *WebSocketHandler) handleSSE(w http.ResponseWriter, r *http.Request) {
+	if !h.allowConnection(w, r, true) {
+		return
+	}
+	components, err := parseStream(r.URL.Query().Get(FormStream))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	// Ask proxies such as nginx not to buffer the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		fmt.Printf("liveflux: SSE not supported by the response writer: %v\n", err)
+		return
+	}
+
+	conn := &sseConn{sendQueue: newSendQueue(h.connOptions, &h.stats, nil)}
+	h.mu.Lock()
+	h.conns[conn] = true
+	h.mu.Unlock()
+	defer func() {
+		conn.close()
+		h.mu.Lock()
+		delete(h.conns, conn)
+		h.mu.Unlock()
+	}()
+
+	ctx := contextWithStore(contextWithRequest(r.Context(), r), h.Store)
+	var joined []string
+	defer func() {
+		for _, id := range joined {
+			h.leave(id, conn)
+		}
+	}()
+	for _, sc := range components {
+		// Only stream components the request may act on
+		if _, ok := h.authorize(ctx, conn, sc.id); !ok {
+			continue
+		}
+		// Join before catching up, so nothing sent in between is lost
+		h.join(sc.id, conn)
+		if _, ok := h.catchUp(ctx, conn, sc); !ok {
+			h.leave(sc.id, conn)
+			continue
+		}
+		joined = append(joined, sc.id)
+	}
+
+	conn.writeLoop(ctx, w, rc)
+}
+
+// writeLoop writes queued messages and keep-alive comments until the
+// client disconnects or a write fails.
+func (c *sseConn) writeLoop(ctx context.Context, w io.Writer, rc *http.ResponseController) {
+	ping, stopPing := c.pingTicker()
+	defer stopPing()
+
+	write := func(chunk string) bool {
+		_ = rc.SetWriteDeadline(c.writeDeadline())
+		if _, err := io.WriteString(w, chunk); err != nil {
+			return false
+		}
+		return rc.Flush() == nil
+	}
+
+	for {
+		select {
+		case data := <-c.send:
+			if !write("data: " + string(data) + "\n\n") {
+				return
+			}
+		case <-ping:
+			if !write(": ping\n\n") {
+				return
+			}
+		case <-ctx.Done():
+			return
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// pollResponse is the JSON body of a poll.
+type pollResponse struct {
+	Messages []json.RawMessage `json:"messages"`
+	// Versions holds each component's current version, for the next poll.
+	Versions map[string]uint64 `json:"versions"`
+}
+
+// pollConn collects the messages for one poll response.
+type pollConn struct {
+	messages []json.RawMessage
+}
+
+func (c *pollConn) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.messages = append(c.messages, data)
+	return nil
+}
+
+func (c *pollConn) queued() int { return 0 }
+
+// handlePoll returns the messages the components in FormStream missed
+// since the versions given, for clients that can use neither WebSockets
+// nor Server-Sent Events. Polls are not rate limited.
+func (h *WebSocketHandler) handlePoll(w http.ResponseWriter, r *http.Request) {
+	if !h.allowConnection(w, r, false) {
+		return
+	}
+	components, err := parseStream(r.URL.Query().Get(FormStream))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx := contextWithStore(contextWithRequest(r.Context(), r), h.Store)
+	conn := &pollConn{}
+	resp := pollResponse{Messages: []json.RawMessage{}, Versions: map[string]uint64{}}
+	for _, sc := range components {
+		if version, ok := h.catchUp(ctx, conn, sc); ok {
+			resp.Versions[sc.id] = version
+		}
+	}
+	resp.Messages = append(resp.Messages, conn.messages...)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// catchUp sends conn what the component missed since the client's version
+// (see resume) and returns the component's current version. Without a
+// version there is nothing to catch up on. Returns false if an error
+// message was sent instead.
+func (h *WebSocketHandler) catchUp(ctx context.Context, conn clientConn, sc streamComponent) (uint64, bool) {
+	unlock, err := h.lockComponent(ctx, sc.id)
+	if err != nil {
+		h.sendError(conn, sc.id, "component busy", http.StatusConflict)
+		return 0, false
+	}
+	defer unlock()
+
+	c, found := h.storeGet(ctx, sc.id)
+	if !found || c == nil {
+		h.sendError(conn, sc.id, "component not found", http.StatusNotFound)
+		return 0, false
+	}
+	if sc.version > 0 {
+		h.resume(ctx, conn, c, sc.version)
+	}
+	return h.replay.current(sc.id), true
+}
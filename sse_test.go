@@ -0,0 +1,235 @@
+package liveflux
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseStream(t *testing.T) {
+	got, err := parseStream("a1:42, b2,,")
+	if err != nil {
+		t.Fatalf("parseStream: %v", err)
+	}
+	if len(got) != 2 || got[0] != (streamComponent{"a1", 42}) || got[1] != (streamComponent{"b2", 0}) {
+		t.Fatalf("unexpected components %+v", got)
+	}
+
+	for _, value := range []string{"", "a1:x", ":5", strings.Repeat("a,", maxComponentsPerConnection+1)} {
+		if _, err := parseStream(value); err == nil {
+			t.Fatalf("expected an error for %q", value)
+		}
+	}
+}
+
+func TestWebSocketHandler_SSEStream(t *testing.T) {
+	store := NewMemoryStore()
+	h := NewWebSocketHandler(store)
+	gauge := newGauge(store, false)
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"?"+FormStream+"="+gauge.GetID(), nil)
+	req.Header.Set("Accept", "text/event-stream")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("unexpected content type %q", ct)
+	}
+	waitForClient(t, h, gauge.GetID())
+
+	lines := make(chan string)
+	go func() {
+		scanner := bufio.NewScanner(resp.Body)
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				lines <- data
+			}
+		}
+		close(lines)
+	}()
+	read := func() WebSocketMessage {
+		t.Helper()
+		select {
+		case data := <-lines:
+			var msg WebSocketMessage
+			if err := json.Unmarshal([]byte(data), &msg); err != nil {
+				t.Fatalf("decode %q: %v", data, err)
+			}
+			return msg
+		case <-time.After(2 * time.Second):
+			t.Fatal("no message received")
+		}
+		return WebSocketMessage{}
+	}
+
+	err = h.Push(context.Background(), gauge.GetID(), func(c ComponentInterface) error {
+		c.(*gaugeWSComp).Value = 4
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if msg := read(); msg.Type != "update" || msg.Version == 0 || !strings.Contains(string(msg.Data), "value=4") {
+		t.Fatalf("expected an update, got %+v %s", msg, msg.Data)
+	}
+
+	err = h.Push(context.Background(), gauge.GetID(), func(c ComponentInterface) error {
+		c.(*gaugeWSComp).Redirect("/done", 1)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Push: %v", err)
+	}
+	if msg := read(); msg.Type != "redirect" || string(msg.Data) != `{"url":"/done","after":1}` {
+		t.Fatalf("expected a redirect, got %+v %s", msg, msg.Data)
+	}
+
+	// Disconnecting releases the component
+	cancel()
+	deadline := time.Now().Add(2 * time.Second)
+	for h.Stats().Connections != 0 {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected the stream to be closed, got %+v", h.Stats())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWebSocketHandler_SSEStreamSkipsForeignSessions(t *testing.T) {
+	store := NewSessionStore(NewMemoryStore(), SessionFromCookie("sid"))
+	h := NewWebSocketHandler(store)
+	own := func(session string) *gaugeWSComp {
+		c := &gaugeWSComp{}
+		c.SetKind(c.GetKind())
+		c.SetID(NewID())
+		store.SetContext(sessionCtx(session), c)
+		return c
+	}
+	alices, bobs := own("alice"), own("bob")
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	// The deadline ends the stream if an expected message never comes
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, ts.URL+"?"+FormStream+"="+alices.GetID()+","+bobs.GetID(), nil)
+	req.Header.Set("Accept", "text/event-stream")
+	req.AddCookie(&http.Cookie{Name: "sid", Value: "bob"})
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("GET: %v", err)
+	}
+	defer func() {
+		_ = resp.Body.Close()
+	}()
+	waitForClient(t, h, bobs.GetID())
+	h.mu.RLock()
+	foreign := len(h.clients[alices.GetID()])
+	h.mu.RUnlock()
+	if foreign != 0 {
+		t.Fatalf("expected no stream for another session's component, got %d", foreign)
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	read := func() map[string]any {
+		t.Helper()
+		for scanner.Scan() {
+			if data, ok := strings.CutPrefix(scanner.Text(), "data: "); ok {
+				var msg map[string]any
+				if err := json.Unmarshal([]byte(data), &msg); err != nil {
+					t.Fatalf("decode %q: %v", data, err)
+				}
+				return msg
+			}
+		}
+		t.Fatal("stream ended")
+		return nil
+	}
+	if msg := read(); msg["type"] != "error" || msg["componentID"] != alices.GetID() || msg["code"] != float64(http.StatusNotFound) {
+		t.Fatalf("expected a 404 for another session's component, got %v", msg)
+	}
+
+	for _, c := range []*gaugeWSComp{alices, bobs} {
+		if err := h.Push(context.Background(), c.GetID(), func(c ComponentInterface) error {
+			c.(*gaugeWSComp).Value = 1
+			return nil
+		}); err != nil {
+			t.Fatalf("Push: %v", err)
+		}
+	}
+	if msg := read(); msg["type"] != "update" || msg["componentID"] != bobs.GetID() {
+		t.Fatalf("expected only the own component's update, got %v", msg)
+	}
+}
+
+func TestWebSocketHandler_Poll(t *testing.T) {
+	store := NewMemoryStore()
+	h := NewWebSocketHandler(store)
+	gauge := newGauge(store, false)
+	id := gauge.GetID()
+
+	set := func(v int) {
+		t.Helper()
+		err := h.Push(context.Background(), id, func(c ComponentInterface) error {
+			c.(*gaugeWSComp).Value = v
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Push: %v", err)
+		}
+	}
+	poll := func(stream string) pollResponse {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, "/?"+FormStream+"="+url.QueryEscape(stream), nil)
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("unexpected status %d: %s", rec.Code, rec.Body.String())
+		}
+		var resp pollResponse
+		if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("decode: %v", err)
+		}
+		return resp
+	}
+
+	set(1)
+	first := poll(id)
+	v1 := first.Versions[id]
+	if len(first.Messages) != 0 || v1 == 0 {
+		t.Fatalf("expected only the current version, got %+v", first)
+	}
+
+	set(2)
+	resp := poll(id + ":" + strconv.FormatUint(v1, 10) + ",missing")
+	if len(resp.Messages) != 2 || resp.Versions[id] != v1+1 {
+		t.Fatalf("expected the missed update and an error, got %+v", resp)
+	}
+	var update, missing WebSocketMessage
+	_ = json.Unmarshal(resp.Messages[0], &update)
+	_ = json.Unmarshal(resp.Messages[1], &missing)
+	if update.Type != "update" || update.Version != v1+1 || !strings.Contains(string(update.Data), "value=2") {
+		t.Fatalf("expected the missed update, got %s", resp.Messages[0])
+	}
+	if missing.Type != "error" || missing.ComponentID != "missing" {
+		t.Fatalf("expected an error for the unknown component, got %s", resp.Messages[1])
+	}
+	if _, ok := resp.Versions["missing"]; ok {
+		t.Fatal("expected no version for the unknown component")
+	}
+}
@@ -32,10 +32,21 @@ func SSR(c ComponentInterface, params ...map[string]string) hb.TagInterface {
 		return hb.Div().Class("alert alert-danger").Text("mount error: " + err.Error())
 	}
 
+	if err := beforeDehydrate(ctx, c); err != nil {
+		return hb.Div().Class("alert alert-danger").Text("dehydrate error: " + err.Error())
+	}
+
 	// Persist for later actions
 	StoreDefault.Set(c)
 
-	return c.Render(ctx)
+	// In client-state mode the snapshot travels inside the rendered root
+	if cs, ok := StoreDefault.(*ClientStateStore); ok {
+		if _, err := cs.attach(c); err != nil {
+			return hb.Div().Class("alert alert-danger").Text("state error: " + err.Error())
+		}
+	}
+
+	return render(ctx, c)
 }
 
 // SSRHTML mounts and renders the component, returning HTML as string.
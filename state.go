@@ -1,6 +1,11 @@
 package liveflux
 
-import "sync"
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
 
 // Store defines how component instances are persisted between requests.
 type Store interface {
@@ -9,26 +14,172 @@ type Store interface {
 	Delete(id string)
 }
 
+// ContextStore is an optional Store extension for stores whose lookups depend
+// on the request (for example the user's session). The HTTP and WebSocket
+// handlers prefer GetContext/SetContext when available; the context carries
+// the incoming request, see RequestFromContext.
+type ContextStore interface {
+	Store
+	GetContext(ctx context.Context, id string) (ComponentInterface, bool)
+	SetContext(ctx context.Context, c ComponentInterface)
+}
+
+// EvictionReason describes why MemoryStore dropped an entry.
+type EvictionReason string
+
+const (
+	EvictionIdle     EvictionReason = "idle"     // not accessed within the idle TTL
+	EvictionExpired  EvictionReason = "expired"  // older than the absolute TTL
+	EvictionCapacity EvictionReason = "capacity" // least recently used entry over MaxEntries
+)
+
+// MemoryStoreOption configures a MemoryStore.
+type MemoryStoreOption func(*memoryStoreOptions)
+
+type memoryStoreOptions struct {
+	idleTTL         time.Duration
+	ttl             time.Duration
+	maxEntries      int
+	janitorInterval time.Duration
+	onEvict         func(c ComponentInterface, reason EvictionReason)
+}
+
+// WithMemoryStoreIdleTTL evicts entries not accessed (Get/Set) within ttl.
+func WithMemoryStoreIdleTTL(ttl time.Duration) MemoryStoreOption {
+	return func(opts *memoryStoreOptions) {
+		opts.idleTTL = ttl
+	}
+}
+
+// WithMemoryStoreTTL evicts entries ttl after they were first stored,
+// regardless of activity.
+func WithMemoryStoreTTL(ttl time.Duration) MemoryStoreOption {
+	return func(opts *memoryStoreOptions) {
+		opts.ttl = ttl
+	}
+}
+
+// WithMemoryStoreMaxEntries caps the number of stored components. When the
+// limit is exceeded the least recently used entry is evicted. Zero means unlimited.
+func WithMemoryStoreMaxEntries(max int) MemoryStoreOption {
+	return func(opts *memoryStoreOptions) {
+		opts.maxEntries = max
+	}
+}
+
+// WithMemoryStoreJanitorInterval sets how often expired entries are swept.
+// Defaults to half of the smallest configured TTL (minimum one second).
+func WithMemoryStoreJanitorInterval(interval time.Duration) MemoryStoreOption {
+	return func(opts *memoryStoreOptions) {
+		opts.janitorInterval = interval
+	}
+}
+
+// WithMemoryStoreOnEvict registers a callback invoked after an entry is
+// evicted, so components can release resources. It is not called for Delete.
+func WithMemoryStoreOnEvict(fn func(c ComponentInterface, reason EvictionReason)) MemoryStoreOption {
+	return func(opts *memoryStoreOptions) {
+		opts.onEvict = fn
+	}
+}
+
 // MemoryStore is a simple in-memory implementation suitable for development
 // and single-instance deployments. Replace with a session or DB-backed
 // implementation for multi-instance deployments.
+//
+// Without options entries live until deleted. Configure idle/absolute TTLs
+// and a capacity limit to bound memory on public pages; call Close to stop
+// the background janitor.
 type MemoryStore struct {
-	mu    sync.RWMutex
-	m     map[string]ComponentInterface
-	locks sync.Map // map[string]*sync.Mutex for per-component locking
+	mu  sync.RWMutex
+	m   map[string]*memoryEntry
+	lru *list.List // front = most recently used; values are ids
+
+	// Per-component locks, kept apart from the entries so evicting an
+	// entry never drops a lock someone holds.
+	locksMu     sync.Mutex
+	locks       map[string]*memoryLock
+	lockByMutex map[*sync.Mutex]*memoryLock
+
+	opts memoryStoreOptions
+	now  func() time.Time
+
+	// evictListeners run after opts.onEvict, see addEvictListener.
+	evictListeners []func(c ComponentInterface, reason EvictionReason)
+
+	stop      chan struct{}
+	closeOnce sync.Once
 }
 
-// NewMemoryStore creates a MemoryStore.
-func NewMemoryStore() *MemoryStore {
-	return &MemoryStore{m: map[string]ComponentInterface{}}
+type memoryEntry struct {
+	c          ComponentInterface
+	created    time.Time
+	lastAccess time.Time
+	elem       *list.Element
 }
 
-// Get returns a component by id.
+// memoryLock is a per-component lock. ch is held by whoever owns the lock,
+// so waiting for it can be cancelled; mu is the handle LockComponent hands
+// out. refs counts the callers holding or waiting for it; the lock is
+// dropped once it reaches zero, so all callers for an ID always share one.
+type memoryLock struct {
+	ch   chan struct{}
+	mu   sync.Mutex
+	id   string
+	refs int
+}
+
+type evicted struct {
+	c      ComponentInterface
+	reason EvictionReason
+}
+
+// NewMemoryStore creates a MemoryStore. A janitor goroutine is started when
+// a TTL is configured; stop it with Close.
+func NewMemoryStore(optFns ...MemoryStoreOption) *MemoryStore {
+	options := memoryStoreOptions{}
+	for _, fn := range optFns {
+		if fn != nil {
+			fn(&options)
+		}
+	}
+
+	s := &MemoryStore{
+		m:           map[string]*memoryEntry{},
+		lru:         list.New(),
+		locks:       map[string]*memoryLock{},
+		lockByMutex: map[*sync.Mutex]*memoryLock{},
+		opts:        options,
+		now:         time.Now,
+		stop:        make(chan struct{}),
+	}
+
+	if interval := s.janitorInterval(); interval > 0 {
+		go s.janitor(interval)
+	}
+	return s
+}
+
+// Get returns a component by id. Expired entries are evicted lazily.
 func (s *MemoryStore) Get(id string) (ComponentInterface, bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	c, ok := s.m[id]
-	return c, ok
+	s.mu.Lock()
+	e, ok := s.m[id]
+	if !ok {
+		s.mu.Unlock()
+		return nil, false
+	}
+	now := s.now()
+	if reason, expired := s.expired(e, now); expired {
+		s.removeLocked(id, e)
+		s.mu.Unlock()
+		s.notify([]evicted{{c: e.c, reason: reason}})
+		return nil, false
+	}
+	e.lastAccess = now
+	s.lru.MoveToFront(e.elem)
+	c := e.c
+	s.mu.Unlock()
+	return c, true
 }
 
 // Set stores a component by its ID.
@@ -36,33 +187,223 @@ func (s *MemoryStore) Set(c ComponentInterface) {
 	if c == nil || c.GetID() == "" {
 		return
 	}
+	id := c.GetID()
+	now := s.now()
+
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	s.m[c.GetID()] = c
+	if e, ok := s.m[id]; ok {
+		e.c = c
+		e.lastAccess = now
+		s.lru.MoveToFront(e.elem)
+		s.mu.Unlock()
+		return
+	}
+	s.m[id] = &memoryEntry{c: c, created: now, lastAccess: now, elem: s.lru.PushFront(id)}
+
+	var out []evicted
+	for s.opts.maxEntries > 0 && len(s.m) > s.opts.maxEntries {
+		oldest := s.lru.Back()
+		oldID := oldest.Value.(string)
+		out = append(out, evicted{c: s.m[oldID].c, reason: EvictionCapacity})
+		s.removeLocked(oldID, s.m[oldID])
+	}
+	s.mu.Unlock()
+	s.notify(out)
 }
 
-// Delete removes a component by id.
+// Delete removes a component by id and calls its Unmount hook.
 func (s *MemoryStore) Delete(id string) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	delete(s.m, id)
-	s.locks.Delete(id)
+	e, ok := s.m[id]
+	if ok {
+		s.removeLocked(id, e)
+	}
+	s.mu.Unlock()
+
+	if ok {
+		Unmount(context.Background(), e.c)
+	}
+}
+
+// Len returns the number of stored components, including expired entries
+// not yet swept.
+func (s *MemoryStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.m)
+}
+
+// Close stops the background janitor. It is safe to call more than once.
+func (s *MemoryStore) Close() error {
+	s.closeOnce.Do(func() { close(s.stop) })
+	return nil
 }
 
 // LockComponent acquires a per-component lock to prevent concurrent modifications.
 // Returns the lock that must be unlocked after the operation completes.
 func (s *MemoryStore) LockComponent(id string) *sync.Mutex {
-	// Get or create a mutex for this component ID
-	actual, _ := s.locks.LoadOrStore(id, &sync.Mutex{})
-	mu := actual.(*sync.Mutex)
-	mu.Lock()
-	return mu
+	l := s.acquireLock(id)
+	l.ch <- struct{}{}
+	l.mu.Lock()
+	return &l.mu
 }
 
 // UnlockComponent releases the per-component lock.
 func (s *MemoryStore) UnlockComponent(mu *sync.Mutex) {
-	if mu != nil {
-		mu.Unlock()
+	if mu == nil {
+		return
+	}
+	mu.Unlock()
+
+	s.locksMu.Lock()
+	l := s.lockByMutex[mu]
+	s.locksMu.Unlock()
+	if l == nil {
+		return
+	}
+	<-l.ch
+	s.releaseLock(l)
+}
+
+// Lock implements LockingStore using the same per-component locks as
+// LockComponent. A cancelled context stops the wait and returns ctx.Err().
+func (s *MemoryStore) Lock(ctx context.Context, id string) (func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	l := s.acquireLock(id)
+	select {
+	case l.ch <- struct{}{}:
+	case <-ctx.Done():
+		s.releaseLock(l)
+		return nil, ctx.Err()
+	}
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			<-l.ch
+			s.releaseLock(l)
+		})
+	}, nil
+}
+
+// acquireLock returns the lock for id, creating it if needed, and counts the
+// caller as one of its users.
+func (s *MemoryStore) acquireLock(id string) *memoryLock {
+	s.locksMu.Lock()
+	defer s.locksMu.Unlock()
+	l := s.locks[id]
+	if l == nil {
+		l = &memoryLock{ch: make(chan struct{}, 1), id: id}
+		s.locks[id] = l
+		s.lockByMutex[&l.mu] = l
+	}
+	l.refs++
+	return l
+}
+
+// releaseLock drops a user of l and removes it once unused.
+func (s *MemoryStore) releaseLock(l *memoryLock) {
+	s.locksMu.Lock()
+	defer s.locksMu.Unlock()
+	l.refs--
+	if l.refs == 0 {
+		delete(s.locks, l.id)
+		delete(s.lockByMutex, &l.mu)
+	}
+}
+
+// sweep evicts all expired entries.
+func (s *MemoryStore) sweep() {
+	now := s.now()
+	var out []evicted
+
+	s.mu.Lock()
+	for id, e := range s.m {
+		if reason, expired := s.expired(e, now); expired {
+			out = append(out, evicted{c: e.c, reason: reason})
+			s.removeLocked(id, e)
+		}
+	}
+	s.mu.Unlock()
+
+	s.notify(out)
+}
+
+func (s *MemoryStore) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.sweep()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// janitorInterval returns the sweep interval, or 0 if no TTL is configured.
+func (s *MemoryStore) janitorInterval() time.Duration {
+	if s.opts.idleTTL <= 0 && s.opts.ttl <= 0 {
+		return 0
+	}
+	if s.opts.janitorInterval > 0 {
+		return s.opts.janitorInterval
+	}
+	shortest := s.opts.ttl
+	if shortest <= 0 || (s.opts.idleTTL > 0 && s.opts.idleTTL < shortest) {
+		shortest = s.opts.idleTTL
+	}
+	if interval := shortest / 2; interval > time.Second {
+		return interval
+	}
+	return time.Second
+}
+
+// expired reports whether e is past its absolute or idle TTL.
+func (s *MemoryStore) expired(e *memoryEntry, now time.Time) (EvictionReason, bool) {
+	if s.opts.ttl > 0 && now.Sub(e.created) >= s.opts.ttl {
+		return EvictionExpired, true
+	}
+	if s.opts.idleTTL > 0 && now.Sub(e.lastAccess) >= s.opts.idleTTL {
+		return EvictionIdle, true
+	}
+	return "", false
+}
+
+// removeLocked drops an entry. Its lock goes away by itself once no
+// caller holds it. Callers must hold s.mu.
+func (s *MemoryStore) removeLocked(id string, e *memoryEntry) {
+	s.lru.Remove(e.elem)
+	delete(s.m, id)
+}
+
+// addEvictListener registers fn to run on every eviction, after the
+// WithMemoryStoreOnEvict callback. SessionStore uses it to drop bindings.
+func (s *MemoryStore) addEvictListener(fn func(c ComponentInterface, reason EvictionReason)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.evictListeners = append(s.evictListeners, fn)
+}
+
+// notify runs the Unmount hook and the eviction callbacks outside the store lock.
+func (s *MemoryStore) notify(out []evicted) {
+	if len(out) == 0 {
+		return
+	}
+	s.mu.RLock()
+	listeners := s.evictListeners
+	s.mu.RUnlock()
+
+	for _, ev := range out {
+		Unmount(context.Background(), ev.c)
+		if s.opts.onEvict != nil {
+			s.opts.onEvict(ev.c, ev.reason)
+		}
+		for _, fn := range listeners {
+			fn(ev.c, ev.reason)
+		}
 	}
 }
 
@@ -2,8 +2,11 @@ package liveflux
 
 import (
 	"context"
+	"errors"
 	"net/url"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/dracory/hb"
 )
@@ -59,3 +62,210 @@ func TestStoreDefault_IsInitialized(t *testing.T) {
 		t.Fatalf("StoreDefault should be initialized")
 	}
 }
+
+func newStoreComp(id string) *storeComp {
+	c := &storeComp{}
+	c.SetID(id)
+	return c
+}
+
+// fakeClock lets tests move MemoryStore time forward deterministically.
+type fakeClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (f *fakeClock) Now() time.Time {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.t
+}
+
+func (f *fakeClock) Advance(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.t = f.t.Add(d)
+}
+
+func TestMemoryStore_IdleTTL(t *testing.T) {
+	clock := &fakeClock{t: time.Now()}
+	var reasons []EvictionReason
+	s := NewMemoryStore(
+		WithMemoryStoreIdleTTL(time.Minute),
+		WithMemoryStoreOnEvict(func(_ ComponentInterface, r EvictionReason) { reasons = append(reasons, r) }),
+	)
+	defer s.Close()
+	s.now = clock.Now
+
+	s.Set(newStoreComp("a"))
+	clock.Advance(40 * time.Second)
+	if _, ok := s.Get("a"); !ok {
+		t.Fatalf("expected entry to be alive before idle TTL")
+	}
+	// Access refreshed the idle timer
+	clock.Advance(40 * time.Second)
+	if _, ok := s.Get("a"); !ok {
+		t.Fatalf("expected Get to refresh idle timer")
+	}
+	clock.Advance(2 * time.Minute)
+	if _, ok := s.Get("a"); ok {
+		t.Fatalf("expected idle entry to be evicted")
+	}
+	if len(reasons) != 1 || reasons[0] != EvictionIdle {
+		t.Fatalf("expected one idle eviction, got %v", reasons)
+	}
+}
+
+func TestMemoryStore_AbsoluteTTL(t *testing.T) {
+	clock := &fakeClock{t: time.Now()}
+	var reasons []EvictionReason
+	s := NewMemoryStore(
+		WithMemoryStoreTTL(time.Minute),
+		WithMemoryStoreOnEvict(func(_ ComponentInterface, r EvictionReason) { reasons = append(reasons, r) }),
+	)
+	defer s.Close()
+	s.now = clock.Now
+
+	s.Set(newStoreComp("a"))
+	for i := 0; i < 3; i++ {
+		clock.Advance(15 * time.Second)
+		s.Get("a")
+	}
+	clock.Advance(20 * time.Second)
+	s.sweep()
+	if s.Len() != 0 {
+		t.Fatalf("expected entry past absolute TTL to be swept")
+	}
+	if len(reasons) != 1 || reasons[0] != EvictionExpired {
+		t.Fatalf("expected one expired eviction, got %v", reasons)
+	}
+}
+
+func TestMemoryStore_MaxEntriesLRU(t *testing.T) {
+	var evictedIDs []string
+	s := NewMemoryStore(
+		WithMemoryStoreMaxEntries(2),
+		WithMemoryStoreOnEvict(func(c ComponentInterface, r EvictionReason) {
+			if r != EvictionCapacity {
+				t.Errorf("unexpected reason %q", r)
+			}
+			evictedIDs = append(evictedIDs, c.GetID())
+		}),
+	)
+	defer s.Close()
+
+	s.Set(newStoreComp("a"))
+	s.Set(newStoreComp("b"))
+	s.Get("a") // a becomes most recently used
+	s.Set(newStoreComp("c"))
+
+	if _, ok := s.Get("b"); ok {
+		t.Fatalf("expected least recently used entry b to be evicted")
+	}
+	if _, ok := s.Get("a"); !ok {
+		t.Fatalf("expected a to survive")
+	}
+	if _, ok := s.Get("c"); !ok {
+		t.Fatalf("expected c to survive")
+	}
+	if len(evictedIDs) != 1 || evictedIDs[0] != "b" {
+		t.Fatalf("expected eviction of b, got %v", evictedIDs)
+	}
+}
+
+func TestMemoryStore_EvictionKeepsHeldLock(t *testing.T) {
+	s := NewMemoryStore(WithMemoryStoreMaxEntries(1))
+	defer s.Close()
+
+	s.Set(newStoreComp("a"))
+	unlock, err := s.Lock(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	// Evicts a while its lock is held
+	s.Set(newStoreComp("b"))
+
+	acquired := make(chan func())
+	go func() {
+		second, _ := s.Lock(context.Background(), "a")
+		acquired <- second
+	}()
+	select {
+	case <-acquired:
+		t.Fatal("expected the second Lock to wait for the held lock")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	unlock()
+	second := <-acquired
+	second()
+
+	s.locksMu.Lock()
+	defer s.locksMu.Unlock()
+	if len(s.locks) != 0 || len(s.lockByMutex) != 0 {
+		t.Fatalf("expected unused locks to be removed, got %d", len(s.locks))
+	}
+}
+
+func TestMemoryStore_LockContextCancel(t *testing.T) {
+	s := NewMemoryStore()
+	defer s.Close()
+
+	mu := s.LockComponent("a")
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := s.Lock(ctx, "a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected deadline exceeded, got %v", err)
+	}
+	s.UnlockComponent(mu)
+
+	unlock, err := s.Lock(context.Background(), "a")
+	if err != nil {
+		t.Fatalf("Lock after release: %v", err)
+	}
+	unlock()
+
+	s.locksMu.Lock()
+	defer s.locksMu.Unlock()
+	if len(s.locks) != 0 || len(s.lockByMutex) != 0 {
+		t.Fatalf("expected unused locks to be removed, got %d", len(s.locks))
+	}
+}
+
+func TestMemoryStore_JanitorSweepsAndCloseStops(t *testing.T) {
+	evictedCh := make(chan string, 1)
+	s := NewMemoryStore(
+		WithMemoryStoreIdleTTL(20*time.Millisecond),
+		WithMemoryStoreJanitorInterval(10*time.Millisecond),
+		WithMemoryStoreOnEvict(func(c ComponentInterface, _ EvictionReason) { evictedCh <- c.GetID() }),
+	)
+	s.Set(newStoreComp("a"))
+
+	select {
+	case id := <-evictedCh:
+		if id != "a" {
+			t.Fatalf("unexpected eviction %q", id)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatalf("janitor did not evict idle entry")
+	}
+
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("second Close: %v", err)
+	}
+}
+
+func TestMemoryStore_NoTTLKeepsEntries(t *testing.T) {
+	s := NewMemoryStore()
+	if s.janitorInterval() != 0 {
+		t.Fatalf("expected no janitor without TTLs")
+	}
+	s.Set(newStoreComp("a"))
+	s.sweep()
+	if _, ok := s.Get("a"); !ok {
+		t.Fatalf("expected entry to persist without TTLs")
+	}
+}
@@ -0,0 +1,16 @@
+// Package stores provides persistent liveflux.Store implementations.
+//
+// Both stores serialize components with liveflux.Dehydrate/Hydrate, so
+// component kinds must be registered and component state must consist of
+// exported, codec-friendly fields (see docs/state_management.md). Get returns
+// a fresh instance on every call; mutations only persist through Set.
+//
+//   - FileStore keeps one file per component in a directory and writes via
+//     atomic rename. Suitable for single-host deployments and development.
+//   - SQLStore keeps components in a database/sql table with an expiry
+//     column and periodic purge. Suitable for multi-instance deployments.
+//
+// Both implement liveflux.LockingStore with an in-process MutexLocker by
+// default; pass a cross-process Locker (e.g. a liveflux.LeaseLocker) when
+// several instances share the same storage.
+package stores
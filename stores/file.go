@@ -0,0 +1,310 @@
+package stores
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dracory/liveflux"
+)
+
+const fileStoreExt = ".flux"
+
+// FileStoreOption configures a FileStore.
+type FileStoreOption func(*fileStoreOptions)
+
+type fileStoreOptions struct {
+	codec         liveflux.StateCodec
+	ttl           time.Duration
+	purgeInterval time.Duration
+	locker        liveflux.Locker
+	onError       func(op string, err error)
+}
+
+// WithFileStoreCodec sets the codec used to serialize components.
+// Defaults to liveflux.DefaultStateCodec.
+func WithFileStoreCodec(codec liveflux.StateCodec) FileStoreOption {
+	return func(opts *fileStoreOptions) {
+		opts.codec = codec
+	}
+}
+
+// WithFileStoreTTL expires components not written within ttl. Expiry is
+// refreshed on every Set.
+func WithFileStoreTTL(ttl time.Duration) FileStoreOption {
+	return func(opts *fileStoreOptions) {
+		opts.ttl = ttl
+	}
+}
+
+// WithFileStorePurgeInterval starts a background goroutine that removes
+// expired files every interval. Requires a TTL; stop it with Close.
+func WithFileStorePurgeInterval(interval time.Duration) FileStoreOption {
+	return func(opts *fileStoreOptions) {
+		opts.purgeInterval = interval
+	}
+}
+
+// WithFileStoreLocker sets the Locker used by Lock. Defaults to an
+// in-process liveflux.MutexLocker.
+func WithFileStoreLocker(locker liveflux.Locker) FileStoreOption {
+	return func(opts *fileStoreOptions) {
+		opts.locker = locker
+	}
+}
+
+// WithFileStoreErrorHandler receives I/O and codec errors, which the Store
+// interface cannot return. By default errors are logged.
+func WithFileStoreErrorHandler(fn func(op string, err error)) FileStoreOption {
+	return func(opts *fileStoreOptions) {
+		opts.onError = fn
+	}
+}
+
+// FileStore persists each component as a file in a directory. Files are
+// written to a temporary name and renamed into place, so readers never see
+// a partial write. File names are derived from a hash of the component ID,
+// which keeps client-supplied IDs out of the file system path.
+type FileStore struct {
+	dir  string
+	opts fileStoreOptions
+	now  func() time.Time
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewFileStore creates a FileStore in dir, creating the directory if needed.
+func NewFileStore(dir string, optFns ...FileStoreOption) (*FileStore, error) {
+	if dir == "" {
+		return nil, errors.New("liveflux: file store requires a directory")
+	}
+	options := fileStoreOptions{}
+	for _, fn := range optFns {
+		if fn != nil {
+			fn(&options)
+		}
+	}
+	if options.codec == nil {
+		options.codec = liveflux.DefaultStateCodec
+	}
+	if options.locker == nil {
+		options.locker = liveflux.NewMutexLocker()
+	}
+	if options.onError == nil {
+		options.onError = logError("file store")
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("liveflux: file store: %w", err)
+	}
+
+	s := &FileStore{
+		dir:  dir,
+		opts: options,
+		now:  time.Now,
+		stop: make(chan struct{}),
+	}
+	if options.ttl > 0 && options.purgeInterval > 0 {
+		go runJanitor(options.purgeInterval, s.stop, func() {
+			if _, err := s.Purge(context.Background()); err != nil {
+				s.opts.onError("purge", err)
+			}
+		})
+	}
+	return s, nil
+}
+
+// Get loads and hydrates a component. Missing, expired and unreadable
+// entries report false.
+func (s *FileStore) Get(id string) (liveflux.ComponentInterface, bool) {
+	if id == "" {
+		return nil, false
+	}
+	path := s.path(id)
+
+	if s.opts.ttl > 0 {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, false
+		}
+		if s.expired(info) {
+			s.remove(path)
+			return nil, false
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			s.opts.onError("read", err)
+		}
+		return nil, false
+	}
+	c, err := liveflux.Hydrate(data, s.opts.codec)
+	if err != nil {
+		s.opts.onError("hydrate", err)
+		return nil, false
+	}
+	if c.GetID() != id {
+		s.opts.onError("hydrate", fmt.Errorf("liveflux: file store: entry for %q holds %q", id, c.GetID()))
+		return nil, false
+	}
+	return c, true
+}
+
+// Set dehydrates the component and atomically replaces its file.
+func (s *FileStore) Set(c liveflux.ComponentInterface) {
+	if c == nil || c.GetID() == "" {
+		return
+	}
+	data, err := liveflux.Dehydrate(c, s.opts.codec)
+	if err != nil {
+		s.opts.onError("dehydrate", err)
+		return
+	}
+	if err := s.writeFile(s.path(c.GetID()), data); err != nil {
+		s.opts.onError("write", err)
+	}
+}
+
+// Delete removes the component's file and calls its Unmount hook.
+func (s *FileStore) Delete(id string) {
+	if id == "" {
+		return
+	}
+	s.remove(s.path(id))
+}
+
+// Lock implements liveflux.LockingStore.
+func (s *FileStore) Lock(ctx context.Context, id string) (func(), error) {
+	return s.opts.locker.Lock(ctx, id)
+}
+
+// Purge removes expired files and returns how many were removed. It is a
+// no-op without a TTL.
+func (s *FileStore) Purge(ctx context.Context) (int, error) {
+	if s.opts.ttl <= 0 {
+		return 0, nil
+	}
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), fileStoreExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !s.expired(info) {
+			continue
+		}
+		if s.remove(filepath.Join(s.dir, entry.Name())) {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// Close stops the purge goroutine. It is safe to call more than once.
+func (s *FileStore) Close() error {
+	s.closeOnce.Do(func() { close(s.stop) })
+	return nil
+}
+
+// path maps a component ID to its file.
+func (s *FileStore) path(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+fileStoreExt)
+}
+
+// load reads and hydrates the file at path.
+func (s *FileStore) load(path string) (liveflux.ComponentInterface, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return liveflux.Hydrate(data, s.opts.codec)
+}
+
+// remove deletes the file at path and unmounts the component it held.
+// Returns true if a file was removed.
+func (s *FileStore) remove(path string) bool {
+	c, loadErr := s.load(path)
+	if err := os.Remove(path); err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			s.opts.onError("delete", err)
+		}
+		return false
+	}
+	if loadErr == nil {
+		liveflux.Unmount(context.Background(), c)
+	}
+	return true
+}
+
+func (s *FileStore) expired(info fs.FileInfo) bool {
+	return s.opts.ttl > 0 && s.now().Sub(info.ModTime()) >= s.opts.ttl
+}
+
+// writeFile writes data to a temporary file in the store directory and
+// renames it over path.
+func (s *FileStore) writeFile(path string, data []byte) error {
+	tmp, err := os.CreateTemp(s.dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() { _ = os.Remove(tmpName) }() // no-op after a successful rename
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	// Stamp the write time explicitly so TTLs follow the store clock.
+	now := s.now()
+	if err := os.Chtimes(tmpName, now, now); err != nil {
+		return err
+	}
+	return os.Rename(tmpName, path)
+}
+
+// runJanitor calls fn every interval until stop is closed.
+func runJanitor(interval time.Duration, stop <-chan struct{}, fn func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			fn()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// logError returns the default error handler for a store.
+func logError(store string) func(op string, err error) {
+	return func(op string, err error) {
+		fmt.Printf("liveflux: %s %s error: %v\n", store, op, err)
+	}
+}
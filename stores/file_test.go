@@ -0,0 +1,139 @@
+package stores
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/liveflux"
+	"github.com/dracory/liveflux/storetest"
+)
+
+var _ liveflux.LockingStore = (*FileStore)(nil)
+
+func newFileStore(t *testing.T, opts ...FileStoreOption) *FileStore {
+	t.Helper()
+	s, err := NewFileStore(t.TempDir(), opts...)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	return s
+}
+
+func TestFileStore_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) liveflux.Store {
+		return newFileStore(t)
+	}, storetest.WithSerialization(), storetest.WithTTL(func(t *testing.T, ttl time.Duration) liveflux.Store {
+		return newFileStore(t, WithFileStoreTTL(ttl))
+	}, 50*time.Millisecond))
+}
+
+func TestFileStore_GobCodec(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) liveflux.Store {
+		return newFileStore(t, WithFileStoreCodec(liveflux.GobCodec{}))
+	}, storetest.WithSerialization())
+}
+
+func TestFileStore_LeavesNoTempFilesAndHashesIDs(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(dir)
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	s.Set(newCounter("../../escape", 1))
+	s.Set(newCounter("plain", 2))
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 files, got %d", len(entries))
+	}
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), ".tmp-") || !strings.HasSuffix(e.Name(), fileStoreExt) {
+			t.Fatalf("unexpected file %q", e.Name())
+		}
+	}
+	if got, ok := s.Get("../../escape"); !ok || got.(*counter).Count != 1 {
+		t.Fatalf("expected hostile id to round trip inside the store dir")
+	}
+}
+
+func TestFileStore_TTLAndPurge(t *testing.T) {
+	s, err := NewFileStore(t.TempDir(), WithFileStoreTTL(time.Minute))
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	s.Set(newCounter("old", 1))
+	now = now.Add(30 * time.Second)
+	s.Set(newCounter("new", 2))
+	now = now.Add(45 * time.Second)
+
+	if _, ok := s.Get("old"); ok {
+		t.Fatalf("expected expired entry to miss")
+	}
+	if _, ok := s.Get("new"); !ok {
+		t.Fatalf("expected fresh entry to be returned")
+	}
+
+	s.Set(newCounter("stale", 3))
+	now = now.Add(2 * time.Minute)
+	n, err := s.Purge(context.Background())
+	if err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if n != 2 {
+		t.Fatalf("expected 2 purged, got %d", n)
+	}
+}
+
+func TestFileStore_ReportsCorruptEntries(t *testing.T) {
+	var ops []string
+	s, err := NewFileStore(t.TempDir(), WithFileStoreErrorHandler(func(op string, _ error) { ops = append(ops, op) }))
+	if err != nil {
+		t.Fatalf("NewFileStore: %v", err)
+	}
+	if err := os.WriteFile(s.path("bad"), []byte("not a snapshot"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, ok := s.Get("bad"); ok {
+		t.Fatalf("expected corrupt entry to miss")
+	}
+	if len(ops) != 1 || ops[0] != "hydrate" {
+		t.Fatalf("expected hydrate error to be reported, got %v", ops)
+	}
+}
+
+func TestNewFileStore_RequiresDir(t *testing.T) {
+	if _, err := NewFileStore(""); err == nil {
+		t.Fatalf("expected error for empty dir")
+	}
+}
+
+func TestFileStore_UnmountOnDeleteAndPurge(t *testing.T) {
+	s := newFileStore(t, WithFileStoreTTL(time.Minute))
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	s.Set(newCounter("file-del", 1))
+	s.Delete("file-del")
+	if !wasUnmounted("file-del") {
+		t.Fatalf("expected Unmount on Delete")
+	}
+
+	s.Set(newCounter("file-exp", 1))
+	now = now.Add(2 * time.Minute)
+	if _, err := s.Purge(context.Background()); err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if !wasUnmounted("file-exp") {
+		t.Fatalf("expected Unmount on Purge")
+	}
+}
@@ -0,0 +1,392 @@
+package stores
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dracory/liveflux"
+)
+
+// Dialect selects the SQL syntax used by SQLStore.
+type Dialect int
+
+const (
+	DialectSQLite   Dialect = iota // ? placeholders, INSERT ... ON CONFLICT
+	DialectPostgres                // $n placeholders, INSERT ... ON CONFLICT
+	DialectMySQL                   // ? placeholders, INSERT ... ON DUPLICATE KEY UPDATE
+)
+
+// DefaultSQLTable is the table used when no name is configured.
+const DefaultSQLTable = "liveflux_components"
+
+var sqlIdentifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// SQLStoreOption configures a SQLStore.
+type SQLStoreOption func(*sqlStoreOptions)
+
+type sqlStoreOptions struct {
+	table         string
+	codec         liveflux.StateCodec
+	ttl           time.Duration
+	purgeInterval time.Duration
+	timeout       time.Duration
+	locker        liveflux.Locker
+	onError       func(op string, err error)
+}
+
+// WithSQLStoreTable sets the table name. Defaults to DefaultSQLTable.
+func WithSQLStoreTable(table string) SQLStoreOption {
+	return func(opts *sqlStoreOptions) {
+		opts.table = table
+	}
+}
+
+// WithSQLStoreCodec sets the codec used to serialize components.
+// Defaults to liveflux.DefaultStateCodec.
+func WithSQLStoreCodec(codec liveflux.StateCodec) SQLStoreOption {
+	return func(opts *sqlStoreOptions) {
+		opts.codec = codec
+	}
+}
+
+// WithSQLStoreTTL sets the expires_at column to ttl after every Set. Rows
+// past their expiry are ignored by Get and removed by Purge. Zero (the
+// default) stores rows without expiry.
+func WithSQLStoreTTL(ttl time.Duration) SQLStoreOption {
+	return func(opts *sqlStoreOptions) {
+		opts.ttl = ttl
+	}
+}
+
+// WithSQLStorePurgeInterval starts a background goroutine that deletes
+// expired rows every interval. Stop it with Close.
+func WithSQLStorePurgeInterval(interval time.Duration) SQLStoreOption {
+	return func(opts *sqlStoreOptions) {
+		opts.purgeInterval = interval
+	}
+}
+
+// WithSQLStoreTimeout bounds queries issued through Get, Set and Delete,
+// which carry no context of their own. Defaults to 5s.
+func WithSQLStoreTimeout(timeout time.Duration) SQLStoreOption {
+	return func(opts *sqlStoreOptions) {
+		opts.timeout = timeout
+	}
+}
+
+// WithSQLStoreLocker sets the Locker used by Lock. Defaults to an
+// in-process liveflux.MutexLocker; use a shared Locker across instances.
+func WithSQLStoreLocker(locker liveflux.Locker) SQLStoreOption {
+	return func(opts *sqlStoreOptions) {
+		opts.locker = locker
+	}
+}
+
+// WithSQLStoreErrorHandler receives query and codec errors, which the Store
+// interface cannot return. By default errors are logged.
+func WithSQLStoreErrorHandler(fn func(op string, err error)) SQLStoreOption {
+	return func(opts *sqlStoreOptions) {
+		opts.onError = fn
+	}
+}
+
+// SQLStore persists components in a database/sql table:
+//
+//	id         primary key (component ID)
+//	kind       component kind, for inspection
+//	state      Dehydrate output
+//	updated_at unix milliseconds of the last Set
+//	expires_at unix milliseconds after which the row is ignored (0 = never)
+//
+// Call CreateTable once (or apply Schema with your migration tool) before use.
+// SQLStore implements liveflux.ContextStore, so handler requests use the
+// request context for queries.
+type SQLStore struct {
+	db      *sql.DB
+	dialect Dialect
+	opts    sqlStoreOptions
+	now     func() time.Time
+
+	getQuery     string
+	loadQuery    string
+	upsertQuery  string
+	deleteQuery  string
+	expiredQuery string
+	purgeQuery   string
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewSQLStore creates a SQLStore using db and dialect.
+func NewSQLStore(db *sql.DB, dialect Dialect, optFns ...SQLStoreOption) (*SQLStore, error) {
+	if db == nil {
+		return nil, errors.New("liveflux: sql store requires a database")
+	}
+	if dialect < DialectSQLite || dialect > DialectMySQL {
+		return nil, fmt.Errorf("liveflux: sql store: unknown dialect %d", dialect)
+	}
+	options := sqlStoreOptions{table: DefaultSQLTable, timeout: 5 * time.Second}
+	for _, fn := range optFns {
+		if fn != nil {
+			fn(&options)
+		}
+	}
+	if !sqlIdentifier.MatchString(options.table) {
+		return nil, fmt.Errorf("liveflux: sql store: invalid table name %q", options.table)
+	}
+	if options.codec == nil {
+		options.codec = liveflux.DefaultStateCodec
+	}
+	if options.locker == nil {
+		options.locker = liveflux.NewMutexLocker()
+	}
+	if options.onError == nil {
+		options.onError = logError("sql store")
+	}
+
+	s := &SQLStore{
+		db:      db,
+		dialect: dialect,
+		opts:    options,
+		now:     time.Now,
+		stop:    make(chan struct{}),
+	}
+	s.buildQueries()
+
+	if options.purgeInterval > 0 {
+		go runJanitor(options.purgeInterval, s.stop, func() {
+			if _, err := s.Purge(context.Background()); err != nil {
+				s.opts.onError("purge", err)
+			}
+		})
+	}
+	return s, nil
+}
+
+// Schema returns the CREATE TABLE and CREATE INDEX statements for the
+// configured table and dialect.
+func (s *SQLStore) Schema() []string {
+	blob := "BLOB"
+	switch s.dialect {
+	case DialectPostgres:
+		blob = "BYTEA"
+	case DialectMySQL:
+		blob = "LONGBLOB"
+	}
+	index := strings.ReplaceAll(s.opts.table, ".", "_") + "_expires_at"
+	createIndex := "CREATE INDEX IF NOT EXISTS " + index + " ON " + s.opts.table + " (expires_at)"
+	if s.dialect == DialectMySQL {
+		// MySQL has no IF NOT EXISTS for indexes; declare it inline instead.
+		return []string{
+			"CREATE TABLE IF NOT EXISTS " + s.opts.table + " (" +
+				"id VARCHAR(255) NOT NULL PRIMARY KEY, " +
+				"kind VARCHAR(255) NOT NULL, " +
+				"state " + blob + " NOT NULL, " +
+				"updated_at BIGINT NOT NULL, " +
+				"expires_at BIGINT NOT NULL DEFAULT 0, " +
+				"INDEX " + index + " (expires_at))",
+		}
+	}
+	return []string{
+		"CREATE TABLE IF NOT EXISTS " + s.opts.table + " (" +
+			"id VARCHAR(255) NOT NULL PRIMARY KEY, " +
+			"kind VARCHAR(255) NOT NULL, " +
+			"state " + blob + " NOT NULL, " +
+			"updated_at BIGINT NOT NULL, " +
+			"expires_at BIGINT NOT NULL DEFAULT 0)",
+		createIndex,
+	}
+}
+
+// CreateTable executes Schema.
+func (s *SQLStore) CreateTable(ctx context.Context) error {
+	for _, stmt := range s.Schema() {
+		if _, err := s.db.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("liveflux: sql store: create table: %w", err)
+		}
+	}
+	return nil
+}
+
+// Get implements liveflux.Store.
+func (s *SQLStore) Get(id string) (liveflux.ComponentInterface, bool) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.timeout)
+	defer cancel()
+	return s.GetContext(ctx, id)
+}
+
+// Set implements liveflux.Store.
+func (s *SQLStore) Set(c liveflux.ComponentInterface) {
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.timeout)
+	defer cancel()
+	s.SetContext(ctx, c)
+}
+
+// Delete implements liveflux.Store. The component's Unmount hook is called
+// after its row is removed.
+func (s *SQLStore) Delete(id string) {
+	if id == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), s.opts.timeout)
+	defer cancel()
+
+	var data []byte
+	err := s.db.QueryRowContext(ctx, s.loadQuery, id).Scan(&data)
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		s.opts.onError("delete", err)
+		return
+	}
+	if _, err := s.db.ExecContext(ctx, s.deleteQuery, id); err != nil {
+		s.opts.onError("delete", err)
+		return
+	}
+	s.unmount(ctx, data)
+}
+
+// GetContext implements liveflux.ContextStore. Missing, expired and
+// unreadable rows report false.
+func (s *SQLStore) GetContext(ctx context.Context, id string) (liveflux.ComponentInterface, bool) {
+	if id == "" {
+		return nil, false
+	}
+	var data []byte
+	err := s.db.QueryRowContext(ctx, s.getQuery, id, s.now().UnixMilli()).Scan(&data)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			s.opts.onError("get", err)
+		}
+		return nil, false
+	}
+	c, err := liveflux.Hydrate(data, s.opts.codec)
+	if err != nil {
+		s.opts.onError("hydrate", err)
+		return nil, false
+	}
+	return c, true
+}
+
+// SetContext implements liveflux.ContextStore. The row is inserted or
+// updated in a single statement.
+func (s *SQLStore) SetContext(ctx context.Context, c liveflux.ComponentInterface) {
+	if c == nil || c.GetID() == "" {
+		return
+	}
+	data, err := liveflux.Dehydrate(c, s.opts.codec)
+	if err != nil {
+		s.opts.onError("dehydrate", err)
+		return
+	}
+	now := s.now()
+	var expires int64
+	if s.opts.ttl > 0 {
+		expires = now.Add(s.opts.ttl).UnixMilli()
+	}
+	if _, err := s.db.ExecContext(ctx, s.upsertQuery, c.GetID(), c.GetKind(), data, now.UnixMilli(), expires); err != nil {
+		s.opts.onError("set", err)
+	}
+}
+
+// Lock implements liveflux.LockingStore.
+func (s *SQLStore) Lock(ctx context.Context, id string) (func(), error) {
+	return s.opts.locker.Lock(ctx, id)
+}
+
+// Purge deletes expired rows, calling the Unmount hook of each removed
+// component, and returns how many were removed.
+func (s *SQLStore) Purge(ctx context.Context) (int64, error) {
+	now := s.now().UnixMilli()
+	rows, err := s.db.QueryContext(ctx, s.expiredQuery, now)
+	if err != nil {
+		return 0, err
+	}
+	type expiredRow struct {
+		id   string
+		data []byte
+	}
+	var expired []expiredRow
+	for rows.Next() {
+		var row expiredRow
+		if err := rows.Scan(&row.id, &row.data); err != nil {
+			_ = rows.Close()
+			return 0, err
+		}
+		expired = append(expired, row)
+	}
+	if err := rows.Close(); err != nil {
+		return 0, err
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	var removed int64
+	for _, row := range expired {
+		// Re-check expiry so a row refreshed in the meantime survives
+		res, err := s.db.ExecContext(ctx, s.purgeQuery, row.id, now)
+		if err != nil {
+			return removed, err
+		}
+		if n, _ := res.RowsAffected(); n > 0 {
+			removed += n
+			s.unmount(ctx, row.data)
+		}
+	}
+	return removed, nil
+}
+
+// Close stops the purge goroutine. It does not close the database.
+func (s *SQLStore) Close() error {
+	s.closeOnce.Do(func() { close(s.stop) })
+	return nil
+}
+
+// unmount hydrates a removed row and calls its Unmount hook.
+func (s *SQLStore) unmount(ctx context.Context, data []byte) {
+	c, err := liveflux.Hydrate(data, s.opts.codec)
+	if err != nil {
+		s.opts.onError("hydrate", err)
+		return
+	}
+	liveflux.Unmount(ctx, c)
+}
+
+func (s *SQLStore) buildQueries() {
+	t := s.opts.table
+	p := s.placeholder
+
+	s.getQuery = "SELECT state FROM " + t + " WHERE id = " + p(1) +
+		" AND (expires_at = 0 OR expires_at > " + p(2) + ")"
+	s.loadQuery = "SELECT state FROM " + t + " WHERE id = " + p(1)
+	s.deleteQuery = "DELETE FROM " + t + " WHERE id = " + p(1)
+	s.expiredQuery = "SELECT id, state FROM " + t + " WHERE expires_at > 0 AND expires_at <= " + p(1)
+	s.purgeQuery = "DELETE FROM " + t + " WHERE id = " + p(1) + " AND expires_at > 0 AND expires_at <= " + p(2)
+
+	insert := "INSERT INTO " + t + " (id, kind, state, updated_at, expires_at) VALUES (" +
+		p(1) + ", " + p(2) + ", " + p(3) + ", " + p(4) + ", " + p(5) + ")"
+	if s.dialect == DialectMySQL {
+		s.upsertQuery = insert + " ON DUPLICATE KEY UPDATE kind = VALUES(kind), state = VALUES(state), " +
+			"updated_at = VALUES(updated_at), expires_at = VALUES(expires_at)"
+		return
+	}
+	s.upsertQuery = insert + " ON CONFLICT (id) DO UPDATE SET kind = excluded.kind, state = excluded.state, " +
+		"updated_at = excluded.updated_at, expires_at = excluded.expires_at"
+}
+
+func (s *SQLStore) placeholder(n int) string {
+	if s.dialect == DialectPostgres {
+		return "$" + strconv.Itoa(n)
+	}
+	return "?"
+}
@@ -0,0 +1,173 @@
+package stores
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/liveflux"
+	"github.com/dracory/liveflux/storetest"
+	_ "modernc.org/sqlite"
+)
+
+var (
+	_ liveflux.LockingStore = (*SQLStore)(nil)
+	_ liveflux.ContextStore = (*SQLStore)(nil)
+)
+
+func newSQLiteStore(t *testing.T, opts ...SQLStoreOption) *SQLStore {
+	t.Helper()
+	db, err := sql.Open("sqlite", "file:"+t.TempDir()+"/store.db?_pragma=busy_timeout(5000)")
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+	t.Cleanup(func() { _ = db.Close() })
+
+	s, err := NewSQLStore(db, DialectSQLite, opts...)
+	if err != nil {
+		t.Fatalf("NewSQLStore: %v", err)
+	}
+	t.Cleanup(func() { _ = s.Close() })
+	if err := s.CreateTable(context.Background()); err != nil {
+		t.Fatalf("CreateTable: %v", err)
+	}
+	return s
+}
+
+func TestSQLStore_Conformance(t *testing.T) {
+	storetest.Run(t, func(t *testing.T) liveflux.Store {
+		return newSQLiteStore(t)
+	}, storetest.WithSerialization(), storetest.WithTTL(func(t *testing.T, ttl time.Duration) liveflux.Store {
+		return newSQLiteStore(t, WithSQLStoreTTL(ttl))
+	}, 50*time.Millisecond))
+}
+
+func TestSQLStore_CreateTableIsIdempotent(t *testing.T) {
+	s := newSQLiteStore(t, WithSQLStoreTable("custom_components"))
+	if err := s.CreateTable(context.Background()); err != nil {
+		t.Fatalf("second CreateTable: %v", err)
+	}
+	s.Set(newCounter("c1", 1))
+
+	var kind string
+	if err := s.db.QueryRow("SELECT kind FROM custom_components WHERE id = ?", "c1").Scan(&kind); err != nil {
+		t.Fatalf("query: %v", err)
+	}
+	if kind != counterKind {
+		t.Fatalf("expected kind column %q, got %q", counterKind, kind)
+	}
+}
+
+func TestSQLStore_UpsertKeepsOneRow(t *testing.T) {
+	s := newSQLiteStore(t)
+	for i := 0; i < 3; i++ {
+		s.Set(newCounter("c1", i))
+	}
+	var n int
+	if err := s.db.QueryRow("SELECT COUNT(*) FROM " + DefaultSQLTable).Scan(&n); err != nil {
+		t.Fatalf("count: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected a single row, got %d", n)
+	}
+}
+
+func TestSQLStore_TTLAndPurge(t *testing.T) {
+	s := newSQLiteStore(t, WithSQLStoreTTL(time.Minute))
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	s.Set(newCounter("old", 1))
+	now = now.Add(30 * time.Second)
+	s.Set(newCounter("new", 2))
+	now = now.Add(45 * time.Second)
+
+	if _, ok := s.Get("old"); ok {
+		t.Fatalf("expected expired row to miss")
+	}
+	if _, ok := s.Get("new"); !ok {
+		t.Fatalf("expected fresh row to be returned")
+	}
+
+	n, err := s.Purge(context.Background())
+	if err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if n != 1 {
+		t.Fatalf("expected 1 purged row, got %d", n)
+	}
+}
+
+func TestSQLStore_PurgeInterval(t *testing.T) {
+	s := newSQLiteStore(t, WithSQLStoreTTL(time.Millisecond), WithSQLStorePurgeInterval(5*time.Millisecond))
+	s.Set(newCounter("c1", 1))
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		var n int
+		if err := s.db.QueryRow("SELECT COUNT(*) FROM " + DefaultSQLTable).Scan(&n); err == nil && n == 0 {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatalf("expected background purge to remove the expired row")
+}
+
+func TestSQLStore_DialectQueries(t *testing.T) {
+	pg, err := NewSQLStore(&sql.DB{}, DialectPostgres)
+	if err != nil {
+		t.Fatalf("NewSQLStore: %v", err)
+	}
+	if !strings.Contains(pg.getQuery, "$2") || !strings.Contains(pg.upsertQuery, "ON CONFLICT (id)") {
+		t.Fatalf("unexpected postgres queries: %q / %q", pg.getQuery, pg.upsertQuery)
+	}
+	if !strings.Contains(pg.Schema()[0], "BYTEA") {
+		t.Fatalf("expected BYTEA column for postgres")
+	}
+
+	my, err := NewSQLStore(&sql.DB{}, DialectMySQL)
+	if err != nil {
+		t.Fatalf("NewSQLStore: %v", err)
+	}
+	if !strings.Contains(my.upsertQuery, "ON DUPLICATE KEY UPDATE") || strings.Contains(my.getQuery, "$") {
+		t.Fatalf("unexpected mysql queries: %q / %q", my.getQuery, my.upsertQuery)
+	}
+}
+
+func TestNewSQLStore_Validation(t *testing.T) {
+	if _, err := NewSQLStore(nil, DialectSQLite); err == nil {
+		t.Fatalf("expected error for nil db")
+	}
+	if _, err := NewSQLStore(&sql.DB{}, DialectSQLite, WithSQLStoreTable("x; DROP TABLE y")); err == nil {
+		t.Fatalf("expected error for invalid table name")
+	}
+	if _, err := NewSQLStore(&sql.DB{}, Dialect(42)); err == nil {
+		t.Fatalf("expected error for unknown dialect")
+	}
+}
+
+func TestSQLStore_UnmountOnDeleteAndPurge(t *testing.T) {
+	s := newSQLiteStore(t, WithSQLStoreTTL(time.Minute))
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	s.Set(newCounter("sql-del", 1))
+	s.Delete("sql-del")
+	if !wasUnmounted("sql-del") {
+		t.Fatalf("expected Unmount on Delete")
+	}
+
+	s.Set(newCounter("sql-exp", 1))
+	s.Set(newCounter("sql-live", 1))
+	now = now.Add(30 * time.Second)
+	s.Set(newCounter("sql-live", 2)) // refreshed, survives
+	now = now.Add(45 * time.Second)
+	if n, err := s.Purge(context.Background()); err != nil || n != 1 {
+		t.Fatalf("Purge: n=%d err=%v", n, err)
+	}
+	if !wasUnmounted("sql-exp") || wasUnmounted("sql-live") {
+		t.Fatalf("expected only the expired row to be unmounted")
+	}
+}
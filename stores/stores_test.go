@@ -0,0 +1,51 @@
+package stores
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+
+	"github.com/dracory/hb"
+	"github.com/dracory/liveflux"
+)
+
+const counterKind = "stores-test.counter"
+
+// counter is the component persisted by the store tests.
+type counter struct {
+	liveflux.Base
+	Count int
+	Items []string
+}
+
+func (c *counter) GetKind() string                                { return counterKind }
+func (c *counter) Mount(context.Context, map[string]string) error { return nil }
+func (c *counter) Handle(context.Context, string, url.Values) error {
+	c.Count++
+	return nil
+}
+func (c *counter) Render(context.Context) hb.TagInterface {
+	return c.Root(hb.Text(fmt.Sprint(c.Count)))
+}
+
+// unmounted records the IDs passed to counter.Unmount.
+var unmounted sync.Map
+
+func (c *counter) Unmount(context.Context) { unmounted.Store(c.GetID(), true) }
+
+func wasUnmounted(id string) bool {
+	_, ok := unmounted.LoadAndDelete(id)
+	return ok
+}
+
+func init() {
+	_ = liveflux.RegisterByKind(counterKind, &counter{})
+}
+
+func newCounter(id string, count int) *counter {
+	c := &counter{Count: count, Items: []string{"a", "b"}}
+	c.SetKind(counterKind)
+	c.SetID(id)
+	return c
+}
@@ -0,0 +1,375 @@
+// Package storetest provides a conformance suite for liveflux.Store
+// implementations.
+//
+// Call Run from a test in your store's package:
+//
+//	func TestRedisStore(t *testing.T) {
+//		storetest.Run(t, func(t *testing.T) liveflux.Store {
+//			return newRedisStore(t)
+//		}, storetest.WithSerialization())
+//	}
+//
+// The suite always checks the Store contract. Locking is checked when the
+// store implements liveflux.LockingStore; TTL and serialization behaviour
+// are checked when enabled through options.
+package storetest
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/dracory/hb"
+	"github.com/dracory/liveflux"
+)
+
+// Kind is the registry kind of the component stored by the suite.
+const Kind = "storetest.component"
+
+// Component is the component stored by the suite. It is registered under
+// Kind, so stores that hydrate through the registry can rebuild it.
+type Component struct {
+	liveflux.Base
+	Count   int
+	Tags    []string
+	Nested  Nested
+	Scratch string `flux:"-"` // must not survive a serializing store
+}
+
+// Nested exercises struct-valued fields.
+type Nested struct {
+	Label string
+	Score float64
+}
+
+// GetKind implements liveflux.ComponentInterface.
+func (c *Component) GetKind() string { return Kind }
+
+// Mount implements liveflux.ComponentInterface.
+func (c *Component) Mount(context.Context, map[string]string) error { return nil }
+
+// Handle implements liveflux.ComponentInterface.
+func (c *Component) Handle(context.Context, string, url.Values) error {
+	c.Count++
+	return nil
+}
+
+// Render implements liveflux.ComponentInterface.
+func (c *Component) Render(context.Context) hb.TagInterface {
+	return c.Root(hb.Text(fmt.Sprint(c.Count)))
+}
+
+func init() {
+	_ = liveflux.RegisterByKind(Kind, &Component{})
+}
+
+// NewComponent returns a populated Component with the given ID.
+func NewComponent(id string, count int) *Component {
+	c := &Component{
+		Count:   count,
+		Tags:    []string{"a", "b"},
+		Nested:  Nested{Label: "n", Score: 1.5},
+		Scratch: "scratch",
+	}
+	c.SetKind(Kind)
+	c.SetID(id)
+	return c
+}
+
+// Factory returns a new, empty store. It is called once per sub-test.
+type Factory func(t *testing.T) liveflux.Store
+
+// TTLFactory returns a new, empty store whose entries expire ttl after
+// they were last written.
+type TTLFactory func(t *testing.T, ttl time.Duration) liveflux.Store
+
+// Option enables optional parts of the suite.
+type Option func(*options)
+
+type options struct {
+	serializing bool
+	ttlFactory  TTLFactory
+	ttl         time.Duration
+}
+
+// WithSerialization declares that the store persists snapshots rather than
+// live pointers: Get returns detached copies and `flux:"-"` fields are reset.
+func WithSerialization() Option {
+	return func(o *options) {
+		o.serializing = true
+	}
+}
+
+// WithTTL enables expiry checks using stores built by factory. The suite
+// waits in real time, so keep ttl short; it defaults to 100ms.
+func WithTTL(factory TTLFactory, ttl time.Duration) Option {
+	return func(o *options) {
+		o.ttlFactory = factory
+		o.ttl = ttl
+	}
+}
+
+// Run executes the conformance suite against stores created by factory.
+func Run(t *testing.T, factory Factory, optFns ...Option) {
+	t.Helper()
+	o := options{ttl: 100 * time.Millisecond}
+	for _, fn := range optFns {
+		if fn != nil {
+			fn(&o)
+		}
+	}
+	if o.ttl <= 0 {
+		o.ttl = 100 * time.Millisecond
+	}
+
+	t.Run("GetMissing", func(t *testing.T) { testGetMissing(t, factory(t)) })
+	t.Run("RoundTrip", func(t *testing.T) { testRoundTrip(t, factory(t), o.serializing) })
+	t.Run("Overwrite", func(t *testing.T) { testOverwrite(t, factory(t)) })
+	t.Run("Delete", func(t *testing.T) { testDelete(t, factory(t)) })
+	t.Run("SetAfterDelete", func(t *testing.T) { testSetAfterDelete(t, factory(t)) })
+	t.Run("EmptyID", func(t *testing.T) { testEmptyID(t, factory(t)) })
+	t.Run("Isolation", func(t *testing.T) { testIsolation(t, factory(t)) })
+	t.Run("Concurrent", func(t *testing.T) { testConcurrent(t, factory(t)) })
+
+	t.Run("Locking", func(t *testing.T) {
+		ls, ok := factory(t).(liveflux.LockingStore)
+		if !ok {
+			t.Skip("store does not implement liveflux.LockingStore")
+		}
+		testLocking(t, ls)
+	})
+
+	t.Run("Serialization", func(t *testing.T) {
+		if !o.serializing {
+			t.Skip("serialization not enabled")
+		}
+		testSerialization(t, factory(t))
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		if o.ttlFactory == nil {
+			t.Skip("TTL not enabled")
+		}
+		testTTL(t, o.ttlFactory(t, o.ttl), o.ttl)
+	})
+}
+
+func get(t *testing.T, s liveflux.Store, id string) *Component {
+	t.Helper()
+	got, ok := s.Get(id)
+	if !ok || got == nil {
+		t.Fatalf("Get(%q): expected component", id)
+	}
+	c, ok := got.(*Component)
+	if !ok {
+		t.Fatalf("Get(%q): expected *storetest.Component, got %T", id, got)
+	}
+	return c
+}
+
+func testGetMissing(t *testing.T, s liveflux.Store) {
+	if c, ok := s.Get("missing"); ok || c != nil {
+		t.Fatalf("Get of unknown id: expected (nil, false), got (%v, %t)", c, ok)
+	}
+}
+
+func testRoundTrip(t *testing.T, s liveflux.Store, serializing bool) {
+	want := NewComponent("rt", 3)
+	s.Set(want)
+	c := get(t, s, "rt")
+
+	if c.GetID() != "rt" || c.GetKind() != Kind {
+		t.Fatalf("expected id/kind rt/%s, got %s/%s", Kind, c.GetID(), c.GetKind())
+	}
+	if c.Count != 3 || len(c.Tags) != 2 || c.Tags[1] != "b" || c.Nested != want.Nested {
+		t.Fatalf("fields did not round trip: %+v", c)
+	}
+	if !serializing && c.Scratch != "scratch" {
+		t.Fatalf("expected non-serializing store to keep all fields, got %+v", c)
+	}
+}
+
+func testOverwrite(t *testing.T, s liveflux.Store) {
+	s.Set(NewComponent("ow", 1))
+	s.Set(NewComponent("ow", 2))
+	if c := get(t, s, "ow"); c.Count != 2 {
+		t.Fatalf("expected Set to overwrite, got count %d", c.Count)
+	}
+}
+
+func testDelete(t *testing.T, s liveflux.Store) {
+	s.Set(NewComponent("del", 1))
+	s.Delete("del")
+	if _, ok := s.Get("del"); ok {
+		t.Fatalf("expected miss after Delete")
+	}
+	s.Delete("del")   // repeated delete
+	s.Delete("never") // unknown id
+}
+
+func testSetAfterDelete(t *testing.T, s liveflux.Store) {
+	s.Set(NewComponent("sad", 1))
+	s.Delete("sad")
+	s.Set(NewComponent("sad", 2))
+	if c := get(t, s, "sad"); c.Count != 2 {
+		t.Fatalf("expected Set after Delete to store the new value, got count %d", c.Count)
+	}
+}
+
+func testEmptyID(t *testing.T, s liveflux.Store) {
+	s.Set(nil)
+	s.Set(NewComponent("", 1))
+	if c, ok := s.Get(""); ok || c != nil {
+		t.Fatalf("expected empty id to miss")
+	}
+	s.Delete("")
+}
+
+func testIsolation(t *testing.T, s liveflux.Store) {
+	s.Set(NewComponent("iso-a", 1))
+	s.Set(NewComponent("iso-b", 2))
+	s.Delete("iso-a")
+	if c := get(t, s, "iso-b"); c.Count != 2 {
+		t.Fatalf("expected unrelated entry to be untouched, got count %d", c.Count)
+	}
+}
+
+func testConcurrent(t *testing.T, s liveflux.Store) {
+	const workers, rounds = 8, 20
+	s.Set(NewComponent("shared", 0))
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			own := fmt.Sprintf("own-%d", w)
+			for i := 0; i < rounds; i++ {
+				s.Set(NewComponent(own, i))
+				got, ok := s.Get(own)
+				if !ok {
+					t.Errorf("Get(%q): expected component", own)
+					return
+				}
+				if c := got.(*Component); c.Count != i {
+					t.Errorf("Get(%q): expected count %d, got %d", own, i, c.Count)
+					return
+				}
+
+				// Readers and writers racing on one ID must always see a complete entry
+				s.Set(NewComponent("shared", i))
+				if got, ok := s.Get("shared"); !ok || got.(*Component).Tags == nil {
+					t.Errorf("Get(shared): expected a complete component")
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
+
+func testLocking(t *testing.T, s liveflux.LockingStore) {
+	ctx := context.Background()
+
+	t.Run("MutualExclusion", func(t *testing.T) {
+		var inside, maxInside int32
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				unlock, err := s.Lock(ctx, "locked")
+				if err != nil {
+					t.Errorf("Lock: %v", err)
+					return
+				}
+				n := atomic.AddInt32(&inside, 1)
+				for {
+					m := atomic.LoadInt32(&maxInside)
+					if n <= m || atomic.CompareAndSwapInt32(&maxInside, m, n) {
+						break
+					}
+				}
+				time.Sleep(time.Millisecond)
+				atomic.AddInt32(&inside, -1)
+				unlock()
+			}()
+		}
+		wg.Wait()
+		if maxInside != 1 {
+			t.Fatalf("expected at most one lock holder, saw %d", maxInside)
+		}
+	})
+
+	t.Run("IndependentIDs", func(t *testing.T) {
+		unlockA, err := s.Lock(ctx, "a")
+		if err != nil {
+			t.Fatalf("Lock(a): %v", err)
+		}
+		defer unlockA()
+
+		done := make(chan error, 1)
+		go func() {
+			unlockB, err := s.Lock(ctx, "b")
+			if err == nil {
+				unlockB()
+			}
+			done <- err
+		}()
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatalf("Lock(b): %v", err)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("lock on one id blocked another id")
+		}
+	})
+
+	t.Run("Reacquire", func(t *testing.T) {
+		unlock, err := s.Lock(ctx, "re")
+		if err != nil {
+			t.Fatalf("Lock: %v", err)
+		}
+		unlock()
+		unlock, err = s.Lock(ctx, "re")
+		if err != nil {
+			t.Fatalf("expected lock to be free after unlock: %v", err)
+		}
+		unlock()
+	})
+}
+
+func testSerialization(t *testing.T, s liveflux.Store) {
+	s.Set(NewComponent("ser", 1))
+
+	c := get(t, s, "ser")
+	if c.Scratch != "" {
+		t.Fatalf("expected `flux:\"-\"` field to be dropped, got %q", c.Scratch)
+	}
+
+	// Mutations are only visible after Set
+	c.Count = 42
+	c.Tags[0] = "changed"
+	if again := get(t, s, "ser"); again.Count != 1 || again.Tags[0] != "a" {
+		t.Fatalf("expected Get to return a detached copy, got %+v", again)
+	}
+	if again := get(t, s, "ser"); again == c {
+		t.Fatalf("expected a new instance per Get")
+	}
+}
+
+func testTTL(t *testing.T, s liveflux.Store, ttl time.Duration) {
+	s.Set(NewComponent("ttl", 1))
+	get(t, s, "ttl")
+
+	// No reads while waiting, so stores with idle expiry behave the same
+	time.Sleep(2 * ttl)
+	if _, ok := s.Get("ttl"); ok {
+		t.Fatalf("expected entry to expire after %s", ttl)
+	}
+}
@@ -0,0 +1,31 @@
+package storetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dracory/liveflux"
+)
+
+func TestRun_MemoryStore(t *testing.T) {
+	Run(t, func(t *testing.T) liveflux.Store {
+		return liveflux.NewMemoryStore()
+	}, WithTTL(func(t *testing.T, ttl time.Duration) liveflux.Store {
+		s := liveflux.NewMemoryStore(liveflux.WithMemoryStoreTTL(ttl))
+		t.Cleanup(func() { _ = s.Close() })
+		return s
+	}, 50*time.Millisecond))
+}
+
+func TestRun_LockingStore(t *testing.T) {
+	Run(t, func(t *testing.T) liveflux.Store {
+		return liveflux.NewLockingStore(liveflux.NewMemoryStore(), liveflux.NewLeaseLocker(nil, time.Second, time.Second))
+	})
+}
+
+func TestRun_SessionStore(t *testing.T) {
+	// Plain Get/Set bypass the session check, so the contract holds unchanged
+	Run(t, func(t *testing.T) liveflux.Store {
+		return liveflux.NewSessionStore(liveflux.NewMemoryStore(), liveflux.SessionFromCookie("sid"))
+	})
+}
@@ -75,7 +75,7 @@ func BuildTargetResponse(fragments []TargetFragment, fullRender string, comp Com
 		componentKind := html.EscapeString(comp.GetKind())
 		componentID := html.EscapeString(comp.GetID())
 		sb.WriteString(fmt.Sprintf(
-			`<template data-flux-component-kind=\"%s\" data-flux-component-id=\"%s\">%s</template>`,
+			`<template data-flux-component-kind="%s" data-flux-component-id="%s">%s</template>`,
 			componentKind,
 			componentID,
 			fullRender,
@@ -0,0 +1,526 @@
+package liveflux
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMaxUploadSize caps the body of multipart requests when
+// Handler.MaxUploadSize is zero.
+const DefaultMaxUploadSize int64 = 32 << 20
+
+// uploadMemory is how much of a multipart body is buffered in memory before
+// net/http spills parts to disk.
+const uploadMemory = 1 << 20
+
+// ErrUploadTooLarge is returned when a request or file exceeds its size
+// limit. The handler answers it with 413 "upload too large".
+var ErrUploadTooLarge = errors.New("liveflux: upload too large")
+
+// TempStorage holds uploaded files between the request that carries them
+// and the action that keeps them. Entries not removed by the application
+// are orphans and should be purged by Cleanup.
+type TempStorage interface {
+	// Store copies r into storage and returns a key and the stored size.
+	// Implementations return ErrUploadTooLarge when r exceeds their limit.
+	Store(ctx context.Context, r io.Reader) (key string, size int64, err error)
+	// Open returns a reader for the entry stored under key.
+	Open(key string) (io.ReadCloser, error)
+	// Remove deletes the entry; removing a missing key is not an error.
+	Remove(key string) error
+	// Cleanup removes entries stored more than maxAge ago and reports how
+	// many were removed.
+	Cleanup(ctx context.Context, maxAge time.Duration) (int, error)
+}
+
+// UploadedFile is a file posted with an action and kept in TempStorage.
+// Read it with Open, keep it with MoveTo, or discard it with Remove; files
+// left in storage are purged as orphans.
+//
+// UploadedFile values can be kept in component state (e.g. to show a
+// preview before saving). After a round-trip through a serializing Store
+// they are opened through the default temp storage.
+type UploadedFile struct {
+	Field       string // form field name
+	Filename    string // client-supplied base name
+	ContentType string
+	Size        int64
+	Key         string // TempStorage key
+
+	storage TempStorage
+}
+
+// Open returns a reader for the file contents.
+func (f *UploadedFile) Open() (io.ReadCloser, error) {
+	storage, err := f.tempStorage()
+	if err != nil {
+		return nil, err
+	}
+	return storage.Open(f.Key)
+}
+
+// Remove deletes the file from temporary storage.
+func (f *UploadedFile) Remove() error {
+	storage, err := f.tempStorage()
+	if err != nil {
+		return err
+	}
+	return storage.Remove(f.Key)
+}
+
+// MoveTo copies the file to path and removes it from temporary storage.
+func (f *UploadedFile) MoveTo(path string) error {
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		_ = dst.Close()
+		_ = os.Remove(path)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	return f.Remove()
+}
+
+func (f *UploadedFile) tempStorage() (TempStorage, error) {
+	if f.storage != nil {
+		return f.storage, nil
+	}
+	return defaultTempStorage()
+}
+
+// Uploads returns the files posted under field with the current request.
+func Uploads(ctx context.Context, field string) []*UploadedFile {
+	if ctx == nil {
+		return nil
+	}
+	files, _ := ctx.Value(uploadsContextKey{}).(map[string][]*UploadedFile)
+	return files[field]
+}
+
+// Upload returns the first file posted under field, or nil.
+func Upload(ctx context.Context, field string) *UploadedFile {
+	if files := Uploads(ctx, field); len(files) > 0 {
+		return files[0]
+	}
+	return nil
+}
+
+type uploadsContextKey struct{}
+
+func contextWithUploads(ctx context.Context, files map[string][]*UploadedFile) context.Context {
+	return context.WithValue(ctx, uploadsContextKey{}, files)
+}
+
+var uploadedFileType = reflect.TypeOf((*UploadedFile)(nil))
+
+// bindUploads fills top-level *UploadedFile and []*UploadedFile fields of a
+// typed action input from the request uploads. Fields are matched like
+// DecodeForm matches form values.
+func bindUploads(ctx context.Context, dst reflect.Value) {
+	files, _ := ctx.Value(uploadsContextKey{}).(map[string][]*UploadedFile)
+	if len(files) == 0 {
+		return
+	}
+	byName := map[string][]*UploadedFile{}
+	for field, list := range files {
+		byName[strings.ToLower(field)] = list
+	}
+
+	v := reflect.Indirect(dst)
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		isFile := field.Type == uploadedFileType
+		isFiles := field.Type.Kind() == reflect.Slice && field.Type.Elem() == uploadedFileType
+		if !field.IsExported() || (!isFile && !isFiles) {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("flux"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		list := byName[strings.ToLower(name)]
+		if len(list) == 0 {
+			continue
+		}
+		if isFile {
+			v.Field(i).Set(reflect.ValueOf(list[0]))
+		} else {
+			v.Field(i).Set(reflect.ValueOf(append([]*UploadedFile(nil), list...)))
+		}
+	}
+}
+
+// parseForm parses urlencoded and multipart bodies. Multipart bodies are
+// capped at MaxUploadSize.
+func (h *Handler) parseForm(w http.ResponseWriter, r *http.Request) error {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return r.ParseForm()
+	}
+	limit := h.MaxUploadSize
+	if limit <= 0 {
+		limit = DefaultMaxUploadSize
+	}
+	r.Body = http.MaxBytesReader(w, r.Body, limit)
+	if err := r.ParseMultipartForm(uploadMemory); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			return ErrUploadTooLarge
+		}
+		return err
+	}
+	return nil
+}
+
+// storeUploads copies the request's multipart files into temp storage and
+// finalizes the chunked uploads it references.
+func (h *Handler) storeUploads(ctx context.Context, r *http.Request, kind, id string) (map[string][]*UploadedFile, error) {
+	refs := chunkedUploadRefs(r.Form)
+	hasFiles := r.MultipartForm != nil && len(r.MultipartForm.File) > 0
+	if !hasFiles && len(refs) == 0 {
+		return nil, nil
+	}
+	storage := h.Uploads
+	if storage == nil {
+		var err error
+		if storage, err = defaultTempStorage(); err != nil {
+			return nil, err
+		}
+	}
+
+	files := map[string][]*UploadedFile{}
+	if hasFiles {
+		for field, headers := range r.MultipartForm.File {
+			for _, header := range headers {
+				f, err := storeUpload(ctx, storage, field, header)
+				if err != nil {
+					removeUploads(files)
+					return nil, err
+				}
+				files[field] = append(files[field], f)
+			}
+		}
+	}
+	for field, uploadIDs := range refs {
+		for _, uploadID := range uploadIDs {
+			if h.ChunkedUploads == nil {
+				removeUploads(files)
+				return nil, ErrUploadNotFound
+			}
+			f, err := h.ChunkedUploads.finalize(ctx, uploadID, kind, id, field, storage)
+			if err != nil {
+				removeUploads(files)
+				return nil, err
+			}
+			files[field] = append(files[field], f)
+		}
+	}
+	return files, nil
+}
+
+func storeUpload(ctx context.Context, storage TempStorage, field string, header *multipart.FileHeader) (*UploadedFile, error) {
+	src, err := header.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = src.Close() }()
+
+	key, size, err := storage.Store(ctx, src)
+	if err != nil {
+		return nil, err
+	}
+	return &UploadedFile{
+		Field:       field,
+		Filename:    cleanFilename(header.Filename),
+		ContentType: header.Header.Get("Content-Type"),
+		Size:        size,
+		Key:         key,
+		storage:     storage,
+	}, nil
+}
+
+// removeUploads discards files whose request failed, so they never become
+// orphans.
+func removeUploads(files map[string][]*UploadedFile) {
+	for _, list := range files {
+		for _, f := range list {
+			if err := f.Remove(); err != nil {
+				fmt.Printf("liveflux: upload cleanup error: %v\n", err)
+			}
+		}
+	}
+}
+
+// cleanFilename strips any client-supplied directories.
+func cleanFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
+
+// statusRecorder remembers the status written by the handler, so uploads
+// of failed requests can be discarded.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (s *statusRecorder) WriteHeader(status int) {
+	if s.status == 0 {
+		s.status = status
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	return s.ResponseWriter.Write(b)
+}
+
+// TempStorageOption configures a DirTempStorage.
+type TempStorageOption func(*tempStorageOptions)
+
+type tempStorageOptions struct {
+	maxFileSize   int64
+	ttl           time.Duration
+	purgeInterval time.Duration
+}
+
+// WithTempStorageMaxFileSize rejects files larger than n bytes with
+// ErrUploadTooLarge. Zero means no per-file limit.
+func WithTempStorageMaxFileSize(n int64) TempStorageOption {
+	return func(opts *tempStorageOptions) {
+		opts.maxFileSize = n
+	}
+}
+
+// WithTempStorageTTL sets the age after which the background purge removes
+// orphaned files. Defaults to 24 hours.
+func WithTempStorageTTL(ttl time.Duration) TempStorageOption {
+	return func(opts *tempStorageOptions) {
+		opts.ttl = ttl
+	}
+}
+
+// WithTempStoragePurgeInterval starts a background goroutine that calls
+// Cleanup with the TTL every interval; stop it with Close.
+func WithTempStoragePurgeInterval(interval time.Duration) TempStorageOption {
+	return func(opts *tempStorageOptions) {
+		opts.purgeInterval = interval
+	}
+}
+
+const tempStorageExt = ".upload"
+
+// DirTempStorage is a TempStorage keeping each upload as a file in a
+// directory. Keys are random, so client file names never reach the disk.
+type DirTempStorage struct {
+	dir  string
+	opts tempStorageOptions
+	now  func() time.Time
+
+	stop      chan struct{}
+	closeOnce sync.Once
+}
+
+// NewDirTempStorage creates a DirTempStorage in dir, creating the directory
+// if needed.
+func NewDirTempStorage(dir string, optFns ...TempStorageOption) (*DirTempStorage, error) {
+	if dir == "" {
+		return nil, errors.New("liveflux: temp storage requires a directory")
+	}
+	options := tempStorageOptions{ttl: 24 * time.Hour}
+	for _, fn := range optFns {
+		if fn != nil {
+			fn(&options)
+		}
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("liveflux: temp storage: %w", err)
+	}
+
+	s := &DirTempStorage{
+		dir:  dir,
+		opts: options,
+		now:  time.Now,
+		stop: make(chan struct{}),
+	}
+	if options.purgeInterval > 0 && options.ttl > 0 {
+		go s.janitor(options.purgeInterval)
+	}
+	return s, nil
+}
+
+// Store implements TempStorage.
+func (s *DirTempStorage) Store(ctx context.Context, r io.Reader) (string, int64, error) {
+	if err := ctx.Err(); err != nil {
+		return "", 0, err
+	}
+	key, err := randomKey()
+	if err != nil {
+		return "", 0, err
+	}
+	path := s.path(key)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return "", 0, err
+	}
+
+	src := r
+	if s.opts.maxFileSize > 0 {
+		src = io.LimitReader(r, s.opts.maxFileSize+1)
+	}
+	size, err := io.Copy(f, src)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && s.opts.maxFileSize > 0 && size > s.opts.maxFileSize {
+		err = ErrUploadTooLarge
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return "", 0, err
+	}
+	// Stamp the write time explicitly so Cleanup follows the storage clock.
+	now := s.now()
+	_ = os.Chtimes(path, now, now)
+	return key, size, nil
+}
+
+// Open implements TempStorage.
+func (s *DirTempStorage) Open(key string) (io.ReadCloser, error) {
+	if !validKey(key) {
+		return nil, fmt.Errorf("liveflux: invalid upload key %q", key)
+	}
+	return os.Open(s.path(key))
+}
+
+// Remove implements TempStorage.
+func (s *DirTempStorage) Remove(key string) error {
+	if !validKey(key) {
+		return fmt.Errorf("liveflux: invalid upload key %q", key)
+	}
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// Cleanup implements TempStorage.
+func (s *DirTempStorage) Cleanup(ctx context.Context, maxAge time.Duration) (int, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return 0, err
+	}
+	cutoff := s.now().Add(-maxAge)
+	removed := 0
+	for _, entry := range entries {
+		if err := ctx.Err(); err != nil {
+			return removed, err
+		}
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), tempStorageExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(s.dir, entry.Name())); err == nil {
+			removed++
+		}
+	}
+	return removed, nil
+}
+
+// Close stops the purge goroutine. It is safe to call more than once.
+func (s *DirTempStorage) Close() error {
+	s.closeOnce.Do(func() { close(s.stop) })
+	return nil
+}
+
+func (s *DirTempStorage) path(key string) string {
+	return filepath.Join(s.dir, key+tempStorageExt)
+}
+
+func (s *DirTempStorage) janitor(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := s.Cleanup(context.Background(), s.opts.ttl); err != nil {
+				fmt.Printf("liveflux: temp storage cleanup error: %v\n", err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func randomKey() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// validKey keeps keys from state or clients from escaping the directory.
+func validKey(key string) bool {
+	if len(key) != 32 {
+		return false
+	}
+	_, err := hex.DecodeString(key)
+	return err == nil
+}
+
+var (
+	tempStorageOnce sync.Once
+	tempStorage     TempStorage
+	tempStorageErr  error
+)
+
+// defaultTempStorage returns the storage used when Handler.Uploads is nil:
+// a DirTempStorage under os.TempDir() purging orphans older than 24 hours.
+func defaultTempStorage() (TempStorage, error) {
+	tempStorageOnce.Do(func() {
+		dir := filepath.Join(os.TempDir(), "liveflux-uploads")
+		s, err := NewDirTempStorage(dir, WithTempStoragePurgeInterval(time.Hour))
+		if err != nil {
+			tempStorageErr = err
+			return
+		}
+		tempStorage = s
+	})
+	return tempStorage, tempStorageErr
+}
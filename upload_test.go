@@ -0,0 +1,237 @@
+package liveflux
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dracory/hb"
+)
+
+// uploadComp reads files through a typed input and through Uploads.
+type uploadComp struct {
+	Base
+	Summary string
+	Avatar  *UploadedFile
+}
+
+type uploadInput struct {
+	Title  string          `flux:"title"`
+	Avatar *UploadedFile   `flux:"avatar"`
+	Docs   []*UploadedFile `flux:"docs"`
+}
+
+func (c *uploadComp) GetKind() string                                { return "test.upload-comp" }
+func (c *uploadComp) Mount(context.Context, map[string]string) error { return nil }
+func (c *uploadComp) Handle(ctx context.Context, action string, data url.Values) error {
+	return DispatchAction(ctx, c, action, data)
+}
+func (c *uploadComp) Render(context.Context) hb.TagInterface {
+	return c.Root(hb.Text("summary=" + c.Summary))
+}
+
+func (c *uploadComp) ActionAttach(ctx context.Context, in uploadInput) error {
+	if in.Avatar == nil {
+		return errors.New("missing avatar")
+	}
+	r, err := in.Avatar.Open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = r.Close() }()
+	body, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	c.Avatar = in.Avatar
+	c.Summary = fmt.Sprintf("%s:%s:%s:%d:%s:docs=%d:ctx=%d",
+		in.Title, in.Avatar.Filename, in.Avatar.ContentType, in.Avatar.Size, body, len(in.Docs), len(Uploads(ctx, "docs")))
+	return nil
+}
+
+func (c *uploadComp) ActionFail(context.Context, uploadInput) error {
+	return errors.New("boom")
+}
+
+type uploadPart struct {
+	field, filename, body string
+}
+
+func postMultipart(h http.Handler, fields url.Values, files ...uploadPart) *httptest.ResponseRecorder {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	for key, vals := range fields {
+		for _, v := range vals {
+			_ = mw.WriteField(key, v)
+		}
+	}
+	for _, f := range files {
+		part, _ := mw.CreateFormFile(f.field, f.filename)
+		_, _ = part.Write([]byte(f.body))
+	}
+	_ = mw.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/liveflux", &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func storedFiles(t *testing.T, dir string) []string {
+	t.Helper()
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+tempStorageExt))
+	if err != nil {
+		t.Fatalf("glob: %v", err)
+	}
+	return matches
+}
+
+func newUploadHandler(t *testing.T, opts ...TempStorageOption) (*Handler, string, string) {
+	t.Helper()
+	dir := t.TempDir()
+	storage, err := NewDirTempStorage(dir, opts...)
+	if err != nil {
+		t.Fatalf("NewDirTempStorage: %v", err)
+	}
+	h := NewHandler(NewMemoryStore())
+	h.Uploads = storage
+	kind := registerTestKind(t, &uploadComp{})
+	return h, kind, dir
+}
+
+func TestHandler_Uploads(t *testing.T) {
+	h, kind, dir := newUploadHandler(t)
+
+	rec := postForm(h, url.Values{FormComponentKind: {kind}})
+	id := extractAttr(t, rec.Body.String(), DataFluxComponentID)
+
+	fields := url.Values{FormComponentKind: {kind}, FormComponentID: {id}, FormAction: {"attach"}, "title": {"Me"}}
+	rec = postMultipart(h, fields,
+		uploadPart{"avatar", `C:\photos\me.txt`, "hello"},
+		uploadPart{"docs", "a.txt", "a"},
+		uploadPart{"docs", "b.txt", "b"},
+	)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d %q", rec.Code, rec.Body.String())
+	}
+	if want := "summary=Me:me.txt:application/octet-stream:5:hello:docs=2:ctx=2"; !strings.Contains(rec.Body.String(), want) {
+		t.Fatalf("expected %q in %q", want, rec.Body.String())
+	}
+	if got := len(storedFiles(t, dir)); got != 3 {
+		t.Fatalf("expected successful uploads to stay in temp storage, got %d files", got)
+	}
+
+	// The file kept in component state can be moved into place
+	c, ok := h.Store.Get(id)
+	if !ok {
+		t.Fatalf("expected component %s in store", id)
+	}
+	avatar := c.(*uploadComp).Avatar
+	target := filepath.Join(t.TempDir(), "avatar.txt")
+	if err := avatar.MoveTo(target); err != nil {
+		t.Fatalf("MoveTo: %v", err)
+	}
+	if b, _ := os.ReadFile(target); string(b) != "hello" {
+		t.Fatalf("expected moved contents, got %q", b)
+	}
+	if got := len(storedFiles(t, dir)); got != 2 {
+		t.Fatalf("expected MoveTo to remove the temp file, got %d files", got)
+	}
+}
+
+func TestHandler_UploadsRemovedWhenRequestFails(t *testing.T) {
+	h, kind, dir := newUploadHandler(t)
+
+	rec := postForm(h, url.Values{FormComponentKind: {kind}})
+	id := extractAttr(t, rec.Body.String(), DataFluxComponentID)
+
+	fields := url.Values{FormComponentKind: {kind}, FormComponentID: {id}, FormAction: {"fail"}}
+	rec = postMultipart(h, fields, uploadPart{"avatar", "me.txt", "hello"})
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d %q", rec.Code, rec.Body.String())
+	}
+	if got := storedFiles(t, dir); len(got) != 0 {
+		t.Fatalf("expected uploads of failed requests to be removed, got %v", got)
+	}
+}
+
+func TestHandler_UploadTooLarge(t *testing.T) {
+	h, kind, dir := newUploadHandler(t, WithTempStorageMaxFileSize(4))
+	fields := url.Values{FormComponentKind: {kind}}
+
+	rec := postMultipart(h, fields, uploadPart{"avatar", "me.txt", "hello"})
+	if rec.Code != http.StatusRequestEntityTooLarge || rec.Body.String() != "upload too large" {
+		t.Fatalf("expected per-file limit to answer 413, got %d %q", rec.Code, rec.Body.String())
+	}
+	if got := storedFiles(t, dir); len(got) != 0 {
+		t.Fatalf("expected no files to be kept, got %v", got)
+	}
+
+	h.MaxUploadSize = 64
+	rec = postMultipart(h, fields, uploadPart{"avatar", "me.txt", strings.Repeat("x", 256)})
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected request limit to answer 413, got %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDirTempStorage(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewDirTempStorage(dir)
+	if err != nil {
+		t.Fatalf("NewDirTempStorage: %v", err)
+	}
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	oldKey, _, err := s.Store(context.Background(), strings.NewReader("old"))
+	if err != nil {
+		t.Fatalf("Store: %v", err)
+	}
+	now = now.Add(2 * time.Hour)
+	newKey, size, err := s.Store(context.Background(), strings.NewReader("new"))
+	if err != nil || size != 3 {
+		t.Fatalf("Store: %d %v", size, err)
+	}
+
+	removed, err := s.Cleanup(context.Background(), time.Hour)
+	if err != nil || removed != 1 {
+		t.Fatalf("expected one orphan to be purged, got %d %v", removed, err)
+	}
+	if _, err := s.Open(oldKey); err == nil {
+		t.Fatalf("expected purged entry to be gone")
+	}
+	r, err := s.Open(newKey)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	b, _ := io.ReadAll(r)
+	_ = r.Close()
+	if string(b) != "new" {
+		t.Fatalf("unexpected contents %q", b)
+	}
+
+	if err := s.Remove(newKey); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	if err := s.Remove(newKey); err != nil {
+		t.Fatalf("expected removing a missing key to succeed, got %v", err)
+	}
+
+	for _, key := range []string{"", "../../etc/passwd", strings.Repeat("z", 32)} {
+		if _, err := s.Open(key); err == nil {
+			t.Errorf("expected key %q to be rejected", key)
+		}
+	}
+}
@@ -0,0 +1,349 @@
+package liveflux
+
+import (
+	"errors"
+	"fmt"
+	"net/mail"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// Rule checks a single value and returns an error message, or "" when the
+// value is valid. Rules other than Required accept empty values, so
+// optional fields only need Required when they must be present.
+type Rule func(value any) string
+
+// RuleFactory builds a Rule from the parameter of a `validate` tag entry,
+// e.g. "3" for "min=3". Register factories with RegisterRule.
+type RuleFactory func(param string) (Rule, error)
+
+var (
+	rulesMu sync.RWMutex
+	rules   = map[string]RuleFactory{
+		"required": func(string) (Rule, error) { return Required(), nil },
+		"email":    func(string) (Rule, error) { return Email(), nil },
+		"min":      numberRule(Min),
+		"max":      numberRule(Max),
+		"regex": func(param string) (Rule, error) {
+			re, err := regexp.Compile(param)
+			if err != nil {
+				return nil, err
+			}
+			return Matches(re), nil
+		},
+	}
+)
+
+// RegisterRule makes a custom rule available to `validate` struct tags.
+// Registering an existing name replaces it.
+func RegisterRule(name string, factory RuleFactory) {
+	rulesMu.Lock()
+	defer rulesMu.Unlock()
+	rules[name] = factory
+}
+
+// Validator collects field checks through a fluent API:
+//
+//	err := liveflux.NewValidator().
+//		Field("email", in.Email, liveflux.Required(), liveflux.Email()).
+//		Field("age", in.Age, liveflux.Min(18)).
+//		Validate()
+//
+// Validate returns ValidationErrors, which the handler turns into an
+// ErrorBag and a re-render when returned from an action.
+type Validator struct {
+	errs ValidationErrors
+	err  error // misconfiguration, e.g. an unknown tag rule
+}
+
+// NewValidator returns an empty Validator.
+func NewValidator() *Validator {
+	return &Validator{}
+}
+
+// Field runs rules against value and records the first failure under field.
+func (v *Validator) Field(field string, value any, fieldRules ...Rule) *Validator {
+	for _, rule := range fieldRules {
+		if msg := rule(value); msg != "" {
+			v.errs = append(v.errs, FieldError{Field: field, Message: msg})
+			break
+		}
+	}
+	return v
+}
+
+// Struct validates the `validate` tags of a struct (see ValidateStruct) and
+// records any failures.
+func (v *Validator) Struct(s any) *Validator {
+	err := ValidateStruct(s)
+	var verrs ValidationErrors
+	switch {
+	case errors.As(err, &verrs):
+		v.errs = append(v.errs, verrs...)
+	case err != nil && v.err == nil:
+		v.err = err
+	}
+	return v
+}
+
+// Validate returns the collected failures as ValidationErrors, or nil. A
+// misconfigured struct tag is returned as a plain error instead.
+func (v *Validator) Validate() error {
+	if v.err != nil {
+		return v.err
+	}
+	if len(v.errs) == 0 {
+		return nil
+	}
+	return v.errs
+}
+
+// ValidateStruct checks the `validate` tags of s, a struct or struct
+// pointer. Rules are comma separated, with parameters after "=":
+//
+//	type SignupInput struct {
+//		Email string `flux:"email" validate:"required,email"`
+//		Name  string `flux:"name" validate:"required,min=2,max=50"`
+//		Code  string `flux:"code" validate:"regex=^[A-Z]{3}$"`
+//	}
+//
+// Field names follow the `flux` tag, as in DecodeForm, and nested structs
+// and slices of structs are validated with dotted paths ("items.0.qty").
+// A regex containing commas must be registered as a custom rule.
+func ValidateStruct(s any) error {
+	rv := reflect.ValueOf(s)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return fmt.Errorf("liveflux: ValidateStruct requires a struct, got nil %T", s)
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return fmt.Errorf("liveflux: ValidateStruct requires a struct, got %T", s)
+	}
+	v := NewValidator()
+	if err := validateStruct(v, "", rv); err != nil {
+		return err
+	}
+	return v.Validate()
+}
+
+func validateStruct(v *Validator, path string, rv reflect.Value) error {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("flux"), ",")
+		if name == "-" {
+			continue
+		}
+		fv := rv.Field(i)
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			if err := validateStruct(v, path, fv); err != nil {
+				return err
+			}
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fieldPath := joinPath(path, name)
+
+		if tag := field.Tag.Get("validate"); tag != "" {
+			fieldRules, err := parseRules(tag)
+			if err != nil {
+				return fmt.Errorf("liveflux: field %s: %w", fieldPath, err)
+			}
+			v.Field(fieldPath, fv.Interface(), fieldRules...)
+		}
+		if err := validateNested(v, fieldPath, fv); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateNested descends into struct, struct pointer and struct slice fields.
+func validateNested(v *Validator, path string, fv reflect.Value) error {
+	if fv.Type() == timeType {
+		return nil
+	}
+	switch fv.Kind() {
+	case reflect.Ptr:
+		if !fv.IsNil() {
+			return validateNested(v, path, fv.Elem())
+		}
+	case reflect.Struct:
+		return validateStruct(v, path, fv)
+	case reflect.Slice:
+		for i := 0; i < fv.Len(); i++ {
+			if err := validateNested(v, path+"."+strconv.Itoa(i), fv.Index(i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// parseRules resolves a `validate` tag against the registered rules.
+func parseRules(tag string) ([]Rule, error) {
+	rulesMu.RLock()
+	defer rulesMu.RUnlock()
+
+	var out []Rule
+	for _, entry := range strings.Split(tag, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, param, _ := strings.Cut(entry, "=")
+		factory, ok := rules[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown validation rule %q", name)
+		}
+		rule, err := factory(param)
+		if err != nil {
+			return nil, fmt.Errorf("rule %q: %w", name, err)
+		}
+		out = append(out, rule)
+	}
+	return out, nil
+}
+
+// Required fails for zero values, blank strings and empty slices or maps.
+func Required() Rule {
+	return func(value any) string {
+		if isEmptyValue(value) {
+			return "is required"
+		}
+		return ""
+	}
+}
+
+// Min checks the minimum length of a string (in characters), slice or map,
+// or the minimum of a number.
+func Min(n float64) Rule {
+	return func(value any) string {
+		size, isLen, ok := measure(value)
+		switch {
+		case !ok || (isLen && isEmptyValue(value)):
+			return ""
+		case size >= n:
+			return ""
+		case isLen:
+			return fmt.Sprintf("must be at least %s %s", formatNumber(n), unitOf(value))
+		default:
+			return fmt.Sprintf("must be at least %s", formatNumber(n))
+		}
+	}
+}
+
+// Max checks the maximum length of a string (in characters), slice or map,
+// or the maximum of a number.
+func Max(n float64) Rule {
+	return func(value any) string {
+		size, isLen, ok := measure(value)
+		switch {
+		case !ok || size <= n:
+			return ""
+		case isLen:
+			return fmt.Sprintf("must be at most %s %s", formatNumber(n), unitOf(value))
+		default:
+			return fmt.Sprintf("must be at most %s", formatNumber(n))
+		}
+	}
+}
+
+// Email checks that a string is a plain email address.
+func Email() Rule {
+	return func(value any) string {
+		s, ok := value.(string)
+		if !ok || s == "" {
+			return ""
+		}
+		addr, err := mail.ParseAddress(s)
+		if err != nil || addr.Address != s {
+			return "must be a valid email address"
+		}
+		return ""
+	}
+}
+
+// Matches checks that a string matches re.
+func Matches(re *regexp.Regexp) Rule {
+	return func(value any) string {
+		s, ok := value.(string)
+		if !ok || s == "" || re.MatchString(s) {
+			return ""
+		}
+		return "has an invalid format"
+	}
+}
+
+// Custom wraps a function as a Rule; fn returns "" for valid values.
+func Custom(fn func(value any) string) Rule {
+	return Rule(fn)
+}
+
+// numberRule adapts Min/Max to a tag factory.
+func numberRule(build func(float64) Rule) RuleFactory {
+	return func(param string) (Rule, error) {
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", param)
+		}
+		return build(n), nil
+	}
+}
+
+// measure returns the length of strings and collections (isLen true) or the
+// value of numbers. ok is false for other types.
+func measure(value any) (size float64, isLen bool, ok bool) {
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(rv.String())), true, true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(rv.Len()), true, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), false, true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), false, true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), false, true
+	}
+	return 0, false, false
+}
+
+func unitOf(value any) string {
+	if _, ok := value.(string); ok {
+		return "characters"
+	}
+	return "items"
+}
+
+func isEmptyValue(value any) bool {
+	if value == nil {
+		return true
+	}
+	rv := reflect.ValueOf(value)
+	switch rv.Kind() {
+	case reflect.String:
+		return strings.TrimSpace(rv.String()) == ""
+	case reflect.Slice, reflect.Map:
+		return rv.Len() == 0
+	case reflect.Ptr, reflect.Interface:
+		return rv.IsNil()
+	}
+	return rv.IsZero()
+}
+
+func formatNumber(n float64) string {
+	return strconv.FormatFloat(n, 'f', -1, 64)
+}
@@ -0,0 +1,205 @@
+package liveflux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/dracory/hb"
+)
+
+func fieldErrors(t *testing.T, err error) map[string]string {
+	t.Helper()
+	var verrs ValidationErrors
+	if !errors.As(err, &verrs) {
+		t.Fatalf("expected ValidationErrors, got %T %v", err, err)
+	}
+	out := map[string]string{}
+	for _, fe := range verrs {
+		out[fe.Field] = fe.Message
+	}
+	return out
+}
+
+func TestValidator_Rules(t *testing.T) {
+	code := regexp.MustCompile(`^[A-Z]{3}$`)
+	err := NewValidator().
+		Field("name", "  ", Required()).
+		Field("email", "not-an-email", Required(), Email()).
+		Field("bio", "hi", Min(3)).
+		Field("age", 15, Min(18)).
+		Field("tags", []string{"a", "b", "c"}, Max(2)).
+		Field("code", "abc", Matches(code)).
+		Field("even", 3, Custom(func(v any) string {
+			if v.(int)%2 != 0 {
+				return "must be even"
+			}
+			return ""
+		})).
+		Validate()
+
+	got := fieldErrors(t, err)
+	want := map[string]string{
+		"name":  "is required",
+		"email": "must be a valid email address",
+		"bio":   "must be at least 3 characters",
+		"age":   "must be at least 18",
+		"tags":  "must be at most 2 items",
+		"code":  "has an invalid format",
+		"even":  "must be even",
+	}
+	for field, msg := range want {
+		if got[field] != msg {
+			t.Errorf("%s: got %q, want %q", field, got[field], msg)
+		}
+	}
+}
+
+func TestValidator_ValidValuesAndOptionalFields(t *testing.T) {
+	err := NewValidator().
+		Field("email", "a@example.com", Required(), Email()).
+		Field("nickname", "", Min(3), Email()). // empty optional field
+		Field("age", 18, Min(18), Max(99)).
+		Field("score", 9.5, Max(10)).
+		Validate()
+	if err != nil {
+		t.Fatalf("expected no errors, got %v", err)
+	}
+}
+
+type signupItem struct {
+	Qty int `flux:"qty" validate:"min=1"`
+}
+
+type signupInput struct {
+	Email   string       `flux:"email" validate:"required,email"`
+	Name    string       `flux:"name" validate:"required,min=2,max=5"`
+	Code    string       `flux:"code" validate:"regex=^[A-Z]+$"`
+	Items   []signupItem `flux:"items"`
+	Ignored string       `flux:"-" validate:"required"`
+}
+
+func TestValidateStruct(t *testing.T) {
+	in := signupInput{
+		Email: "bad",
+		Name:  "toolong",
+		Code:  "abc",
+		Items: []signupItem{{Qty: 1}, {Qty: 0}},
+	}
+	got := fieldErrors(t, ValidateStruct(&in))
+	for _, field := range []string{"email", "name", "code", "items.1.qty"} {
+		if got[field] == "" {
+			t.Errorf("expected an error for %q, got %v", field, got)
+		}
+	}
+	if len(got) != 4 {
+		t.Fatalf("expected 4 errors, got %v", got)
+	}
+
+	valid := signupInput{Email: "a@example.com", Name: "Ann", Items: []signupItem{{Qty: 2}}}
+	if err := ValidateStruct(valid); err != nil {
+		t.Fatalf("expected valid struct, got %v", err)
+	}
+}
+
+func TestValidateStruct_CustomAndUnknownRules(t *testing.T) {
+	RegisterRule("test-slug", func(string) (Rule, error) {
+		return func(v any) string {
+			if strings.Contains(v.(string), " ") {
+				return "must not contain spaces"
+			}
+			return ""
+		}, nil
+	})
+
+	var slug struct {
+		Slug string `flux:"slug" validate:"test-slug"`
+	}
+	slug.Slug = "a b"
+	if got := fieldErrors(t, ValidateStruct(slug)); got["slug"] != "must not contain spaces" {
+		t.Fatalf("expected custom rule to run, got %v", got)
+	}
+
+	var unknown struct {
+		Name string `validate:"nope"`
+	}
+	err := NewValidator().Struct(unknown).Validate()
+	var verrs ValidationErrors
+	if err == nil || errors.As(err, &verrs) {
+		t.Fatalf("expected a configuration error, got %v", err)
+	}
+}
+
+// validatingComp validates a typed action input.
+type validatingComp struct {
+	Base
+	Email string
+}
+
+type validatingInput struct {
+	Email string `flux:"email" validate:"required,email"`
+	Age   int    `flux:"age"`
+}
+
+func (c *validatingComp) GetKind() string                                { return "test.validating-comp" }
+func (c *validatingComp) Mount(context.Context, map[string]string) error { return nil }
+func (c *validatingComp) Handle(ctx context.Context, action string, data url.Values) error {
+	return DispatchAction(ctx, c, action, data)
+}
+func (c *validatingComp) Render(context.Context) hb.TagInterface {
+	return c.Root(hb.Wrap().
+		Child(hb.Input().Name("email").Class("form-control " + c.InvalidClass("email"))).
+		Child(c.ErrorFor("email")).
+		Child(c.ErrorFor("age")).
+		Child(hb.Text("saved=" + c.Email)))
+}
+
+func (c *validatingComp) ActionSave(_ context.Context, in validatingInput) error {
+	if err := ValidateStruct(in); err != nil {
+		return err
+	}
+	c.Email = in.Email
+	return nil
+}
+
+func TestHandler_ValidationErrorsReRender(t *testing.T) {
+	h := NewHandler(NewMemoryStore())
+	kind := registerTestKind(t, &validatingComp{})
+
+	rec := postForm(h, url.Values{FormComponentKind: {kind}})
+	id := extractAttr(t, rec.Body.String(), DataFluxComponentID)
+	post := func(fields url.Values) string {
+		t.Helper()
+		fields.Set(FormComponentKind, kind)
+		fields.Set(FormComponentID, id)
+		fields.Set(FormAction, "save")
+		rec := postForm(h, fields)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d %q", rec.Code, rec.Body.String())
+		}
+		return rec.Body.String()
+	}
+
+	body := post(url.Values{"email": {"nope"}})
+	if !strings.Contains(body, "must be a valid email address") || !strings.Contains(body, InvalidFieldClass) {
+		t.Fatalf("expected inline validation error, got %q", body)
+	}
+	if !strings.Contains(body, DataFluxError+`="email"`) {
+		t.Fatalf("expected error element to name the field, got %q", body)
+	}
+
+	// Conversion errors from decoding are reported the same way
+	body = post(url.Values{"email": {"a@example.com"}, "age": {"old"}})
+	if !strings.Contains(body, "must be a whole number") || strings.Contains(body, "valid email") {
+		t.Fatalf("expected only the conversion error, got %q", body)
+	}
+
+	body = post(url.Values{"email": {"a@example.com"}})
+	if strings.Contains(body, DataFluxError) || !strings.Contains(body, "saved=a@example.com") {
+		t.Fatalf("expected errors to clear after a valid submit, got %q", body)
+	}
+}
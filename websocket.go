@@ -1,16 +1,20 @@
 package liveflux
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"net/url"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/spf13/cast"
 )
 
 var (
@@ -61,6 +65,67 @@ func WithWebSocketMessageValidator(validator func(*WebSocketMessage) error) WebS
 	}
 }
 
+// WithWebSocketSendQueue sets how many messages may wait to be written to one
+// connection, and what happens when that queue is full. A size <= 0 keeps
+// the default of 256.
+func WithWebSocketSendQueue(size int, policy WebSocketBackpressure) WebSocketOption {
+	return func(opts *websocketOptions) {
+		if size > 0 {
+			opts.sendQueueSize = size
+		}
+		opts.backpressure = policy
+	}
+}
+
+// WithWebSocketPingInterval sets how often the server pings each connection.
+// Keep it below the pong timeout. Zero disables pings. Defaults to 30s.
+func WithWebSocketPingInterval(interval time.Duration) WebSocketOption {
+	return func(opts *websocketOptions) {
+		opts.pingInterval = interval
+	}
+}
+
+// WithWebSocketPongTimeout sets how long a connection may stay silent (no
+// pong or message) before it is closed. Zero disables the deadline.
+// Defaults to 60s.
+func WithWebSocketPongTimeout(timeout time.Duration) WebSocketOption {
+	return func(opts *websocketOptions) {
+		opts.pongTimeout = timeout
+	}
+}
+
+// WithWebSocketReadLimit sets the largest message accepted from a client, in
+// bytes; larger messages close the connection. Zero disables the limit.
+// Defaults to 1 MiB.
+func WithWebSocketReadLimit(limit int64) WebSocketOption {
+	return func(opts *websocketOptions) {
+		opts.readLimit = limit
+	}
+}
+
+// WithWebSocketWriteTimeout sets the deadline for writing one message; a
+// peer that does not accept it in time is disconnected. Zero disables the
+// deadline. Defaults to 10s.
+func WithWebSocketWriteTimeout(timeout time.Duration) WebSocketOption {
+	return func(opts *websocketOptions) {
+		opts.writeTimeout = timeout
+	}
+}
+
+// WithWebSocketReplay sets how many recent updates are kept per component
+// for clients that reconnect, and how long a component's updates are kept
+// after its last use. A client that missed more gets a full render. A size
+// <= 0 keeps none; a retention <= 0 keeps the default of 5 minutes.
+// Defaults to 64 updates.
+func WithWebSocketReplay(size int, retention time.Duration) WebSocketOption {
+	return func(opts *websocketOptions) {
+		opts.replaySize = max(size, 0)
+		if retention > 0 {
+			opts.replayRetention = retention
+		}
+	}
+}
+
 type websocketOptions struct {
 	allowedOrigins   []string
 	csrfCheck        func(*http.Request) error
@@ -68,13 +133,30 @@ type websocketOptions struct {
 	rateLimitMax     int
 	rateLimitWindow  time.Duration
 	messageValidator func(*WebSocketMessage) error
+	sendQueueSize    int
+	backpressure     WebSocketBackpressure
+	pingInterval     time.Duration
+	pongTimeout      time.Duration
+	readLimit        int64
+	writeTimeout     time.Duration
+	replaySize       int
+	replayRetention  time.Duration
 }
 
 // WebSocketOption configures optional behaviour for the WebSocket handler.
 type WebSocketOption func(*websocketOptions)
 
 func defaultWebSocketOptions() websocketOptions {
-	return websocketOptions{}
+	return websocketOptions{
+		sendQueueSize:   256,
+		backpressure:    WebSocketDropMessage,
+		pingInterval:    30 * time.Second,
+		pongTimeout:     60 * time.Second,
+		readLimit:       1 << 20,
+		writeTimeout:    10 * time.Second,
+		replaySize:      64,
+		replayRetention: 5 * time.Minute,
+	}
 }
 
 // WithWebSocketAllowedOrigins restricts allowed WebSocket upgrade origins to the
@@ -97,17 +179,39 @@ func WithWebSocketAllowedOrigins(origins ...string) WebSocketOption {
 
 // WebSocketMessage represents a message sent over WebSocket.
 type WebSocketMessage struct {
-	Type        string          `json:"type"`             // message type (e.g., "action", "update", "error")
-	ComponentID string          `json:"componentID"`      // ID of the target component
-	Action      string          `json:"action,omitempty"` // action name (for action messages)
-	Data        json.RawMessage `json:"data,omitempty"`   // message payload
+	Type        string `json:"type"`             // message type (e.g., "action", "update", "error")
+	ComponentID string `json:"componentID"`      // ID of the target component
+	Action      string `json:"action,omitempty"` // action name (for action messages)
+	// Kind is the component's kind. The first message for a component on a
+	// connection must carry it; it is checked per Handler.KindCheck.
+	Kind string          `json:"kind,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"` // message payload
+	// Version orders the update, events and redirect messages sent to all
+	// of a component's clients. A reconnecting client sends the last version it
+	// saw in its "init" message to get what it missed.
+	Version uint64 `json:"version,omitempty"`
+}
+
+// Values flattens the JSON object in Data into form values, so HandleWS can
+// share action code with Handle:
+//
+//	values, err := msg.Values()
+//	if err != nil {
+//		return nil, err
+//	}
+//	return nil, liveflux.DispatchAction(ctx, c, msg.Action, values)
+//
+// Use DecodeJSON to decode Data straight into a struct.
+func (m *WebSocketMessage) Values() (url.Values, error) {
+	return jsonToValues(m.Data)
 }
 
 // WebSocketComponent is the interface that components can implement to handle WebSocket messages.
 type WebSocketComponent interface {
 	ComponentInterface
 	// HandleWS handles WebSocket messages for this component.
-	// It should return a response message or an error.
+	// It should return a response message or an error. The response goes
+	// to the sender only and is not kept for clients that reconnect.
 	HandleWS(ctx context.Context, message *WebSocketMessage) (any, error)
 }
 
@@ -116,13 +220,18 @@ type WebSocketHandler struct {
 	*Handler
 	upgrader         websocket.Upgrader
 	mu               sync.RWMutex
-	clients          map[string]map[*websocket.Conn]bool  // componentID -> connections
+	clients          map[string]map[clientConn]bool       // componentID -> connections
+	subscriptions    map[string]func()                    // componentID -> PubSub unsubscribe
 	constructors     map[string]func() ComponentInterface // kind -> constructor
 	allowedOrigins   []string
 	csrfCheck        func(*http.Request) error
 	requireTLS       bool
 	rateLimiter      *wsRateLimiter
 	messageValidator func(*WebSocketMessage) error
+	conns            map[clientConn]bool // open connections
+	connOptions      websocketOptions
+	stats            wsCounters
+	replay           *wsReplay
 }
 
 // NewWebSocketHandler creates a new WebSocketHandler.
@@ -137,13 +246,17 @@ func NewWebSocketHandler(store Store, optFns ...WebSocketOption) *WebSocketHandl
 	h := &WebSocketHandler{
 		Handler:          NewHandler(store),
 		upgrader:         DefaultWebSocketUpgrader,
-		clients:          make(map[string]map[*websocket.Conn]bool),
+		clients:          make(map[string]map[clientConn]bool),
+		subscriptions:    make(map[string]func()),
 		constructors:     make(map[string]func() ComponentInterface),
 		allowedOrigins:   append([]string(nil), options.allowedOrigins...),
 		csrfCheck:        options.csrfCheck,
 		requireTLS:       options.requireTLS,
 		rateLimiter:      newWSRateLimiter(options.rateLimitMax, options.rateLimitWindow),
 		messageValidator: options.messageValidator,
+		conns:            make(map[clientConn]bool),
+		connOptions:      options,
+		replay:           newWSReplay(options.replaySize, options.replayRetention),
 	}
 
 	defaultCheck := DefaultWebSocketUpgrader.CheckOrigin
@@ -190,46 +303,64 @@ func (h *WebSocketHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Server-Sent Events streams and polls for clients without WebSockets
+	if r.Method == http.MethodGet && r.URL.Query().Has(FormStream) {
+		if acceptsEventStream(r) {
+			h.handleSSE(w, r)
+		} else {
+			h.handlePoll(w, r)
+		}
+		return
+	}
+
 	// Fall back to regular HTTP handler
 	h.Handler.ServeHTTP(w, r)
 }
 
-// handleWebSocket handles a WebSocket connection.
-func (h *WebSocketHandler) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+// allowConnection applies the TLS, rate limit and CSRF checks to a request
+// opening a connection. Returns false if an error was written.
+func (h *WebSocketHandler) allowConnection(w http.ResponseWriter, r *http.Request, rateLimit bool) bool {
 	if h.requireTLS && r.TLS == nil {
 		http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
-		return
+		return false
 	}
 
-	if h.rateLimiter != nil {
+	if rateLimit && h.rateLimiter != nil {
 		ip := clientIP(r)
 		if !h.rateLimiter.Allow(ip) {
 			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
-			return
+			return false
 		}
 	}
 
 	if h.csrfCheck != nil {
 		if err := h.csrfCheck(r); err != nil {
 			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
-			return
+			return false
 		}
 	}
+	return true
+}
 
-	conn, err := h.upgrader.Upgrade(w, r, nil)
+// handleWebSocket handles a WebSocket connection.
+func (h *WebSocketHandler) handleWebSocket(w http.ResponseWriter, r *http.Request) {
+	if !h.allowConnection(w, r, true) {
+		return
+	}
+
+	raw, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
 		// The upgrader has already written an error response
 		return
 	}
-	defer func() {
-		_ = conn.Close()
-	}()
+	conn := h.openConn(raw)
+	defer h.closeConn(conn)
 
 	// Set up a context for this connection
-	ctx, cancel := context.WithCancel(r.Context())
+	ctx, cancel := context.WithCancel(contextWithStore(contextWithRequest(r.Context(), r), h.Store))
 	defer cancel()
 
-	// Read the initial message to learn the componentID and process it
+	// Read the initial message to learn the first componentID and process it
 	var firstMsg WebSocketMessage
 	if err := conn.ReadJSON(&firstMsg); err != nil {
 		if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
@@ -237,8 +368,9 @@ func (h *WebSocketHandler) handleWebSocket(w http.ResponseWriter, r *http.Reques
 		}
 		return
 	}
+	conn.extendReadDeadline()
 	if firstMsg.ComponentID == "" {
-		h.sendError(conn, "missing component ID", http.StatusBadRequest)
+		h.sendError(conn, "", "missing component ID", http.StatusBadRequest)
 		return
 	}
 
@@ -246,13 +378,48 @@ func (h *WebSocketHandler) handleWebSocket(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	// Register and ensure cleanup
-	componentID := firstMsg.ComponentID
-	h.registerConnection(componentID, conn)
-	defer h.unregisterConnection(componentID, conn)
+	// One connection carries messages for any number of components. Only
+	// this goroutine touches joined.
+	joined := map[string]bool{}
+	defer func() {
+		for componentID := range joined {
+			h.leave(componentID, conn)
+		}
+	}()
+	route := func(msg *WebSocketMessage, async bool) {
+		switch {
+		case msg.Type == "leave":
+			if joined[msg.ComponentID] {
+				delete(joined, msg.ComponentID)
+				h.leave(msg.ComponentID, conn)
+			}
+			return
+		case !joined[msg.ComponentID]:
+			if len(joined) >= maxComponentsPerConnection {
+				h.sendError(conn, msg.ComponentID, "too many components", http.StatusTooManyRequests)
+				return
+			}
+			// Only join components the connection's request may act on
+			c, ok := h.authorize(ctx, conn, msg.ComponentID)
+			if !ok {
+				return
+			}
+			if !h.kindAllowed(ctx, msg.Kind, c) {
+				h.sendError(conn, msg.ComponentID, "component kind does not match the requested component", http.StatusBadRequest)
+				return
+			}
+			joined[msg.ComponentID] = true
+			h.join(msg.ComponentID, conn)
+		}
+		if async {
+			go h.handleMessage(ctx, conn, msg)
+			return
+		}
+		h.handleMessage(ctx, conn, msg)
+	}
 
 	// Handle the initial message
-	h.handleMessage(ctx, conn, &firstMsg)
+	route(&firstMsg, false)
 
 	// Continue handling subsequent messages
 	for {
@@ -264,9 +431,11 @@ func (h *WebSocketHandler) handleWebSocket(w http.ResponseWriter, r *http.Reques
 			break
 		}
 
-		// Guard against accidental mismatched component IDs from the client
+		conn.extendReadDeadline()
+
+		// Messages without a component ID address the first component
 		if msg.ComponentID == "" {
-			msg.ComponentID = componentID
+			msg.ComponentID = firstMsg.ComponentID
 		}
 
 		if !h.validateMessage(conn, &msg) {
@@ -274,36 +443,100 @@ func (h *WebSocketHandler) handleWebSocket(w http.ResponseWriter, r *http.Reques
 		}
 
 		// Handle the message in a goroutine
-		go h.handleMessage(ctx, conn, &msg)
+		route(&msg, true)
 	}
 }
 
-// handleMessage processes a WebSocket message.
-func (h *WebSocketHandler) handleMessage(ctx context.Context, conn *websocket.Conn, msg *WebSocketMessage) {
-	h.mu.RLock()
-	defer h.mu.RUnlock()
+// maxComponentsPerConnection bounds the components one connection carries.
+const maxComponentsPerConnection = 256
+
+// authorize returns the component a connection asks to receive messages
+// for, found with the connection's request context so a SessionStore only
+// yields the session's own instances. Otherwise an error message is sent.
+func (h *WebSocketHandler) authorize(ctx context.Context, conn clientConn, componentID string) (ComponentInterface, bool) {
+	c, ok := h.storeGet(ctx, componentID)
+	if !ok || c == nil {
+		h.sendError(conn, componentID, "component not found", http.StatusNotFound)
+		return nil, false
+	}
+	return c, true
+}
+
+// join registers conn for a component and subscribes the component to its
+// channels when this is its first connection.
+func (h *WebSocketHandler) join(componentID string, conn clientConn) {
+	if h.registerConnection(componentID, conn) {
+		h.subscribeChannels(componentID)
+	}
+}
+
+// leave unregisters conn for a component and cancels the component's
+// subscriptions when this was its last connection.
+func (h *WebSocketHandler) leave(componentID string, conn clientConn) {
+	if h.unregisterConnection(componentID, conn) {
+		h.unsubscribeChannels(componentID)
+	}
+}
+
+// handleMessage processes a WebSocket message. Components implementing
+// WebSocketComponent handle it in HandleWS; for all others, action messages
+// run through Handle and Render exactly like an HTTP action request.
+func (h *WebSocketHandler) handleMessage(ctx context.Context, conn *wsConn, msg *WebSocketMessage) {
+	// Serialize access to the component across HTTP and WebSocket traffic
+	unlock, err := h.lockComponent(ctx, msg.ComponentID)
+	if err != nil {
+		h.sendError(conn, msg.ComponentID, "component busy", http.StatusConflict)
+		return
+	}
+	defer unlock()
 
 	// Get the component from the store
-	c, found := h.Store.Get(msg.ComponentID)
+	c, found := h.storeGet(ctx, msg.ComponentID)
 	if !found {
-		h.sendError(conn, "component not found", http.StatusNotFound)
+		h.sendError(conn, msg.ComponentID, "component not found", http.StatusNotFound)
 		return
 	}
 
-	// Check if the component supports WebSocket
+	if msg.Type == "init" && msg.Version > 0 {
+		h.resume(ctx, conn, c, msg.Version)
+	}
+
 	wsComp, ok := c.(WebSocketComponent)
 	if !ok {
-		h.sendError(conn, "component does not support WebSocket", http.StatusBadRequest)
+		h.handleAction(ctx, conn, msg, c)
+		return
+	}
+
+	if err := afterHydrate(ctx, c); err != nil {
+		fmt.Printf("liveflux: hydrate error: %v\n", err)
+		h.sendError(conn, msg.ComponentID, "hydrate error", http.StatusInternalServerError)
 		return
 	}
 
 	// Handle the message
-	resp, wsErr := wsComp.HandleWS(ctx, msg)
+	var resp any
+	wsErr := runAction(ctx, c, msg.Action, nil, func() error {
+		var err error
+		resp, err = wsComp.HandleWS(ctx, msg)
+		return err
+	})
+	if errors.Is(wsErr, ErrUnknownAction) {
+		h.sendError(conn, msg.ComponentID, "unknown action", http.StatusBadRequest)
+		return
+	}
 	if wsErr != nil {
-		h.sendError(conn, wsErr.Error(), http.StatusInternalServerError)
+		h.sendError(conn, msg.ComponentID, wsErr.Error(), http.StatusInternalServerError)
 		return
 	}
 
+	// Persist after mutation, as the HTTP handler does
+	if err := h.save(ctx, c); err != nil {
+		fmt.Printf("liveflux: dehydrate error: %v\n", err)
+		h.sendError(conn, msg.ComponentID, "dehydrate error", http.StatusInternalServerError)
+		return
+	}
+	h.publishBroadcasts(ctx, c)
+
 	// Send the response
 	if resp != nil {
 		if err := conn.WriteJSON(resp); err != nil {
@@ -313,19 +546,87 @@ func (h *WebSocketHandler) handleMessage(ctx context.Context, conn *websocket.Co
 	}
 }
 
+// handleAction runs an action message through the HTTP handler's action
+// path and translates its response into messages: "error" for the sender,
+// or "redirect", or "update" followed by "events" for all of the
+// component's clients, versioned and kept for clients that reconnect. The
+// data object becomes the form values, so models and liveflux_components
+// work as over HTTP. The caller holds the component's lock.
+func (h *WebSocketHandler) handleAction(ctx context.Context, conn *wsConn, msg *WebSocketMessage, c ComponentInterface) {
+	switch msg.Type {
+	case "init":
+		// The connection is registered; there is nothing to run
+		return
+	case "action":
+	default:
+		h.sendError(conn, msg.ComponentID, "unknown message type", http.StatusBadRequest)
+		return
+	}
+
+	values, err := msg.Values()
+	if err != nil {
+		h.sendError(conn, msg.ComponentID, "invalid data", http.StatusBadRequest)
+		return
+	}
+	values.Set(FormComponentKind, c.GetKind())
+	values.Set(FormComponentID, c.GetID())
+	values.Set(FormAction, msg.Action)
+	r := &http.Request{Method: http.MethodPost, Header: http.Header{}, Form: values, PostForm: values}
+
+	w := &wsResponse{header: http.Header{}, status: http.StatusOK}
+	h.handleLoaded(ctx, w, r, c, msg.Action)
+
+	if w.status >= http.StatusBadRequest {
+		h.sendError(conn, msg.ComponentID, w.body.String(), w.status)
+		return
+	}
+	if redirect := w.header.Get(RedirectHeader); redirect != "" {
+		data, err := json.Marshal(redirectData{URL: redirect, After: cast.ToInt(w.header.Get(RedirectAfterHeader))})
+		if err != nil {
+			return
+		}
+		_ = h.sendVersioned(WebSocketMessage{Type: "redirect", ComponentID: c.GetID(), Data: data})
+		return
+	}
+
+	if err := h.sendUpdate(c.GetID(), w.body.String()); err != nil {
+		return
+	}
+	if events := w.header.Get(EventsHeader); events != "" {
+		data := []byte(`{"events":` + events + `}`)
+		_ = h.sendVersioned(WebSocketMessage{Type: "events", ComponentID: c.GetID(), Data: data})
+	}
+}
+
+// wsResponse collects the response the HTTP action path writes for a
+// WebSocket action.
+type wsResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (w *wsResponse) Header() http.Header         { return w.header }
+func (w *wsResponse) Write(b []byte) (int, error) { return w.body.Write(b) }
+func (w *wsResponse) WriteHeader(status int)      { w.status = status }
+
 // registerConnection registers a WebSocket connection for a component.
-func (h *WebSocketHandler) registerConnection(componentID string, conn *websocket.Conn) {
+// Returns true for the component's first connection.
+func (h *WebSocketHandler) registerConnection(componentID string, conn clientConn) bool {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
-	if h.clients[componentID] == nil {
-		h.clients[componentID] = make(map[*websocket.Conn]bool)
+	first := h.clients[componentID] == nil
+	if first {
+		h.clients[componentID] = make(map[clientConn]bool)
 	}
 	h.clients[componentID][conn] = true
+	return first
 }
 
-// unregisterConnection removes a WebSocket connection.
-func (h *WebSocketHandler) unregisterConnection(componentID string, conn *websocket.Conn) {
+// unregisterConnection removes a WebSocket connection. Returns true when it
+// was the component's last connection.
+func (h *WebSocketHandler) unregisterConnection(componentID string, conn clientConn) bool {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
@@ -333,11 +634,14 @@ func (h *WebSocketHandler) unregisterConnection(componentID string, conn *websoc
 		delete(connections, conn)
 		if len(connections) == 0 {
 			delete(h.clients, componentID)
+			return true
 		}
 	}
+	return false
 }
 
-// Broadcast sends a message to all connected clients for a component.
+// Broadcast sends a message to all connected clients for a component. It is
+// not versioned or kept for clients that reconnect.
 func (h *WebSocketHandler) Broadcast(componentID string, message interface{}) error {
 	h.mu.RLock()
 	defer h.mu.RUnlock()
@@ -361,27 +665,192 @@ func (h *WebSocketHandler) Broadcast(componentID string, message interface{}) er
 	return nil
 }
 
-// sendError sends an error message to the client.
-func (h *WebSocketHandler) sendError(conn *websocket.Conn, message string, code int) {
+// broadcastQueueSize bounds the broadcasts waiting for one component.
+const broadcastQueueSize = 64
+
+// subscribeChannels subscribes the component to the PubSub channels it
+// declares (see ChannelSubscriber). Broadcasts are delivered in order by one
+// goroutine per component, outside the publisher's call stack, so a
+// publisher holding a component lock cannot deadlock its own delivery.
+//
+// Only components a connection has joined are subscribed, so they are read
+// without a request (see storeGetDetached); the subscription outlives the
+// connection that opened it.
+func (h *WebSocketHandler) subscribeChannels(componentID string) {
+	ctx := contextWithStore(context.Background(), h.Store)
+	c, ok := h.storeGetDetached(componentID)
+	if !ok || c == nil {
+		return
+	}
+	cs, ok := c.(ChannelSubscriber)
+	if !ok {
+		return
+	}
+	channels := cs.Channels()
+	if len(channels) == 0 {
+		return
+	}
+
+	queue := make(chan []byte, broadcastQueueSize)
+	done := make(chan struct{})
+	var cancels []func()
+	for _, channel := range channels {
+		cancel, err := h.pubSub().Subscribe(ctx, channel, func(payload []byte) {
+			select {
+			case queue <- payload:
+			case <-done:
+			default:
+				h.broadcastError(ctx, channel, fmt.Errorf("%w for '%s'", ErrBroadcastDropped, componentID))
+			}
+		})
+		if err != nil {
+			h.broadcastError(ctx, channel, fmt.Errorf("liveflux: subscribe: %w", err))
+			continue
+		}
+		cancels = append(cancels, cancel)
+	}
+	unsubscribe := func() {
+		for _, cancel := range cancels {
+			cancel()
+		}
+		close(done)
+	}
+
+	go func() {
+		for {
+			select {
+			case payload := <-queue:
+				h.deliverBroadcast(ctx, componentID, payload)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.clients[componentID] == nil {
+		// The last connection closed while subscribing
+		unsubscribe()
+		return
+	}
+	h.subscriptions[componentID] = unsubscribe
+}
+
+// unsubscribeChannels cancels the component's PubSub subscriptions.
+func (h *WebSocketHandler) unsubscribeChannels(componentID string) {
+	h.mu.Lock()
+	unsubscribe := h.subscriptions[componentID]
+	delete(h.subscriptions, componentID)
+	h.mu.Unlock()
+	if unsubscribe != nil {
+		unsubscribe()
+	}
+}
+
+// deliverBroadcast runs the component's On* listeners for a broadcast
+// event, persists and re-renders it, and forwards the event to its clients.
+func (h *WebSocketHandler) deliverBroadcast(ctx context.Context, componentID string, payload []byte) {
+	var msg broadcastMessage
+	if err := json.Unmarshal(payload, &msg); err != nil {
+		fmt.Printf("liveflux: invalid broadcast: %v\n", err)
+		return
+	}
+
+	unlock, err := h.lockComponent(ctx, componentID)
+	if err != nil {
+		fmt.Printf("liveflux: lock error: %v\n", err)
+		return
+	}
+	defer unlock()
+
+	c, ok := h.storeGetDetached(componentID)
+	if !ok || c == nil {
+		return
+	}
+
+	events := []Event{msg.Event}
+	listeners, addressed := eventListeners(c, events)
+	if len(addressed) > 0 {
+		if err := afterHydrate(ctx, c); err != nil {
+			fmt.Printf("liveflux: hydrate error: %v\n", err)
+			return
+		}
+		if err := triggerEvents(ctx, listeners, events, addressed); err != nil {
+			fmt.Printf("liveflux: event listener error in '%s': %v\n", c.GetKind(), err)
+			return
+		}
+		if err := h.save(ctx, c); err != nil {
+			fmt.Printf("liveflux: dehydrate error: %v\n", err)
+			return
+		}
+		h.publishBroadcasts(ctx, c)
+		if sent, _ := h.sendRedirect(c); sent {
+			return
+		}
+		_ = h.sendUpdate(componentID, renderForClients(ctx, c))
+	}
+
+	// The broadcast ID lets a page with several connections handle it once
+	_ = h.sendEvents(componentID, msg.ID, events)
+	_ = h.sendQueuedEvents(c)
+}
+
+// sendUpdate sends the component's new HTML to all of its clients.
+func (h *WebSocketHandler) sendUpdate(componentID, html string) error {
+	data, err := json.Marshal(map[string]string{"html": html})
+	if err != nil {
+		return err
+	}
+	return h.sendVersioned(WebSocketMessage{Type: "update", ComponentID: componentID, Data: data})
+}
+
+// sendQueuedEvents sends the events c dispatched to its clients.
+func (h *WebSocketHandler) sendQueuedEvents(c ComponentInterface) error {
+	ea, ok := c.(EventAware)
+	if !ok || ea.GetEventDispatcher() == nil || !ea.GetEventDispatcher().HasEvents() {
+		return nil
+	}
+	return h.sendEvents(c.GetID(), "", ea.GetEventDispatcher().TakeEvents())
+}
+
+// sendEvents sends events to all clients of the component, which dispatch
+// them to their JavaScript listeners.
+func (h *WebSocketHandler) sendEvents(componentID, id string, events []Event) error {
+	data, err := json.Marshal(struct {
+		ID     string  `json:"id,omitempty"`
+		Events []Event `json:"events"`
+	}{ID: id, Events: events})
+	if err != nil {
+		return err
+	}
+	return h.sendVersioned(WebSocketMessage{Type: "events", ComponentID: componentID, Data: data})
+}
+
+// sendError sends an error message to the client. componentID names the
+// component the failed message addressed, if known.
+func (h *WebSocketHandler) sendError(conn clientConn, componentID, message string, code int) {
 	errMsg := struct {
-		Type    string `json:"type"`
-		Message string `json:"message"`
-		Code    int    `json:"code"`
+		Type        string `json:"type"`
+		ComponentID string `json:"componentID,omitempty"`
+		Message     string `json:"message"`
+		Code        int    `json:"code"`
 	}{
-		Type:    "error",
-		Message: message,
-		Code:    code,
+		Type:        "error",
+		ComponentID: componentID,
+		Message:     message,
+		Code:        code,
 	}
 
 	_ = conn.WriteJSON(errMsg)
 }
 
-func (h *WebSocketHandler) validateMessage(conn *websocket.Conn, msg *WebSocketMessage) bool {
+func (h *WebSocketHandler) validateMessage(conn *wsConn, msg *WebSocketMessage) bool {
 	if h.messageValidator == nil {
 		return true
 	}
 	if err := h.messageValidator(msg); err != nil {
-		h.sendError(conn, err.Error(), http.StatusBadRequest)
+		h.sendError(conn, msg.ComponentID, err.Error(), http.StatusBadRequest)
 		return false
 	}
 	return true
@@ -0,0 +1,264 @@
+package liveflux
+
+import (
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// ErrWebSocketQueueFull is returned when a message does not fit in a
+// connection's send queue.
+var ErrWebSocketQueueFull = errors.New("liveflux: websocket send queue full")
+
+// errWebSocketClosed is returned for writes to a closed connection.
+var errWebSocketClosed = errors.New("liveflux: websocket connection closed")
+
+// WebSocketBackpressure decides what happens to a message for a connection
+// whose send queue is full, i.e. a client that reads slower than the server
+// writes.
+type WebSocketBackpressure int
+
+const (
+	// WebSocketDropMessage drops the message and keeps the connection.
+	WebSocketDropMessage WebSocketBackpressure = iota
+	// WebSocketCloseConnection closes the connection. The client reconnects
+	// and joins its components again.
+	WebSocketCloseConnection
+)
+
+// WebSocketStats is a snapshot of a WebSocketHandler's connections and send
+// queues, for metrics.
+type WebSocketStats struct {
+	Connections     int    // open connections
+	Components      int    // components with at least one connection
+	QueuedMessages  int    // messages waiting in all send queues
+	MaxQueueDepth   int    // messages waiting in the fullest queue
+	QueueCapacity   int    // send queue size per connection
+	DroppedMessages uint64 // messages dropped on a full queue, since start
+	ClosedSlow      uint64 // connections closed on a full queue, since start
+}
+
+// wsCounters holds the handler's cumulative statistics.
+type wsCounters struct {
+	dropped    atomic.Uint64
+	closedSlow atomic.Uint64
+}
+
+// clientConn is a connection that receives messages for components: a
+// WebSocket or a Server-Sent Events stream.
+type clientConn interface {
+	WriteJSON(v any) error
+	// queued returns the number of messages waiting to be written.
+	queued() int
+}
+
+// Stats returns the current connection and send queue statistics.
+func (h *WebSocketHandler) Stats() WebSocketStats {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+
+	stats := WebSocketStats{
+		Connections:     len(h.conns),
+		Components:      len(h.clients),
+		QueueCapacity:   h.connOptions.sendQueueSize,
+		DroppedMessages: h.stats.dropped.Load(),
+		ClosedSlow:      h.stats.closedSlow.Load(),
+	}
+	for conn := range h.conns {
+		depth := conn.queued()
+		stats.QueuedMessages += depth
+		stats.MaxQueueDepth = max(stats.MaxQueueDepth, depth)
+	}
+	return stats
+}
+
+// sendQueue is the bounded queue of messages waiting for a connection's
+// writer. Writing to it never blocks.
+type sendQueue struct {
+	opts      websocketOptions
+	counters  *wsCounters
+	send      chan []byte
+	done      chan struct{} // closed once the connection is closed
+	closeOnce sync.Once
+	onClose   func() // closes the underlying connection
+}
+
+func newSendQueue(opts websocketOptions, counters *wsCounters, onClose func()) sendQueue {
+	return sendQueue{
+		opts:     opts,
+		counters: counters,
+		send:     make(chan []byte, opts.sendQueueSize),
+		done:     make(chan struct{}),
+		onClose:  onClose,
+	}
+}
+
+// WriteJSON queues v for the writer. It never blocks: when the queue is full
+// the backpressure policy applies and ErrWebSocketQueueFull is returned.
+func (q *sendQueue) WriteJSON(v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	select {
+	case <-q.done:
+		return errWebSocketClosed
+	default:
+	}
+	select {
+	case q.send <- data:
+		return nil
+	default:
+	}
+
+	if q.opts.backpressure == WebSocketCloseConnection {
+		q.counters.closedSlow.Add(1)
+		q.close()
+	} else {
+		q.counters.dropped.Add(1)
+	}
+	return ErrWebSocketQueueFull
+}
+
+func (q *sendQueue) queued() int { return len(q.send) }
+
+// writeDeadline returns the deadline for a write starting now.
+func (q *sendQueue) writeDeadline() time.Time {
+	if q.opts.writeTimeout <= 0 {
+		return time.Time{}
+	}
+	return time.Now().Add(q.opts.writeTimeout)
+}
+
+// pingTicker returns the channel to ping the peer on, and a function to
+// stop it. Without a ping interval the channel never fires.
+func (q *sendQueue) pingTicker() (<-chan time.Time, func()) {
+	if q.opts.pingInterval <= 0 {
+		return nil, func() {}
+	}
+	ticker := time.NewTicker(q.opts.pingInterval)
+	return ticker.C, ticker.Stop
+}
+
+// close stops the writer and closes the underlying connection without
+// flushing. Safe to call more than once and from any goroutine.
+func (q *sendQueue) close() {
+	q.closeOnce.Do(func() {
+		close(q.done)
+		if q.onClose != nil {
+			q.onClose()
+		}
+	})
+}
+
+// wsConn is a WebSocket connection shared by the goroutines handling its
+// components' messages. Messages are queued and written by one writer
+// goroutine, which also pings the peer, as the connection supports only one
+// concurrent writer.
+type wsConn struct {
+	*websocket.Conn
+	sendQueue
+	stop     chan struct{} // asks the writer to flush and close
+	stopOnce sync.Once
+}
+
+// openConn configures a freshly upgraded connection, starts its writer and
+// tracks it for Stats.
+func (h *WebSocketHandler) openConn(raw *websocket.Conn) *wsConn {
+	conn := &wsConn{
+		Conn:      raw,
+		sendQueue: newSendQueue(h.connOptions, &h.stats, func() { _ = raw.Close() }),
+		stop:      make(chan struct{}),
+	}
+	if conn.opts.readLimit > 0 {
+		raw.SetReadLimit(conn.opts.readLimit)
+	}
+	raw.SetPongHandler(func(string) error {
+		conn.extendReadDeadline()
+		return nil
+	})
+	conn.extendReadDeadline()
+
+	h.mu.Lock()
+	h.conns[conn] = true
+	h.mu.Unlock()
+
+	go conn.writeLoop()
+	return conn
+}
+
+// closeConn flushes and closes the connection and stops tracking it.
+func (h *WebSocketHandler) closeConn(conn *wsConn) {
+	conn.shutdown()
+	h.mu.Lock()
+	delete(h.conns, conn)
+	h.mu.Unlock()
+}
+
+// WriteJSON queues v for the writer (see sendQueue.WriteJSON).
+func (c *wsConn) WriteJSON(v any) error {
+	return c.sendQueue.WriteJSON(v)
+}
+
+// writeLoop writes queued messages and pings until the connection closes.
+// A failed write closes the connection, which ends the reader too.
+func (c *wsConn) writeLoop() {
+	defer c.close()
+
+	ping, stopPing := c.pingTicker()
+	defer stopPing()
+
+	for {
+		select {
+		case data := <-c.send:
+			_ = c.Conn.SetWriteDeadline(c.writeDeadline())
+			if err := c.Conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ping:
+			if err := c.Conn.WriteControl(websocket.PingMessage, nil, c.writeDeadline()); err != nil {
+				return
+			}
+		case <-c.stop:
+			c.flush()
+			return
+		case <-c.done:
+			return
+		}
+	}
+}
+
+// flush writes the messages still queued and a close frame.
+func (c *wsConn) flush() {
+	for {
+		select {
+		case data := <-c.send:
+			_ = c.Conn.SetWriteDeadline(c.writeDeadline())
+			if err := c.Conn.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		default:
+			msg := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "")
+			_ = c.Conn.WriteControl(websocket.CloseMessage, msg, c.writeDeadline())
+			return
+		}
+	}
+}
+
+// extendReadDeadline gives the peer another pong timeout to show it is alive.
+func (c *wsConn) extendReadDeadline() {
+	if c.opts.pongTimeout > 0 {
+		_ = c.Conn.SetReadDeadline(time.Now().Add(c.opts.pongTimeout))
+	}
+}
+
+// shutdown lets the writer flush the queue, then waits for the connection
+// to close.
+func (c *wsConn) shutdown() {
+	c.stopOnce.Do(func() { close(c.stop) })
+	<-c.done
+}
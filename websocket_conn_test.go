@@ -0,0 +1,184 @@
+package liveflux
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// wsPair returns the server side of a fresh WebSocket connection and the
+// client dialed to it.
+func wsPair(t *testing.T) (*websocket.Conn, *websocket.Conn) {
+	t.Helper()
+	accepted := make(chan *websocket.Conn, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, err := DefaultWebSocketUpgrader.Upgrade(w, r, nil)
+		if err != nil {
+			t.Errorf("upgrade: %v", err)
+			return
+		}
+		accepted <- raw
+	}))
+	t.Cleanup(ts.Close)
+
+	client, _, err := dialWS(t, ts.URL)
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	return <-accepted, client
+}
+
+// newQueueOnlyConn returns a wsConn without a writer, so its queue fills.
+func newQueueOnlyConn(raw *websocket.Conn, policy WebSocketBackpressure, counters *wsCounters) *wsConn {
+	opts := defaultWebSocketOptions()
+	opts.sendQueueSize = 1
+	opts.backpressure = policy
+	return &wsConn{
+		Conn:      raw,
+		sendQueue: newSendQueue(opts, counters, func() { _ = raw.Close() }),
+		stop:      make(chan struct{}),
+	}
+}
+
+func TestWebSocketConn_Backpressure(t *testing.T) {
+	var counters wsCounters
+
+	raw, _ := wsPair(t)
+	drop := newQueueOnlyConn(raw, WebSocketDropMessage, &counters)
+	if err := drop.WriteJSON("first"); err != nil {
+		t.Fatalf("WriteJSON: %v", err)
+	}
+	if err := drop.WriteJSON("second"); !errors.Is(err, ErrWebSocketQueueFull) {
+		t.Fatalf("expected ErrWebSocketQueueFull, got %v", err)
+	}
+	select {
+	case <-drop.done:
+		t.Fatal("expected the connection to stay open")
+	default:
+	}
+	if counters.dropped.Load() != 1 {
+		t.Fatalf("expected one dropped message, got %d", counters.dropped.Load())
+	}
+
+	raw, client := wsPair(t)
+	closing := newQueueOnlyConn(raw, WebSocketCloseConnection, &counters)
+	_ = closing.WriteJSON("first")
+	if err := closing.WriteJSON("second"); !errors.Is(err, ErrWebSocketQueueFull) {
+		t.Fatalf("expected ErrWebSocketQueueFull, got %v", err)
+	}
+	if err := closing.WriteJSON("third"); err == nil {
+		t.Fatal("expected writes to a closed connection to fail")
+	}
+	_ = client.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, _, err := client.ReadMessage(); err == nil {
+		t.Fatal("expected the slow connection to be closed")
+	}
+	if counters.closedSlow.Load() != 1 {
+		t.Fatalf("expected one closed connection, got %d", counters.closedSlow.Load())
+	}
+}
+
+func TestWebSocketHandler_PongTimeoutClosesDeadPeers(t *testing.T) {
+	store := NewMemoryStore()
+	h := NewWebSocketHandler(store,
+		WithWebSocketPingInterval(20*time.Millisecond),
+		WithWebSocketPongTimeout(150*time.Millisecond))
+	alive, dead := newGauge(store, false), newGauge(store, false)
+
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	// The live peer reads, which answers pings with pongs
+	liveConn, _, err := dialWS(t, ts.URL)
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer func() {
+		_ = liveConn.Close()
+	}()
+	_ = liveConn.WriteJSON(WebSocketMessage{Type: "init", ComponentID: alive.GetID(), Kind: alive.GetKind()})
+	go func() {
+		for {
+			if _, _, err := liveConn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	// The dead peer never reads, so never answers
+	deadConn, _, err := dialWS(t, ts.URL)
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer func() {
+		_ = deadConn.Close()
+	}()
+	_ = deadConn.WriteJSON(WebSocketMessage{Type: "init", ComponentID: dead.GetID(), Kind: dead.GetKind()})
+	waitForClient(t, h, alive.GetID())
+	waitForClient(t, h, dead.GetID())
+
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		h.mu.RLock()
+		deadGone, aliveKept := len(h.clients[dead.GetID()]) == 0, len(h.clients[alive.GetID()]) == 1
+		h.mu.RUnlock()
+		if deadGone {
+			if !aliveKept {
+				t.Fatal("expected the live peer to stay connected")
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the dead peer to be dropped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Outlast several pong timeouts
+	time.Sleep(400 * time.Millisecond)
+	stats := h.Stats()
+	if stats.Connections != 1 || stats.Components != 1 || stats.QueueCapacity != 256 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestWebSocketHandler_ReadLimit(t *testing.T) {
+	store := NewMemoryStore()
+	h := NewWebSocketHandler(store, WithWebSocketReadLimit(256))
+	gauge := newGauge(store, false)
+
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+	conn, _, err := dialWS(t, ts.URL)
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	_ = conn.WriteJSON(WebSocketMessage{Type: "init", ComponentID: gauge.GetID(), Kind: gauge.GetKind()})
+	waitForClient(t, h, gauge.GetID())
+	big := WebSocketMessage{Type: "action", ComponentID: gauge.GetID(), Data: []byte(`"` + strings.Repeat("x", 512) + `"`)}
+	_ = conn.WriteJSON(big)
+
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		if _, _, err := conn.ReadMessage(); err != nil {
+			var closeErr *websocket.CloseError
+			if errors.As(err, &closeErr) && closeErr.Code != websocket.CloseMessageTooBig {
+				t.Fatalf("expected close for a message too big, got %v", err)
+			}
+			break
+		}
+	}
+	if stats := h.Stats(); stats.Connections != 0 {
+		t.Fatalf("expected the connection to be closed, got %+v", stats)
+	}
+}
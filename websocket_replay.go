@@ -0,0 +1,139 @@
+package liveflux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// wsReplay keeps, per component, the recent update, events and redirect
+// messages sent to all of its clients, so a client that reconnects can catch up on what it
+// missed. Every such message carries the component's next version.
+type wsReplay struct {
+	mu        sync.Mutex
+	size      int
+	retention time.Duration
+	logs      map[string]*wsReplayLog // componentID -> log
+}
+
+// wsReplayLog is one component's version counter and its most recent
+// messages, oldest first.
+type wsReplayLog struct {
+	version  uint64
+	entries  []WebSocketMessage
+	lastUsed time.Time
+}
+
+func newWSReplay(size int, retention time.Duration) *wsReplay {
+	return &wsReplay{
+		size:      size,
+		retention: retention,
+		logs:      make(map[string]*wsReplayLog),
+	}
+}
+
+// logFor returns the component's log, creating it if needed. The caller
+// holds r.mu.
+func (r *wsReplay) logFor(componentID string, now time.Time) *wsReplayLog {
+	if l := r.logs[componentID]; l != nil {
+		l.lastUsed = now
+		return l
+	}
+
+	// Forget components nobody has used for a while
+	for id, l := range r.logs {
+		if now.Sub(l.lastUsed) > r.retention {
+			delete(r.logs, id)
+		}
+	}
+
+	// Versions start at the current time in microseconds, so they keep
+	// increasing when a log is recreated or a client reconnects to another
+	// instance, and stay exact as JavaScript numbers.
+	l := &wsReplayLog{version: uint64(now.UnixMicro()), lastUsed: now}
+	r.logs[componentID] = l
+	return l
+}
+
+// record assigns msg the component's next version and keeps it for replay.
+func (r *wsReplay) record(msg WebSocketMessage) WebSocketMessage {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l := r.logFor(msg.ComponentID, time.Now())
+	l.version++
+	msg.Version = l.version
+	if r.size > 0 {
+		if len(l.entries) == r.size {
+			copy(l.entries, l.entries[1:])
+			l.entries = l.entries[:r.size-1]
+		}
+		l.entries = append(l.entries, msg)
+	}
+	return msg
+}
+
+// since returns the messages after version and the current version. ok is
+// false when some of them are no longer kept, or version is unknown.
+func (r *wsReplay) since(componentID string, version uint64) (missed []WebSocketMessage, current uint64, ok bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	l := r.logFor(componentID, time.Now())
+	if version > l.version {
+		return nil, l.version, false
+	}
+	if version == l.version {
+		return nil, l.version, true
+	}
+	oldest := l.version - uint64(len(l.entries)) + 1
+	if len(l.entries) == 0 || version+1 < oldest {
+		return nil, l.version, false
+	}
+	missed = append(missed, l.entries[version+1-oldest:]...)
+	return missed, l.version, true
+}
+
+// current returns the component's current version.
+func (r *wsReplay) current(componentID string) uint64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.logFor(componentID, time.Now()).version
+}
+
+// sendVersioned sends msg to all of the component's clients with the next
+// version, keeping it for clients that reconnect. The caller holds the
+// component's lock, so versions reach clients in order.
+func (h *WebSocketHandler) sendVersioned(msg WebSocketMessage) error {
+	return h.Broadcast(msg.ComponentID, h.replay.record(msg))
+}
+
+// resume brings a reconnecting client up to date: it replays the messages
+// after the version the client last saw or, when they are no longer kept,
+// sends a full render marked "resync". The caller holds c's lock. Messages
+// sent live since conn joined may arrive twice; the client ignores versions
+// it has seen.
+func (h *WebSocketHandler) resume(ctx context.Context, conn clientConn, c ComponentInterface, version uint64) {
+	missed, current, ok := h.replay.since(c.GetID(), version)
+	if ok {
+		for _, msg := range missed {
+			if err := conn.WriteJSON(msg); err != nil {
+				return
+			}
+		}
+		return
+	}
+
+	if err := afterHydrate(ctx, c); err != nil {
+		fmt.Printf("liveflux: hydrate error: %v\n", err)
+		return
+	}
+	html := render(contextWithHeld(ctx, c), c).ToHTML()
+	data, err := json.Marshal(map[string]any{"html": html, "resync": true})
+	if err != nil {
+		return
+	}
+	_ = conn.WriteJSON(WebSocketMessage{Type: "update", ComponentID: c.GetID(), Version: current, Data: data})
+}
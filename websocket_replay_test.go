@@ -0,0 +1,193 @@
+package liveflux
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestWSReplay_Since(t *testing.T) {
+	r := newWSReplay(3, time.Minute)
+	var versions []uint64
+	for i := 0; i < 5; i++ {
+		versions = append(versions, r.record(WebSocketMessage{Type: "update", ComponentID: "c1"}).Version)
+	}
+	for i := 1; i < len(versions); i++ {
+		if versions[i] != versions[i-1]+1 {
+			t.Fatalf("expected consecutive versions, got %v", versions)
+		}
+	}
+
+	missed, current, ok := r.since("c1", versions[1])
+	if !ok || current != versions[4] || len(missed) != 3 || missed[0].Version != versions[2] {
+		t.Fatalf("expected the last three messages, got %v %d %v", ok, current, missed)
+	}
+	if missed, _, ok := r.since("c1", versions[4]); !ok || len(missed) != 0 {
+		t.Fatalf("expected nothing missed, got %v %v", ok, missed)
+	}
+	if _, _, ok := r.since("c1", versions[0]); ok {
+		t.Fatal("expected a gap larger than the buffer to need a full render")
+	}
+	if _, _, ok := r.since("c1", versions[4]+1); ok {
+		t.Fatal("expected an unknown version to need a full render")
+	}
+	if _, _, ok := r.since("c2", 42); ok {
+		t.Fatal("expected an unknown component to need a full render")
+	}
+}
+
+func TestWebSocketHandler_ResumeReplaysMissedUpdates(t *testing.T) {
+	store := NewMemoryStore()
+	h := NewWebSocketHandler(store, WithWebSocketReplay(2, time.Minute))
+	gauge := newGauge(store, false)
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	set := func(v int) {
+		t.Helper()
+		err := h.Push(context.Background(), gauge.GetID(), func(c ComponentInterface) error {
+			c.(*gaugeWSComp).Value = v
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Push: %v", err)
+		}
+	}
+
+	type update struct {
+		Version uint64         `json:"version"`
+		Data    map[string]any `json:"data"`
+	}
+	// connect joins with the given version and reads n updates.
+	connect := func(version uint64, n int) []update {
+		t.Helper()
+		conn, _, err := dialWS(t, ts.URL)
+		if err != nil {
+			t.Fatalf("dial error: %v", err)
+		}
+		defer func() {
+			_ = conn.Close()
+		}()
+		if err := conn.WriteJSON(WebSocketMessage{Type: "init", ComponentID: gauge.GetID(), Kind: gauge.GetKind(), Version: version}); err != nil {
+			t.Fatalf("write init: %v", err)
+		}
+		var got []update
+		for len(got) < n {
+			_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			var msg update
+			if err := conn.ReadJSON(&msg); err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			got = append(got, msg)
+		}
+		return got
+	}
+
+	// The version a client saw before going offline
+	set(1)
+	_, v1, _ := h.replay.since(gauge.GetID(), 0)
+
+	// Missed while offline, and still kept
+	set(2)
+	set(3)
+	got := connect(v1, 2)
+	if got[0].Version != v1+1 || got[1].Version != v1+2 {
+		t.Fatalf("expected versions %d and %d, got %+v", v1+1, v1+2, got)
+	}
+	if !strings.Contains(fmt.Sprint(got[0].Data["html"]), "value=2") || !strings.Contains(fmt.Sprint(got[1].Data["html"]), "value=3") {
+		t.Fatalf("expected the missed updates in order, got %+v", got)
+	}
+
+	// Missed more than the buffer keeps
+	set(4)
+	set(5)
+	set(6)
+	got = connect(v1+2, 1)
+	if got[0].Version != v1+5 || got[0].Data["resync"] != true || !strings.Contains(fmt.Sprint(got[0].Data["html"]), "value=6") {
+		t.Fatalf("expected a full render at the current version, got %+v", got)
+	}
+}
+
+func TestWebSocketHandler_ResumeReplaysActionResults(t *testing.T) {
+	store := NewMemoryStore()
+	h := NewWebSocketHandler(store)
+	comp := &wsFormComp{}
+	comp.SetKind(comp.GetKind())
+	comp.SetID(NewID())
+	store.Set(comp)
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+
+	type reply struct {
+		Type    string          `json:"type"`
+		Version uint64          `json:"version"`
+		Data    json.RawMessage `json:"data"`
+	}
+	join := func(version uint64) *websocket.Conn {
+		t.Helper()
+		conn, _, err := dialWS(t, ts.URL)
+		if err != nil {
+			t.Fatalf("dial error: %v", err)
+		}
+		t.Cleanup(func() { _ = conn.Close() })
+		if err := conn.WriteJSON(WebSocketMessage{Type: "init", ComponentID: comp.GetID(), Kind: comp.GetKind(), Version: version}); err != nil {
+			t.Fatalf("write init: %v", err)
+		}
+		return conn
+	}
+	read := func(conn *websocket.Conn) reply {
+		t.Helper()
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var r reply
+		if err := conn.ReadJSON(&r); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return r
+	}
+
+	sender, other := join(0), join(0)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		h.mu.RLock()
+		joined := len(h.clients[comp.GetID()])
+		h.mu.RUnlock()
+		if joined == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected two connections, got %d", joined)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	before := h.replay.current(comp.GetID())
+	if err := sender.WriteJSON(WebSocketMessage{Type: "action", ComponentID: comp.GetID(), Action: "add", Data: json.RawMessage(`{"by": 3}`)}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	// The sender and the component's other clients see the result
+	for _, conn := range []*websocket.Conn{sender, other} {
+		update, events := read(conn), read(conn)
+		if update.Type != "update" || update.Version != before+1 || !strings.Contains(string(update.Data), "count=3") {
+			t.Fatalf("expected a versioned update, got %+v %s", update, update.Data)
+		}
+		if events.Type != "events" || events.Version != before+2 || !strings.Contains(string(events.Data), `"added"`) {
+			t.Fatalf("expected versioned events, got %+v %s", events, events.Data)
+		}
+	}
+
+	// A client that dropped before the reply reached it catches up
+	resumed := join(before)
+	if update := read(resumed); update.Type != "update" || update.Version != before+1 || !strings.Contains(string(update.Data), "count=3") {
+		t.Fatalf("expected the action's update to be replayed, got %+v %s", update, update.Data)
+	}
+	if events := read(resumed); events.Type != "events" || events.Version != before+2 {
+		t.Fatalf("expected the action's events to be replayed, got %+v %s", events, events.Data)
+	}
+}
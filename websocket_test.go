@@ -3,15 +3,19 @@ package liveflux
 import (
 	"context"
 	"crypto/tls"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/dracory/hb"
 	"github.com/gorilla/websocket"
+	"github.com/spf13/cast"
 )
 
 // fakeWSComponent is a minimal component implementing WebSocketComponent
@@ -332,7 +336,7 @@ func TestWebSocketHandler_ActionFlow(t *testing.T) {
 	}()
 
 	// Send initial message with componentID and an action
-	init := WebSocketMessage{Type: "action", ComponentID: comp.GetID(), Action: "inc"}
+	init := WebSocketMessage{Type: "action", ComponentID: comp.GetID(), Kind: comp.GetKind(), Action: "inc"}
 	if err := conn.WriteJSON(init); err != nil {
 		t.Fatalf("write init: %v", err)
 	}
@@ -392,7 +396,7 @@ func (c *nonWSComponent) Mount(context.Context, map[string]string) error   { ret
 func (c *nonWSComponent) Handle(context.Context, string, url.Values) error { return nil }
 func (c *nonWSComponent) Render(context.Context) hb.TagInterface           { return hb.Div() }
 
-func TestWebSocketHandler_ComponentDoesNotSupportWS(t *testing.T) {
+func TestWebSocketHandler_ComponentWithoutHandleWS(t *testing.T) {
 	h := NewWebSocketHandler(nil)
 
 	comp := &nonWSComponent{}
@@ -411,20 +415,133 @@ func TestWebSocketHandler_ComponentDoesNotSupportWS(t *testing.T) {
 		_ = conn.Close()
 	}()
 
-	msg := WebSocketMessage{Type: "action", ComponentID: comp.GetID(), Action: "x"}
+	msg := WebSocketMessage{Type: "action", ComponentID: comp.GetID(), Kind: comp.GetKind(), Action: "x"}
 	if err := conn.WriteJSON(msg); err != nil {
 		t.Fatalf("write: %v", err)
 	}
 
+	// Actions run through Handle and Render instead of HandleWS
 	var resp struct {
-		Type, Message string
-		Code          int
+		Type string
+		Data struct{ HTML string }
 	}
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
 	if err := conn.ReadJSON(&resp); err != nil {
 		t.Fatalf("read: %v", err)
 	}
-	if resp.Type != "error" || resp.Code != 400 {
-		t.Fatalf("expected error 400, got %#v", resp)
+	if resp.Type != "update" || !strings.Contains(resp.Data.HTML, "<div") {
+		t.Fatalf("expected an update with the render, got %#v", resp)
+	}
+}
+
+// wsFormComp implements only Handle; the WebSocket transport runs it like
+// the HTTP handler.
+type wsFormComp struct {
+	Base
+	Count int
+	Name  string
+}
+
+func (c *wsFormComp) GetKind() string                                { return "test.ws-form" }
+func (c *wsFormComp) Mount(context.Context, map[string]string) error { return nil }
+func (c *wsFormComp) BindableFields() []string                       { return []string{"Name"} }
+func (c *wsFormComp) Handle(_ context.Context, action string, data url.Values) error {
+	switch action {
+	case "add":
+		c.Count += cast.ToInt(data.Get("by"))
+		c.Dispatch("added", map[string]any{"count": c.Count})
+	case "leave":
+		c.Redirect("/bye", 2)
+	case "check":
+		return ValidationErrors{{Field: "Name", Message: "is taken"}}
+	case "fail":
+		return errors.New("boom")
+	}
+	return nil
+}
+func (c *wsFormComp) Render(context.Context) hb.TagInterface {
+	return c.Root(hb.Text(fmt.Sprintf("count=%d name=%s error=%s", c.Count, c.Name, c.Errors().First("Name"))))
+}
+
+func TestWebSocketHandler_DefaultActionPath(t *testing.T) {
+	store := NewMemoryStore()
+	h := NewWebSocketHandler(store)
+
+	comp := &wsFormComp{}
+	comp.SetKind(comp.GetKind())
+	comp.SetID(NewID())
+	store.Set(comp)
+
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+	conn, _, err := dialWS(t, ts.URL)
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	type reply struct {
+		Type    string          `json:"type"`
+		Message string          `json:"message"`
+		Code    int             `json:"code"`
+		Data    json.RawMessage `json:"data"`
+	}
+	send := func(action string, data string) {
+		t.Helper()
+		msg := WebSocketMessage{Type: "action", ComponentID: comp.GetID(), Action: action, Data: json.RawMessage(data)}
+		if err := conn.WriteJSON(msg); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	read := func() reply {
+		t.Helper()
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		var r reply
+		if err := conn.ReadJSON(&r); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return r
+	}
+
+	// The init message only registers the connection
+	if err := conn.WriteJSON(WebSocketMessage{Type: "init", ComponentID: comp.GetID(), Kind: comp.GetKind()}); err != nil {
+		t.Fatalf("write init: %v", err)
+	}
+
+	send("add", `{"by": 2, "liveflux_model[Name]": "ann"}`)
+	r := read()
+	if r.Type != "update" || !strings.Contains(string(r.Data), "count=2 name=ann") {
+		t.Fatalf("expected an update with the action and model applied, got %+v %s", r, r.Data)
+	}
+	r = read()
+	if r.Type != "events" || !strings.Contains(string(r.Data), `"added"`) {
+		t.Fatalf("expected the dispatched event, got %+v %s", r, r.Data)
+	}
+	saved, _ := store.Get(comp.GetID())
+	if saved.(*wsFormComp).Count != 2 {
+		t.Fatalf("expected the component to be persisted")
+	}
+
+	send("check", `{}`)
+	if r = read(); r.Type != "update" || !strings.Contains(string(r.Data), "error=is taken") {
+		t.Fatalf("expected a re-render with the error bag, got %+v %s", r, r.Data)
+	}
+
+	send("leave", `{}`)
+	if r = read(); r.Type != "redirect" || string(r.Data) != `{"url":"/bye","after":2}` {
+		t.Fatalf("expected a redirect, got %+v %s", r, r.Data)
+	}
+
+	send("fail", `{}`)
+	if r = read(); r.Type != "error" || r.Code != http.StatusBadRequest || r.Message != "action error" {
+		t.Fatalf("expected an action error, got %+v", r)
+	}
+
+	send("add", `{"liveflux_model[Count]": "9"}`)
+	if r = read(); r.Type != "error" || r.Message != "field not bindable" {
+		t.Fatalf("expected a model error, got %+v", r)
 	}
 }
 
@@ -482,3 +599,130 @@ func TestWebSocketHandler_AllowedOriginsRejectMismatch(t *testing.T) {
 		t.Fatalf("expected missing origin to be rejected when allow list configured")
 	}
 }
+
+func TestWebSocketHandler_MultiplexesComponents(t *testing.T) {
+	store := NewMemoryStore()
+	h := NewWebSocketHandler(store)
+
+	var ids []string
+	for i := 0; i < 3; i++ {
+		comp := &wsFormComp{}
+		comp.SetKind(comp.GetKind())
+		comp.SetID(NewID())
+		store.Set(comp)
+		ids = append(ids, comp.GetID())
+	}
+
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+	conn, _, err := dialWS(t, ts.URL)
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	for _, id := range ids {
+		if err := conn.WriteJSON(WebSocketMessage{Type: "init", ComponentID: id, Kind: (&wsFormComp{}).GetKind()}); err != nil {
+			t.Fatalf("write init: %v", err)
+		}
+	}
+	for _, id := range ids {
+		waitForClient(t, h, id)
+	}
+
+	// Each action is answered for its own component over the shared socket
+	for i, id := range ids {
+		data := json.RawMessage(fmt.Sprintf(`{"by": %d}`, i+1))
+		if err := conn.WriteJSON(WebSocketMessage{Type: "action", ComponentID: id, Action: "add", Data: data}); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		for _, want := range []string{"update", "events"} {
+			_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+			var msg WebSocketMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				t.Fatalf("read: %v", err)
+			}
+			if msg.Type != want || msg.ComponentID != id {
+				t.Fatalf("expected %s for %s, got %s for %s", want, id, msg.Type, msg.ComponentID)
+			}
+			if want == "update" && !strings.Contains(string(msg.Data), fmt.Sprintf("count=%d", i+1)) {
+				t.Fatalf("unexpected render %s", msg.Data)
+			}
+		}
+	}
+
+	// Leaving unregisters only that component
+	if err := conn.WriteJSON(WebSocketMessage{Type: "leave", ComponentID: ids[1]}); err != nil {
+		t.Fatalf("write leave: %v", err)
+	}
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		h.mu.RLock()
+		left, others := len(h.clients[ids[1]]) == 0, len(h.clients[ids[0]]) == 1 && len(h.clients[ids[2]]) == 1
+		h.mu.RUnlock()
+		if left && others {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected only the left component to be unregistered")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	// Closing the socket unregisters the rest
+	_ = conn.Close()
+	deadline = time.Now().Add(2 * time.Second)
+	for {
+		h.mu.RLock()
+		remaining := len(h.clients)
+		h.mu.RUnlock()
+		if remaining == 0 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("expected no clients after close, got %d", remaining)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestWebSocketHandler_JoinChecksKind(t *testing.T) {
+	store := NewMemoryStore()
+	h := NewWebSocketHandler(store)
+	comp := &wsFormComp{}
+	comp.SetKind(comp.GetKind())
+	comp.SetID(NewID())
+	store.Set(comp)
+
+	ts := httptest.NewServer(h)
+	defer ts.Close()
+	conn, _, err := dialWS(t, ts.URL)
+	if err != nil {
+		t.Fatalf("dial error: %v", err)
+	}
+	defer func() {
+		_ = conn.Close()
+	}()
+
+	for _, kind := range []string{"other.kind", ""} {
+		if err := conn.WriteJSON(WebSocketMessage{Type: "init", ComponentID: comp.GetID(), Kind: kind}); err != nil {
+			t.Fatalf("write init: %v", err)
+		}
+		var r struct {
+			Type string `json:"type"`
+			Code int    `json:"code"`
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		if err := conn.ReadJSON(&r); err != nil || r.Type != "error" || r.Code != http.StatusBadRequest {
+			t.Fatalf("expected 400 for kind %q, got %+v %v", kind, r, err)
+		}
+	}
+	h.mu.RLock()
+	joined := len(h.clients[comp.GetID()])
+	h.mu.RUnlock()
+	if joined != 0 {
+		t.Fatalf("expected the connection not to join, got %d clients", joined)
+	}
+}